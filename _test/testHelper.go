@@ -1,8 +1,10 @@
 package _test
 
 import (
+	"encoding/json"
 	"math"
 	"os"
+	"strconv"
 
 	"github.com/gomodule/redigo/redis"
 	"github.com/mgironi/operation-fire-quasar/model"
@@ -22,7 +24,7 @@ func AreFloatsEquals(a float64, b float64) bool {
 // Cleans the satelite info environment variables
 func CleanSatelitesInfoEnvs() {
 	//clean envs
-	envs := []string{store.SATELITE_KENOBI_ENV, store.SATELITE_SKYWALKER_ENV, store.SATELITE_SATO_ENV}
+	envs := []string{store.SATELITE_KENOBI_ENV, store.SATELITE_SKYWALKER_ENV, store.SATELITE_SATO_ENV, store.SATELITES_EXTRA_ENV, store.REFERENCE_ORIGIN_ENV, store.SATELITES_KEYS_ENV}
 	for _, key := range envs {
 		os.Unsetenv(key)
 	}
@@ -35,3 +37,27 @@ func InitRedisMockConnection() *redigomock.Conn {
 	}
 	return conn
 }
+
+// Builds the redis HGETALL reply of a dataset hash
+func DatasetHashReply(dataset model.Dataset) []interface{} {
+	satellites, _ := json.Marshal(dataset.Satellites)
+	return []interface{}{
+		[]byte(store.DATASET_FIELD_KEY), []byte(dataset.Key),
+		[]byte(store.DATASET_FIELD_OPERATION), []byte(dataset.Operation),
+		[]byte(store.DATASET_FIELD_VERSION), []byte(strconv.FormatInt(dataset.Version, 10)),
+		[]byte(store.DATASET_FIELD_SATELLITES), satellites,
+	}
+}
+
+// Mocks the redis commands queued to save a dataset in its hash and in the message words index, see store.UpdateDataset
+// output: the mocked HSET command
+func MockDatasetWrite(conn *redigomock.Conn, dataset model.Dataset) (cmdHSET *redigomock.Cmd) {
+	satellites, _ := json.Marshal(dataset.Satellites)
+	conn.GenericCommand("SADD").Expect("QUEUED")
+	conn.GenericCommand("SREM").Expect("QUEUED")
+	return conn.Command("HSET", store.DATASET_HASH_KEY_PREFIX+dataset.Operation,
+		store.DATASET_FIELD_KEY, dataset.Key,
+		store.DATASET_FIELD_OPERATION, dataset.Operation,
+		store.DATASET_FIELD_VERSION, dataset.Version,
+		store.DATASET_FIELD_SATELLITES, satellites).Expect("QUEUED")
+}
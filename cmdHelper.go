@@ -4,17 +4,23 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/mgironi/operation-fire-quasar/support"
 )
 
 // Help example to passing distances as a program argument
 const HELP_PASING_DISTANCES_ARG_EXAMPLE = "-distances=100,200.65,-300.47"
 
 // Help message to passing distances as a program argument
-const HELP_PASING_DISTANCES_ARG = "Required ordered list of distances to each satelite Kenobi,Skywalker,Sato.\n\t\tPlease use keyword 'distances' with '=' and coma ',' as list separator values.\n\t\texample: cmd " + HELP_PASING_DISTANCES_ARG_EXAMPLE
+const HELP_PASING_DISTANCES_ARG = "Required ordered list of distances to each satelite Kenobi,Skywalker,Sato (followed by the additional satelites, if configured).\n\t\tPlease use keyword 'distances' with '=' and coma ',' as list separator values.\n\t\tLeave a value empty if the distance is missing, with only two distances the location is ambiguous and the candidates are displayed.\n\t\texample: cmd " + HELP_PASING_DISTANCES_ARG_EXAMPLE + " or cmd " + HELP_PASING_AMBIGUOUS_DISTANCES_ARG_EXAMPLE
+
+// Help example to passing distances with a missing one as a program argument
+const HELP_PASING_AMBIGUOUS_DISTANCES_ARG_EXAMPLE = "-distances=100,,-300.47"
 
 // Help example to passing messages as a program argument
 const HELP_PASING_MESSAGES_ARG_EXAMPLE = "-messages=this..the.complete.message,.is.the..message,.is...message"
@@ -22,6 +28,33 @@ const HELP_PASING_MESSAGES_ARG_EXAMPLE = "-messages=this..the.complete.message,.
 // Help message for passing messages as a program argument
 const HELP_PASING_MESSAGES_ARG = "Required list of messages transmited to each satelite Kenobi,Skywalker,Sato.\n\t\tPlease use keyword 'messages' with '=' and coma ',' as list separator values.\n\t\tAlso use '.' to word separator (don't use empty spaces just '.' instead)\n\t\texample: cmd " + HELP_PASING_MESSAGES_ARG_EXAMPLE
 
+// Help message for asking robust location calculation
+const HELP_ROBUST_ARG = "Optional. Calculates location in robust mode, identifying and excluding the satelites with inconsistent distances.\n\t\tIt's the same as -solver=robust."
+
+// Help message for asking best effort location calculation
+const HELP_BEST_EFFORT_ARG = "Optional. Calculates location in best effort mode, always displaying the closest fit location with its quality grade (exact, good, degraded or rejected).\n\t\tThe thresholds are configurable with env variables OFQ_SIGNIFICANCE, OFQ_RELATIVE_STD_DEV and OFQ_DEGRADED_RATIO."
+
+// Help message for asking message reconciliation by vote
+const HELP_VOTE_ARG = "Optional. Resolves the conflicting words between the satelites messages by majority vote, displaying the contested positions."
+
+// Help message for asking the message gaps suggestions
+const HELP_SUGGEST_ARG = "Optional. Suggests the words of the message gaps with the phrases dictionary of the file configured with env variable OFQ_PHRASES_FILE (a phrase per line).\n\t\tThe suggestions are displayed with its scores, and never merged into the message."
+
+// Help message for selecting the message gaps placeholder
+const HELP_GAP_PLACEHOLDER_ARG = "Optional. The text displayed in the message for the words that no satelite received, empty by default (configurable with env variable OFQ_GAP_PLACEHOLDER).\n\t\texample: cmd -gap-placeholder=[?]"
+
+// Help message for selecting the location solver
+const HELP_SOLVER_ARG = "Optional. The location calculation algorithm: auto (default, configurable with env variable OFQ_SOLVER), trilateration, leastsquares or robust.\n\t\texample: cmd -solver=leastsquares"
+
+// Help example to passing track fixes as a program argument
+const HELP_PASING_TRACK_ARG_EXAMPLE = "-track='0:-100,75;1:-98.5,74;2:-97,73.2' -predict=5"
+
+// Help message to passing track fixes as a program argument
+const HELP_PASING_TRACK_ARG = "Optional. Tracks the emitter over time, filtering the fixes with a constant velocity Kalman filter.\n\t\tPlease use keyword 'track' with '=', semicolon ';' as fixes separator and ':' between the fix timestamp (seconds) and its values.\n\t\tThe values are the fix position 'x,y' or the ordered list of distances to each satelite.\n\t\texample: cmd " + HELP_PASING_TRACK_ARG_EXAMPLE
+
+// Help message for asking the track predicted position
+const HELP_PREDICT_ARG = "Optional, with track. Predicts the emitter position at the given timestamp (seconds)."
+
 func AskForHelp() (askedForHelp bool) {
 	cmdArgs := os.Args
 	helpArgRegex := regexp.MustCompile(`(-h)|(help)`)
@@ -37,8 +70,25 @@ func AskForHelp() (askedForHelp bool) {
 			log.Print("\t\t" + HELP_PASING_DISTANCES_ARG + "\n")
 			log.Print("\n\t-messages\n")
 			log.Print("\t\t" + HELP_PASING_MESSAGES_ARG + "\n")
+			log.Print("\n\t-vote\n")
+			log.Print("\t\t" + HELP_VOTE_ARG + "\n")
+			log.Print("\n\t-suggest\n")
+			log.Print("\t\t" + HELP_SUGGEST_ARG + "\n")
+			log.Print("\n\t-gap-placeholder\n")
+			log.Print("\t\t" + HELP_GAP_PLACEHOLDER_ARG + "\n")
+			log.Print("\n\t-robust\n")
+			log.Print("\t\t" + HELP_ROBUST_ARG + "\n")
+			log.Print("\n\t-best-effort\n")
+			log.Print("\t\t" + HELP_BEST_EFFORT_ARG + "\n")
+			log.Print("\n\t-solver\n")
+			log.Print("\t\t" + HELP_SOLVER_ARG + "\n")
+			log.Print("\n\t-track\n")
+			log.Print("\t\t" + HELP_PASING_TRACK_ARG + "\n")
+			log.Print("\n\t-predict\n")
+			log.Print("\t\t" + HELP_PREDICT_ARG + "\n")
 			log.Print("\nexamples:\n")
 			log.Printf("\n\toperation-fire-quasar %s %s\n", HELP_PASING_DISTANCES_ARG_EXAMPLE, HELP_PASING_MESSAGES_ARG_EXAMPLE)
+			log.Printf("\n\toperation-fire-quasar %s\n", HELP_PASING_TRACK_ARG_EXAMPLE)
 			log.Println()
 			askedForHelp = true
 		}
@@ -60,6 +110,163 @@ func IsProfileServerArgPresent() (isPresent bool) {
 	return
 }
 
+// Searchs the command args to detect if the datasets migration profile is present
+func IsProfileMigrateArgPresent() (isPresent bool) {
+	migrateArgRegex := regexp.MustCompile(`^-profile=migrate$`)
+	for _, arg := range os.Args {
+		if migrateArgRegex.MatchString(arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// Searchs the command args to detect if robust location calculation is asked for
+func IsRobustArgPresent() (isPresent bool) {
+	robustArgRegex := regexp.MustCompile(`^-robust$`)
+	for _, arg := range os.Args {
+		if robustArgRegex.MatchString(arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// Searchs the command args to detect if message reconciliation by vote is asked for
+func IsVoteArgPresent() (isPresent bool) {
+	voteArgRegex := regexp.MustCompile(`^-vote$`)
+	for _, arg := range os.Args {
+		if voteArgRegex.MatchString(arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// Searchs the command args to detect if the message gaps suggestions are asked for
+func IsSuggestArgPresent() (isPresent bool) {
+	suggestArgRegex := regexp.MustCompile(`^-suggest$`)
+	for _, arg := range os.Args {
+		if suggestArgRegex.MatchString(arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// Searchs the command args to detect if best effort location calculation is asked for
+func IsBestEffortArgPresent() (isPresent bool) {
+	bestEffortArgRegex := regexp.MustCompile(`^-best-effort$`)
+	for _, arg := range os.Args {
+		if bestEffortArgRegex.MatchString(arg) {
+			return true
+		}
+	}
+	return false
+}
+
+// Searchs the command args to get the location solver name. The robust arg is an alias of the robust solver.
+// output: the solver name, empty if not present (the configured one is used)
+func GetSolverArg() (solverName string) {
+	solverArgRegex := regexp.MustCompile(`^-solver=`)
+	for _, arg := range os.Args {
+		if solverArgRegex.MatchString(arg) {
+			return arg[strings.Index(arg, "=")+1:]
+		}
+	}
+	if IsRobustArgPresent() {
+		return "robust"
+	}
+	return solverName
+}
+
+// Searchs the command args to get the message gaps placeholder.
+// output: the placeholder, the configured one if not present
+func GetGapPlaceholderArg() (placeholder string) {
+	gapPlaceholderArgRegex := regexp.MustCompile(`^-gap-placeholder=`)
+	for _, arg := range os.Args {
+		if gapPlaceholderArgRegex.MatchString(arg) {
+			return arg[strings.Index(arg, "=")+1:]
+		}
+	}
+	return support.GapPlaceholder()
+}
+
+// Searchs the command args to get the track fixes argument
+// output: the track argument and true if it's present
+func GetTrackArg() (trackArg string, isPresent bool) {
+	trackArgRegex := regexp.MustCompile(`^-track=`)
+	for _, arg := range os.Args {
+		if trackArgRegex.MatchString(arg) {
+			return arg, true
+		}
+	}
+	return trackArg, false
+}
+
+// Searchs the command args to get the track prediction timestamp
+// output: the prediction timestamp and true if it's present
+// error: if the timestamp can't be parsed
+func GetPredictArg() (timestamp float64, isPresent bool, err error) {
+	predictArgRegex := regexp.MustCompile(`^-predict=`)
+	for _, arg := range os.Args {
+		if predictArgRegex.MatchString(arg) {
+			timestamp, err = strconv.ParseFloat(arg[strings.Index(arg, "=")+1:], 64)
+			if err != nil {
+				return timestamp, true, fmt.Errorf("error parsing predict timestamp '%s'. %s", arg, err.Error())
+			}
+			return timestamp, true, nil
+		}
+	}
+	return timestamp, false, nil
+}
+
+// Parse track fixes from arg string
+// input: the string argument, with format '<timestamp>:<values>;<timestamp>:<values>...'
+// output: the fixes timestamps and the values of each one (the position 'x,y' or the distances to each satelite)
+// erorr1: if error parsing fixes list is detected
+// erorr2: if error parsing floats values is detected
+func ParseTrackFixes(arg string) (timestamps []float64, values [][]float32, err error) {
+	//gets separator '=' idx
+	separatorIdx := strings.Index(arg, "=")
+	if separatorIdx < 0 {
+		return timestamps, values, errors.New("error parsing track, no values detected.\n\t\t" + HELP_PASING_TRACK_ARG)
+	}
+
+	// split fixes list strings
+	fixes := strings.Split(strings.Trim(arg[separatorIdx+1:], "'\""), ";")
+	timestamps = make([]float64, len(fixes))
+	values = make([][]float32, len(fixes))
+	for i, fix := range fixes {
+		fixParts := strings.Split(fix, ":")
+		if len(fixParts) != 2 {
+			return timestamps, values, fmt.Errorf("error parsing track fix '%s', use format '<timestamp>:<values>'", fix)
+		}
+
+		// parse timestamp
+		var parseErr error
+		timestamps[i], parseErr = strconv.ParseFloat(strings.TrimSpace(fixParts[0]), 64)
+		if parseErr != nil {
+			return timestamps, values, fmt.Errorf("error parsing track fix '%s' timestamp. %s", fix, parseErr.Error())
+		}
+
+		// parse values
+		fixValues := strings.Split(fixParts[1], ",")
+		if len(fixValues) < 2 {
+			return timestamps, values, fmt.Errorf("error parsing track fix '%s', values count: %d. Need 2 at least", fix, len(fixValues))
+		}
+		values[i] = make([]float32, len(fixValues))
+		for j, value := range fixValues {
+			valueFloat, parseErr := strconv.ParseFloat(strings.TrimSpace(value), 32)
+			if parseErr != nil {
+				return timestamps, values, fmt.Errorf("error parsing track fix '%s' values. %s", fix, parseErr.Error())
+			}
+			values[i][j] = float32(valueFloat)
+		}
+	}
+	return timestamps, values, nil
+}
+
 // Parses command args to get distances and messages list
 func ParseArgs() (distances []float32, messages [][]string, err error) {
 	cmdArgs := os.Args
@@ -132,6 +339,7 @@ func ParseMessages(arg string) (messages [][]string, err error) {
 }
 
 // Parse distances from arg string
+// An empty value means the distance to that satelite is missing, and is parsed as NaN (see GetAvailableDistances).
 // input: the string argument
 // output: the distances list in float32
 // erorr1: if error parsing list is detected
@@ -153,6 +361,12 @@ func ParseDistances(arg string) (distances []float32, err error) {
 
 		// parse values
 		for i, value := range values {
+			// missing distance
+			if strings.TrimSpace(value) == "" {
+				distances[i] = float32(math.NaN())
+				continue
+			}
+
 			// parse value to float
 			valueFloat, parseErr := strconv.ParseFloat(value, 32)
 			if parseErr != nil {
@@ -164,3 +378,16 @@ func ParseDistances(arg string) (distances []float32, err error) {
 	}
 	return distances, nil
 }
+
+// Gets the distances that aren't missing (NaN) from the parsed distances list.
+// input: the parsed distances list
+// output: the indexes (satelites order) and the values of the available distances
+func GetAvailableDistances(distances []float32) (indexes []int, available []float32) {
+	for i, distance := range distances {
+		if !math.IsNaN(float64(distance)) {
+			indexes = append(indexes, i)
+			available = append(available, distance)
+		}
+	}
+	return indexes, available
+}
@@ -75,3 +75,84 @@ func TestIsProfileServerArgPresent(t *testing.T) {
 		t.Errorf("Test IsProfileServerArgPresent() wiout presence result error, got %t wanted %t", got, wanted)
 	}
 }
+
+func TestIsProfileMigrateArgPresent(t *testing.T) {
+	oldsArgs := os.Args
+	os.Args = []string{"cmd", "-profile=migrate"}
+	if got := IsProfileMigrateArgPresent(); !got {
+		t.Errorf("Test IsProfileMigrateArgPresent() with presence result error, got %t wanted %t", got, true)
+	}
+
+	os.Args = []string{"cmd", "-profile=server"}
+	if got := IsProfileMigrateArgPresent(); got {
+		t.Errorf("Test IsProfileMigrateArgPresent() without presence result error, got %t wanted %t", got, false)
+	}
+	os.Args = oldsArgs
+}
+
+func TestIsRobustArgPresent(t *testing.T) {
+	oldsArgs := os.Args
+	os.Args = []string{"cmd", "-distances=500,424.26,707.10", "-robust"}
+	if got := IsRobustArgPresent(); !got {
+		t.Errorf("Test IsRobustArgPresent() with presence result error, got %t wanted %t", got, true)
+	}
+
+	os.Args = []string{"cmd", "-distances=500,424.26,707.10"}
+	if got := IsRobustArgPresent(); got {
+		t.Errorf("Test IsRobustArgPresent() without presence result error, got %t wanted %t", got, false)
+	}
+	os.Args = oldsArgs
+}
+
+func TestGetSolverArg(t *testing.T) {
+	oldsArgs := os.Args
+	defer func() { os.Args = oldsArgs }()
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{args: []string{"cmd", "-distances=500,424.26,707.10", "-solver=leastsquares"}, want: "leastsquares"},
+		{args: []string{"cmd", "-distances=500,424.26,707.10", "-robust"}, want: "robust"},
+		{args: []string{"cmd", "-distances=500,424.26,707.10"}, want: ""},
+	}
+	for _, tt := range tests {
+		os.Args = tt.args
+		if got := GetSolverArg(); got != tt.want {
+			t.Errorf("Test GetSolverArg() with args %v, got '%s' wanted '%s'", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestParseDistancesWithMissingValue(t *testing.T) {
+	distances, err := ParseDistances("-distances=500,,707.10")
+	if err != nil {
+		t.Fatalf("Error parsing distances %e", err)
+	}
+	indexes, available := GetAvailableDistances(distances)
+	if !reflect.DeepEqual(indexes, []int{0, 2}) {
+		t.Errorf("Test GetAvailableDistances() indexes are %v, wanted %v", indexes, []int{0, 2})
+	}
+	if !reflect.DeepEqual(available, []float32{500, 707.10}) {
+		t.Errorf("Test GetAvailableDistances() distances are %v, wanted %v", available, []float32{500, 707.10})
+	}
+}
+
+func TestParseTrackFixes(t *testing.T) {
+	timestamps, values, err := ParseTrackFixes("-track=0:-100,75;1.5:500,424.26,707.10")
+	if err != nil {
+		t.Fatalf("Error parsing track fixes %e", err)
+	}
+	if !reflect.DeepEqual(timestamps, []float64{0, 1.5}) {
+		t.Errorf("Test ParseTrackFixes() timestamps are %v, wanted %v", timestamps, []float64{0, 1.5})
+	}
+	if !reflect.DeepEqual(values, [][]float32{{-100, 75}, {500, 424.26, 707.10}}) {
+		t.Errorf("Test ParseTrackFixes() values are %v, wanted %v", values, [][]float32{{-100, 75}, {500, 424.26, 707.10}})
+	}
+
+	for _, arg := range []string{"-track=0", "-track=a:1,2", "-track=0:1", "-track=0:1,b"} {
+		if _, _, err := ParseTrackFixes(arg); err == nil {
+			t.Errorf("Test ParseTrackFixes(%s) wanted error", arg)
+		}
+	}
+}
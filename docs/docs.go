@@ -62,6 +62,96 @@ var doc = `{
                         "schema": {
                             "$ref": "#/definitions/model.TopSecretRequest"
                         }
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Incluye la estimacion de precision de la ubicacion (residuos, elipse de error y GDOP)",
+                        "name": "accuracy",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Calcula la ubicacion en modo robusto, excluyendo los satelites con distancias inconsistentes (equivale a solver=robust)",
+                        "name": "robust",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Algoritmo de calculo de la ubicacion: auto (por defecto, configurable con OFQ_SOLVER), trilateration, leastsquares o robust",
+                        "name": "solver",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Incluye el detalle de cada palabra del mensaje: satelites que la recibieron, coincidencias, confianza y posiciones sin recibir",
+                        "name": "detail",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Normalizaciones al comparar las palabras de los mensajes, separadas por coma: case (mayusculas), nfc (Unicode) y punctuation (signos de puntuacion). Configurable con OFQ_MESSAGE_NORMALIZATION",
+                        "name": "normalize",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Similitud minima (0 a 100, por distancia de edicion) para considerar iguales dos palabras, por defecto 100 (exactas). Configurable con OFQ_MESSAGE_MIN_RATIO",
+                        "name": "minRatio",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Texto con el que se muestran en el mensaje las palabras que ningun satelite recibio, por ejemplo [?]. Por defecto vacio, configurable con OFQ_GAP_PLACEHOLDER",
+                        "name": "gapPlaceholder",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Sugiere palabras para los huecos del mensaje segun el diccionario de frases configurado con OFQ_PHRASES_FILE. Las sugerencias no se agregan al mensaje",
+                        "name": "suggest",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Resuelve las palabras en conflicto entre satelites por mayoria de votos (ponderados por la confiabilidad de cada satelite), informando las posiciones en disputa",
+                        "name": "vote",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Devuelve siempre la ubicacion que mejor ajusta las distancias, con su calificacion (exact, good, degraded o rejected) en lugar de 404",
+                        "name": "bestEffort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Nivel de significancia del test chi-cuadrado de los residuos (por defecto 0.001, configurable con OFQ_SIGNIFICANCE)",
+                        "name": "significance",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Desvio estandar de las distancias sin ruido conocido, relativo a la distancia (por defecto 0.001, configurable con OFQ_RELATIVE_STD_DEV)",
+                        "name": "relativeStdDev",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Error cuadratico medio relativo maximo de una ubicacion degradada en modo best effort (por defecto 0.05, configurable con OFQ_DEGRADED_RATIO)",
+                        "name": "degradedRatio",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sistema de referencia de la ubicacion: cartesian (por defecto), enu o geodetic (latitud, longitud y altitud WGS84)",
+                        "name": "frame",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Origen del sistema enu como '\u003clatitud\u003e,\u003clongitud\u003e[,\u003caltitud\u003e]', por defecto el origen de referencia (OFQ_ORIGIN)",
+                        "name": "origin",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -77,11 +167,29 @@ var doc = `{
                             "$ref": "#/definitions/model.ErrorResponse"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/model.ErrorResponse"
                         }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
                     }
                 }
             }
@@ -100,6 +208,102 @@ var doc = `{
                         "name": "operation",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Incluye la estimacion de precision de la ubicacion (residuos, elipse de error y GDOP)",
+                        "name": "accuracy",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Calcula la ubicacion en modo robusto, excluyendo los satelites con distancias inconsistentes (equivale a solver=robust)",
+                        "name": "robust",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Algoritmo de calculo de la ubicacion: auto (por defecto, configurable con OFQ_SOLVER), trilateration, leastsquares o robust",
+                        "name": "solver",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Incluye el detalle de cada palabra del mensaje: satelites que la recibieron, coincidencias, confianza y posiciones sin recibir",
+                        "name": "detail",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Normalizaciones al comparar las palabras de los mensajes, separadas por coma: case (mayusculas), nfc (Unicode) y punctuation (signos de puntuacion). Configurable con OFQ_MESSAGE_NORMALIZATION",
+                        "name": "normalize",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Similitud minima (0 a 100, por distancia de edicion) para considerar iguales dos palabras, por defecto 100 (exactas). Configurable con OFQ_MESSAGE_MIN_RATIO",
+                        "name": "minRatio",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Texto con el que se muestran en el mensaje las palabras que ningun satelite recibio, por ejemplo [?]. Por defecto vacio, configurable con OFQ_GAP_PLACEHOLDER",
+                        "name": "gapPlaceholder",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Sugiere palabras para los huecos del mensaje segun el diccionario de frases configurado con OFQ_PHRASES_FILE. Las sugerencias no se agregan al mensaje",
+                        "name": "suggest",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Resuelve las palabras en conflicto entre satelites por mayoria de votos (ponderados por la confiabilidad de cada satelite), informando las posiciones en disputa",
+                        "name": "vote",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Devuelve siempre la ubicacion que mejor ajusta las distancias, con su calificacion (exact, good, degraded o rejected) en lugar de 404",
+                        "name": "bestEffort",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Nivel de significancia del test chi-cuadrado de los residuos (por defecto 0.001, configurable con OFQ_SIGNIFICANCE)",
+                        "name": "significance",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Desvio estandar de las distancias sin ruido conocido, relativo a la distancia (por defecto 0.001, configurable con OFQ_RELATIVE_STD_DEV)",
+                        "name": "relativeStdDev",
+                        "in": "query"
+                    },
+                    {
+                        "type": "number",
+                        "description": "Error cuadratico medio relativo maximo de una ubicacion degradada en modo best effort (por defecto 0.05, configurable con OFQ_DEGRADED_RATIO)",
+                        "name": "degradedRatio",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sistema de referencia de la ubicacion: cartesian (por defecto), enu o geodetic (latitud, longitud y altitud WGS84)",
+                        "name": "frame",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Origen del sistema enu como '\u003clatitud\u003e,\u003clongitud\u003e[,\u003caltitud\u003e]', por defecto el origen de referencia (OFQ_ORIGIN)",
+                        "name": "origin",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Con el set de datos incompleto (solo dos satelites), devuelve las ubicaciones candidatas marcando el resultado como ambiguo",
+                        "name": "ambiguous",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -141,6 +345,24 @@ var doc = `{
                         "schema": {
                             "$ref": "#/definitions/model.TopSecretSplitRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Normalizaciones al comparar las palabras de los mensajes, separadas por coma: case (mayusculas), nfc (Unicode) y punctuation (signos de puntuacion). Configurable con OFQ_MESSAGE_NORMALIZATION",
+                        "name": "normalize",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Similitud minima (0 a 100, por distancia de edicion) para considerar iguales dos palabras, por defecto 100 (exactas). Configurable con OFQ_MESSAGE_MIN_RATIO",
+                        "name": "minRatio",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Resuelve las palabras en conflicto entre satelites por mayoria de votos (ponderados por la confiabilidad de cada satelite), informando las posiciones en disputa",
+                        "name": "vote",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -156,12 +378,24 @@ var doc = `{
                             "$ref": "#/definitions/model.ErrorResponse"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/model.ErrorResponse"
                         }
                     },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -170,108 +404,836 @@ var doc = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "model.CoordinatesResponse": {
-            "type": "object",
-            "properties": {
-                "x": {
-                    "type": "number"
-                },
-                "y": {
-                    "type": "number"
-                }
-            }
-        },
-        "model.ErrorResponse": {
-            "type": "object",
-            "properties": {
-                "error": {
-                    "type": "string",
-                    "example": "this is an error message description"
-                }
-            }
         },
-        "model.SatelliteInfoRequest": {
-            "type": "object",
-            "properties": {
-                "distance": {
-                    "type": "number",
-                    "example": 100.23
-                },
-                "message": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
+        "/topsecret_tdoa/": {
+            "post": {
+                "description": "Basado en el instante de recepcion de la señal en cada satelite (diferencia de tiempos de llegada, TDOA) y los mensajes recibidos, se obtienen la posicion y el mensaje emitido Con 5 o mas satelites fuera de un mismo plano la posicion se calcula en el espacio (incluye z). Con 3 satelites la señal puede provenir de dos posiciones: la respuesta es ambigua e informa ambos candidatos. Los reportes de tiempos no se firman ni tienen nonce, por lo que solo se aceptan si se permiten los reportes reenviables sin firma (OFQ_ALLOW_UNSIGNED_REPLAYS), no se requieren firmas (OFQ_REQUIRE_SIGNATURES) y ningun satelite informado tiene clave.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene la ubicacion de la nave y el mensaje que emite, a partir de los tiempos de llegada de la señal.",
+                "parameters": [
+                    {
+                        "description": "Los tiempos de llegada y mensajes recibidos por los satelites, y opcionalmente la velocidad de propagacion",
+                        "name": "Body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.TopSecretTDOARequest"
+                        }
                     },
-                    "example": [
-                        "",
-                        "is",
-                        "a",
-                        "",
-                        "message"
-                    ]
-                },
-                "name": {
-                    "type": "string",
-                    "example": "kenobi"
-                }
-            }
-        },
-        "model.TopSecretRequest": {
-            "type": "object",
-            "properties": {
-                "satellites": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/model.SatelliteInfoRequest"
+                    {
+                        "type": "boolean",
+                        "description": "Incluye el detalle de cada palabra del mensaje: satelites que la recibieron, coincidencias, confianza y posiciones sin recibir",
+                        "name": "detail",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Normalizaciones al comparar las palabras de los mensajes, separadas por coma: case (mayusculas), nfc (Unicode) y punctuation (signos de puntuacion). Configurable con OFQ_MESSAGE_NORMALIZATION",
+                        "name": "normalize",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Similitud minima (0 a 100, por distancia de edicion) para considerar iguales dos palabras, por defecto 100 (exactas). Configurable con OFQ_MESSAGE_MIN_RATIO",
+                        "name": "minRatio",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Texto con el que se muestran en el mensaje las palabras que ningun satelite recibio, por ejemplo [?]. Por defecto vacio, configurable con OFQ_GAP_PLACEHOLDER",
+                        "name": "gapPlaceholder",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Sugiere palabras para los huecos del mensaje segun el diccionario de frases configurado con OFQ_PHRASES_FILE. Las sugerencias no se agregan al mensaje",
+                        "name": "suggest",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Resuelve las palabras en conflicto entre satelites por mayoria de votos (ponderados por la confiabilidad de cada satelite), informando las posiciones en disputa",
+                        "name": "vote",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sistema de referencia de la ubicacion: cartesian (por defecto), enu o geodetic (latitud, longitud y altitud WGS84)",
+                        "name": "frame",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Origen del sistema enu como '\u003clatitud\u003e,\u003clongitud\u003e[,\u003caltitud\u003e]', por defecto el origen de referencia (OFQ_ORIGIN)",
+                        "name": "origin",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.TopSecretResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
                     }
                 }
             }
         },
-        "model.TopSecretResponse": {
-            "type": "object",
-            "properties": {
-                "message": {
-                    "type": "string"
-                },
-                "position": {
-                    "$ref": "#/definitions/model.CoordinatesResponse"
-                }
-            }
-        },
-        "model.TopSecretSplitPOSTResponse": {
-            "type": "object",
-            "properties": {
-                "operation": {
-                    "type": "string"
-                }
-            }
-        },
-        "model.TopSecretSplitRequest": {
-            "type": "object",
-            "properties": {
-                "distance": {
-                    "type": "number",
-                    "example": 100.23
-                },
-                "message": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
+        "/tracks/{track}": {
+            "get": {
+                "description": "Obtiene la posicion y velocidad estimadas (en el plano) del emisor al ultimo fix, y opcionalmente la posicion predicha para un instante posterior. Responde 404 si el seguimiento no existe o fue eliminado por inactividad.",
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Obtiene el estado del seguimiento de un emisor.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Identificador del seguimiento (emisor)",
+                        "name": "track",
+                        "in": "path",
+                        "required": true
                     },
-                    "example": [
-                        "",
-                        "is",
-                        "a",
-                        "",
+                    {
+                        "type": "number",
+                        "description": "Instante (segundos) para el cual se predice la posicion",
+                        "name": "at",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.TrackResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Agrega una serie temporal de fixes (posiciones o distancias a los satelites) al seguimiento del emisor, filtrados con un filtro de Kalman de velocidad constante en el plano (la altura Z de los fixes se ignora). Responde la posicion y velocidad estimadas. Los fixes se aplican todos o ninguno, si alguno es invalido o el instante de prediccion es invalido o anterior al ultimo fix el seguimiento no se modifica. Los seguimientos sin fixes durante OFQ_TRACKING_TTL segundos se eliminan.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Agrega fixes de posicion al seguimiento de un emisor.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Identificador del seguimiento (emisor)",
+                        "name": "track",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Los fixes ordenados por tiempo, cada uno con la posicion o las distancias a los satelites",
+                        "name": "Body",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/model.TrackRequest"
+                        }
+                    },
+                    {
+                        "type": "number",
+                        "description": "Instante (segundos) para el cual se predice la posicion",
+                        "name": "at",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.TrackResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "produces": [
+                    "application/json"
+                ],
+                "summary": "Elimina el seguimiento de un emisor.",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Identificador del seguimiento (emisor)",
+                        "name": "track",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": ""
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/model.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "model.AccuracyResponse": {
+            "type": "object",
+            "properties": {
+                "covariance": {
+                    "description": "the position covariance matrix, for example [[0.25,0.01],[0.01,0.16]]. Nested arrays examples aren't supported by swag",
+                    "type": "array",
+                    "items": {
+                        "type": "array",
+                        "items": {
+                            "type": "number"
+                        }
+                    }
+                },
+                "errorEllipse": {
+                    "$ref": "#/definitions/model.ErrorEllipseResponse"
+                },
+                "gdop": {
+                    "type": "number",
+                    "example": 1.42
+                },
+                "residuals": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.SatelliteResidualResponse"
+                    }
+                }
+            }
+        },
+        "model.ContestedWordResponse": {
+            "type": "object",
+            "properties": {
+                "alternatives": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.WordVotesResponse"
+                    }
+                },
+                "chosen": {
+                    "$ref": "#/definitions/model.WordVotesResponse"
+                },
+                "position": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "model.CoordinatesRequest": {
+            "type": "object",
+            "properties": {
+                "x": {
+                    "type": "number",
+                    "example": -100.5
+                },
+                "y": {
+                    "type": "number",
+                    "example": 75.2
+                }
+            }
+        },
+        "model.CoordinatesResponse": {
+            "type": "object",
+            "properties": {
+                "geodetic": {
+                    "description": "only present if the geodetic frame was asked for",
+                    "$ref": "#/definitions/model.GeodeticResponse"
+                },
+                "x": {
+                    "type": "number"
+                },
+                "y": {
+                    "type": "number"
+                },
+                "z": {
+                    "description": "only present if the location was calculated in the space (altitude-aware satellites)",
+                    "type": "number",
+                    "example": 1500.5
+                }
+            }
+        },
+        "model.ErrorEllipseResponse": {
+            "type": "object",
+            "properties": {
+                "orientation": {
+                    "type": "number",
+                    "example": 12.5
+                },
+                "semiMajorAxis": {
+                    "type": "number",
+                    "example": 0.5
+                },
+                "semiMinorAxis": {
+                    "type": "number",
+                    "example": 0.4
+                }
+            }
+        },
+        "model.ErrorResponse": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string",
+                    "example": "this is an error message description"
+                }
+            }
+        },
+        "model.GapSuggestionsResponse": {
+            "type": "object",
+            "properties": {
+                "candidates": {
+                    "description": "the suggested words, the best scored first",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.SuggestionResponse"
+                    }
+                },
+                "position": {
+                    "description": "the gap position in the message",
+                    "type": "integer",
+                    "example": 2
+                }
+            }
+        },
+        "model.GeodeticResponse": {
+            "type": "object",
+            "properties": {
+                "altitude": {
+                    "type": "number",
+                    "example": 25.5
+                },
+                "latitude": {
+                    "type": "number",
+                    "example": -34.6037
+                },
+                "longitude": {
+                    "type": "number",
+                    "example": -58.3816
+                }
+            }
+        },
+        "model.PredictedPositionResponse": {
+            "type": "object",
+            "properties": {
+                "position": {
+                    "$ref": "#/definitions/model.CoordinatesResponse"
+                },
+                "timestamp": {
+                    "type": "number",
+                    "example": 20
+                }
+            }
+        },
+        "model.QualityResponse": {
+            "type": "object",
+            "properties": {
+                "chiSquare": {
+                    "type": "number",
+                    "example": 1.27
+                },
+                "grade": {
+                    "description": "exact, good, degraded or rejected",
+                    "type": "string",
+                    "example": "good"
+                },
+                "pValue": {
+                    "type": "number",
+                    "example": 0.26
+                },
+                "relativeRMS": {
+                    "type": "number",
+                    "example": 0.0004
+                }
+            }
+        },
+        "model.SatelliteInfoRequest": {
+            "type": "object",
+            "properties": {
+                "distance": {
+                    "type": "number",
+                    "example": 100.23
+                },
+                "message": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "",
+                        "is",
+                        "a",
+                        "",
                         "message"
                     ]
                 },
                 "name": {
                     "type": "string",
                     "example": "kenobi"
+                },
+                "nonce": {
+                    "description": "unique report identifier, signed with the report data. Required, unless the unsigned replays are allowed\n(OFQ_ALLOW_UNSIGNED_REPLAYS) and the satellite hasn't verification key",
+                    "type": "string",
+                    "example": "3f2a9c"
+                },
+                "reliability": {
+                    "description": "satellite reliability, its vote weight when the message words are reconciled by vote. Optional, 1 by default",
+                    "type": "number",
+                    "example": 0.8
+                },
+                "signature": {
+                    "description": "base64 signature of the report, required for the satellites with verification key. See security.SignedPayload",
+                    "type": "string",
+                    "example": "dGhpcyBpcyBhIHNpZ25hdHVyZQ=="
+                },
+                "snr": {
+                    "description": "signal to noise ratio (dB), optional. Used to estimate the distance standard deviation when it's absent",
+                    "type": "number",
+                    "example": 20
+                },
+                "stdDev": {
+                    "description": "distance standard deviation (coordinates units), optional",
+                    "type": "number",
+                    "example": 0.5
+                },
+                "timestamp": {
+                    "description": "report time (unix seconds), signed with the report data. Required, unless the unsigned replays are allowed\n(OFQ_ALLOW_UNSIGNED_REPLAYS) and the satellite hasn't verification key",
+                    "type": "integer",
+                    "example": 1700000000
+                }
+            }
+        },
+        "model.SatelliteResidualResponse": {
+            "type": "object",
+            "properties": {
+                "name": {
+                    "type": "string",
+                    "example": "kenobi"
+                },
+                "residual": {
+                    "type": "number",
+                    "example": -0.0032
+                }
+            }
+        },
+        "model.SatelliteTimingRequest": {
+            "type": "object",
+            "properties": {
+                "message": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "",
+                        "is",
+                        "a",
+                        "",
+                        "message"
+                    ]
+                },
+                "name": {
+                    "type": "string",
+                    "example": "kenobi"
+                },
+                "timestamp": {
+                    "description": "signal arrival time in seconds, relative to a reference common to all the satellites (and near, to keep the precision)",
+                    "type": "number",
+                    "example": 0.0000016678
+                }
+            }
+        },
+        "model.SuggestionResponse": {
+            "type": "object",
+            "properties": {
+                "score": {
+                    "description": "the context evidence of the word over the evidence of every suggested word, from 0 to 1",
+                    "type": "number",
+                    "example": 0.75
+                },
+                "word": {
+                    "type": "string",
+                    "example": "auxilio"
+                }
+            }
+        },
+        "model.TopSecretRequest": {
+            "type": "object",
+            "properties": {
+                "satellites": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.SatelliteInfoRequest"
+                    }
+                }
+            }
+        },
+        "model.TopSecretResponse": {
+            "type": "object",
+            "properties": {
+                "accuracy": {
+                    "$ref": "#/definitions/model.AccuracyResponse"
+                },
+                "ambiguous": {
+                    "description": "true when the location can't be determined univocally (only two distances or three timestamps), see candidates",
+                    "type": "boolean"
+                },
+                "candidates": {
+                    "description": "the candidate locations of an ambiguous result",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.CoordinatesResponse"
+                    }
+                },
+                "closestApproach": {
+                    "description": "true when the circles don't intersect and the candidate is the closest approach between them",
+                    "type": "boolean"
+                },
+                "completeness": {
+                    "description": "the received words over the message words, from 0 to 1. 1 for a complete message",
+                    "type": "number",
+                    "example": 1
+                },
+                "contested": {
+                    "description": "the message positions where the satellites received different words, present only in vote mode",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.ContestedWordResponse"
+                    }
+                },
+                "frame": {
+                    "description": "the reference frame of the position, present only if it was asked for",
+                    "type": "string",
+                    "example": "geodetic"
+                },
+                "gaps": {
+                    "description": "the positions of the message words that no satellite received",
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    },
+                    "example": [
+                        2
+                    ]
+                },
+                "message": {
+                    "type": "string"
+                },
+                "position": {
+                    "$ref": "#/definitions/model.CoordinatesResponse"
+                },
+                "quality": {
+                    "description": "the location quality, present only in best effort mode",
+                    "$ref": "#/definitions/model.QualityResponse"
+                },
+                "rejected": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "sato"
+                    ]
+                },
+                "solver": {
+                    "description": "the name of the solver used to calculate the location",
+                    "type": "string",
+                    "example": "auto"
+                },
+                "suggestions": {
+                    "description": "the suggested words of the message gaps, present only if it was asked for. They aren't merged into the message",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.GapSuggestionsResponse"
+                    }
+                },
+                "words": {
+                    "description": "the detail of each message word, present only if it was asked for",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.WordDetailResponse"
+                    }
+                }
+            }
+        },
+        "model.TopSecretSplitPOSTResponse": {
+            "type": "object",
+            "properties": {
+                "matchScore": {
+                    "description": "the similarity (0 to 100) of the reported message with the one of the dataset it was collected in, present only\nif the dataset was found by message (without operation)",
+                    "type": "integer",
+                    "example": 86
+                },
+                "operation": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.TopSecretSplitRequest": {
+            "type": "object",
+            "properties": {
+                "distance": {
+                    "type": "number",
+                    "example": 100.23
+                },
+                "message": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "",
+                        "is",
+                        "a",
+                        "",
+                        "message"
+                    ]
+                },
+                "name": {
+                    "type": "string",
+                    "example": "kenobi"
+                },
+                "nonce": {
+                    "description": "unique report identifier, signed with the report data. Required, unless the unsigned replays are allowed\n(OFQ_ALLOW_UNSIGNED_REPLAYS) and the satellite hasn't verification key",
+                    "type": "string",
+                    "example": "3f2a9c"
+                },
+                "reliability": {
+                    "description": "satellite reliability, its vote weight when the message words are reconciled by vote. Optional, 1 by default",
+                    "type": "number",
+                    "example": 0.8
+                },
+                "signature": {
+                    "description": "base64 signature of the report, required for the satellites with verification key. See security.SignedPayload",
+                    "type": "string",
+                    "example": "dGhpcyBpcyBhIHNpZ25hdHVyZQ=="
+                },
+                "snr": {
+                    "description": "signal to noise ratio (dB), optional. Used to estimate the distance standard deviation when it's absent",
+                    "type": "number",
+                    "example": 20
+                },
+                "stdDev": {
+                    "description": "distance standard deviation (coordinates units), optional",
+                    "type": "number",
+                    "example": 0.5
+                },
+                "timestamp": {
+                    "description": "report time (unix seconds), signed with the report data. Required, unless the unsigned replays are allowed\n(OFQ_ALLOW_UNSIGNED_REPLAYS) and the satellite hasn't verification key",
+                    "type": "integer",
+                    "example": 1700000000
+                }
+            }
+        },
+        "model.TopSecretTDOARequest": {
+            "type": "object",
+            "properties": {
+                "propagationSpeed": {
+                    "description": "signal propagation speed (coordinates units per second), optional. By default the configured one (OFQ_PROPAGATION_SPEED)",
+                    "type": "number",
+                    "example": 299792458
+                },
+                "satellites": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.SatelliteTimingRequest"
+                    }
+                }
+            }
+        },
+        "model.TrackFixRequest": {
+            "type": "object",
+            "properties": {
+                "position": {
+                    "description": "the fix position in the plane, if absent it's calculated with the satellites distances",
+                    "$ref": "#/definitions/model.CoordinatesRequest"
+                },
+                "satellites": {
+                    "description": "the distances to the satellites (raw ranges), used when the position is absent",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.SatelliteInfoRequest"
+                    }
+                },
+                "timestamp": {
+                    "description": "fix time in seconds, must not be previous to the last track fix",
+                    "type": "number",
+                    "example": 12.5
+                }
+            }
+        },
+        "model.TrackRequest": {
+            "type": "object",
+            "properties": {
+                "fixes": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.TrackFixRequest"
+                    }
+                }
+            }
+        },
+        "model.TrackResponse": {
+            "type": "object",
+            "properties": {
+                "position": {
+                    "$ref": "#/definitions/model.CoordinatesResponse"
+                },
+                "predicted": {
+                    "description": "only present if a prediction timestamp was asked for",
+                    "$ref": "#/definitions/model.PredictedPositionResponse"
+                },
+                "timestamp": {
+                    "description": "timestamp of the last fix",
+                    "type": "number",
+                    "example": 12.5
+                },
+                "track": {
+                    "type": "string",
+                    "example": "emitter1"
+                },
+                "updates": {
+                    "description": "amount of fixes applied to the track",
+                    "type": "integer",
+                    "example": 3
+                },
+                "velocity": {
+                    "$ref": "#/definitions/model.VelocityResponse"
+                }
+            }
+        },
+        "model.VelocityResponse": {
+            "type": "object",
+            "properties": {
+                "vx": {
+                    "type": "number",
+                    "example": 1.5
+                },
+                "vy": {
+                    "type": "number",
+                    "example": -0.25
+                }
+            }
+        },
+        "model.WordDetailResponse": {
+            "type": "object",
+            "properties": {
+                "agreement": {
+                    "type": "integer",
+                    "example": 2
+                },
+                "confidence": {
+                    "type": "number",
+                    "example": 0.67
+                },
+                "gap": {
+                    "description": "true if no satellite received a word at the position",
+                    "type": "boolean"
+                },
+                "position": {
+                    "type": "integer",
+                    "example": 3
+                },
+                "satellites": {
+                    "description": "the satellites that received the word",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "kenobi",
+                        "sato"
+                    ]
+                },
+                "word": {
+                    "description": "the word, empty for a gap",
+                    "type": "string",
+                    "example": "mensaje"
+                }
+            }
+        },
+        "model.WordVotesResponse": {
+            "type": "object",
+            "properties": {
+                "votes": {
+                    "type": "number",
+                    "example": 2
+                },
+                "word": {
+                    "type": "string",
+                    "example": "mensaje"
                 }
             }
         }
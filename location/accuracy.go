@@ -0,0 +1,131 @@
+package location
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/mgironi/operation-fire-quasar/model"
+)
+
+// Location accuracy estimation of a calculated coordinate.
+type Accuracy struct {
+	// residual of each distance (calculated distance - given distance), same order as distances
+	Residuals []float64
+	// position covariance matrix [[varX, covXY], [covXY, varY]] (3x3 including Z for a location in the space)
+	Covariance [][]float64
+	// error ellipse (1 sigma, in the XY plane) semi major axis
+	SemiMajorAxis float64
+	// error ellipse (1 sigma) semi minor axis
+	SemiMinorAxis float64
+	// error ellipse orientation, angle in degrees between X axis and semi major axis (anticlockwise)
+	Orientation float64
+	// geometric dilution of precision
+	GDOP float64
+}
+
+// Estimates the accuracy of a calculated location.
+//
+// The position covariance is calculated as sigma^2 * (Jt*J)^-1, where J is the jacobian of the distances to the
+// points coordinates (unit vectors from each point to the location) and sigma^2 is the residuals variance
+// (sum of squared residuals / degrees of freedom). With no degrees of freedom (as many distances as unknowns)
+// the variance can't be estimated, so the covariance is reported as zero.
+//
+// The GDOP is sqrt(trace((Jt*J)^-1)) and depends only on the points coordinates geometry seen from the location.
+//
+// input: x and y calculated location coordinates, the distances and the points coordinates (converted to the XY plane, see ProjectDistancesToPlane).
+// output: the accuracy estimation.
+// error: if distances and points coordinates sizes mismatch or the geometry is singular.
+func EstimateAccuracy(x, y float32, distances []float32, pointsCoordinates []model.Point) (accuracy Accuracy, err error) {
+	position := model.Point{X: float64(x), Y: float64(y)}
+	distances, _, pointsCoordinates, err = ProjectDistancesToPlane(distances, nil, pointsCoordinates)
+	if err != nil {
+		return accuracy, err
+	}
+	return estimateAccuracy(position, distances, pointsCoordinates, PLANE_DIMENSIONS)
+}
+
+// Estimates the accuracy of a location calculated in the space, see EstimateAccuracy.
+// The covariance includes Z, the error ellipse is calculated in the XY plane and GDOP includes the Z variance.
+// input: the calculated location, the distances and the points coordinates.
+// output: the accuracy estimation.
+// error: if distances and points coordinates sizes mismatch or the geometry is singular.
+func EstimateSpatialAccuracy(position model.Point, distances []float32, pointsCoordinates []model.Point) (accuracy Accuracy, err error) {
+	return estimateAccuracy(position, distances, pointsCoordinates, SPACE_DIMENSIONS)
+}
+
+// Estimates the accuracy of a calculated location for the given dimensions (2: plane, 3: space).
+func estimateAccuracy(position model.Point, distances []float32, pointsCoordinates []model.Point, dimensions int) (accuracy Accuracy, err error) {
+	if len(distances) != len(pointsCoordinates) {
+		return accuracy, fmt.Errorf("can't estimate accuracy. Distances and Points coordinates has diferent sizes. Distances: %d, PointsCoord: %d", len(distances), len(pointsCoordinates))
+	}
+
+	accuracy.Residuals = calculateResiduals(position, distances, pointsCoordinates)
+
+	// builds jacobian
+	positionCoords := coordinatesOf(position, dimensions)
+	jacobian := make([][]float64, len(pointsCoordinates))
+	for i, pt := range pointsCoordinates {
+		calcDistance := position.DistanceToPoint(pt)
+		if calcDistance == 0 {
+			return accuracy, errors.New("can't estimate accuracy. Location is over a reference point")
+		}
+		ptCoords := coordinatesOf(pt, dimensions)
+		jacobian[i] = make([]float64, dimensions)
+		for k := 0; k < dimensions; k++ {
+			jacobian[i][k] = (positionCoords[k] - ptCoords[k]) / calcDistance
+		}
+	}
+
+	// calculates cofactor matrix (Jt*J)^-1
+	jtj, _ := normalEquations(jacobian, make([]float64, len(jacobian)))
+	cofactor, invErr := invertMatrix(jtj)
+	if invErr != nil {
+		return accuracy, fmt.Errorf("can't estimate accuracy. %s", invErr.Error())
+	}
+	trace := float64(0)
+	for k := 0; k < dimensions; k++ {
+		trace += cofactor[k][k]
+	}
+	accuracy.GDOP = math.Sqrt(trace)
+
+	// calculates residuals variance
+	variance := float64(0)
+	degreesOfFreedom := len(distances) - len(cofactor)
+	if degreesOfFreedom > 0 {
+		for _, residual := range accuracy.Residuals {
+			variance += math.Pow(residual, 2)
+		}
+		variance = variance / float64(degreesOfFreedom)
+	}
+
+	accuracy.Covariance = make([][]float64, dimensions)
+	for row := 0; row < dimensions; row++ {
+		accuracy.Covariance[row] = make([]float64, dimensions)
+		for col := 0; col < dimensions; col++ {
+			accuracy.Covariance[row][col] = variance * cofactor[row][col]
+		}
+	}
+
+	accuracy.SemiMajorAxis, accuracy.SemiMinorAxis, accuracy.Orientation = errorEllipse(accuracy.Covariance)
+	return accuracy, nil
+}
+
+// Calculates the error ellipse of a 2x2 covariance matrix, by its eigenvalues and eigenvectors.
+// input: the covariance matrix (for a bigger matrix only the XY block is used).
+// output: the semi major and semi minor axes (square root of the eigenvalues) and the orientation of the
+// semi major axis in degrees.
+func errorEllipse(covariance [][]float64) (semiMajorAxis, semiMinorAxis, orientation float64) {
+	varX := covariance[0][0]
+	varY := covariance[1][1]
+	covXY := covariance[0][1]
+
+	mean := (varX + varY) / 2
+	radius := math.Hypot((varX-varY)/2, covXY)
+
+	// prevents negative values by rounding errors
+	semiMajorAxis = math.Sqrt(math.Max(mean+radius, 0))
+	semiMinorAxis = math.Sqrt(math.Max(mean-radius, 0))
+	orientation = 0.5 * math.Atan2(2*covXY, varX-varY) * 180 / math.Pi
+	return semiMajorAxis, semiMinorAxis, orientation
+}
@@ -0,0 +1,107 @@
+package location_test
+
+import (
+	"math"
+	"testing"
+
+	test "github.com/mgironi/operation-fire-quasar/_test"
+	"github.com/mgironi/operation-fire-quasar/location"
+	"github.com/mgironi/operation-fire-quasar/model"
+)
+
+// Tests EstimateAccuracy with exact and noisy distances
+func TestEstimateAccuracy(t *testing.T) {
+	pointsCoordinates := []model.Point{{X: -500, Y: -200}, {X: 100, Y: -100}, {X: 500, Y: 100}, {X: 0, Y: 500}}
+	position := model.Point{X: -200, Y: 200}
+
+	tests := []struct {
+		name            string
+		noise           []float32
+		wantZeroEllipse bool
+	}{
+		{name: "exact", noise: []float32{0, 0, 0, 0}, wantZeroEllipse: true},
+		{name: "noisy", noise: []float32{0.5, -0.5, 0.3, -0.2}, wantZeroEllipse: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			distances := make([]float32, len(pointsCoordinates))
+			for i, pt := range pointsCoordinates {
+				distances[i] = float32(pt.DistanceToPoint(position)) + tt.noise[i]
+			}
+			x, y, _, err := location.CalculateLocationByMultilateration(distances, pointsCoordinates)
+			if err != nil {
+				t.Fatalf("CalculateLocationByMultilateration() error = %v", err)
+			}
+
+			accuracy, err := location.EstimateAccuracy(x, y, distances, pointsCoordinates)
+			if err != nil {
+				t.Fatalf("EstimateAccuracy() error = %v", err)
+			}
+			if len(accuracy.Residuals) != len(pointsCoordinates) {
+				t.Errorf("EstimateAccuracy() residuals size is %d, want %d", len(accuracy.Residuals), len(pointsCoordinates))
+			}
+			if accuracy.GDOP <= 0 || math.IsNaN(accuracy.GDOP) {
+				t.Errorf("EstimateAccuracy() GDOP is %f, want a positive value", accuracy.GDOP)
+			}
+			if accuracy.SemiMajorAxis < accuracy.SemiMinorAxis {
+				t.Errorf("EstimateAccuracy() semi major axis %f is smaller than semi minor axis %f", accuracy.SemiMajorAxis, accuracy.SemiMinorAxis)
+			}
+			// float32 coordinates rounding keeps a small ellipse for exact distances
+			isZeroEllipse := accuracy.SemiMajorAxis < 0.01
+			if isZeroEllipse != tt.wantZeroEllipse {
+				t.Errorf("EstimateAccuracy() semi major axis is %f, want zero ellipse %t", accuracy.SemiMajorAxis, tt.wantZeroEllipse)
+			}
+		})
+	}
+}
+
+// Tests EstimateAccuracy GDOP is the same for any location at the same geometry, and worse for a far location
+func TestEstimateAccuracyGDOP(t *testing.T) {
+	pointsCoordinates := []model.Point{{X: -500, Y: -200}, {X: 100, Y: -100}, {X: 500, Y: 100}}
+	near := model.Point{X: 0, Y: 0}
+	far := model.Point{X: 100000, Y: 100000}
+
+	gdop := func(position model.Point) float64 {
+		distances := make([]float32, len(pointsCoordinates))
+		for i, pt := range pointsCoordinates {
+			distances[i] = float32(pt.DistanceToPoint(position))
+		}
+		accuracy, err := location.EstimateAccuracy(float32(position.X), float32(position.Y), distances, pointsCoordinates)
+		if err != nil {
+			t.Fatalf("EstimateAccuracy() error = %v", err)
+		}
+		return accuracy.GDOP
+	}
+
+	if gdopNear, gdopFar := gdop(near), gdop(far); gdopFar <= gdopNear {
+		t.Errorf("EstimateAccuracy() GDOP far from satellites %f, want greater than near %f", gdopFar, gdopNear)
+	}
+}
+
+// Tests EstimateAccuracy with a location over a reference point
+func TestEstimateAccuracyOverReferencePoint(t *testing.T) {
+	pointsCoordinates := []model.Point{{X: -500, Y: -200}, {X: 100, Y: -100}, {X: 500, Y: 100}}
+	distances := []float32{0, float32(pointsCoordinates[0].DistanceToPoint(pointsCoordinates[1])), float32(pointsCoordinates[0].DistanceToPoint(pointsCoordinates[2]))}
+	_, err := location.EstimateAccuracy(-500, -200, distances, pointsCoordinates)
+	if err == nil {
+		t.Error("EstimateAccuracy() error was expected with location over a reference point")
+	}
+}
+
+// Tests CalculateLocationWithAccuracy
+func TestCalculateLocationWithAccuracy(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+
+	distances := []float32{500, 424.26, 707.10}
+	x, y, accuracy, err := location.CalculateLocationWithAccuracy(distances)
+	if err != nil {
+		t.Fatalf("CalculateLocationWithAccuracy() error = %v", err)
+	}
+	wantX, wantY, _ := location.CalculateLocation(distances)
+	if x != wantX || y != wantY {
+		t.Errorf("CalculateLocationWithAccuracy() is (%f, %f), want (%f, %f)", x, y, wantX, wantY)
+	}
+	if len(accuracy.Residuals) != len(distances) {
+		t.Errorf("CalculateLocationWithAccuracy() residuals size is %d, want %d", len(accuracy.Residuals), len(distances))
+	}
+}
@@ -0,0 +1,116 @@
+package location
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/mgironi/operation-fire-quasar/model"
+	"github.com/mgironi/operation-fire-quasar/store"
+)
+
+// Ambiguous location calculation result. With only two distances the location can be any of the candidates.
+type AmbiguousLocation struct {
+	// the candidate locations, two intersection points or a single one (tangent circles or closest approach)
+	Candidates []model.Point
+	// true if the circles don't intersect and the candidate is the closest approach point between them
+	ClosestApproach bool
+}
+
+// Calculates the ambiguous location with the distances to two of the known satellites.
+// See also CalculateAmbiguousLocation.
+// input: the satellites indexes (as in store) and the distances to each one.
+// output: the ambiguous location.
+// error: in case calculation couldn't be done.
+func CalculateAmbiguousLocationFor(satellitesIndexes []int, distances []float32) (result AmbiguousLocation, err error) {
+	if len(satellitesIndexes) != len(distances) {
+		return result, fmt.Errorf("satellites indexes and distances has diferent sizes. Indexes: %d, Distances: %d", len(satellitesIndexes), len(distances))
+	}
+
+	// gets reference points coordinates
+	knownCoordinates := store.GetKnownReferenceCoordinates()
+	pointsCoordinates := make([]model.Point, len(satellitesIndexes))
+	for i, satIdx := range satellitesIndexes {
+		if satIdx < 0 || satIdx >= len(knownCoordinates) {
+			return result, fmt.Errorf("there isn't satellite reference data for index %d", satIdx)
+		}
+		pointsCoordinates[i] = knownCoordinates[satIdx]
+	}
+	return CalculateAmbiguousLocation(distances, pointsCoordinates)
+}
+
+// Calculates the ambiguous location with two distances, by the intersection of two circles.
+//
+// With P1 as origin and P2 over the X' axis (at distance d), the circles intersection ecuations are
+//
+// a = (r1^2 - r2^2 + d^2) / (2*d)
+//
+// h = sqrt(r1^2 - a^2)
+//
+// where 'a' is the position of the intersection chord over X' axis and 'h' is half the chord length.
+// Then the candidates are P1 + a*u +/- h*v, where u is the unit vector from P1 to P2 and v its perpendicular.
+//
+// If the circles are tangent (h = 0) there is a single candidate. If the circles don't intersect, the single
+// candidate is the closest approach, the middle point between the nearest points of both circles.
+//
+// input: the two distances and the two points coordinates.
+// output: the ambiguous location.
+// error: if there aren't two distances, some distance is negative or the points are coincident.
+func CalculateAmbiguousLocation(distances []float32, pointsCoordinates []model.Point) (result AmbiguousLocation, err error) {
+	if len(distances) != 2 || len(pointsCoordinates) != 2 {
+		return result, fmt.Errorf("ambiguous location needs 2 distances and 2 points coordinates. Distances: %d, PointsCoord: %d", len(distances), len(pointsCoordinates))
+	}
+
+	radiusP1 := float64(distances[0])
+	radiusP2 := float64(distances[1])
+	if radiusP1 < 0 || radiusP2 < 0 || math.IsNaN(radiusP1) || math.IsNaN(radiusP2) {
+		return result, fmt.Errorf("can't calculate ambiguous location with distances %f and %f", radiusP1, radiusP2)
+	}
+
+	// the location is in the XY plane
+	p1 := pointsCoordinates[0].ProjectionToPlane()
+	p2 := pointsCoordinates[1].ProjectionToPlane()
+	d := p1.DistanceToPoint(p2)
+	if d < model.FLOAT_COMPARISION_TOLERANCE {
+		return result, errors.New("can't calculate ambiguous location, points coordinates are coincident")
+	}
+
+	// unit vector from P1 to P2 and its perpendicular
+	u := model.Point{X: (p2.X - p1.X) / d, Y: (p2.Y - p1.Y) / d}
+	v := model.Point{X: -u.Y, Y: u.X}
+
+	// position over the line of centers (as distance from P1) to point coordinates
+	alongCenters := func(t float64, h float64) model.Point {
+		return model.Point{X: p1.X + t*u.X + h*v.X, Y: p1.Y + t*u.Y + h*v.Y}
+	}
+
+	a := (math.Pow(radiusP1, 2) - math.Pow(radiusP2, 2) + math.Pow(d, 2)) / (2 * d)
+	hSquared := math.Pow(radiusP1, 2) - math.Pow(a, 2)
+
+	// tolerance relative to the circles size
+	tolerance := model.FLOAT_COMPARISION_TOLERANCE * math.Max(radiusP1, radiusP2)
+
+	switch {
+	case hSquared > math.Pow(tolerance, 2):
+		h := math.Sqrt(hSquared)
+		result.Candidates = []model.Point{alongCenters(a, h), alongCenters(a, -h)}
+	case hSquared >= -math.Pow(tolerance, 2):
+		// tangent circles
+		result.Candidates = []model.Point{alongCenters(a, 0)}
+	default:
+		// circles don't intersect, searchs the nearest points of both circles over the line of centers
+		result.ClosestApproach = true
+		bestGap := math.Inf(1)
+		var bestMiddle float64
+		for _, t1 := range []float64{radiusP1, -radiusP1} {
+			for _, t2 := range []float64{d + radiusP2, d - radiusP2} {
+				if gap := math.Abs(t1 - t2); gap < bestGap {
+					bestGap = gap
+					bestMiddle = (t1 + t2) / 2
+				}
+			}
+		}
+		result.Candidates = []model.Point{alongCenters(bestMiddle, 0)}
+	}
+	return result, nil
+}
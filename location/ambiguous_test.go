@@ -0,0 +1,54 @@
+package location_test
+
+import (
+	"math"
+	"testing"
+
+	test "github.com/mgironi/operation-fire-quasar/_test"
+	"github.com/mgironi/operation-fire-quasar/location"
+	"github.com/mgironi/operation-fire-quasar/model"
+)
+
+// Tests CalculateAmbiguousLocation with intersecting, tangent and not intersecting circles
+func TestCalculateAmbiguousLocation(t *testing.T) {
+	points := []model.Point{{X: 0, Y: 0}, {X: 10, Y: 0}}
+
+	tests := []struct {
+		name                string
+		distances           []float32
+		points              []model.Point
+		wantCandidates      []model.Point
+		wantClosestApproach bool
+		wantErr             bool
+	}{
+		{name: "intersecting", distances: []float32{5, float32(math.Sqrt(65))}, points: points, wantCandidates: []model.Point{{X: 3, Y: 4}, {X: 3, Y: -4}}},
+		{name: "tangent", distances: []float32{5, 5}, points: points, wantCandidates: []model.Point{{X: 5, Y: 0}}},
+		{name: "separated", distances: []float32{2, 3}, points: points, wantCandidates: []model.Point{{X: 4.5, Y: 0}}, wantClosestApproach: true},
+		{name: "contained", distances: []float32{20, 5}, points: points, wantCandidates: []model.Point{{X: 17.5, Y: 0}}, wantClosestApproach: true},
+		{name: "coincidentPoints", distances: []float32{5, 5}, points: []model.Point{{X: 1, Y: 1}, {X: 1, Y: 1}}, wantErr: true},
+		{name: "negativeDistance", distances: []float32{-5, 5}, points: points, wantErr: true},
+		{name: "threeDistances", distances: []float32{5, 5, 5}, points: append(points, model.Point{X: 5, Y: 5}), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := location.CalculateAmbiguousLocation(tt.distances, tt.points)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CalculateAmbiguousLocation() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.ClosestApproach != tt.wantClosestApproach {
+				t.Errorf("CalculateAmbiguousLocation() closest approach = %t, want %t", got.ClosestApproach, tt.wantClosestApproach)
+			}
+			if len(got.Candidates) != len(tt.wantCandidates) {
+				t.Fatalf("CalculateAmbiguousLocation() candidates = %v, want %v", got.Candidates, tt.wantCandidates)
+			}
+			for i, want := range tt.wantCandidates {
+				if !test.AreFloatsEquals(math.Round(got.Candidates[i].X*1e4)/1e4, want.X) || !test.AreFloatsEquals(math.Round(got.Candidates[i].Y*1e4)/1e4, want.Y) {
+					t.Errorf("CalculateAmbiguousLocation() candidate %d = %s, want %s", i, got.Candidates[i], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package location
+
+import (
+	"errors"
+
+	"github.com/mgironi/operation-fire-quasar/model"
+	"github.com/mgironi/operation-fire-quasar/store"
+)
+
+// Converts a position in the local cartesian frame to WGS84 geodetic coordinates.
+// The local cartesian frame is the ENU frame at the reference origin, see store.GetReferenceOrigin.
+// input: the position in the local cartesian frame (Z is 0 for a location in the plane).
+// output: the geodetic coordinates.
+// error: if the reference origin isn't configured.
+func ToGeodetic(position model.Point) (geo model.GeodeticCoordinates, err error) {
+	referenceOrigin, present := store.GetReferenceOrigin()
+	if !present {
+		return geo, errors.New("can't convert to geodetic coordinates, the reference origin isn't configured")
+	}
+	return model.ENUToGeodetic(position, referenceOrigin), nil
+}
+
+// Converts a position in the local cartesian frame to the East-North-Up frame at the given origin.
+// See also ToGeodetic.
+// input: the position in the local cartesian frame and the ENU frame origin.
+// output: the position in the ENU frame (X: east, Y: north, Z: up).
+// error: if the reference origin isn't configured.
+func ToENU(position model.Point, origin model.GeodeticCoordinates) (enu model.Point, err error) {
+	referenceOrigin, present := store.GetReferenceOrigin()
+	if !present {
+		return enu, errors.New("can't convert to ENU frame, the reference origin isn't configured")
+	}
+	ecef := model.ENUToECEF(position, referenceOrigin)
+	return model.ECEFToENU(ecef, origin), nil
+}
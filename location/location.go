@@ -1,7 +1,6 @@
 package location
 
 import (
-	"errors"
 	"fmt"
 
 	"log"
@@ -28,46 +27,202 @@ func GetLocation(distances ...float32) (x, y float32) {
 	return x, y
 }
 
+// input: distance to the transmitter recieved on each satlelite
+// output: the location position of the message emiter, and true if it was determined in the space (Z coordinate is valid)
+func GetPosition(distances ...float32) (position model.Point, spatial bool) {
+	var err error
+	position, spatial, err = CalculatePosition(distances)
+	if err != nil {
+		log.Printf("Is no possible to compelete calculations. %s", err.Error())
+	}
+	return position, spatial
+}
+
 // Calculates coordinates location.
-// The array should have 3 ordered distances to the known coordinates.
+// The array should have ordered distances to the known coordinates (one for each satellite).
+// With 3 satellites uses trilateration, with more satellites uses multilateration so every distance is considered.
+// See also CalculatePosition, if the satellites layout allows the location in the space only X and Y are returned.
 // input: Recieves distances array to a known coordinates.
 // output: Returns X and Y coordinates of the calculated location and an error in case calculation couldn't be done.
 func CalculateLocation(distances []float32) (x, y float32, err error) {
-
-	// gets reference points coordinates
-	pointsCoordinates := store.GetKnownReferenceCoordinates()
-
-	// checks if distances has same amount of elements that the refences points coordiantes.
-	if len(distances) != len(pointsCoordinates) {
-		return 0, 0, fmt.Errorf("distances and Points coordinates has diferent sizes. Distances: %d, PointsCoord: %d", len(distances), len(pointsCoordinates))
+	position, _, err := CalculatePosition(distances)
+	if err != nil {
+		return 0, 0, err
 	}
+	return float32(position.X), float32(position.Y), nil
+}
 
-	// calculates location with the distances to the points coordinates using trilateration math method
-	x, y = CalculateLocationByTrilateration(distances, pointsCoordinates)
+// Calculates location position, in the space (X, Y and Z) if the satellites layout allows it.
+// With 4 or more satellites not over the same plane uses multilateration in the space, see CalculateLocationByMultilateration3D.
+// Otherwise falls back to the location in the XY plane (emitter at Z=0, the distances are converted to horizontal ones), see CalculatePlanarLocation.
+// input: Recieves distances array to a known coordinates.
+// output: the location position, and true if it was calculated in the space (Z is determined).
+// error: in case calculation couldn't be done.
+func CalculatePosition(distances []float32) (position model.Point, spatial bool, err error) {
+	solution, _, err := CalculateLocationWithSolver(distances, nil, AUTO_SOLVER, DefaultThresholds())
+	return solution.Position, solution.Spatial, err
+}
 
-	// checks if calculated coorinates match with given distances, getting ratioError
-	ratioErr, err := ChecksDistancesToCoordinate(distances, pointsCoordinates, x, y)
+// Calculates coordinates location in the XY plane, the points coordinates are projected to the plane and the distances
+// converted to horizontal distances (the emitter is in the plane), see ProjectDistancesToPlane.
+// With 3 points and unknown distances noise uses trilateration, otherwise uses weighted multilateration so every
+// distance is considered by its standard deviation. The location is checked with the residuals chi-square test.
+// input: the distances, its standard deviations (nil if unknown, see ResolveStdDevs), the points coordinates and the thresholds to accept the location.
+// output: Returns X and Y coordinates of the calculated location and an error in case calculation couldn't be done.
+// error: RangeBelowAltitudeError if a distance can't reach the plane.
+func CalculatePlanarLocation(distances []float32, stdDevs []float64, pointsCoordinates []model.Point, thresholds Thresholds) (x, y float32, err error) {
+	distances, stdDevs, pointsCoordinates, err = ProjectDistancesToPlane(distances, stdDevs, pointsCoordinates)
 	if err != nil {
 		log.Print(err)
 		return 0, 0, err
 	}
 
-	// checks if ratioError is acceptable with float comparission tolerance
-	acceptedRatio := 10 * model.FLOAT_COMPARISION_TOLERANCE
-	if ratioErr > acceptedRatio {
-		errMsg := fmt.Sprintf("ratio error exceeds acceptable level of %.4f. Ratio ~ %.4f", acceptedRatio, ratioErr)
-		log.Printf("WARN %s", errMsg)
-		return 0, 0, errors.New(errMsg)
+	// checks points coordinates geometry, to prevent solving with a degenerate layout
+	geomErr := model.ValidateGeometry(pointsCoordinates)
+	if geomErr != nil {
+		log.Print(geomErr)
+		return 0, 0, geomErr
+	}
+
+	useMultilateration := len(pointsCoordinates) > 3 || hasStdDevs(stdDevs)
+	if !useMultilateration {
+		// calculates location with the distances to the points coordinates using trilateration math method
+		x, y = CalculateLocationByTrilateration(distances, pointsCoordinates)
+
+		// fallback to multilateration if trilateration can't solve the points layout
+		if !isFiniteCoordinate(x, y) {
+			log.Printf("WARN trilateration result is not finite (%f, %f). Falling back to multilateration", x, y)
+			useMultilateration = true
+		}
+	}
+
+	if useMultilateration {
+		// calculates location with the distances to all the points coordinates using multilateration math method
+		var multErr error
+		x, y, _, multErr = CalculateLocationByWeightedMultilateration(distances, stdDevs, pointsCoordinates)
+		if multErr != nil {
+			log.Print(multErr)
+			return 0, 0, multErr
+		}
+	}
+
+	// checks if calculated coorinates match with given distances
+	err = checksAcceptableResiduals(distances, stdDevs, pointsCoordinates, model.Point{X: float64(x), Y: float64(y)}, PLANE_DIMENSIONS, thresholds)
+	if err != nil {
+		return 0, 0, err
 	}
 	return x, y, nil
 }
 
+// Error of a distance shorter than the altitude of its point, so the emitter can't be in the XY plane.
+type RangeBelowAltitudeError struct {
+	// the index of the distance (and its point)
+	Index    int
+	Distance float32
+	Altitude float64
+}
+
+func (rangeErr RangeBelowAltitudeError) Error() string {
+	return fmt.Sprintf("can't calculate location in the XY plane, distance %d (%f) is shorter than its point altitude (%f)", rangeErr.Index, rangeErr.Distance, rangeErr.Altitude)
+}
+
+// Projects the points coordinates to the XY plane, converting the slant distances to them to horizontal distances to
+// their projections. The emitter is assumed in the plane (Z=0), so the horizontal distance is sqrt(r^2 - z^2). The
+// standard deviations are scaled by r/h, as the horizontal distance error is bigger than the slant distance one.
+// The distances to the points in the plane aren't modified.
+// input: the slant distances, its standard deviations (nil if unknown) and the points coordinates.
+// output: the horizontal distances, its standard deviations and the projected points coordinates.
+// error: RangeBelowAltitudeError if a distance is shorter than its point altitude.
+func ProjectDistancesToPlane(distances []float32, stdDevs []float64, pointsCoordinates []model.Point) (planeDistances []float32, planeStdDevs []float64, planePoints []model.Point, err error) {
+	if len(distances) != len(pointsCoordinates) {
+		return distances, stdDevs, pointsCoordinates, fmt.Errorf("distances and Points coordinates has diferent sizes. Distances: %d, PointsCoord: %d", len(distances), len(pointsCoordinates))
+	}
+	planeDistances = append([]float32(nil), distances...)
+	if len(stdDevs) == len(distances) {
+		planeStdDevs = append([]float64(nil), stdDevs...)
+	} else {
+		planeStdDevs = stdDevs
+	}
+	for i, pt := range pointsCoordinates {
+		if pt.Z == 0 {
+			continue
+		}
+		slant := float64(distances[i])
+		horizontalSquared := math.Pow(slant, 2) - math.Pow(pt.Z, 2)
+		if slant < 0 || horizontalSquared < 0 || math.IsNaN(horizontalSquared) {
+			return nil, nil, nil, RangeBelowAltitudeError{Index: i, Distance: distances[i], Altitude: pt.Z}
+		}
+		horizontal := math.Sqrt(horizontalSquared)
+		planeDistances[i] = float32(horizontal)
+		if len(planeStdDevs) == len(distances) && horizontal > 0 {
+			planeStdDevs[i] *= slant / horizontal
+		}
+	}
+	return planeDistances, planeStdDevs, model.ProjectPointsToPlane(pointsCoordinates), nil
+}
+
+// Calculates coordinates location and estimates its accuracy.
+// See also CalculatePositionWithAccuracy.
+// input: Recieves distances array to a known coordinates.
+// output: Returns X and Y coordinates of the calculated location, its accuracy estimation and an error in case calculation couldn't be done.
+func CalculateLocationWithAccuracy(distances []float32) (x, y float32, accuracy Accuracy, err error) {
+	position, _, accuracy, err := CalculatePositionWithAccuracy(distances)
+	if err != nil {
+		return 0, 0, accuracy, err
+	}
+	return float32(position.X), float32(position.Y), accuracy, nil
+}
+
+// Calculates location position and estimates its accuracy.
+// See also CalculatePosition, EstimateAccuracy and EstimateSpatialAccuracy.
+// input: Recieves distances array to a known coordinates.
+// output: the location position, true if it was calculated in the space and its accuracy estimation.
+// error: in case calculation couldn't be done.
+func CalculatePositionWithAccuracy(distances []float32) (position model.Point, spatial bool, accuracy Accuracy, err error) {
+	position, spatial, err = CalculatePosition(distances)
+	if err != nil {
+		return position, spatial, accuracy, err
+	}
+
+	if spatial {
+		accuracy, err = EstimateSpatialAccuracy(position, distances, store.GetKnownReferenceCoordinates())
+	} else {
+		accuracy, err = EstimateAccuracy(float32(position.X), float32(position.Y), distances, store.GetKnownReferenceCoordinates())
+	}
+	if err != nil {
+		log.Printf("WARN %s", err.Error())
+		return model.Point{}, spatial, accuracy, err
+	}
+	return position, spatial, accuracy, nil
+}
+
+// Checks if both coordinates are finite numbers (not NaN nor infinite).
+func isFiniteCoordinate(x, y float32) bool {
+	for _, value := range []float64{float64(x), float64(y)} {
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return false
+		}
+	}
+	return true
+}
+
 // Checks if the X, Y coordinates distance to each pointsCoordinates matchs with the given distances.
+// The location calculations check the residuals with the chi-square test instead, see ChecksChiSquare.
 // input: distances, points coordinates and 'x','y' calculated coordinates to check.
 // output: the median errorRatio calculated (0: no error, interval [0,1]: percent error)
 // error1: if detects arrays length diferences (between distances and points coordinates)
 // error2: an internal calculation error.
 func ChecksDistancesToCoordinate(distances []float32, pointsCoordinates []model.Point, x, y float32) (errorRatio float64, err error) {
+	return ChecksDistancesToPosition(distances, pointsCoordinates, model.Point{X: float64(x), Y: float64(y)})
+}
+
+// Checks if the position distance to each pointsCoordinates matchs with the given distances.
+// See also ChecksDistancesToCoordinate.
+// input: distances, points coordinates and the calculated position to check.
+// output: the median errorRatio calculated (0: no error, interval [0,1]: percent error)
+// error1: if detects arrays length diferences (between distances and points coordinates)
+// error2: an internal calculation error.
+func ChecksDistancesToPosition(distances []float32, pointsCoordinates []model.Point, position model.Point) (errorRatio float64, err error) {
 	// checks arrays length, they must be equals
 	if len(distances) != len(pointsCoordinates) {
 		return 0, fmt.Errorf("can't check distances with coordinate. Distances and Points coordinates has diferent sizes. Distances: %d, PointsCoord: %d", len(distances), len(pointsCoordinates))
@@ -77,7 +232,7 @@ func ChecksDistancesToCoordinate(distances []float32, pointsCoordinates []model.
 	ratios := make([]float64, len(distances))
 	for i, pt := range pointsCoordinates {
 		distance := float64(distances[i])
-		calcDistance := pt.DistanceToCoordinatesfloat32(x, y)
+		calcDistance := pt.DistanceToPoint(position)
 		ratios[i] = calcDistance / distance
 	}
 
@@ -165,8 +320,9 @@ func CalculateLocationByTrilateration(distances []float32, pointsCoodrinates []m
 	p2Prime := p2.TranslationTo(p1)
 	p3Prime := p3.TranslationTo(p1)
 
-	// Calculate alfa angle to rotate axes. Aligning p1Prime with p2Prime points to use it as X" axis
-	axesRotationAngle := math.Atan(p2Prime.Y / p2Prime.X)
+	// Calculate alfa angle to rotate axes. Aligning p1Prime with p2Prime points to use it as X" axis.
+	// Uses Atan2 so vertical alignments (P1 and P2 with the same X coordinate) are supported, and P2 always ends at positive X".
+	axesRotationAngle := math.Atan2(p2Prime.Y, p2Prime.X)
 
 	// rotates p2Prime to the axes rotation angle
 	p22ndPrime := p2Prime.RotateAxesTo(axesRotationAngle)
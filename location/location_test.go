@@ -196,3 +196,19 @@ func TestCalculateLocation(t *testing.T) {
 		})
 	}
 }
+
+// Tests CalculateLocationByTrilateration with the first two points vertically aligned
+func TestCalculateLocationByTrilaterationVerticalAlignment(t *testing.T) {
+	pointsCoordinates := []model.Point{{X: 0, Y: 0}, {X: 0, Y: 100}, {X: 100, Y: 50}}
+	tests := []model.Point{{X: -200, Y: 200}, {X: 30, Y: 40}, {X: 500, Y: -300}}
+	for _, want := range tests {
+		distances := make([]float32, len(pointsCoordinates))
+		for i, pt := range pointsCoordinates {
+			distances[i] = float32(pt.DistanceToPoint(want))
+		}
+		gotX, gotY := location.CalculateLocationByTrilateration(distances, pointsCoordinates)
+		if !location.HasTorableDiffByDynamicScale(want.X, float64(gotX), 10*model.FLOAT_COMPARISION_TOLERANCE) || !location.HasTorableDiffByDynamicScale(want.Y, float64(gotY), 10*model.FLOAT_COMPARISION_TOLERANCE) {
+			t.Errorf("CalculateLocationByTrilateration() is (%f, %f), want %s", gotX, gotY, want)
+		}
+	}
+}
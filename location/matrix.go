@@ -0,0 +1,112 @@
+package location
+
+import (
+	"errors"
+	"math"
+)
+
+// Defines the pivot magnitude under which a linear system is considered singular.
+const SINGULAR_PIVOT_TOLERANCE float64 = 1e-12
+
+// Solves the linear system A*x = b by gaussian elimination with partial pivoting.
+// input: the square matrix A (as rows) and the b vector. Both are not modified.
+// output: the x vector solution.
+// error: if the system is singular (or nearly singular) and can't be solved.
+func solveLinearSystem(a [][]float64, b []float64) (x []float64, err error) {
+	size := len(b)
+	if len(a) != size {
+		return x, errors.New("linear system size mismatch")
+	}
+
+	// copies matrix and vector into an augmented matrix to keep the inputs unmodified
+	augmented := make([][]float64, size)
+	for i := 0; i < size; i++ {
+		if len(a[i]) != size {
+			return x, errors.New("linear system matrix is not square")
+		}
+		augmented[i] = make([]float64, size+1)
+		copy(augmented[i], a[i])
+		augmented[i][size] = b[i]
+	}
+
+	// forward elimination
+	for col := 0; col < size; col++ {
+		// selects the row with the biggest pivot to keep numerical stability
+		pivotRow := col
+		for row := col + 1; row < size; row++ {
+			if math.Abs(augmented[row][col]) > math.Abs(augmented[pivotRow][col]) {
+				pivotRow = row
+			}
+		}
+		if math.Abs(augmented[pivotRow][col]) < SINGULAR_PIVOT_TOLERANCE {
+			return x, errors.New("linear system is singular")
+		}
+		augmented[col], augmented[pivotRow] = augmented[pivotRow], augmented[col]
+
+		for row := col + 1; row < size; row++ {
+			factor := augmented[row][col] / augmented[col][col]
+			for k := col; k <= size; k++ {
+				augmented[row][k] -= factor * augmented[col][k]
+			}
+		}
+	}
+
+	// back substitution
+	x = make([]float64, size)
+	for row := size - 1; row >= 0; row-- {
+		sum := augmented[row][size]
+		for k := row + 1; k < size; k++ {
+			sum -= augmented[row][k] * x[k]
+		}
+		x[row] = sum / augmented[row][row]
+	}
+	return x, nil
+}
+
+// Builds the normal equations (At*A)*x = At*b of an overdetermined system A*x = b.
+// input: the A matrix (as rows, one per equation) and the b vector.
+// output: the square matrix At*A and the vector At*b.
+func normalEquations(a [][]float64, b []float64) (ata [][]float64, atb []float64) {
+	if len(a) == 0 {
+		return ata, atb
+	}
+	cols := len(a[0])
+	ata = make([][]float64, cols)
+	atb = make([]float64, cols)
+	for i := 0; i < cols; i++ {
+		ata[i] = make([]float64, cols)
+		for j := 0; j < cols; j++ {
+			for row := range a {
+				ata[i][j] += a[row][i] * a[row][j]
+			}
+		}
+		for row := range a {
+			atb[i] += a[row][i] * b[row]
+		}
+	}
+	return ata, atb
+}
+
+// Calculates the inverse of a square matrix, solving a linear system for each identity column.
+// input: the square matrix (as rows). It's not modified.
+// output: the inverse matrix.
+// error: if the matrix is singular.
+func invertMatrix(a [][]float64) (inverse [][]float64, err error) {
+	size := len(a)
+	inverse = make([][]float64, size)
+	for i := 0; i < size; i++ {
+		inverse[i] = make([]float64, size)
+	}
+	for col := 0; col < size; col++ {
+		identityCol := make([]float64, size)
+		identityCol[col] = 1
+		solution, solveErr := solveLinearSystem(a, identityCol)
+		if solveErr != nil {
+			return nil, solveErr
+		}
+		for row := 0; row < size; row++ {
+			inverse[row][col] = solution[row]
+		}
+	}
+	return inverse, nil
+}
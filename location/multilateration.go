@@ -0,0 +1,212 @@
+package location
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/mgironi/operation-fire-quasar/model"
+)
+
+// Defines the dimensions count to solve a location in the plane (X and Y).
+const PLANE_DIMENSIONS int = 2
+
+// Defines the dimensions count to solve a location in the space (X, Y and Z).
+const SPACE_DIMENSIONS int = 3
+
+// Defines the max iterations count for the Gauss-Newton refinement.
+const MULTILATERATION_MAX_ITERATIONS int = 50
+
+// Defines the step size under which the Gauss-Newton refinement is considered converged.
+const MULTILATERATION_CONVERGENCE_TOLERANCE float64 = 1e-9
+
+// Calculates location by multilateration math method, using every distance to the points coordinates.
+//
+// The method has two stages. First an initial position is obtained by linearized least squares, subtracting
+// the first circle ecuation to the others:
+//
+// 2*(xi-x1)*x + 2*(yi-y1)*y = r1^2 - ri^2 + xi^2 + yi^2 - x1^2 - y1^2
+//
+// Then the position is refined with Gauss-Newton iterations minimizing the sum of the squared range residuals:
+//
+// fi(x,y) = sqrt((x-xi)^2 + (y-yi)^2) - ri
+//
+// input: the distances to the points coordinates (3 at least). The location is in the XY plane (Z=0), so the distances to points with altitude are converted to horizontal ones, see ProjectDistancesToPlane.
+// output: x and y calculated location coordinates and the residual of each distance (calculated distance - given distance)
+// error: if there is not enough distances or the points coordinates geometry can't be solved.
+//
+// For more information please see https://en.wikipedia.org/wiki/True-range_multilateration
+func CalculateLocationByMultilateration(distances []float32, pointsCoordinates []model.Point) (x, y float32, residuals []float64, err error) {
+	return CalculateLocationByWeightedMultilateration(distances, nil, pointsCoordinates)
+}
+
+// Calculates location by weighted multilateration, each squared range residual is weighted by the inverse of the
+// distance variance in the Gauss-Newton iterations, so the noisier distances count less.
+// See also CalculateLocationByMultilateration.
+// input: the distances, its standard deviations (nil for equal weights, see ResolveStdDevs) and the points coordinates (3 at least).
+// output: x and y calculated location coordinates and the residual of each distance (calculated distance - given distance)
+// error: if there is not enough distances or the points coordinates geometry can't be solved.
+func CalculateLocationByWeightedMultilateration(distances []float32, stdDevs []float64, pointsCoordinates []model.Point) (x, y float32, residuals []float64, err error) {
+	if len(distances) != len(pointsCoordinates) {
+		return 0, 0, residuals, fmt.Errorf("distances and Points coordinates has diferent sizes. Distances: %d, PointsCoord: %d", len(distances), len(pointsCoordinates))
+	}
+	if len(distances) < 3 {
+		return 0, 0, residuals, fmt.Errorf("not enough distances to apply multilateration. Distances: %d, need 3 at least", len(distances))
+	}
+	distances, stdDevs, pointsCoordinates, err = ProjectDistancesToPlane(distances, stdDevs, pointsCoordinates)
+	if err != nil {
+		return 0, 0, residuals, err
+	}
+
+	// gets initial position by linearized least squares
+	position, linErr := linearizedLeastSquares(distances, pointsCoordinates, PLANE_DIMENSIONS)
+	if linErr != nil {
+		return 0, 0, residuals, fmt.Errorf("can't calculate initial position. %s", linErr.Error())
+	}
+
+	// refines position by Gauss-Newton iterations
+	position = refineByGaussNewton(position, distances, pointsCoordinates, weightsOf(distances, stdDevs), PLANE_DIMENSIONS)
+
+	residuals = calculateResiduals(position, distances, pointsCoordinates)
+	return float32(position.X), float32(position.Y), residuals, nil
+}
+
+// Calculates location in the space (X, Y and Z) by multilateration math method, with spheres instead of circles.
+// See also CalculateLocationByMultilateration, the ecuations are the same including the Z terms:
+//
+// 2*(xi-x1)*x + 2*(yi-y1)*y + 2*(zi-z1)*z = r1^2 - ri^2 + xi^2 + yi^2 + zi^2 - x1^2 - y1^2 - z1^2
+//
+// input: the distances to the points coordinates (4 at least, not coplanar)
+// output: the calculated location and the residual of each distance (calculated distance - given distance)
+// error: if there is not enough distances or the points coordinates geometry can't be solved.
+func CalculateLocationByMultilateration3D(distances []float32, pointsCoordinates []model.Point) (position model.Point, residuals []float64, err error) {
+	return CalculateLocationByWeightedMultilateration3D(distances, nil, pointsCoordinates)
+}
+
+// Calculates location in the space by weighted multilateration. See CalculateLocationByWeightedMultilateration.
+// input: the distances, its standard deviations (nil for equal weights) and the points coordinates (4 at least, not coplanar).
+// output: the calculated location and the residual of each distance (calculated distance - given distance)
+// error: if there is not enough distances or the points coordinates geometry can't be solved.
+func CalculateLocationByWeightedMultilateration3D(distances []float32, stdDevs []float64, pointsCoordinates []model.Point) (position model.Point, residuals []float64, err error) {
+	if len(distances) != len(pointsCoordinates) {
+		return position, residuals, fmt.Errorf("distances and Points coordinates has diferent sizes. Distances: %d, PointsCoord: %d", len(distances), len(pointsCoordinates))
+	}
+	if len(distances) < 4 {
+		return position, residuals, fmt.Errorf("not enough distances to apply multilateration in the space. Distances: %d, need 4 at least", len(distances))
+	}
+
+	// gets initial position by linearized least squares
+	position, linErr := linearizedLeastSquares(distances, pointsCoordinates, SPACE_DIMENSIONS)
+	if linErr != nil {
+		return position, residuals, fmt.Errorf("can't calculate initial position. %s", linErr.Error())
+	}
+
+	// refines position by Gauss-Newton iterations
+	position = refineByGaussNewton(position, distances, pointsCoordinates, weightsOf(distances, stdDevs), SPACE_DIMENSIONS)
+
+	residuals = calculateResiduals(position, distances, pointsCoordinates)
+	return position, residuals, nil
+}
+
+// Calculates position solving the linearized circles (or spheres) ecuations system by least squares.
+// input: the distances to the points coordinates and the dimensions to solve (2: plane, 3: space).
+// output: the position.
+// error: if the ecuations system is singular.
+func linearizedLeastSquares(distances []float32, pointsCoordinates []model.Point, dimensions int) (position model.Point, err error) {
+	p1 := coordinatesOf(pointsCoordinates[0], dimensions)
+	r1 := float64(distances[0])
+
+	rows := len(pointsCoordinates) - 1
+	a := make([][]float64, rows)
+	b := make([]float64, rows)
+	for i := 1; i < len(pointsCoordinates); i++ {
+		pi := coordinatesOf(pointsCoordinates[i], dimensions)
+		ri := float64(distances[i])
+		a[i-1] = make([]float64, dimensions)
+		b[i-1] = math.Pow(r1, 2) - math.Pow(ri, 2)
+		for k := 0; k < dimensions; k++ {
+			a[i-1][k] = 2 * (pi[k] - p1[k])
+			b[i-1] += math.Pow(pi[k], 2) - math.Pow(p1[k], 2)
+		}
+	}
+
+	ata, atb := normalEquations(a, b)
+	solution, solveErr := solveLinearSystem(ata, atb)
+	if solveErr != nil {
+		return position, solveErr
+	}
+	return pointOf(solution), nil
+}
+
+// Refines position by Gauss-Newton iterations over the range residuals.
+// input: the initial position, the distances and points coordinates, the weight of each distance (nil for equal weights)
+// and the dimensions to solve (2: plane, 3: space).
+// output: the refined position (the initial one if can't be refined).
+func refineByGaussNewton(initial model.Point, distances []float32, pointsCoordinates []model.Point, weights []float64, dimensions int) (position model.Point) {
+	position = initial
+	for iteration := 0; iteration < MULTILATERATION_MAX_ITERATIONS; iteration++ {
+		jacobian := make([][]float64, len(pointsCoordinates))
+		negResiduals := make([]float64, len(pointsCoordinates))
+		positionCoords := coordinatesOf(position, dimensions)
+		for i, pt := range pointsCoordinates {
+			calcDistance := position.DistanceToPoint(pt)
+			if calcDistance == 0 {
+				// position over a reference point, the derivative isn't defined
+				return position
+			}
+			// weights the ecuation scaling it by the square root of the weight
+			weightFactor := float64(1)
+			if weights != nil {
+				weightFactor = math.Sqrt(weights[i])
+			}
+			ptCoords := coordinatesOf(pt, dimensions)
+			jacobian[i] = make([]float64, dimensions)
+			for k := 0; k < dimensions; k++ {
+				jacobian[i][k] = weightFactor * (positionCoords[k] - ptCoords[k]) / calcDistance
+			}
+			negResiduals[i] = weightFactor * (float64(distances[i]) - calcDistance)
+		}
+
+		jtj, jtr := normalEquations(jacobian, negResiduals)
+		step, solveErr := solveLinearSystem(jtj, jtr)
+		if solveErr != nil {
+			return position
+		}
+
+		stepSize := float64(0)
+		for k := 0; k < dimensions; k++ {
+			positionCoords[k] += step[k]
+			stepSize += math.Pow(step[k], 2)
+		}
+		position = pointOf(positionCoords)
+		if math.Sqrt(stepSize) < MULTILATERATION_CONVERGENCE_TOLERANCE {
+			break
+		}
+	}
+	return position
+}
+
+// Gets the point coordinates as a list for the given dimensions (2: X and Y, 3: X, Y and Z).
+func coordinatesOf(pt model.Point, dimensions int) []float64 {
+	if dimensions == SPACE_DIMENSIONS {
+		return []float64{pt.X, pt.Y, pt.Z}
+	}
+	return []float64{pt.X, pt.Y}
+}
+
+// Gets the point of a coordinates list (2 or 3 coordinates). See also coordinatesOf.
+func pointOf(coordinates []float64) (pt model.Point) {
+	pt = model.Point{X: coordinates[0], Y: coordinates[1]}
+	if len(coordinates) == SPACE_DIMENSIONS {
+		pt.Z = coordinates[2]
+	}
+	return pt
+}
+
+// Calculates the residual of each distance to the position (calculated distance - given distance).
+func calculateResiduals(position model.Point, distances []float32, pointsCoordinates []model.Point) (residuals []float64) {
+	residuals = make([]float64, len(pointsCoordinates))
+	for i, pt := range pointsCoordinates {
+		residuals[i] = position.DistanceToPoint(pt) - float64(distances[i])
+	}
+	return residuals
+}
@@ -0,0 +1,204 @@
+package location_test
+
+import (
+	"errors"
+	"math"
+	"os"
+	"testing"
+
+	test "github.com/mgironi/operation-fire-quasar/_test"
+	"github.com/mgironi/operation-fire-quasar/location"
+	"github.com/mgironi/operation-fire-quasar/model"
+	"github.com/mgironi/operation-fire-quasar/store"
+)
+
+// Tests CalculateLocationByMultilateration
+func TestCalculateLocationByMultilateration(t *testing.T) {
+	pointsCoordinates := []model.Point{{X: -500, Y: -200}, {X: 100, Y: -100}, {X: 500, Y: 100}, {X: 0, Y: 500}, {X: -300, Y: -600}}
+
+	tests := []struct {
+		name    string
+		want    model.Point
+		noise   []float32
+		wantErr bool
+	}{
+		{name: "test1", want: model.Point{X: -200, Y: 200}, noise: []float32{0, 0, 0, 0, 0}, wantErr: false},
+		{name: "test2", want: model.Point{X: 1000, Y: 1000}, noise: []float32{0, 0, 0, 0, 0}, wantErr: false},
+		{name: "test3", want: model.Point{X: 300, Y: -700}, noise: []float32{0.3, -0.2, 0.1, -0.3, 0.2}, wantErr: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			distances := make([]float32, len(pointsCoordinates))
+			for i, pt := range pointsCoordinates {
+				distances[i] = float32(pt.DistanceToPoint(tt.want)) + tt.noise[i]
+			}
+
+			gotX, gotY, residuals, err := location.CalculateLocationByMultilateration(distances, pointsCoordinates)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CalculateLocationByMultilateration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(residuals) != len(pointsCoordinates) {
+				t.Fatalf("CalculateLocationByMultilateration() residuals size is %d, want %d", len(residuals), len(pointsCoordinates))
+			}
+
+			// the tolerance is the noise level applied to the distances
+			tolerance := 0.5
+			if math.Abs(float64(gotX)-tt.want.X) > tolerance || math.Abs(float64(gotY)-tt.want.Y) > tolerance {
+				t.Errorf("CalculateLocationByMultilateration() is (%f, %f), want (%f, %f) +/- %f", gotX, gotY, tt.want.X, tt.want.Y, tolerance)
+			}
+			for i, residual := range residuals {
+				if math.Abs(residual) > tolerance {
+					t.Errorf("CalculateLocationByMultilateration() residual %d is %f, want less than %f", i, residual, tolerance)
+				}
+			}
+		})
+	}
+}
+
+// Tests CalculateLocationByMultilateration with not enough distances
+func TestCalculateLocationByMultilaterationNotEnoughDistances(t *testing.T) {
+	pointsCoordinates := []model.Point{{X: -500, Y: -200}, {X: 100, Y: -100}}
+	_, _, _, err := location.CalculateLocationByMultilateration([]float32{100, 200}, pointsCoordinates)
+	if err == nil {
+		t.Error("CalculateLocationByMultilateration() error was expected with 2 distances")
+	}
+}
+
+// Tests CalculateLocation using additional satellites configured by environment
+func TestCalculateLocationWithExtraSatellites(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	os.Setenv(store.SATELITES_EXTRA_ENV, "rex_0,500;cody_-300,-600")
+	store.InitializeSatelitesInfo()
+	defer func() {
+		test.CleanSatelitesInfoEnvs()
+		store.InitializeSatelitesInfo()
+	}()
+
+	want := model.Point{X: -200, Y: 200}
+	pointsCoordinates := store.GetKnownReferenceCoordinates()
+	if len(pointsCoordinates) != 5 {
+		t.Fatalf("Known reference coordinates size is %d, want 5", len(pointsCoordinates))
+	}
+	distances := make([]float32, len(pointsCoordinates))
+	for i, pt := range pointsCoordinates {
+		distances[i] = float32(pt.DistanceToPoint(want))
+	}
+
+	gotX, gotY, err := location.CalculateLocation(distances)
+	if err != nil {
+		t.Fatalf("CalculateLocation() error = %v", err)
+	}
+	if !location.HasTorableDiffByDynamicScale(want.X, float64(gotX), model.FLOAT_COMPARISION_TOLERANCE) || !location.HasTorableDiffByDynamicScale(want.Y, float64(gotY), model.FLOAT_COMPARISION_TOLERANCE) {
+		t.Errorf("CalculateLocation() is (%f, %f), want (%f, %f)", gotX, gotY, want.X, want.Y)
+	}
+}
+
+// Tests CalculateLocationByMultilateration3D
+func TestCalculateLocationByMultilateration3D(t *testing.T) {
+	pointsCoordinates := []model.Point{{X: -500, Y: -200, Z: 0}, {X: 100, Y: -100, Z: 300}, {X: 500, Y: 100, Z: 0}, {X: 0, Y: 500, Z: 1200}, {X: -300, Y: -600, Z: 800}}
+
+	tests := []struct {
+		name  string
+		want  model.Point
+		noise []float32
+	}{
+		{name: "test1", want: model.Point{X: -200, Y: 200, Z: 300}, noise: []float32{0, 0, 0, 0, 0}},
+		{name: "test2", want: model.Point{X: 300, Y: -700, Z: -150}, noise: []float32{0, 0, 0, 0, 0}},
+		{name: "test3", want: model.Point{X: 100, Y: 100, Z: 500}, noise: []float32{0.3, -0.2, 0.1, -0.3, 0.2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			distances := make([]float32, len(pointsCoordinates))
+			for i, pt := range pointsCoordinates {
+				distances[i] = float32(pt.DistanceToPoint(tt.want)) + tt.noise[i]
+			}
+
+			got, residuals, err := location.CalculateLocationByMultilateration3D(distances, pointsCoordinates)
+			if err != nil {
+				t.Fatalf("CalculateLocationByMultilateration3D() error = %v", err)
+			}
+
+			// the tolerance is the noise level applied to the distances, amplified by the geometry
+			tolerance := 2.0
+			if got.DistanceToPoint(tt.want) > tolerance {
+				t.Errorf("CalculateLocationByMultilateration3D() is %s, want %s +/- %f", got, tt.want, tolerance)
+			}
+			for i, residual := range residuals {
+				if math.Abs(residual) > tolerance {
+					t.Errorf("CalculateLocationByMultilateration3D() residual %d is %f, want less than %f", i, residual, tolerance)
+				}
+			}
+		})
+	}
+}
+
+// Tests CalculatePosition with altitude-aware satellites, in the space and with the fallback to the plane
+func TestCalculatePositionWithAltitudeSatellites(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	defer func() {
+		test.CleanSatelitesInfoEnvs()
+		store.InitializeSatelitesInfo()
+	}()
+
+	// default satellites, in the plane
+	store.InitializeSatelitesInfo()
+	_, spatial, err := location.CalculatePosition([]float32{500, 424.26, 707.10})
+	if err != nil || spatial {
+		t.Fatalf("CalculatePosition() with default satellites spatial = %t, error = %v, want planar location", spatial, err)
+	}
+
+	// additional satellites with altitude, in the space
+	os.Setenv(store.SATELITES_EXTRA_ENV, "rex_0,500,1200;cody_-300,-600,800")
+	store.InitializeSatelitesInfo()
+	want := model.Point{X: -200, Y: 200, Z: 300}
+	pointsCoordinates := store.GetKnownReferenceCoordinates()
+	distances := make([]float32, len(pointsCoordinates))
+	for i, pt := range pointsCoordinates {
+		distances[i] = float32(pt.DistanceToPoint(want))
+	}
+
+	got, spatial, err := location.CalculatePosition(distances)
+	if err != nil || !spatial {
+		t.Fatalf("CalculatePosition() with altitude satellites spatial = %t, error = %v, want spatial location", spatial, err)
+	}
+	if got.DistanceToPoint(want) > 0.1 {
+		t.Errorf("CalculatePosition() is %s, want %s", got, want)
+	}
+}
+
+// Tests the location in the XY plane with satellites at different altitudes, the emitter is at Z=0
+func TestCalculatePlanarLocationWithAltitudeSatellites(t *testing.T) {
+	pointsCoordinates := []model.Point{{X: -500, Y: -200, Z: 0}, {X: 100, Y: -100, Z: 300}, {X: 500, Y: 100, Z: 800}}
+	want := model.Point{X: -200, Y: 200}
+	distances := make([]float32, len(pointsCoordinates))
+	for i, pt := range pointsCoordinates {
+		distances[i] = float32(pt.DistanceToPoint(want))
+	}
+
+	gotX, gotY, err := location.CalculatePlanarLocation(distances, nil, pointsCoordinates, location.DefaultThresholds())
+	if err != nil {
+		t.Fatalf("CalculatePlanarLocation() error = %v", err)
+	}
+	if (model.Point{X: float64(gotX), Y: float64(gotY)}).DistanceToPoint(want) > 0.1 {
+		t.Errorf("CalculatePlanarLocation() is (%f, %f), want %s", gotX, gotY, want)
+	}
+
+	for _, solverName := range []string{location.TRILATERATION_SOLVER, location.LEAST_SQUARES_SOLVER, location.ROBUST_SOLVER} {
+		solver, _ := location.GetSolver(solverName)
+		solution, err := solver.Solve(distances, nil, pointsCoordinates, location.DefaultThresholds())
+		if err != nil {
+			t.Fatalf("%s Solve() error = %v", solverName, err)
+		}
+		if solution.Position.DistanceToPoint(want) > 0.1 {
+			t.Errorf("%s Solve() is %s, want %s", solverName, solution.Position, want)
+		}
+	}
+
+	// a distance shorter than its satellite altitude can't reach the plane
+	distances[2] = 700
+	_, _, err = location.CalculatePlanarLocation(distances, nil, pointsCoordinates, location.DefaultThresholds())
+	var rangeErr location.RangeBelowAltitudeError
+	if !errors.As(err, &rangeErr) || rangeErr.Index != 2 {
+		t.Errorf("CalculatePlanarLocation() error = %v, want RangeBelowAltitudeError for distance 2", err)
+	}
+}
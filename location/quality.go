@@ -0,0 +1,176 @@
+package location
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/mgironi/operation-fire-quasar/model"
+	"github.com/mgironi/operation-fire-quasar/support"
+)
+
+// Defines the minimum standard deviation of a distance, prevents dividing by zero with null distances.
+const MIN_STD_DEV float64 = model.FLOAT_COMPARISION_TOLERANCE
+
+// Defines the standard deviation of a distance measured with a signal to noise ratio of 0 dB (coordinates units).
+const SNR_REFERENCE_STD_DEV float64 = 10
+
+// Defines the max iterations count for the incomplete gamma function series and continued fraction.
+const GAMMA_MAX_ITERATIONS int = 200
+
+// Defines the relative precision of the incomplete gamma function series and continued fraction.
+const GAMMA_PRECISION float64 = 1e-14
+
+// Gets the standard deviation of a distance measured with the given signal to noise ratio.
+// The ranging standard deviation is inversely proportional to the square root of the SNR (linear), so
+// sigma = SNR_REFERENCE_STD_DEV / sqrt(10^(snr/10)).
+// input: the signal to noise ratio in dB.
+// output: the distance standard deviation (coordinates units).
+func StdDevFromSNR(snr float64) float64 {
+	return SNR_REFERENCE_STD_DEV / math.Sqrt(math.Pow(10, snr/10))
+}
+
+// Gets the standard deviation of each distance, the given one or the relative one if unknown.
+// input: the distances, its standard deviations (nil or not positive values if unknown) and the standard deviation
+// relative to the distance (see support.RelativeStdDev).
+// output: the standard deviation of each distance.
+func ResolveStdDevs(distances []float32, stdDevs []float64, relativeStdDev float64) (resolved []float64) {
+	resolved = make([]float64, len(distances))
+	for i, distance := range distances {
+		if i < len(stdDevs) && stdDevs[i] > 0 {
+			resolved[i] = stdDevs[i]
+			continue
+		}
+		resolved[i] = math.Max(relativeStdDev*math.Abs(float64(distance)), MIN_STD_DEV)
+	}
+	return resolved
+}
+
+// Checks if some standard deviation is known (positive).
+func hasStdDevs(stdDevs []float64) bool {
+	for _, stdDev := range stdDevs {
+		if stdDev > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Gets the weight of each distance, the inverse of its variance. See ResolveStdDevs.
+// The unknown standard deviations take the default relative one, the solvers resolve them before (see CalculateLocationWithSolver).
+// output: the weights, nil if every standard deviation is unknown (equal weights).
+func weightsOf(distances []float32, stdDevs []float64) (weights []float64) {
+	if !hasStdDevs(stdDevs) {
+		return nil
+	}
+	resolved := ResolveStdDevs(distances, stdDevs, support.DEFAULT_RELATIVE_STD_DEV)
+	weights = make([]float64, len(resolved))
+	for i, stdDev := range resolved {
+		weights[i] = 1 / math.Pow(stdDev, 2)
+	}
+	return weights
+}
+
+// Calculates the chi-square test of the position residuals, normalized by the distances standard deviations.
+//
+// If the distances errors are independent and normally distributed, the sum of the squared normalized residuals
+//
+//	T = sum((|P - Pi| - ri)^2 / sigmai^2)
+//
+// follows a chi-square distribution with n - dimensions degrees of freedom (1 at least). The p-value is the
+// probability of a statistic as big as T with a right location.
+//
+// input: the distances, its standard deviations (nil for unknown, see ResolveStdDevs), the points coordinates,
+// the position to check, the dimensions solved (2: plane, 3: space) and the relative standard deviation of the unknown ones.
+// output: the statistic T and its p-value.
+// error: if distances and points coordinates have different sizes.
+func ChecksChiSquare(distances []float32, stdDevs []float64, pointsCoordinates []model.Point, position model.Point, dimensions int, relativeStdDev float64) (statistic float64, pValue float64, err error) {
+	if len(distances) != len(pointsCoordinates) {
+		return 0, 0, fmt.Errorf("can't check distances with position. Distances and Points coordinates has diferent sizes. Distances: %d, PointsCoord: %d", len(distances), len(pointsCoordinates))
+	}
+
+	resolved := ResolveStdDevs(distances, stdDevs, relativeStdDev)
+	for i, residual := range calculateResiduals(position, distances, pointsCoordinates) {
+		statistic += math.Pow(residual/resolved[i], 2)
+	}
+	degreesOfFreedom := int(math.Max(float64(len(distances)-dimensions), 1))
+	return statistic, chiSquareSurvival(statistic, degreesOfFreedom), nil
+}
+
+// Checks if the position residuals pass the chi-square test at the thresholds significance level. See ChecksChiSquare.
+// error1: if the test can't be calculated.
+// error2: if the residuals are too big for the distances standard deviations.
+func checksAcceptableResiduals(distances []float32, stdDevs []float64, pointsCoordinates []model.Point, position model.Point, dimensions int, thresholds Thresholds) (err error) {
+	statistic, pValue, err := ChecksChiSquare(distances, stdDevs, pointsCoordinates, position, dimensions, thresholds.RelativeStdDev)
+	if err != nil {
+		log.Print(err)
+		return err
+	}
+	if math.IsNaN(statistic) || pValue < thresholds.Significance {
+		errMsg := fmt.Sprintf("residuals chi-square test failed at significance %g. Statistic ~ %.4f, p-value ~ %.6f", thresholds.Significance, statistic, pValue)
+		log.Printf("WARN %s", errMsg)
+		return errors.New(errMsg)
+	}
+	return nil
+}
+
+// Gets the probability of a chi-square statistic bigger than the given one, with the given degrees of freedom.
+// It's the complement of the regularized lower incomplete gamma function, 1 - P(k/2, x/2).
+func chiSquareSurvival(statistic float64, degreesOfFreedom int) float64 {
+	if statistic <= 0 {
+		return 1
+	}
+	if math.IsInf(statistic, 1) {
+		return 0
+	}
+	return 1 - regularizedGammaP(float64(degreesOfFreedom)/2, statistic/2)
+}
+
+// Calculates the regularized lower incomplete gamma function P(a, x).
+// Uses the series expansion for x < a+1, and the continued fraction (Lentz method) of Q(a, x) = 1 - P(a, x) otherwise.
+func regularizedGammaP(a, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	logGammaA, _ := math.Lgamma(a)
+	prefactor := math.Exp(-x + a*math.Log(x) - logGammaA)
+
+	if x < a+1 {
+		term := 1 / a
+		sum := term
+		for n := 1; n < GAMMA_MAX_ITERATIONS; n++ {
+			term *= x / (a + float64(n))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*GAMMA_PRECISION {
+				break
+			}
+		}
+		return sum * prefactor
+	}
+
+	const tiny = 1e-300
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for n := 1; n < GAMMA_MAX_ITERATIONS; n++ {
+		an := -float64(n) * (float64(n) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < GAMMA_PRECISION {
+			break
+		}
+	}
+	return 1 - prefactor*h
+}
@@ -0,0 +1,94 @@
+package location_test
+
+import (
+	"math"
+	"testing"
+
+	test "github.com/mgironi/operation-fire-quasar/_test"
+	"github.com/mgironi/operation-fire-quasar/location"
+	"github.com/mgironi/operation-fire-quasar/model"
+	"github.com/mgironi/operation-fire-quasar/support"
+)
+
+// Tests ChecksChiSquare statistic and p-value with known chi-square distribution values
+func TestChecksChiSquare(t *testing.T) {
+	points := []model.Point{{X: -500, Y: -200}, {X: 100, Y: -100}, {X: 500, Y: 100}, {X: 0, Y: 500}}
+	position := model.Point{X: -200, Y: 200}
+
+	tests := []struct {
+		name          string
+		bias          []float32
+		stdDevs       []float64
+		wantStatistic float64
+		wantPValue    float64
+	}{
+		{name: "exact", bias: []float32{0, 0, 0, 0}, stdDevs: []float64{1, 1, 1, 1}, wantStatistic: 0, wantPValue: 1},
+		// two degrees of freedom, the survival function is exp(-x/2)
+		{name: "twoDegrees", bias: []float32{2, 0, 0, 0}, stdDevs: []float64{1, 1, 1, 1}, wantStatistic: 4, wantPValue: math.Exp(-2)},
+		{name: "weighted", bias: []float32{2, 0, 0, 0}, stdDevs: []float64{2, 1, 1, 1}, wantStatistic: 1, wantPValue: math.Exp(-0.5)},
+		// the 95% quantile with two degrees of freedom
+		{name: "quantile", bias: []float32{0, 0, 0, float32(math.Sqrt(5.991465))}, stdDevs: []float64{1, 1, 1, 1}, wantStatistic: 5.991465, wantPValue: 0.05},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			distances := make([]float32, len(points))
+			for i, pt := range points {
+				// negative bias, so the residual (calculated - given) is the bias
+				distances[i] = float32(pt.DistanceToPoint(position)) - tt.bias[i]
+			}
+			statistic, pValue, err := location.ChecksChiSquare(distances, tt.stdDevs, points, position, location.PLANE_DIMENSIONS, support.DEFAULT_RELATIVE_STD_DEV)
+			if err != nil {
+				t.Fatalf("ChecksChiSquare() error = %v", err)
+			}
+			if math.Abs(statistic-tt.wantStatistic) > 1e-3 {
+				t.Errorf("ChecksChiSquare() statistic = %f, want %f", statistic, tt.wantStatistic)
+			}
+			if math.Abs(pValue-tt.wantPValue) > 1e-4 {
+				t.Errorf("ChecksChiSquare() p-value = %f, want %f", pValue, tt.wantPValue)
+			}
+		})
+	}
+}
+
+// Tests StdDevFromSNR and ResolveStdDevs
+func TestStdDevs(t *testing.T) {
+	if got := location.StdDevFromSNR(20); !test.AreFloatsEquals(got, location.SNR_REFERENCE_STD_DEV/10) {
+		t.Errorf("StdDevFromSNR(20) = %f, want %f", got, location.SNR_REFERENCE_STD_DEV/10)
+	}
+
+	got := location.ResolveStdDevs([]float32{500, 1000, 0}, []float64{2, 0}, 0.01)
+	want := []float64{2, 1000 * 0.01, location.MIN_STD_DEV}
+	for i := range want {
+		if !test.AreFloatsEquals(got[i], want[i]) {
+			t.Errorf("ResolveStdDevs() = %v, want %v", got, want)
+			break
+		}
+	}
+}
+
+// Tests CalculateLocationByWeightedMultilateration with a noisy distance
+func TestCalculateLocationByWeightedMultilateration(t *testing.T) {
+	points := []model.Point{{X: -500, Y: -200}, {X: 100, Y: -100}, {X: 500, Y: 100}, {X: 0, Y: 500}}
+	position := model.Point{X: -200, Y: 200}
+	bias := []float32{0, 0, 0, 30}
+	distances := make([]float32, len(points))
+	for i, pt := range points {
+		distances[i] = float32(pt.DistanceToPoint(position)) + bias[i]
+	}
+
+	x, y, _, err := location.CalculateLocationByMultilateration(distances, points)
+	if err != nil {
+		t.Fatalf("CalculateLocationByMultilateration() error = %v", err)
+	}
+	unweightedError := position.DistanceToPoint(model.Point{X: float64(x), Y: float64(y)})
+
+	// the noisy distance counts less
+	x, y, _, err = location.CalculateLocationByWeightedMultilateration(distances, []float64{0.1, 0.1, 0.1, 30}, points)
+	if err != nil {
+		t.Fatalf("CalculateLocationByWeightedMultilateration() error = %v", err)
+	}
+	weightedError := position.DistanceToPoint(model.Point{X: float64(x), Y: float64(y)})
+	if weightedError >= unweightedError/10 {
+		t.Errorf("CalculateLocationByWeightedMultilateration() error = %f, want less than a tenth of the unweighted one %f", weightedError, unweightedError)
+	}
+}
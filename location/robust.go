@@ -0,0 +1,266 @@
+package location
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/mgironi/operation-fire-quasar/model"
+	"github.com/mgironi/operation-fire-quasar/store"
+	"github.com/mgironi/operation-fire-quasar/support"
+
+	"github.com/montanaflynn/stats"
+)
+
+// Defines the Huber loss tuning constant (in scale units). Residuals under it keep full weight.
+const HUBER_TUNING_CONSTANT float64 = 1.345
+
+// Defines the max iterations count for the Huber iteratively reweighted least squares.
+const HUBER_MAX_ITERATIONS int = 20
+
+// Defines the Huber weight under which a distance is considered rejected.
+const HUBER_REJECTION_WEIGHT float64 = 0.5
+
+// Defines the factor to estimate the standard deviation from the median absolute deviation (for normal distribution).
+const MAD_TO_STANDARD_DEVIATION float64 = 1.4826
+
+// Robust location calculation result.
+type RobustLocation struct {
+	X float32
+	Y float32
+	// indexes of the rejected distances (satellites), in rejection order
+	Rejected []int
+	// indexes of the distances (satellites) used to calculate the location
+	Inliers []int
+}
+
+// input: distance to the transmitter recieved on each satlelite
+// output: the coordinates 'x' and 'y' of the message emiter and the names of the satellites rejected as faulty
+func GetRobustLocation(distances ...float32) (x, y float32, rejected []string) {
+	result, err := CalculateLocationRobust(distances)
+	if err != nil {
+		log.Printf("Is no possible to compelete calculations. %s", err.Error())
+		return 0, 0, rejected
+	}
+	satellitesInfo := store.GetSatellitesInfo()
+	for _, satIdx := range result.Rejected {
+		rejected = append(rejected, satellitesInfo[satIdx].Name)
+	}
+	return result.X, result.Y, rejected
+}
+
+// Calculates coordinates location tolerating a faulty distance.
+// See also CalculateLocationByRobustMultilateration.
+// input: Recieves distances array to a known coordinates.
+// output: Returns the location calculated with the consistent distances and the rejected ones.
+// error: in case calculation couldn't be done.
+func CalculateLocationRobust(distances []float32) (result RobustLocation, err error) {
+	// gets reference points coordinates
+	pointsCoordinates := store.GetKnownReferenceCoordinates()
+
+	// checks if distances has same amount of elements that the refences points coordiantes.
+	if len(distances) != len(pointsCoordinates) {
+		return result, fmt.Errorf("distances and Points coordinates has diferent sizes. Distances: %d, PointsCoord: %d", len(distances), len(pointsCoordinates))
+	}
+
+	// checks points coordinates geometry (projected to the plane), to prevent solving with a degenerate layout
+	geomErr := model.ValidateGeometry(model.ProjectPointsToPlane(pointsCoordinates))
+	if geomErr != nil {
+		log.Print(geomErr)
+		return result, geomErr
+	}
+
+	return CalculateLocationByRobustMultilateration(distances, pointsCoordinates)
+}
+
+// Calculates location by robust multilateration, identifying and excluding the faulty distances.
+//
+// With more than 3 distances applies leave-one-out over the subsets of points: while the location calculated with
+// the current subset is not acceptable, the point whose exclusion gives the most consistent subset (the smallest
+// relative residuals) is rejected. The process stops when the subset is acceptable or only 3 points are left.
+//
+// With 3 distances there is not enough redundancy to exclude one, so applies Huber weighted least squares
+// (iteratively reweighted), where the distances with big residuals lose weight. Those with a final weight under
+// HUBER_REJECTION_WEIGHT are reported as rejected. Note that with a single redundant distance the error of a
+// faulty one is usually spread over the others, so the faulty one can only be identified reliably with more than 3.
+//
+// input: the distances to the points coordinates (3 at least). The location is in the XY plane (Z=0), so the distances to points with altitude are converted to horizontal ones, see ProjectDistancesToPlane.
+// output: the location, the rejected distances indexes and the inliers indexes.
+// error: if there is not enough distances or no consistent subset was found.
+func CalculateLocationByRobustMultilateration(distances []float32, pointsCoordinates []model.Point) (result RobustLocation, err error) {
+	return CalculateLocationByWeightedRobustMultilateration(distances, nil, pointsCoordinates, DefaultThresholds())
+}
+
+// Calculates location by robust multilateration weighting each distance by the inverse of its variance.
+// See CalculateLocationByRobustMultilateration and CalculateLocationByWeightedMultilateration.
+// input: the distances, its standard deviations (nil for equal weights), the points coordinates (3 at least) and the
+// thresholds to accept the location.
+// output: the location, the rejected distances indexes and the inliers indexes.
+// error: if there is not enough distances or no consistent subset was found.
+func CalculateLocationByWeightedRobustMultilateration(distances []float32, stdDevs []float64, pointsCoordinates []model.Point, thresholds Thresholds) (result RobustLocation, err error) {
+	if len(distances) != len(pointsCoordinates) {
+		return result, fmt.Errorf("distances and Points coordinates has diferent sizes. Distances: %d, PointsCoord: %d", len(distances), len(pointsCoordinates))
+	}
+	if len(distances) < 3 {
+		return result, fmt.Errorf("not enough distances to apply robust multilateration. Distances: %d, need 3 at least", len(distances))
+	}
+	distances, stdDevs, pointsCoordinates, err = ProjectDistancesToPlane(distances, stdDevs, pointsCoordinates)
+	if err != nil {
+		return result, err
+	}
+
+	if len(stdDevs) != len(distances) {
+		stdDevs = make([]float64, len(distances))
+	}
+
+	if len(distances) == 3 {
+		return calculateLocationByHuber(distances, stdDevs, pointsCoordinates, thresholds)
+	}
+	return calculateLocationByLeaveOneOut(distances, stdDevs, pointsCoordinates, thresholds)
+}
+
+// Calculates location by iterative leave-one-out rejection. See CalculateLocationByRobustMultilateration.
+func calculateLocationByLeaveOneOut(distances []float32, stdDevs []float64, pointsCoordinates []model.Point, thresholds Thresholds) (result RobustLocation, err error) {
+	result.Inliers = make([]int, len(distances))
+	for i := range distances {
+		result.Inliers[i] = i
+	}
+
+	for {
+		subsetDistances, subsetStdDevs, subsetPoints := subsetOf(result.Inliers, distances, stdDevs, pointsCoordinates)
+		x, y, _, multErr := CalculateLocationByWeightedMultilateration(subsetDistances, subsetStdDevs, subsetPoints)
+		if multErr == nil && checksAcceptableResiduals(subsetDistances, subsetStdDevs, subsetPoints, model.Point{X: float64(x), Y: float64(y)}, PLANE_DIMENSIONS, thresholds) == nil {
+			result.X, result.Y = x, y
+			return result, nil
+		}
+
+		if len(result.Inliers) <= 3 {
+			return result, errors.New("can't find a consistent subset of distances")
+		}
+
+		// searchs for the point whose exclusion gives the most consistent subset
+		bestExcluded := -1
+		bestScore := math.Inf(1)
+		for k := range result.Inliers {
+			candidate := withoutIndex(result.Inliers, k)
+			candidateDistances, candidateStdDevs, candidatePoints := subsetOf(candidate, distances, stdDevs, pointsCoordinates)
+			if model.ValidateGeometry(candidatePoints) != nil {
+				continue
+			}
+			_, _, residuals, candidateErr := CalculateLocationByWeightedMultilateration(candidateDistances, candidateStdDevs, candidatePoints)
+			if candidateErr != nil {
+				continue
+			}
+			score := relativeRMS(residuals, candidateDistances, candidateStdDevs)
+			if score < bestScore {
+				bestScore = score
+				bestExcluded = k
+			}
+		}
+		if bestExcluded == -1 {
+			return result, errors.New("can't find a consistent subset of distances, every subset has a degenerate geometry")
+		}
+
+		log.Printf("WARN rejecting distance %d (%f) as inconsistent. Subset relative RMS ~ %.6f", result.Inliers[bestExcluded], distances[result.Inliers[bestExcluded]], bestScore)
+		result.Rejected = append(result.Rejected, result.Inliers[bestExcluded])
+		result.Inliers = withoutIndex(result.Inliers, bestExcluded)
+	}
+}
+
+// Calculates location by Huber iteratively reweighted least squares. See CalculateLocationByRobustMultilateration.
+func calculateLocationByHuber(distances []float32, stdDevs []float64, pointsCoordinates []model.Point, thresholds Thresholds) (result RobustLocation, err error) {
+	x, y, _, multErr := CalculateLocationByWeightedMultilateration(distances, stdDevs, pointsCoordinates)
+	if multErr != nil {
+		return result, multErr
+	}
+	position := model.Point{X: float64(x), Y: float64(y)}
+
+	// scale floor, prevents rejecting distances by rounding errors
+	absDistances := make([]float64, len(distances))
+	for i, distance := range distances {
+		absDistances[i] = math.Abs(float64(distance))
+	}
+	medianDistance, _ := stats.Median(absDistances)
+	scaleFloor := 10 * model.FLOAT_COMPARISION_TOLERANCE * medianDistance
+
+	// the distances precision (inverse of the variance), equal weights if unknown
+	precisions := weightsOf(distances, stdDevs)
+
+	weights := make([]float64, len(distances))
+	for iteration := 0; iteration < HUBER_MAX_ITERATIONS; iteration++ {
+		residuals := calculateResiduals(position, distances, pointsCoordinates)
+		absResiduals := make([]float64, len(residuals))
+		for i, residual := range residuals {
+			absResiduals[i] = math.Abs(residual)
+		}
+		medianResidual, _ := stats.Median(absResiduals)
+		scale := math.Max(MAD_TO_STANDARD_DEVIATION*medianResidual, scaleFloor)
+
+		for i, absResidual := range absResiduals {
+			weights[i] = 1
+			if absResidual > HUBER_TUNING_CONSTANT*scale {
+				weights[i] = HUBER_TUNING_CONSTANT * scale / absResidual
+			}
+		}
+		combinedWeights := weights
+		if precisions != nil {
+			combinedWeights = make([]float64, len(weights))
+			for i, weight := range weights {
+				combinedWeights[i] = weight * precisions[i]
+			}
+		}
+		position = refineByGaussNewton(position, distances, pointsCoordinates, combinedWeights, PLANE_DIMENSIONS)
+	}
+
+	for i, weight := range weights {
+		if weight < HUBER_REJECTION_WEIGHT {
+			result.Rejected = append(result.Rejected, i)
+		} else {
+			result.Inliers = append(result.Inliers, i)
+		}
+	}
+	result.X, result.Y = float32(position.X), float32(position.Y)
+
+	// checks the location only with the distances that weren't rejected
+	inlierDistances, inlierStdDevs, inlierPoints := subsetOf(result.Inliers, distances, stdDevs, pointsCoordinates)
+	err = checksAcceptableResiduals(inlierDistances, inlierStdDevs, inlierPoints, position, PLANE_DIMENSIONS, thresholds)
+	return result, err
+}
+
+// Gets the distances, standard deviations and points coordinates for the given indexes.
+func subsetOf(indexes []int, distances []float32, stdDevs []float64, pointsCoordinates []model.Point) (subsetDistances []float32, subsetStdDevs []float64, subsetPoints []model.Point) {
+	subsetDistances = make([]float32, len(indexes))
+	subsetStdDevs = make([]float64, len(indexes))
+	subsetPoints = make([]model.Point, len(indexes))
+	for i, idx := range indexes {
+		subsetDistances[i] = distances[idx]
+		subsetStdDevs[i] = stdDevs[idx]
+		subsetPoints[i] = pointsCoordinates[idx]
+	}
+	return subsetDistances, subsetStdDevs, subsetPoints
+}
+
+// Gets a copy of the indexes list without the element at position k.
+func withoutIndex(indexes []int, k int) (result []int) {
+	result = make([]int, 0, len(indexes)-1)
+	result = append(result, indexes[:k]...)
+	return append(result, indexes[k+1:]...)
+}
+
+// Calculates the root mean square of the residuals relative to its distances, or to its standard deviations if known.
+func relativeRMS(residuals []float64, distances []float32, stdDevs []float64) float64 {
+	var resolved []float64
+	if hasStdDevs(stdDevs) {
+		resolved = ResolveStdDevs(distances, stdDevs, support.DEFAULT_RELATIVE_STD_DEV)
+	}
+	sum := float64(0)
+	for i, residual := range residuals {
+		scale := float64(distances[i])
+		if resolved != nil {
+			scale = resolved[i]
+		}
+		sum += math.Pow(residual/scale, 2)
+	}
+	return math.Sqrt(sum / float64(len(residuals)))
+}
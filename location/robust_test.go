@@ -0,0 +1,68 @@
+package location_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mgironi/operation-fire-quasar/location"
+	"github.com/mgironi/operation-fire-quasar/model"
+)
+
+// Tests CalculateLocationByRobustMultilateration with a faulty distance
+func TestCalculateLocationByRobustMultilateration(t *testing.T) {
+	fivePoints := []model.Point{{X: -500, Y: -200}, {X: 100, Y: -100}, {X: 500, Y: 100}, {X: 0, Y: 500}, {X: -300, Y: -600}}
+	threePoints := []model.Point{{X: -500, Y: -200}, {X: 100, Y: -100}, {X: 500, Y: 100}}
+	position := model.Point{X: -200, Y: 200}
+
+	tests := []struct {
+		name         string
+		points       []model.Point
+		bias         []float32
+		wantRejected []int
+		wantErr      bool
+	}{
+		{name: "fiveConsistent", points: fivePoints, bias: []float32{0, 0, 0, 0, 0}, wantRejected: nil, wantErr: false},
+		{name: "fiveOneFaulty", points: fivePoints, bias: []float32{0, 0, 150, 0, 0}, wantRejected: []int{2}, wantErr: false},
+		{name: "fiveTwoFaulty", points: fivePoints, bias: []float32{-90, 0, 0, 0, 200}, wantRejected: []int{4, 0}, wantErr: false},
+		{name: "threeConsistent", points: threePoints, bias: []float32{0, 0, 0}, wantRejected: nil, wantErr: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			distances := make([]float32, len(tt.points))
+			for i, pt := range tt.points {
+				distances[i] = float32(pt.DistanceToPoint(position)) + tt.bias[i]
+			}
+
+			got, err := location.CalculateLocationByRobustMultilateration(distances, tt.points)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CalculateLocationByRobustMultilateration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got.Rejected, tt.wantRejected) {
+				t.Errorf("CalculateLocationByRobustMultilateration() rejected = %v, want %v", got.Rejected, tt.wantRejected)
+			}
+			if len(got.Rejected)+len(got.Inliers) != len(tt.points) {
+				t.Errorf("CalculateLocationByRobustMultilateration() rejected %v and inliers %v don't cover all the points", got.Rejected, got.Inliers)
+			}
+			if !location.HasTorableDiffByDynamicScale(position.X, float64(got.X), 10*model.FLOAT_COMPARISION_TOLERANCE) || !location.HasTorableDiffByDynamicScale(position.Y, float64(got.Y), 10*model.FLOAT_COMPARISION_TOLERANCE) {
+				t.Errorf("CalculateLocationByRobustMultilateration() is (%f, %f), want %s", got.X, got.Y, position)
+			}
+		})
+	}
+}
+
+// Tests CalculateLocationByRobustMultilateration with 3 distances and a faulty one.
+// Huber weighting spreads the error, there is not enough redundancy to identify the faulty one so the location is not accepted.
+func TestCalculateLocationByRobustMultilaterationHuber(t *testing.T) {
+	points := []model.Point{{X: -500, Y: -200}, {X: 100, Y: -100}, {X: 500, Y: 100}}
+	position := model.Point{X: -200, Y: 200}
+	distances := make([]float32, len(points))
+	for i, pt := range points {
+		distances[i] = float32(pt.DistanceToPoint(position))
+	}
+	distances[1] += 80
+
+	_, err := location.CalculateLocationByRobustMultilateration(distances, points)
+	if err == nil {
+		t.Errorf("CalculateLocationByRobustMultilateration() error was expected with 3 distances and a faulty one")
+	}
+}
@@ -0,0 +1,273 @@
+package location
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/mgironi/operation-fire-quasar/model"
+	"github.com/mgironi/operation-fire-quasar/store"
+	"github.com/mgironi/operation-fire-quasar/support"
+)
+
+// Defines the name of the solver that selects the method by the satellites layout (the default one).
+const AUTO_SOLVER string = "auto"
+
+// Defines the name of the closed form trilateration solver.
+const TRILATERATION_SOLVER string = "trilateration"
+
+// Defines the name of the least squares multilateration solver.
+const LEAST_SQUARES_SOLVER string = "leastsquares"
+
+// Defines the name of the robust multilateration solver.
+const ROBUST_SOLVER string = "robust"
+
+// Location calculation algorithm.
+type Solver interface {
+	// Gets the solver name, used to select it.
+	Name() string
+	// Calculates the location with the distances, its standard deviations (not positive if unknown, see ResolveStdDevs),
+	// the points coordinates and the thresholds to accept the location.
+	Solve(distances []float32, stdDevs []float64, pointsCoordinates []model.Point, thresholds Thresholds) (solution Solution, err error)
+}
+
+// Solver location calculation result.
+type Solution struct {
+	// the location position
+	Position model.Point
+	// true if the position was calculated in the space (Z is determined)
+	Spatial bool
+	// indexes of the distances (satellites) rejected as faulty, only by the robust solvers
+	Rejected []int
+	// indexes of the distances (satellites) used to calculate the location
+	Inliers []int
+}
+
+// registered solvers, by name
+var solvers map[string]Solver = map[string]Solver{}
+
+func init() {
+	RegisterSolver(autoSolver{})
+	RegisterSolver(trilaterationSolver{})
+	RegisterSolver(leastSquaresSolver{})
+	RegisterSolver(robustSolver{})
+}
+
+// Registers a solver, replacing the registered one with the same name.
+func RegisterSolver(solver Solver) {
+	solvers[strings.ToLower(solver.Name())] = solver
+}
+
+// Gets a registered solver by name.
+// input: the solver name (case insensitive), the configured one (OFQ_SOLVER) if empty.
+// output: the solver.
+// error: if there isn't a solver registered with the name.
+func GetSolver(name string) (solver Solver, err error) {
+	if strings.TrimSpace(name) == "" {
+		name = support.SolverName()
+	}
+	solver, found := solvers[strings.ToLower(strings.TrimSpace(name))]
+	if !found {
+		return solver, fmt.Errorf("unknown solver '%s', use one of: %s", name, strings.Join(SolverNames(), ", "))
+	}
+	return solver, nil
+}
+
+// Gets the registered solvers names, sorted.
+func SolverNames() (names []string) {
+	for name := range solvers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Calculates location with the named solver and the distances to the known satellites.
+// The distances with unknown standard deviation take the satellite noise of the registry, if configured.
+// input: Recieves distances array to a known coordinates, its standard deviations (nil or not positive values if unknown),
+// the solver name (the configured one if empty) and the thresholds to accept the location (see DefaultThresholds).
+// output: the solver solution and the solver used.
+// error: if the solver is unknown or the calculation couldn't be done.
+func CalculateLocationWithSolver(distances []float32, stdDevs []float64, solverName string, thresholds Thresholds) (solution Solution, solver Solver, err error) {
+	solver, err = GetSolver(solverName)
+	if err != nil {
+		return solution, solver, err
+	}
+
+	// gets reference points coordinates
+	pointsCoordinates := store.GetKnownReferenceCoordinates()
+
+	// checks if distances has same amount of elements that the refences points coordiantes.
+	if len(distances) != len(pointsCoordinates) {
+		return solution, solver, fmt.Errorf("distances and Points coordinates has diferent sizes. Distances: %d, PointsCoord: %d", len(distances), len(pointsCoordinates))
+	}
+
+	solution, err = solver.Solve(distances, resolveSolverStdDevs(distances, stdDevs, thresholds), pointsCoordinates, thresholds)
+	return solution, solver, err
+}
+
+// Completes the unknown standard deviations with the satellites noise of the registry. If some one is known, the
+// still unknown ones take the thresholds relative standard deviation, so every distance is weighted.
+// output: the standard deviations, all 0 if every one is unknown (equal weights).
+func resolveSolverStdDevs(distances []float32, stdDevs []float64, thresholds Thresholds) (resolvedStdDevs []float64) {
+	satellitesInfo := store.GetSatellitesInfo()
+	resolvedStdDevs = make([]float64, len(distances))
+	for i := range resolvedStdDevs {
+		if i < len(stdDevs) && stdDevs[i] > 0 {
+			resolvedStdDevs[i] = stdDevs[i]
+		} else if i < len(satellitesInfo) {
+			resolvedStdDevs[i] = satellitesInfo[i].NoiseStdDev
+		}
+	}
+	if hasStdDevs(resolvedStdDevs) {
+		resolvedStdDevs = ResolveStdDevs(distances, resolvedStdDevs, thresholds.RelativeStdDev)
+	}
+	return resolvedStdDevs
+}
+
+// Selects the method by the satellites layout, see CalculatePosition.
+type autoSolver struct{}
+
+func (autoSolver) Name() string {
+	return AUTO_SOLVER
+}
+
+func (autoSolver) Solve(distances []float32, stdDevs []float64, pointsCoordinates []model.Point, thresholds Thresholds) (solution Solution, err error) {
+	solution.Inliers = allIndexes(len(distances))
+	if model.ValidateSpatialGeometry(pointsCoordinates) != nil {
+		x, y, planarErr := CalculatePlanarLocation(distances, stdDevs, pointsCoordinates, thresholds)
+		if planarErr != nil {
+			return Solution{}, planarErr
+		}
+		solution.Position = model.Point{X: float64(x), Y: float64(y)}
+		return solution, nil
+	}
+
+	// calculates location with the distances to all the points coordinates using multilateration in the space
+	solution.Spatial = true
+	solution.Position, _, err = CalculateLocationByWeightedMultilateration3D(distances, stdDevs, pointsCoordinates)
+	if err != nil {
+		log.Print(err)
+		return Solution{Spatial: true}, err
+	}
+
+	// checks if calculated position match with given distances
+	err = checksAcceptableResiduals(distances, stdDevs, pointsCoordinates, solution.Position, SPACE_DIMENSIONS, thresholds)
+	if err != nil {
+		return Solution{Spatial: true}, err
+	}
+	return solution, nil
+}
+
+// Closed form trilateration in the XY plane, needs exactly 3 distances. See CalculateLocationByTrilateration.
+// The distances standard deviations are only used to check the location, the closed form can't weight them.
+type trilaterationSolver struct{}
+
+func (trilaterationSolver) Name() string {
+	return TRILATERATION_SOLVER
+}
+
+func (trilaterationSolver) Solve(distances []float32, stdDevs []float64, pointsCoordinates []model.Point, thresholds Thresholds) (solution Solution, err error) {
+	if len(distances) != 3 || len(pointsCoordinates) != 3 {
+		return solution, fmt.Errorf("trilateration needs 3 distances and 3 points coordinates. Distances: %d, PointsCoord: %d", len(distances), len(pointsCoordinates))
+	}
+	distances, stdDevs, pointsCoordinates, err = ProjectDistancesToPlane(distances, stdDevs, pointsCoordinates)
+	if err != nil {
+		log.Print(err)
+		return solution, err
+	}
+
+	// checks points coordinates geometry, to prevent solving with a degenerate layout
+	geomErr := model.ValidateGeometry(pointsCoordinates)
+	if geomErr != nil {
+		log.Print(geomErr)
+		return solution, geomErr
+	}
+
+	x, y := CalculateLocationByTrilateration(distances, pointsCoordinates)
+	if !isFiniteCoordinate(x, y) {
+		return solution, fmt.Errorf("trilateration result is not finite (%f, %f)", x, y)
+	}
+
+	// checks if calculated coorinates match with given distances
+	err = checksAcceptableResiduals(distances, stdDevs, pointsCoordinates, model.Point{X: float64(x), Y: float64(y)}, PLANE_DIMENSIONS, thresholds)
+	if err != nil {
+		return solution, err
+	}
+	return Solution{Position: model.Point{X: float64(x), Y: float64(y)}, Inliers: allIndexes(len(distances))}, nil
+}
+
+// Weighted least squares multilateration with every distance, in the space if the satellites layout allows it.
+// See CalculateLocationByWeightedMultilateration and CalculateLocationByWeightedMultilateration3D.
+type leastSquaresSolver struct{}
+
+func (leastSquaresSolver) Name() string {
+	return LEAST_SQUARES_SOLVER
+}
+
+func (leastSquaresSolver) Solve(distances []float32, stdDevs []float64, pointsCoordinates []model.Point, thresholds Thresholds) (solution Solution, err error) {
+	solution.Inliers = allIndexes(len(distances))
+	dimensions := PLANE_DIMENSIONS
+	if model.ValidateSpatialGeometry(pointsCoordinates) == nil {
+		solution.Spatial = true
+		dimensions = SPACE_DIMENSIONS
+		solution.Position, _, err = CalculateLocationByWeightedMultilateration3D(distances, stdDevs, pointsCoordinates)
+	} else {
+		distances, stdDevs, pointsCoordinates, err = ProjectDistancesToPlane(distances, stdDevs, pointsCoordinates)
+		if err == nil {
+			err = model.ValidateGeometry(pointsCoordinates)
+		}
+		if err == nil {
+			var x, y float32
+			x, y, _, err = CalculateLocationByWeightedMultilateration(distances, stdDevs, pointsCoordinates)
+			solution.Position = model.Point{X: float64(x), Y: float64(y)}
+		}
+	}
+	if err != nil {
+		log.Print(err)
+		return Solution{}, err
+	}
+
+	// checks if calculated position match with given distances
+	err = checksAcceptableResiduals(distances, stdDevs, pointsCoordinates, solution.Position, dimensions, thresholds)
+	if err != nil {
+		return Solution{}, err
+	}
+	return solution, nil
+}
+
+// Robust multilateration in the XY plane, excluding the faulty distances. See CalculateLocationByRobustMultilateration.
+type robustSolver struct{}
+
+func (robustSolver) Name() string {
+	return ROBUST_SOLVER
+}
+
+func (robustSolver) Solve(distances []float32, stdDevs []float64, pointsCoordinates []model.Point, thresholds Thresholds) (solution Solution, err error) {
+	// checks points coordinates geometry (projected to the plane), to prevent solving with a degenerate layout
+	geomErr := model.ValidateGeometry(model.ProjectPointsToPlane(pointsCoordinates))
+	if geomErr != nil {
+		log.Print(geomErr)
+		return solution, geomErr
+	}
+
+	robustLocation, err := CalculateLocationByWeightedRobustMultilateration(distances, stdDevs, pointsCoordinates, thresholds)
+	if err != nil {
+		return solution, err
+	}
+	return Solution{
+		Position: model.Point{X: float64(robustLocation.X), Y: float64(robustLocation.Y)},
+		Rejected: robustLocation.Rejected,
+		Inliers:  robustLocation.Inliers,
+	}, nil
+}
+
+// Gets the indexes from 0 to count-1.
+func allIndexes(count int) (indexes []int) {
+	indexes = make([]int, count)
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return indexes
+}
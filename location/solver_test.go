@@ -0,0 +1,89 @@
+package location_test
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	test "github.com/mgironi/operation-fire-quasar/_test"
+	"github.com/mgironi/operation-fire-quasar/location"
+	"github.com/mgironi/operation-fire-quasar/model"
+	"github.com/mgironi/operation-fire-quasar/store"
+)
+
+// Tests the registered solvers with the distances to the default satellites
+func TestCalculateLocationWithSolver(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	store.InitializeSatelitesInfo()
+	distances := []float32{500, 424.26, 707.10}
+
+	for _, solverName := range []string{"", location.AUTO_SOLVER, location.TRILATERATION_SOLVER, location.LEAST_SQUARES_SOLVER, location.ROBUST_SOLVER} {
+		t.Run(solverName, func(t *testing.T) {
+			solution, solver, err := location.CalculateLocationWithSolver(distances, nil, solverName, location.DefaultThresholds())
+			if err != nil {
+				t.Fatalf("CalculateLocationWithSolver() error = %v", err)
+			}
+			if solverName != "" && solver.Name() != solverName {
+				t.Errorf("CalculateLocationWithSolver() solver = %s, want %s", solver.Name(), solverName)
+			}
+			if math.Round(solution.Position.X) != -200 || math.Round(solution.Position.Y) != 200 {
+				t.Errorf("CalculateLocationWithSolver() position = %s, want (-200, 200)", solution.Position)
+			}
+			if !reflect.DeepEqual(solution.Inliers, []int{0, 1, 2}) {
+				t.Errorf("CalculateLocationWithSolver() inliers = %v, want [0 1 2]", solution.Inliers)
+			}
+		})
+	}
+
+	if _, _, err := location.CalculateLocationWithSolver(distances, nil, "unknown", location.DefaultThresholds()); err == nil {
+		t.Error("CalculateLocationWithSolver() with unknown solver, want error")
+	}
+}
+
+// Tests the trilateration solver only accepts 3 distances
+func TestTrilaterationSolverDistancesCount(t *testing.T) {
+	solver, err := location.GetSolver(location.TRILATERATION_SOLVER)
+	if err != nil {
+		t.Fatalf("GetSolver() error = %v", err)
+	}
+	points := []model.Point{{X: -500, Y: -200}, {X: 100, Y: -100}, {X: 500, Y: 100}, {X: 0, Y: 500}}
+	if _, err := solver.Solve([]float32{500, 424.26, 707.10, 700}, nil, points, location.DefaultThresholds()); err == nil {
+		t.Error("Solve() with 4 distances, want error")
+	}
+}
+
+type fixedSolver struct{}
+
+func (fixedSolver) Name() string {
+	return "fixed"
+}
+
+func (fixedSolver) Solve(distances []float32, stdDevs []float64, pointsCoordinates []model.Point, thresholds location.Thresholds) (solution location.Solution, err error) {
+	if len(distances) == 0 {
+		return solution, errors.New("no distances")
+	}
+	return location.Solution{Position: model.Point{X: 1, Y: 2}}, nil
+}
+
+// Tests RegisterSolver with a custom solver
+func TestRegisterSolver(t *testing.T) {
+	location.RegisterSolver(fixedSolver{})
+
+	solver, err := location.GetSolver("Fixed")
+	if err != nil {
+		t.Fatalf("GetSolver() error = %v", err)
+	}
+	solution, _ := solver.Solve([]float32{1}, nil, nil, location.DefaultThresholds())
+	if !solution.Position.EqualTo(model.Point{X: 1, Y: 2}) {
+		t.Errorf("Solve() position = %s, want (1, 2)", solution.Position)
+	}
+
+	found := false
+	for _, name := range location.SolverNames() {
+		found = found || name == "fixed"
+	}
+	if !found {
+		t.Errorf("SolverNames() = %v, want to include 'fixed'", location.SolverNames())
+	}
+}
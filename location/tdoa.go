@@ -0,0 +1,304 @@
+package location
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/mgironi/operation-fire-quasar/model"
+	"github.com/mgironi/operation-fire-quasar/store"
+)
+
+// TDOA location calculation result.
+type TDOALocation struct {
+	// the location position, the first candidate if the location is ambiguous
+	Position model.Point
+	// true if the position was calculated in the space (Z is determined)
+	Spatial bool
+	// the candidate locations when the timestamps fit two positions (only with 3 timestamps)
+	Candidates []model.Point
+	// the distance to each point derived from the timestamps, for the position
+	Distances []float32
+}
+
+// Indicates if the timestamps fit more than one location, see Candidates.
+func (tdoaLocation TDOALocation) Ambiguous() bool {
+	return len(tdoaLocation.Candidates) > 1
+}
+
+// Calculates location position with the arrival timestamps of the signal to each satellite (TDOA).
+// See also CalculatePositionByTDOA.
+// input: the arrival timestamps (seconds, ordered as the known satellites) and the propagation speed (coordinates units per second).
+// output: the calculated location, in the space if the satellites layout allows it, with the distance to each satellite derived from the timestamps.
+// error: in case calculation couldn't be done.
+func CalculatePositionFromTimestamps(timestamps []float64, propagationSpeed float64) (result TDOALocation, err error) {
+	// gets reference points coordinates
+	pointsCoordinates := store.GetKnownReferenceCoordinates()
+
+	// checks if timestamps has same amount of elements that the refences points coordiantes.
+	if len(timestamps) != len(pointsCoordinates) {
+		return result, fmt.Errorf("timestamps and Points coordinates has diferent sizes. Timestamps: %d, PointsCoord: %d", len(timestamps), len(pointsCoordinates))
+	}
+
+	// checks points coordinates geometry (projected to the plane if the location is in the plane), to prevent solving with a degenerate layout
+	if !isSpatialTDOALayout(pointsCoordinates) {
+		geomErr := model.ValidateGeometry(model.ProjectPointsToPlane(pointsCoordinates))
+		if geomErr != nil {
+			log.Print(geomErr)
+			return result, geomErr
+		}
+	}
+
+	return CalculatePositionByTDOA(timestamps, propagationSpeed, pointsCoordinates)
+}
+
+// Calculates location by time difference of arrival (hyperbolic positioning).
+//
+// The emission time t0 is unknown, so the distances are known except for a common offset b = speed*t0. Each timestamp
+// gives a pseudo range ecuation, where the differences between them describe hyperbolas with focus in the points:
+//
+// speed*ti = sqrt((x-xi)^2 + (y-yi)^2 + (z-zi)^2) + b
+//
+// With 5 or more points not over the same plane the location is calculated in the space. Otherwise the emitter is in
+// the XY plane (z=0), the points altitude zi is kept so the distances are the slant ones. The initial position is
+// obtained by linearized least squares, subtracting the first squared ecuation to the others (linear in x, y, z and b):
+//
+// 2*(xi-x1)*x + 2*(yi-y1)*y + 2*(zi-z1)*z - 2*(ri-r1)*b = xi^2 + yi^2 + zi^2 - x1^2 - y1^2 - z1^2 - ri^2 + r1^2, with ri = speed*ti
+//
+// Then the position and offset are refined with Gauss-Newton iterations. The timestamps are taken relative to the first
+// arrival to keep the precision.
+//
+// With 3 timestamps in the plane there are as many ecuations as unknowns, and the hyperbolas can intersect in two
+// points. The linearized ecuations give x and y as a function of b, and the first ecuation gives a quadratic one in b,
+// whose roots with positive distances are the candidates. If both are valid the location is ambiguous.
+//
+// input: the arrival timestamps (seconds), the propagation speed (coordinates units per second) and the points coordinates (3 at least).
+// output: the calculated location and the distance to each point (speed * (ti - t0)).
+// error: if there is not enough timestamps, the speed is not positive or the location doesn't match the timestamps.
+func CalculatePositionByTDOA(timestamps []float64, propagationSpeed float64, pointsCoordinates []model.Point) (result TDOALocation, err error) {
+	if len(timestamps) != len(pointsCoordinates) {
+		return result, fmt.Errorf("timestamps and Points coordinates has diferent sizes. Timestamps: %d, PointsCoord: %d", len(timestamps), len(pointsCoordinates))
+	}
+	if len(timestamps) < 3 {
+		return result, fmt.Errorf("not enough timestamps to apply TDOA. Timestamps: %d, need 3 at least", len(timestamps))
+	}
+	if propagationSpeed <= 0 || math.IsNaN(propagationSpeed) || math.IsInf(propagationSpeed, 0) {
+		return result, fmt.Errorf("propagation speed must be a positive number. Speed: %f", propagationSpeed)
+	}
+
+	// converts timestamps to pseudo ranges, relative to the first arrival
+	firstArrival := math.Inf(1)
+	for _, timestamp := range timestamps {
+		firstArrival = math.Min(firstArrival, timestamp)
+	}
+	pseudoRanges := make([]float64, len(timestamps))
+	for i, timestamp := range timestamps {
+		pseudoRanges[i] = propagationSpeed * (timestamp - firstArrival)
+	}
+
+	if len(timestamps) == 3 {
+		return calculateTDOACandidates(pseudoRanges, pointsCoordinates)
+	}
+
+	dimensions := PLANE_DIMENSIONS
+	if isSpatialTDOALayout(pointsCoordinates) {
+		result.Spatial = true
+		dimensions = SPACE_DIMENSIONS
+	}
+
+	// gets initial position and offset
+	position, offset := initialTDOAEstimation(pseudoRanges, pointsCoordinates, dimensions)
+
+	// refines position and offset by Gauss-Newton iterations
+	position, offset = refineTDOAByGaussNewton(position, offset, pseudoRanges, pointsCoordinates, dimensions)
+
+	result.Distances, err = tdoaDistances(pseudoRanges, offset)
+	if err != nil {
+		return result, err
+	}
+
+	// checks if calculated position match with the distances derived from timestamps
+	// the offset is solved too, so there is a degree of freedom less
+	err = checksAcceptableResiduals(result.Distances, nil, pointsCoordinates, position, dimensions+1, DefaultThresholds())
+	if err != nil {
+		return result, err
+	}
+	result.Position = position
+	return result, nil
+}
+
+// Indicates if the points layout allows to calculate the TDOA location in the space: the points aren't over the same
+// plane and there are enough of them to linearize the ecuations (x, y, z and b, one ecuation is subtracted).
+func isSpatialTDOALayout(pointsCoordinates []model.Point) bool {
+	return len(pointsCoordinates) >= SPACE_DIMENSIONS+2 && model.ValidateSpatialGeometry(pointsCoordinates) == nil
+}
+
+// Gets the distances to the points for the pseudo ranges offset.
+// error: if some distance isn't positive, the timestamps don't fit a location.
+func tdoaDistances(pseudoRanges []float64, offset float64) (distances []float32, err error) {
+	distances = make([]float32, len(pseudoRanges))
+	for i, pseudoRange := range pseudoRanges {
+		distances[i] = float32(pseudoRange - offset)
+		if distances[i] <= 0 {
+			return distances, errors.New("can't calculate location by TDOA, the timestamps are inconsistent with the points coordinates")
+		}
+	}
+	return distances, nil
+}
+
+// Calculates the TDOA candidate locations in the XY plane with 3 pseudo ranges, see CalculatePositionByTDOA.
+func calculateTDOACandidates(pseudoRanges []float64, pointsCoordinates []model.Point) (result TDOALocation, err error) {
+	// x and y as a function of b: A*(x, y) = c + q*b
+	p1 := pointsCoordinates[0]
+	r1 := pseudoRanges[0]
+	a := make([][]float64, 2)
+	c := make([]float64, 2)
+	q := make([]float64, 2)
+	for i := 1; i < 3; i++ {
+		pi := pointsCoordinates[i]
+		ri := pseudoRanges[i]
+		a[i-1] = []float64{2 * (pi.X - p1.X), 2 * (pi.Y - p1.Y)}
+		c[i-1] = math.Pow(pi.X, 2) + math.Pow(pi.Y, 2) + math.Pow(pi.Z, 2) - math.Pow(p1.X, 2) - math.Pow(p1.Y, 2) - math.Pow(p1.Z, 2) - math.Pow(ri, 2) + math.Pow(r1, 2)
+		q[i-1] = 2 * (ri - r1)
+	}
+	base, baseErr := solveLinearSystem(a, c)
+	if baseErr != nil {
+		return result, baseErr
+	}
+	slope, slopeErr := solveLinearSystem(a, q)
+	if slopeErr != nil {
+		return result, slopeErr
+	}
+
+	// replaces x and y in the first ecuation: (x-x1)^2 + (y-y1)^2 + z1^2 = (r1-b)^2
+	dx, dy := base[0]-p1.X, base[1]-p1.Y
+	quadA := math.Pow(slope[0], 2) + math.Pow(slope[1], 2) - 1
+	quadB := 2 * (dx*slope[0] + dy*slope[1] + r1)
+	quadC := math.Pow(dx, 2) + math.Pow(dy, 2) + math.Pow(p1.Z, 2) - math.Pow(r1, 2)
+	offsets := quadraticRoots(quadA, quadB, quadC)
+
+	for _, offset := range offsets {
+		distances, distErr := tdoaDistances(pseudoRanges, offset)
+		if distErr != nil {
+			continue
+		}
+		candidate := model.Point{X: base[0] + slope[0]*offset, Y: base[1] + slope[1]*offset}
+		if checksAcceptableResiduals(distances, nil, pointsCoordinates, candidate, PLANE_DIMENSIONS+1, DefaultThresholds()) != nil {
+			continue
+		}
+		if len(result.Candidates) == 0 {
+			result.Position = candidate
+			result.Distances = distances
+		}
+		result.Candidates = append(result.Candidates, candidate)
+	}
+	if len(result.Candidates) == 0 {
+		return result, errors.New("can't calculate location by TDOA, the timestamps are inconsistent with the points coordinates")
+	}
+	if result.Ambiguous() {
+		log.Printf("WARN the timestamps fit two locations %s and %s, the location is ambiguous", result.Candidates[0], result.Candidates[1])
+	}
+	return result, nil
+}
+
+// Gets the real roots of a*x^2 + b*x + c = 0, the single root of the linear ecuation if a is almost zero.
+// If there aren't real roots, gets the vertex (the closest approach to a root).
+func quadraticRoots(a, b, c float64) (roots []float64) {
+	if math.Abs(a) < model.FLOAT_COMPARISION_TOLERANCE {
+		if b == 0 {
+			return roots
+		}
+		return []float64{-c / b}
+	}
+	discriminant := math.Pow(b, 2) - 4*a*c
+	if discriminant <= 0 {
+		return []float64{-b / (2 * a)}
+	}
+	sqrtDiscriminant := math.Sqrt(discriminant)
+	return []float64{(-b - sqrtDiscriminant) / (2 * a), (-b + sqrtDiscriminant) / (2 * a)}
+}
+
+// Gets the TDOA initial position and pseudo ranges offset, see CalculatePositionByTDOA.
+func initialTDOAEstimation(pseudoRanges []float64, pointsCoordinates []model.Point, dimensions int) (position model.Point, offset float64) {
+	// the points centroid as default position, with the altitude in the space
+	centroid := make([]float64, dimensions)
+	for _, pt := range pointsCoordinates {
+		for k, coordinate := range coordinatesOf(pt, dimensions) {
+			centroid[k] += coordinate / float64(len(pointsCoordinates))
+		}
+	}
+	position = pointOf(centroid)
+
+	p1 := pointsCoordinates[0]
+	r1 := pseudoRanges[0]
+	p1Coords := coordinatesOf(p1, dimensions)
+	rows := len(pointsCoordinates) - 1
+	a := make([][]float64, rows)
+	b := make([]float64, rows)
+	for i := 1; i < len(pointsCoordinates); i++ {
+		pi := pointsCoordinates[i]
+		ri := pseudoRanges[i]
+		piCoords := coordinatesOf(pi, dimensions)
+		a[i-1] = make([]float64, dimensions+1)
+		for k := 0; k < dimensions; k++ {
+			a[i-1][k] = 2 * (piCoords[k] - p1Coords[k])
+		}
+		a[i-1][dimensions] = -2 * (ri - r1)
+		b[i-1] = math.Pow(pi.X, 2) + math.Pow(pi.Y, 2) + math.Pow(pi.Z, 2) - math.Pow(p1.X, 2) - math.Pow(p1.Y, 2) - math.Pow(p1.Z, 2) - math.Pow(ri, 2) + math.Pow(r1, 2)
+	}
+	ata, atb := normalEquations(a, b)
+	solution, solveErr := solveLinearSystem(ata, atb)
+	if solveErr == nil {
+		return pointOf(solution[:dimensions]), solution[dimensions]
+	}
+	log.Printf("WARN can't calculate TDOA initial position by linearized least squares, using points centroid. %s", solveErr.Error())
+
+	// the offset that best fits the distances from the initial position
+	for i, pt := range pointsCoordinates {
+		offset += (pseudoRanges[i] - position.DistanceToPoint(pt)) / float64(len(pointsCoordinates))
+	}
+	return position, offset
+}
+
+// Refines TDOA position and pseudo ranges offset by Gauss-Newton iterations, see CalculatePositionByTDOA.
+func refineTDOAByGaussNewton(initial model.Point, initialOffset float64, pseudoRanges []float64, pointsCoordinates []model.Point, dimensions int) (position model.Point, offset float64) {
+	position, offset = initial, initialOffset
+	for iteration := 0; iteration < MULTILATERATION_MAX_ITERATIONS; iteration++ {
+		jacobian := make([][]float64, len(pointsCoordinates))
+		negResiduals := make([]float64, len(pointsCoordinates))
+		positionCoords := coordinatesOf(position, dimensions)
+		for i, pt := range pointsCoordinates {
+			calcDistance := position.DistanceToPoint(pt)
+			if calcDistance == 0 {
+				// position over a reference point, the derivative isn't defined
+				return position, offset
+			}
+			ptCoords := coordinatesOf(pt, dimensions)
+			jacobian[i] = make([]float64, dimensions+1)
+			for k := 0; k < dimensions; k++ {
+				jacobian[i][k] = (positionCoords[k] - ptCoords[k]) / calcDistance
+			}
+			jacobian[i][dimensions] = 1
+			negResiduals[i] = pseudoRanges[i] - (calcDistance + offset)
+		}
+
+		jtj, jtr := normalEquations(jacobian, negResiduals)
+		step, solveErr := solveLinearSystem(jtj, jtr)
+		if solveErr != nil {
+			return position, offset
+		}
+
+		stepSize := float64(0)
+		for k := 0; k < dimensions; k++ {
+			positionCoords[k] += step[k]
+			stepSize += math.Pow(step[k], 2)
+		}
+		position = pointOf(positionCoords)
+		offset += step[dimensions]
+		if math.Sqrt(stepSize+math.Pow(step[dimensions], 2)) < MULTILATERATION_CONVERGENCE_TOLERANCE {
+			break
+		}
+	}
+	return position, offset
+}
@@ -0,0 +1,82 @@
+package location_test
+
+import (
+	"testing"
+
+	"github.com/mgironi/operation-fire-quasar/location"
+	"github.com/mgironi/operation-fire-quasar/model"
+)
+
+// Tests CalculatePositionByTDOA with 3 and more points
+func TestCalculatePositionByTDOA(t *testing.T) {
+	fivePoints := []model.Point{{X: -500, Y: -200}, {X: 100, Y: -100}, {X: 500, Y: 100}, {X: 0, Y: 500}, {X: -300, Y: -600}}
+	threePoints := []model.Point{{X: -500, Y: -200}, {X: 100, Y: -100}, {X: 500, Y: 100}}
+	trianglePoints := []model.Point{{X: 0, Y: 0}, {X: 1000, Y: 0}, {X: 0, Y: 1000}}
+	altitudePoints := []model.Point{{X: -500, Y: -200, Z: 0}, {X: 100, Y: -100, Z: 300}, {X: 500, Y: 100, Z: 800}, {X: 0, Y: 500, Z: 1200}}
+	spatialPoints := []model.Point{{X: -500, Y: -200, Z: 0}, {X: 100, Y: -100, Z: 300}, {X: 500, Y: 100, Z: 0}, {X: 0, Y: 500, Z: 1200}, {X: -300, Y: -600, Z: 800}, {X: 600, Y: -500, Z: 400}}
+	const speed float64 = 299792458
+	// relative to a near reference, absolute epoch seconds haven't enough precision at the speed of light
+	const emissionTime float64 = 0.25
+
+	tests := []struct {
+		name        string
+		points      []model.Point
+		want        model.Point
+		speed       float64
+		wantSpatial bool
+		// the location can be any of two candidates, want is one of them
+		wantAmbiguous bool
+		wantErr       bool
+	}{
+		{name: "threePoints", points: threePoints, want: model.Point{X: -200, Y: 200}, speed: speed, wantAmbiguous: true},
+		{name: "threePointsInsideTriangle", points: trianglePoints, want: model.Point{X: 300, Y: 200}, speed: speed},
+		{name: "fivePoints", points: fivePoints, want: model.Point{X: -200, Y: 200}, speed: speed},
+		{name: "fivePointsFar", points: fivePoints, want: model.Point{X: 1000, Y: -800}, speed: speed},
+		{name: "altitudePoints", points: altitudePoints, want: model.Point{X: -200, Y: 200}, speed: speed},
+		{name: "spatialPoints", points: spatialPoints, want: model.Point{X: -200, Y: 200, Z: 300}, speed: speed, wantSpatial: true},
+		{name: "slowSpeed", points: fivePoints, want: model.Point{X: 300, Y: 50}, speed: 343},
+		{name: "invalidSpeed", points: fivePoints, want: model.Point{X: 300, Y: 50}, speed: 0, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timestamps := make([]float64, len(tt.points))
+			for i, pt := range tt.points {
+				timestamps[i] = emissionTime + pt.DistanceToPoint(tt.want)/speed
+			}
+			if tt.speed != speed {
+				// relative timestamps for other speeds
+				for i, pt := range tt.points {
+					timestamps[i] = 10 + pt.DistanceToPoint(tt.want)/tt.speed
+				}
+			}
+
+			got, err := location.CalculatePositionByTDOA(timestamps, tt.speed, tt.points)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CalculatePositionByTDOA() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Spatial != tt.wantSpatial {
+				t.Errorf("CalculatePositionByTDOA() spatial is %t, want %t", got.Spatial, tt.wantSpatial)
+			}
+			if got.Ambiguous() != tt.wantAmbiguous {
+				t.Errorf("CalculatePositionByTDOA() ambiguous is %t (candidates %v), want %t", got.Ambiguous(), got.Candidates, tt.wantAmbiguous)
+			}
+			tolerance := 0.01
+			found := got.Position.DistanceToPoint(tt.want) <= tolerance
+			for _, candidate := range got.Candidates {
+				found = found || (tt.wantAmbiguous && candidate.DistanceToPoint(tt.want) <= tolerance)
+			}
+			if !found {
+				t.Errorf("CalculatePositionByTDOA() is %s (candidates %v), want %s", got.Position, got.Candidates, tt.want)
+			}
+			// the distances are the ones of the position (the first candidate if ambiguous)
+			for i, pt := range tt.points {
+				if diff := float64(got.Distances[i]) - pt.DistanceToPoint(got.Position); diff > tolerance || diff < -tolerance {
+					t.Errorf("CalculatePositionByTDOA() distance %d is %f, want %f", i, got.Distances[i], pt.DistanceToPoint(got.Position))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package location
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/mgironi/operation-fire-quasar/model"
+	"github.com/mgironi/operation-fire-quasar/store"
+	"github.com/mgironi/operation-fire-quasar/support"
+)
+
+// Location quality grade, see GradeLocation.
+type Grade string
+
+// Defines the grade of a location that matches the distances (relative RMS of the residuals under FLOAT_COMPARISION_TOLERANCE).
+const GRADE_EXACT Grade = "exact"
+
+// Defines the grade of a location that passes the residuals chi-square test.
+const GRADE_GOOD Grade = "good"
+
+// Defines the grade of a location that fails the chi-square test, but its relative RMS is under the degraded ratio.
+const GRADE_DEGRADED Grade = "degraded"
+
+// Defines the grade of a location that doesn't fit the distances, only returned in best effort mode.
+const GRADE_REJECTED Grade = "rejected"
+
+// Location acceptance thresholds.
+type Thresholds struct {
+	// significance level of the residuals chi-square test, the probability of rejecting a right location
+	Significance float64
+	// standard deviation of a distance relative to it, used when the distance noise is unknown
+	RelativeStdDev float64
+	// max relative RMS of the residuals of a degraded location, in best effort mode
+	DegradedRatio float64
+}
+
+// Location quality, see GradeLocation.
+type Quality struct {
+	Grade Grade
+	// the residuals chi-square statistic and its p-value, see ChecksChiSquare
+	ChiSquare float64
+	PValue    float64
+	// the root mean square of the residuals relative to its distances
+	RelativeRMS float64
+}
+
+// Gets the configured thresholds (OFQ_SIGNIFICANCE, OFQ_RELATIVE_STD_DEV and OFQ_DEGRADED_RATIO).
+func DefaultThresholds() Thresholds {
+	return Thresholds{
+		Significance:   support.Significance(),
+		RelativeStdDev: support.RelativeStdDev(),
+		DegradedRatio:  support.DegradedRatio(),
+	}
+}
+
+// Validates the thresholds values.
+// error: if the significance isn't in the interval (0, 1) or some threshold isn't positive.
+func (thresholds Thresholds) Validate() error {
+	if !(thresholds.Significance > 0 && thresholds.Significance < 1) {
+		return fmt.Errorf("invalid significance %g, must be between 0 and 1", thresholds.Significance)
+	}
+	if !(thresholds.RelativeStdDev > 0) || math.IsInf(thresholds.RelativeStdDev, 1) {
+		return fmt.Errorf("invalid relative standard deviation %g, must be positive", thresholds.RelativeStdDev)
+	}
+	if !(thresholds.DegradedRatio > 0) || math.IsInf(thresholds.DegradedRatio, 1) {
+		return fmt.Errorf("invalid degraded ratio %g, must be positive", thresholds.DegradedRatio)
+	}
+	return nil
+}
+
+// Grades a location by its residuals:
+// exact if the relative RMS of the residuals is under FLOAT_COMPARISION_TOLERANCE, good if it passes the chi-square test,
+// degraded if the relative RMS is under the degraded ratio and rejected otherwise.
+// input: the distances, its standard deviations (nil for unknown), the points coordinates, the position, the dimensions
+// solved (2: plane, 3: space) and the thresholds.
+// output: the location quality.
+// error: if distances and points coordinates have different sizes.
+func GradeLocation(distances []float32, stdDevs []float64, pointsCoordinates []model.Point, position model.Point, dimensions int, thresholds Thresholds) (quality Quality, err error) {
+	quality.ChiSquare, quality.PValue, err = ChecksChiSquare(distances, stdDevs, pointsCoordinates, position, dimensions, thresholds.RelativeStdDev)
+	if err != nil {
+		return quality, err
+	}
+	quality.RelativeRMS = relativeRMS(calculateResiduals(position, distances, pointsCoordinates), distances, nil)
+
+	switch {
+	case math.IsNaN(quality.ChiSquare) || math.IsNaN(quality.RelativeRMS):
+		quality.Grade = GRADE_REJECTED
+	case quality.RelativeRMS <= model.FLOAT_COMPARISION_TOLERANCE:
+		quality.Grade = GRADE_EXACT
+	case quality.PValue >= thresholds.Significance:
+		quality.Grade = GRADE_GOOD
+	case quality.RelativeRMS <= thresholds.DegradedRatio:
+		quality.Grade = GRADE_DEGRADED
+	default:
+		quality.Grade = GRADE_REJECTED
+	}
+	return quality, nil
+}
+
+// Calculates location with the named solver in best effort mode: if the solver can't accept the location, gets the
+// closest fit one (least squares multilateration, unchecked) instead. The location is graded with the inliers distances.
+// See CalculateLocationWithSolver and GradeLocation.
+// input: the distances, its standard deviations (nil or not positive values if unknown), the solver name (the configured one if empty) and the thresholds.
+// output: the solution, its quality and the solver used.
+// error: if the solver is unknown or even the closest fit location couldn't be calculated.
+func CalculateBestEffortLocation(distances []float32, stdDevs []float64, solverName string, thresholds Thresholds) (solution Solution, quality Quality, solver Solver, err error) {
+	solver, err = GetSolver(solverName)
+	if err != nil {
+		return solution, quality, solver, err
+	}
+
+	solution, _, solveErr := CalculateLocationWithSolver(distances, stdDevs, solverName, thresholds)
+	pointsCoordinates := store.GetKnownReferenceCoordinates()
+	resolvedStdDevs := resolveSolverStdDevs(distances, stdDevs, thresholds)
+	if solveErr != nil {
+		log.Printf("WARN location not accepted by solver '%s', searching the closest fit. Trace: %s", solver.Name(), solveErr.Error())
+		solution, err = calculateClosestFit(distances, resolvedStdDevs, pointsCoordinates)
+		if err != nil {
+			log.Print(err)
+			return solution, quality, solver, solveErr
+		}
+	}
+
+	// grades the location only with the distances that weren't rejected
+	inlierDistances, inlierStdDevs, inlierPoints := subsetOf(solution.Inliers, distances, resolvedStdDevs, pointsCoordinates)
+	dimensions := SPACE_DIMENSIONS
+	if !solution.Spatial {
+		dimensions = PLANE_DIMENSIONS
+		inlierDistances, inlierStdDevs, inlierPoints, err = ProjectDistancesToPlane(inlierDistances, inlierStdDevs, inlierPoints)
+		if err != nil {
+			return solution, quality, solver, err
+		}
+	}
+	quality, err = GradeLocation(inlierDistances, inlierStdDevs, inlierPoints, solution.Position, dimensions, thresholds)
+	return solution, quality, solver, err
+}
+
+// Calculates the location that best fits every distance (weighted least squares), without checking its residuals.
+// Solves in the space if the satellites layout allows it, otherwise in the XY plane.
+// error: if the points coordinates layout is degenerate or the calculation couldn't be done.
+func calculateClosestFit(distances []float32, stdDevs []float64, pointsCoordinates []model.Point) (solution Solution, err error) {
+	if len(distances) != len(pointsCoordinates) {
+		return solution, fmt.Errorf("distances and Points coordinates has diferent sizes. Distances: %d, PointsCoord: %d", len(distances), len(pointsCoordinates))
+	}
+	solution.Inliers = allIndexes(len(distances))
+	if model.ValidateSpatialGeometry(pointsCoordinates) == nil {
+		solution.Spatial = true
+		solution.Position, _, err = CalculateLocationByWeightedMultilateration3D(distances, stdDevs, pointsCoordinates)
+		return solution, err
+	}
+
+	distances, stdDevs, pointsCoordinates, err = ProjectDistancesToPlane(distances, stdDevs, pointsCoordinates)
+	if err != nil {
+		return solution, err
+	}
+	if err = model.ValidateGeometry(pointsCoordinates); err != nil {
+		return solution, err
+	}
+	x, y, _, err := CalculateLocationByWeightedMultilateration(distances, stdDevs, pointsCoordinates)
+	if err == nil && !isFiniteCoordinate(x, y) {
+		err = errors.New("closest fit location is not finite")
+	}
+	solution.Position = model.Point{X: float64(x), Y: float64(y)}
+	return solution, err
+}
@@ -0,0 +1,96 @@
+package location_test
+
+import (
+	"math"
+	"testing"
+
+	test "github.com/mgironi/operation-fire-quasar/_test"
+	"github.com/mgironi/operation-fire-quasar/location"
+	"github.com/mgironi/operation-fire-quasar/model"
+	"github.com/mgironi/operation-fire-quasar/store"
+)
+
+// Tests GradeLocation grades with growing distances errors
+func TestGradeLocation(t *testing.T) {
+	points := []model.Point{{X: -500, Y: -200}, {X: 100, Y: -100}, {X: 500, Y: 100}, {X: 0, Y: 500}}
+	position := model.Point{X: -200, Y: 200}
+	thresholds := location.Thresholds{Significance: 0.001, RelativeStdDev: 0.001, DegradedRatio: 0.05}
+
+	tests := []struct {
+		name      string
+		bias      float32
+		wantGrade location.Grade
+	}{
+		{name: "exact", bias: 0, wantGrade: location.GRADE_EXACT},
+		{name: "good", bias: 0.5, wantGrade: location.GRADE_GOOD},
+		{name: "degraded", bias: 30, wantGrade: location.GRADE_DEGRADED},
+		{name: "rejected", bias: 300, wantGrade: location.GRADE_REJECTED},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			distances := make([]float32, len(points))
+			for i, pt := range points {
+				distances[i] = float32(pt.DistanceToPoint(position))
+			}
+			distances[3] += tt.bias
+			quality, err := location.GradeLocation(distances, nil, points, position, location.PLANE_DIMENSIONS, thresholds)
+			if err != nil {
+				t.Fatalf("GradeLocation() error = %v", err)
+			}
+			if quality.Grade != tt.wantGrade {
+				t.Errorf("GradeLocation() grade = %s, want %s (%+v)", quality.Grade, tt.wantGrade, quality)
+			}
+		})
+	}
+}
+
+// Tests Thresholds.Validate
+func TestThresholdsValidate(t *testing.T) {
+	valid := location.Thresholds{Significance: 0.01, RelativeStdDev: 0.01, DegradedRatio: 0.1}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	for _, invalid := range []location.Thresholds{
+		{Significance: 1, RelativeStdDev: 0.01, DegradedRatio: 0.1},
+		{Significance: 0.01, RelativeStdDev: 0, DegradedRatio: 0.1},
+		{Significance: 0.01, RelativeStdDev: 0.01, DegradedRatio: math.NaN()},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Validate() with %+v, want error", invalid)
+		}
+	}
+}
+
+// Tests CalculateBestEffortLocation with distances that the solvers reject
+func TestCalculateBestEffortLocation(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	store.InitializeSatelitesInfo()
+	// the distances of test1 with a 1% error
+	distances := []float32{505, 424.26, 707.10}
+	thresholds := location.DefaultThresholds()
+
+	if _, _, err := location.CalculateLocationWithSolver(distances, nil, location.AUTO_SOLVER, thresholds); err == nil {
+		t.Fatal("CalculateLocationWithSolver() with noisy distances, want error")
+	}
+
+	solution, quality, solver, err := location.CalculateBestEffortLocation(distances, nil, location.AUTO_SOLVER, thresholds)
+	if err != nil {
+		t.Fatalf("CalculateBestEffortLocation() error = %v", err)
+	}
+	if solver.Name() != location.AUTO_SOLVER {
+		t.Errorf("CalculateBestEffortLocation() solver = %s, want %s", solver.Name(), location.AUTO_SOLVER)
+	}
+	if quality.Grade != location.GRADE_DEGRADED {
+		t.Errorf("CalculateBestEffortLocation() grade = %s, want %s", quality.Grade, location.GRADE_DEGRADED)
+	}
+	if solution.Position.DistanceToPoint(model.Point{X: -200, Y: 200}) > 10 {
+		t.Errorf("CalculateBestEffortLocation() position = %s, want close to (-200, 200)", solution.Position)
+	}
+
+	// with a looser noise the location is accepted
+	thresholds.RelativeStdDev = 0.05
+	_, quality, _, err = location.CalculateBestEffortLocation(distances, nil, location.AUTO_SOLVER, thresholds)
+	if err != nil || quality.Grade != location.GRADE_GOOD {
+		t.Errorf("CalculateBestEffortLocation() with relative standard deviation 0.05 grade = %s, error = %v, want %s", quality.Grade, err, location.GRADE_GOOD)
+	}
+}
@@ -1,14 +1,21 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"strings"
 
 	"github.com/mgironi/operation-fire-quasar/location"
 	"github.com/mgironi/operation-fire-quasar/message"
+	"github.com/mgironi/operation-fire-quasar/model"
 	"github.com/mgironi/operation-fire-quasar/store"
+	"github.com/mgironi/operation-fire-quasar/tracking"
 	"github.com/mgironi/operation-fire-quasar/web"
 )
 
+// Defines the track identifier of the command execution, there is a single track.
+const CMD_TRACK_ID = "cmd"
+
 func main() {
 	log.SetFlags(0)
 
@@ -16,6 +23,9 @@ func main() {
 	if isAskingToRunAsWebServer() {
 		// Runs as as a web server
 		RunAsWebServer()
+	} else if IsProfileMigrateArgPresent() {
+		// Migrates the datasets to the current keys schema, and exits
+		RunDatasetsMigration()
 	} else {
 		// Runs as simple cmd execution
 		RunAsSimpleCmdExecution()
@@ -33,10 +43,28 @@ func RunAsWebServer() {
 	// initialices the store (in memory)
 	store.Initialize()
 
+	// loads the phrases dictionary of the message gaps suggestions
+	message.InitializePhraseModel()
+
 	// initialize web server
 	web.InitializeServer()
 }
 
+// Migrates the datasets saved in Redis with the previous keys schema, see store.MigrateDatasets.
+func RunDatasetsMigration() {
+	log.Print("migrating datasets...")
+
+	// only the Redis connection, the datasets store in use is Redis
+	store.InitializeMemorycacheConnection()
+
+	migrated, indexed, skipped, err := store.MigrateDatasets()
+	if err != nil {
+		log.Printf("Error migrating datasets. Trace: %s", err.Error())
+		return
+	}
+	log.Printf("datasets migration done. Migrated: %d, indexed: %d, skipped: %d", migrated, indexed, skipped)
+}
+
 func RunAsSimpleCmdExecution() {
 	// checks and console display, if only asked for help menu/instructions
 	if AskForHelp() {
@@ -46,21 +74,135 @@ func RunAsSimpleCmdExecution() {
 	// initialices the store (in memory)
 	store.InitializeCmd()
 
+	// checks for tracking execution
+	if trackArg, isPresent := GetTrackArg(); isPresent {
+		RunTrackCmdExecution(trackArg)
+		return
+	}
+
 	// parse
 	distances, messages, parseErr := ParseArgs()
 	if parseErr != nil {
 		log.Fatalf("ERROR\t%s", parseErr.Error())
 	}
 
-	// Gets location
-	x, y := GetLocation(distances...)
-	log.Printf("The location coordinates is x: %f, y: %f", x, y)
+	// checks for missing distances, with only two distances gets the ambiguous location
+	indexes, available := GetAvailableDistances(distances)
+	if len(available) < len(distances) {
+		if len(available) != 2 {
+			log.Fatalf("ERROR\tmissing distances, only %d of %d available. The ambiguous location needs 2 distances.", len(available), len(distances))
+		}
+		RunAmbiguousCmdExecution(indexes, available, messages)
+		return
+	}
+
+	// Gets location with the solver asked for
+	var solution location.Solution
+	var solver location.Solver
+	var locErr error
+	if IsBestEffortArgPresent() {
+		var quality location.Quality
+		solution, quality, solver, locErr = location.CalculateBestEffortLocation(distances, nil, GetSolverArg(), location.DefaultThresholds())
+		if locErr == nil {
+			log.Printf("The location quality is '%s' (chi-square: %f, p-value: %f, relative RMS: %f).", quality.Grade, quality.ChiSquare, quality.PValue, quality.RelativeRMS)
+		}
+	} else {
+		solution, solver, locErr = location.CalculateLocationWithSolver(distances, nil, GetSolverArg(), location.DefaultThresholds())
+	}
+	if locErr != nil {
+		log.Printf("Is no possible to compelete calculations. %s", locErr.Error())
+	} else {
+		log.Printf("The location was calculated with the solver '%s'.", solver.Name())
+	}
+	if solution.Spatial {
+		log.Printf("The location coordinates is x: %f, y: %f, z: %f", solution.Position.X, solution.Position.Y, solution.Position.Z)
+	} else {
+		log.Printf("The location coordinates is x: %f, y: %f", solution.Position.X, solution.Position.Y)
+	}
+	if len(solution.Rejected) > 0 {
+		satellitesInfo := store.GetSatellitesInfo()
+		rejected := make([]string, len(solution.Rejected))
+		for i, satIdx := range solution.Rejected {
+			rejected[i] = satellitesInfo[satIdx].Name
+		}
+		log.Printf("The rejected satellites are %s.", strings.Join(rejected, ", "))
+	}
 
 	// Gets complete message
 	message := GetMessage(messages...)
 	log.Printf("The complete message is '%s'.", message)
 }
 
+// Displays the ambiguous location candidates and the message, with the data of only two satelites.
+// input: the satelites indexes, its distances and the messages of all the satelites
+func RunAmbiguousCmdExecution(indexes []int, distances []float32, messages [][]string) {
+	ambiguousLocation, err := location.CalculateAmbiguousLocationFor(indexes, distances)
+	if err != nil {
+		log.Fatalf("ERROR\tIs no possible to compelete calculations. %s", err.Error())
+	}
+	log.Print("The location is ambiguous, there are only two distances.")
+	if ambiguousLocation.ClosestApproach {
+		log.Print("The distances circles don't intersect, the candidate is the closest approach.")
+	}
+	for i, candidate := range ambiguousLocation.Candidates {
+		log.Printf("The location candidate %d coordinates is x: %f, y: %f", i+1, candidate.X, candidate.Y)
+	}
+
+	// Gets partial message, only with the messages of the available satelites
+	availableMessages := [][]string{}
+	for _, satIdx := range indexes {
+		if satIdx < len(messages) {
+			availableMessages = append(availableMessages, messages[satIdx])
+		}
+	}
+	message := GetMessage(availableMessages...)
+	log.Printf("The partial message is '%s'.", message)
+}
+
+// Displays the emitter track state after filtering the fixes, and its predicted position if asked for.
+// input: the track argument
+func RunTrackCmdExecution(trackArg string) {
+	timestamps, values, parseErr := ParseTrackFixes(trackArg)
+	if parseErr != nil {
+		log.Fatalf("ERROR\t%s", parseErr.Error())
+	}
+	predictTimestamp, predict, predictErr := GetPredictArg()
+	if predictErr != nil {
+		log.Fatalf("ERROR\t%s", predictErr.Error())
+	}
+
+	var state tracking.TrackState
+	for i, fixValues := range values {
+		// two values are the fix position, more values are the distances to each satelite
+		position := model.Point{}
+		if len(fixValues) == 2 {
+			position.X, position.Y = float64(fixValues[0]), float64(fixValues[1])
+		} else {
+			x, y, locErr := location.CalculateLocation(fixValues)
+			if locErr != nil {
+				log.Fatalf("ERROR\tIs no possible to calculate fix %d location. %s", i+1, locErr.Error())
+			}
+			position.X, position.Y = float64(x), float64(y)
+		}
+
+		var fixErr error
+		state, fixErr = tracking.AddFix(CMD_TRACK_ID, timestamps[i], position)
+		if fixErr != nil {
+			log.Fatalf("ERROR\t%s", fixErr.Error())
+		}
+	}
+	log.Printf("The track position at %f is x: %f, y: %f", state.Timestamp, state.Position.X, state.Position.Y)
+	log.Printf("The track velocity is vx: %f, vy: %f", state.Velocity.X, state.Velocity.Y)
+
+	if predict {
+		predicted, _, err := tracking.PredictTrack(CMD_TRACK_ID, predictTimestamp)
+		if err != nil {
+			log.Fatalf("ERROR\t%s", err.Error())
+		}
+		log.Printf("The predicted position at %f is x: %f, y: %f", predictTimestamp, predicted.X, predicted.Y)
+	}
+}
+
 // input: distance to the transmitter recieved on each satlelite
 // output: the coordinates 'x' and 'y' of the message emiter
 func GetLocation(distances ...float32) (x, y float32) {
@@ -70,5 +212,38 @@ func GetLocation(distances ...float32) (x, y float32) {
 // input: the message as it is recieved on each satelite
 // output: the message as it is generated by the transmitter
 func GetMessage(messages ...[]string) (msg string) {
-	return message.GetMessage(messages...)
+	var completeMessage message.ConsolidatedMessage
+	if IsVoteArgPresent() {
+		// resolves the conflicting words by vote, displaying the contested ones
+		var contested []message.ContestedWord
+		completeMessage, contested = message.VoteMessage(messages, nil, message.DefaultMatchOptions())
+		for _, contestedWord := range contested {
+			alternatives := make([]string, len(contestedWord.Alternatives))
+			for i, alternative := range contestedWord.Alternatives {
+				alternatives[i] = fmt.Sprintf("'%s' (%g votes)", alternative.Word, alternative.Votes)
+			}
+			log.Printf("The word at position %d is contested, chosen '%s' (%g votes) over %s.", contestedWord.Position, contestedWord.Chosen.Word, contestedWord.Chosen.Votes, strings.Join(alternatives, ", "))
+		}
+	} else {
+		var err error
+		completeMessage, err = message.ConsolidateMessage(messages)
+		if err != nil {
+			log.Printf("Is no possible to compelete calculations. %s", err.Error())
+		}
+	}
+
+	if len(completeMessage.Gaps) > 0 {
+		log.Printf("The message is %.0f%% complete, missing the words at positions %v.", completeMessage.Completeness*100, completeMessage.Gaps)
+	}
+	if IsSuggestArgPresent() {
+		message.InitializePhraseModel()
+		for _, gapSuggestions := range message.SuggestGaps(completeMessage, message.DefaultMatchOptions()) {
+			candidates := make([]string, len(gapSuggestions.Suggestions))
+			for i, suggestion := range gapSuggestions.Suggestions {
+				candidates[i] = fmt.Sprintf("'%s' (score %.2f)", suggestion.Word, suggestion.Score)
+			}
+			log.Printf("The suggested words for the gap at position %d are %s.", gapSuggestions.Position, strings.Join(candidates, ", "))
+		}
+	}
+	return completeMessage.Text(GetGapPlaceholderArg())
 }
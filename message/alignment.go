@@ -0,0 +1,230 @@
+package message
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Defines the alignment score of two equal words.
+const MATCH_SCORE float64 = 2
+
+// Defines the alignment score of two different words, a conflict.
+const MISMATCH_SCORE float64 = -2
+
+// Defines the alignment penalty of a gap in the middle of a message (a shifted word).
+const GAP_PENALTY float64 = 1.5
+
+// Defines the alignment penalty of each word missing at the ends of a message (truncated or delayed transmission).
+const TRUNCATION_PENALTY float64 = 0.5
+
+// Defines the alignment penalty of each word beyond the ends of the full message known so far.
+const EXTENSION_PENALTY float64 = 2.5
+
+// Messages alignment result.
+type Alignment struct {
+	// the words of the full message, empty if unknown
+	Words []string
+	// for each message, the position in the full message of each of its words
+	Positions [][]int
+	// for each message, the position in the full message of its first word (the words missing at the start)
+	Offsets []int
+}
+
+// alignment traceback moves
+const (
+	moveDiagonal = iota
+	moveSkipColumn
+	moveInsertWord
+)
+
+// Aligns the messages received by each satellite, using progressive global alignment (Needleman-Wunsch).
+// The empty words are unknown ones, so they are aligned with any word without score. The messages are aligned
+// from the longest one, each one against the full message built so far, allowing words missing at both ends
+// (TRUNCATION_PENALTY), words beyond the known ends (EXTENSION_PENALTY) and shifted words (GAP_PENALTY).
+// On ties the messages are aligned to the end, so the shorter messages miss words at the start.
+// The words are compared with the given options, see MatchOptions.
+// input: the messages as received by each satellite and the words comparison options.
+// output: the alignment, with the full message words and the position of each message word.
+// error: if two different words are aligned at the same position.
+func AlignMessages(messages [][]string, options MatchOptions) (alignment Alignment, err error) {
+	return alignMessages(messages, false, options)
+}
+
+// Aligns the messages, see AlignMessages.
+// input: the messages, true to keep aligning on conflicts (the full message keeps the word aligned first) and the words comparison options.
+func alignMessages(messages [][]string, keepConflicts bool, options MatchOptions) (alignment Alignment, err error) {
+	alignment.Positions = make([][]int, len(messages))
+	alignment.Offsets = make([]int, len(messages))
+
+	// aligns from the longest message, keeping the given order between messages with the same length
+	for _, msgIdx := range alignmentOrder(messages) {
+		message := messages[msgIdx]
+		if len(message) == 0 {
+			continue
+		}
+		if alignment.Words == nil {
+			alignment.Words = append([]string(nil), message...)
+			alignment.Positions[msgIdx] = identityPositions(len(message))
+			continue
+		}
+
+		columns, positions := alignPair(alignment.Words, message, options)
+		words, conflictErr := mergeAligned(alignment.Words, message, columns, positions, options)
+		if conflictErr != nil && !keepConflicts {
+			return alignment, conflictErr
+		}
+		// moves the positions of the already aligned messages to the new columns
+		for i, previous := range alignment.Positions {
+			for j, position := range previous {
+				alignment.Positions[i][j] = columns[position]
+			}
+		}
+		alignment.Words = words
+		alignment.Positions[msgIdx] = positions
+	}
+
+	for i, positions := range alignment.Positions {
+		if len(positions) > 0 {
+			alignment.Offsets[i] = positions[0]
+		}
+	}
+	return alignment, nil
+}
+
+// Aligns a message against the full message, see AlignMessages.
+// output: the new column of each full message word, and the new column of each message word.
+func alignPair(fullMessage []string, message []string, options MatchOptions) (columns []int, positions []int) {
+	rows, cols := len(fullMessage), len(message)
+
+	// score of the best alignment of the first i full message words with the first j message words
+	score := make([][]float64, rows+1)
+	for i := range score {
+		score[i] = make([]float64, cols+1)
+	}
+	skipPenalty := func(j int) float64 {
+		if j == 0 || j == cols {
+			return TRUNCATION_PENALTY
+		}
+		return GAP_PENALTY
+	}
+	insertPenalty := func(i int) float64 {
+		if i == 0 || i == rows {
+			return EXTENSION_PENALTY
+		}
+		return GAP_PENALTY
+	}
+	for i := 1; i <= rows; i++ {
+		score[i][0] = score[i-1][0] - skipPenalty(0)
+	}
+	for j := 1; j <= cols; j++ {
+		score[0][j] = score[0][j-1] - insertPenalty(0)
+	}
+	for i := 1; i <= rows; i++ {
+		for j := 1; j <= cols; j++ {
+			score[i][j] = score[i-1][j-1] + wordsScore(fullMessage[i-1], message[j-1], options)
+			if skip := score[i-1][j] - skipPenalty(j); skip > score[i][j] {
+				score[i][j] = skip
+			}
+			if insert := score[i][j-1] - insertPenalty(i); insert > score[i][j] {
+				score[i][j] = insert
+			}
+		}
+	}
+
+	// traceback from the end, preferring the diagonal to align the messages to the end
+	moves := []int{}
+	for i, j := rows, cols; i > 0 || j > 0; {
+		switch {
+		case i > 0 && j > 0 && score[i][j] == score[i-1][j-1]+wordsScore(fullMessage[i-1], message[j-1], options):
+			moves = append(moves, moveDiagonal)
+			i, j = i-1, j-1
+		case i > 0 && score[i][j] == score[i-1][j]-skipPenalty(j):
+			moves = append(moves, moveSkipColumn)
+			i--
+		default:
+			moves = append(moves, moveInsertWord)
+			j--
+		}
+	}
+
+	columns = make([]int, 0, rows)
+	positions = make([]int, 0, cols)
+	for k, column := len(moves)-1, 0; k >= 0; k, column = k-1, column+1 {
+		switch moves[k] {
+		case moveDiagonal:
+			columns = append(columns, column)
+			positions = append(positions, column)
+		case moveSkipColumn:
+			columns = append(columns, column)
+		case moveInsertWord:
+			positions = append(positions, column)
+		}
+	}
+	return columns, positions
+}
+
+// Merges an aligned message into the full message.
+// input: the full message, the message and the new columns of both (see alignPair).
+// output: the merged full message, that keeps the full message word on conflicts and matching words.
+// error: if two different words are aligned at the same column.
+func mergeAligned(fullMessage []string, message []string, columns []int, positions []int, options MatchOptions) (merged []string, err error) {
+	// the columns are ascending, so the last ones are the biggest
+	size := 0
+	if len(columns) > 0 {
+		size = columns[len(columns)-1] + 1
+	}
+	if len(positions) > 0 && positions[len(positions)-1] >= size {
+		size = positions[len(positions)-1] + 1
+	}
+	merged = make([]string, size)
+	for i, column := range columns {
+		merged[column] = fullMessage[i]
+	}
+	for j, position := range positions {
+		if message[j] == "" {
+			continue
+		}
+		if merged[position] != "" && !options.Match(merged[position], message[j]) {
+			if err == nil {
+				err = fmt.Errorf("the words at position %d mismatch between full an partial messages. Is '%s' and in the partial message is '%s'", position, merged[position], message[j])
+			}
+			continue
+		}
+		if merged[position] == "" {
+			merged[position] = message[j]
+		}
+	}
+	return merged, err
+}
+
+// Gets the alignment score of two words, 0 if some one is unknown (empty).
+func wordsScore(fullMessageWord string, word string, options MatchOptions) float64 {
+	if fullMessageWord == "" || word == "" {
+		return 0
+	}
+	if options.Match(fullMessageWord, word) {
+		return MATCH_SCORE
+	}
+	return MISMATCH_SCORE
+}
+
+// Gets the positions from 0 to count-1.
+func identityPositions(count int) (positions []int) {
+	positions = make([]int, count)
+	for i := range positions {
+		positions[i] = i
+	}
+	return positions
+}
+
+// Gets the messages indexes in alignment order, the longest first. See AlignMessages.
+func alignmentOrder(messages [][]string) (order []int) {
+	order = make([]int, len(messages))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return len(messages[order[a]]) > len(messages[order[b]])
+	})
+	return order
+}
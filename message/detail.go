@@ -0,0 +1,63 @@
+package message
+
+import (
+	"sort"
+)
+
+// Detail of a complete message word.
+type WordDetail struct {
+	// the position in the complete message
+	Position int
+	// the word, empty for a gap
+	Word string
+	// indexes of the messages (satellites) that received the word, ascending
+	Sources []int
+	// count of messages that received the word
+	Agreement int
+	// the word votes over the votes of every message, in the interval [0, 1]. See VoteMessage
+	Confidence float64
+	// true if no message received a word at the position
+	Gap bool
+}
+
+// Builds consolidation message detailing each word: the messages that received it, its agreement and confidence.
+// The confidence of a word is the sum of the reliabilities of the messages that received it, over the sum of the
+// reliabilities of every message. So a word received by every satellite has confidence 1, and a gap has confidence 0.
+// input: the diferent sources of messages list, the reliability of each one (nil or not positive values for
+// DEFAULT_RELIABILITY), true to resolve the conflicting words by vote (see VoteMessage) and the words comparison options.
+// output: the complete message with its gaps, the detail of each position and the contested positions (only by vote).
+// error: if the messages words mismatch, only if not resolving by vote.
+func DetailMessage(messages [][]string, reliabilities []float64, vote bool, options MatchOptions) (completeMessage ConsolidatedMessage, details []WordDetail, contested []ContestedWord, err error) {
+	alignment, err := alignMessages(messages, vote, options)
+	if err != nil {
+		return completeMessage, details, contested, err
+	}
+	words, tallies := tallyVotes(messages, reliabilities, alignment, options)
+
+	totalVotes := float64(0)
+	for msgIdx := range messages {
+		totalVotes += reliabilityOf(reliabilities, msgIdx)
+	}
+
+	details = make([]WordDetail, len(words))
+	for position, positionTallies := range tallies {
+		details[position] = WordDetail{Position: position, Gap: true}
+		if len(positionTallies) == 0 {
+			continue
+		}
+		chosen := positionTallies[0]
+		sources := append([]int(nil), chosen.sources...)
+		sort.Ints(sources)
+		details[position] = WordDetail{
+			Position:   position,
+			Word:       chosen.Word,
+			Sources:    sources,
+			Agreement:  len(sources),
+			Confidence: chosen.Votes / totalVotes,
+		}
+		if len(positionTallies) > 1 {
+			contested = append(contested, contestedWordOf(position, positionTallies))
+		}
+	}
+	return consolidatedMessageOf(words), details, contested, nil
+}
@@ -1,7 +1,6 @@
 package message
 
 import (
-	"fmt"
 	"log"
 	"strings"
 )
@@ -9,52 +8,78 @@ import (
 // input: the message as it is recieved on each satelite
 // output: the message as it is generated by the transmitter
 func GetMessage(messages ...[]string) (msg string) {
-	var err error
-	msg, err = ConsolidateMessage(messages)
+	completeMessage, err := ConsolidateMessage(messages)
 	if err != nil {
 		log.Printf("Is no possible to compelete calculations. %s", err.Error())
 	}
-	return msg
+	return completeMessage.Text("")
 }
 
-// Builds consolidation message
-// input: the diferent sources of messages list
-// outpur: the complete message
-func ConsolidateMessage(messages [][]string) (completeMessage string, err error) {
-	// get coutn messages
-	countMessages := len(messages)
-
-	// get count words of the full message
-	countWords := 0
-	for _, message := range messages {
-		msgLength := len(message)
-		if countWords < msgLength {
-			countWords = msgLength
-		}
+// Consolidated message, with the positions that no message received (the gaps).
+type ConsolidatedMessage struct {
+	// the words of the complete message, empty for a gap
+	Words []string
+	// the positions of the gaps, ascending
+	Gaps []int
+	// the known words over the message words, in the interval [0, 1]. 0 for an empty message
+	Completeness float64
+}
+
+// Gets the message text, rendering each gap with the placeholder.
+func (completeMessage ConsolidatedMessage) Text(placeholder string) string {
+	if placeholder == "" || len(completeMessage.Gaps) == 0 {
+		return strings.Join(completeMessage.Words, " ")
+	}
+	words := append([]string(nil), completeMessage.Words...)
+	for _, position := range completeMessage.Gaps {
+		words[position] = placeholder
 	}
+	return strings.Join(words, " ")
+}
+
+// Checks if the message is complete, without gaps. An empty message isn't complete.
+func (completeMessage ConsolidatedMessage) IsComplete() bool {
+	return len(completeMessage.Words) > 0 && len(completeMessage.Gaps) == 0
+}
 
-	// autcomplete messages with less words
-	for i := 0; i < countMessages; i++ {
-		msgLength := len(messages[i])
-		if countWords > msgLength {
-			partialMessage := make([]string, countWords-msgLength)
-			messages[i] = append(partialMessage, messages[i]...)
+// Builds the consolidated message of the complete message words, finding its gaps.
+func consolidatedMessageOf(words []string) (completeMessage ConsolidatedMessage) {
+	completeMessage.Words = words
+	for position, word := range words {
+		if word == "" {
+			completeMessage.Gaps = append(completeMessage.Gaps, position)
 		}
 	}
+	if len(words) > 0 {
+		completeMessage.Completeness = float64(len(words)-len(completeMessage.Gaps)) / float64(len(words))
+	}
+	return completeMessage
+}
+
+// Builds consolidation message, comparing the words with the configured options (see DefaultMatchOptions).
+// input: the diferent sources of messages list
+// outpur: the complete message, with its gaps
+func ConsolidateMessage(messages [][]string) (completeMessage ConsolidatedMessage, err error) {
+	return ConsolidateMessageWithOptions(messages, DefaultMatchOptions())
+}
 
-	// initializes the fulle message
-	fullMessage := make([]string, countWords)
-	for i := 0; i < countMessages; i++ {
-		for j := 0; j < countWords; j++ {
-			// checks if fullMessage is empty at position message and
-			if len(fullMessage[j]) == 0 && len(messages[i][j]) != 0 {
-				// compies word to fullMessage
-				fullMessage[j] = messages[i][j]
-			} else if (len(fullMessage[j]) > 0 && len(messages[i][j]) > 0) && (fullMessage[j] != messages[i][j]) {
-				return completeMessage, fmt.Errorf("the words at position %d mismatch between full an partial messages. Is '%s' and in the partial message is '%s'", j, fullMessage[j], messages[i][j])
-			}
+// Builds consolidation message, aligning the messages first (see AlignMessages). The matching words with different
+// spellings take the most voted one (see VoteMessage).
+// input: the diferent sources of messages list and the words comparison options.
+// outpur: the complete message, with its gaps
+func ConsolidateMessageWithOptions(messages [][]string, options MatchOptions) (completeMessage ConsolidatedMessage, err error) {
+	alignment, err := AlignMessages(messages, options)
+	if err != nil {
+		return completeMessage, err
+	}
+	words, _ := tallyVotes(messages, nil, alignment, options)
+
+	// reports the applied offsets
+	for i, offset := range alignment.Offsets {
+		if offset > 0 {
+			log.Printf("the message %d was aligned with offset %d", i, offset)
 		}
 	}
-	completeMessage = strings.Join(fullMessage, " ")
-	return completeMessage, nil
+
+	return consolidatedMessageOf(words), nil
 }
@@ -1,6 +1,8 @@
 package message_test
 
 import (
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/mgironi/operation-fire-quasar/message"
@@ -44,6 +46,9 @@ func TestConsolidateMessage(t *testing.T) {
 		{name: "testNils", args: args{[][]string{nil, nil, nil}}, wantCompleteMessage: "", wantErr: false},
 		{name: "testSameSizes", args: args{[][]string{{"", "este", "es", "un", "mensaje"}, {"", "este", "", "un", "mensaje"}, {"", "", "es", "", "mensaje"}}}, wantCompleteMessage: " este es un mensaje", wantErr: false},
 		{name: "testDiferentSizes", args: args{[][]string{{"", "este", "es", "un", "mensaje"}, {"este", "", "un", "mensaje"}, {"", "", "es", "", "mensaje"}}}, wantCompleteMessage: " este es un mensaje", wantErr: false},
+		{name: "testTrailingTruncation", args: args{[][]string{{"este", "es", "un", "mensaje"}, {"este", "es"}, {"", "", "un", ""}}}, wantCompleteMessage: "este es un mensaje", wantErr: false},
+		{name: "testMidShift", args: args{[][]string{{"este", "es", "un", "mensaje"}, {"este", "un", "mensaje"}}}, wantCompleteMessage: "este es un mensaje", wantErr: false},
+		{name: "testTrailingOffset", args: args{[][]string{{"", "este", "es", "un"}, {"este", "es", "un", "mensaje"}}}, wantCompleteMessage: " este es un mensaje", wantErr: false},
 		{name: "testMismatchWords", args: args{[][]string{{"", "este", "es", "un", "mensaje"}, {"este", "", "un", "mensaje"}, {"", "otra", "es", "", "mensaje"}}}, wantCompleteMessage: "", wantErr: true},
 	}
 	for _, tt := range tests {
@@ -53,9 +58,204 @@ func TestConsolidateMessage(t *testing.T) {
 				t.Errorf("ConsolidateMessage() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if gotCompleteMessage != tt.wantCompleteMessage {
-				t.Errorf("ConsolidateMessage() = %v, want %v", gotCompleteMessage, tt.wantCompleteMessage)
+			if gotCompleteMessage.Text("") != tt.wantCompleteMessage {
+				t.Errorf("ConsolidateMessage() = %v, want %v", gotCompleteMessage.Text(""), tt.wantCompleteMessage)
 			}
 		})
 	}
 }
+
+func TestAlignMessages(t *testing.T) {
+	messages := [][]string{{"", "este", "", "un"}, {"es", "un", "mensaje"}, {"este", "es"}}
+	alignment, err := message.AlignMessages(messages, message.MatchOptions{})
+	if err != nil {
+		t.Fatalf("AlignMessages() error = %v", err)
+	}
+	if got := strings.Join(alignment.Words, " "); got != " este es un mensaje" {
+		t.Errorf("AlignMessages() words = '%s', want ' este es un mensaje'", got)
+	}
+	if wantOffsets := []int{0, 2, 1}; !reflect.DeepEqual(alignment.Offsets, wantOffsets) {
+		t.Errorf("AlignMessages() offsets = %v, want %v", alignment.Offsets, wantOffsets)
+	}
+	if wantPositions := [][]int{{0, 1, 2, 3}, {2, 3, 4}, {1, 2}}; !reflect.DeepEqual(alignment.Positions, wantPositions) {
+		t.Errorf("AlignMessages() positions = %v, want %v", alignment.Positions, wantPositions)
+	}
+}
+
+func TestVoteMessage(t *testing.T) {
+	messages := [][]string{{"este", "es", "un", "mensaje"}, {"este", "es", "un", "mesnaje"}, {"", "ez", "un", "mesnaje"}}
+	tests := []struct {
+		name          string
+		reliabilities []float64
+		wantMsg       string
+		wantContested []message.ContestedWord
+	}{
+		{name: "majority", reliabilities: nil, wantMsg: "este es un mesnaje", wantContested: []message.ContestedWord{
+			{Position: 1, Chosen: message.WordVotes{Word: "es", Votes: 2}, Alternatives: []message.WordVotes{{Word: "ez", Votes: 1}}},
+			{Position: 3, Chosen: message.WordVotes{Word: "mesnaje", Votes: 2}, Alternatives: []message.WordVotes{{Word: "mensaje", Votes: 1}}},
+		}},
+		{name: "weighted", reliabilities: []float64{3, 1, 0.5}, wantMsg: "este es un mensaje", wantContested: []message.ContestedWord{
+			{Position: 1, Chosen: message.WordVotes{Word: "es", Votes: 4}, Alternatives: []message.WordVotes{{Word: "ez", Votes: 0.5}}},
+			{Position: 3, Chosen: message.WordVotes{Word: "mensaje", Votes: 3}, Alternatives: []message.WordVotes{{Word: "mesnaje", Votes: 1.5}}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotMsg, gotContested := message.VoteMessage(messages, tt.reliabilities, message.MatchOptions{})
+			if gotMsg.Text("") != tt.wantMsg {
+				t.Errorf("VoteMessage() = '%s', want '%s'", gotMsg.Text(""), tt.wantMsg)
+			}
+			if !reflect.DeepEqual(gotContested, tt.wantContested) {
+				t.Errorf("VoteMessage() contested = %+v, want %+v", gotContested, tt.wantContested)
+			}
+		})
+	}
+}
+
+func TestDetailMessage(t *testing.T) {
+	messages := [][]string{{"este", "", "un", "", "mensaje"}, {"", "es", "", "", "mensaje"}, {"este", "es", "", "", "mesnaje"}}
+	gotMsg, gotDetails, gotContested, err := message.DetailMessage(messages, []float64{2, 1, 1}, true, message.MatchOptions{})
+	if err != nil {
+		t.Fatalf("DetailMessage() error = %v", err)
+	}
+	if gotMsg.Text("[?]") != "este es un [?] mensaje" {
+		t.Errorf("DetailMessage() = '%s', want 'este es un [?] mensaje'", gotMsg.Text("[?]"))
+	}
+	wantDetails := []message.WordDetail{
+		{Position: 0, Word: "este", Sources: []int{0, 2}, Agreement: 2, Confidence: 0.75},
+		{Position: 1, Word: "es", Sources: []int{1, 2}, Agreement: 2, Confidence: 0.5},
+		{Position: 2, Word: "un", Sources: []int{0}, Agreement: 1, Confidence: 0.5},
+		{Position: 3, Gap: true},
+		{Position: 4, Word: "mensaje", Sources: []int{0, 1}, Agreement: 2, Confidence: 0.75},
+	}
+	if !reflect.DeepEqual(gotDetails, wantDetails) {
+		t.Errorf("DetailMessage() details = %+v, want %+v", gotDetails, wantDetails)
+	}
+	if len(gotContested) != 1 || gotContested[0].Position != 4 {
+		t.Errorf("DetailMessage() contested = %+v, want position 4", gotContested)
+	}
+
+	// without vote the mismatching words fail
+	if _, _, _, err := message.DetailMessage(messages, nil, false, message.MatchOptions{}); err == nil {
+		t.Error("DetailMessage() without vote, want error")
+	}
+}
+
+func TestMatchOptions(t *testing.T) {
+	tests := []struct {
+		name           string
+		normalizations string
+		minRatio       int
+		word           string
+		otherWord      string
+		want           bool
+	}{
+		{name: "testExact", normalizations: "", minRatio: 100, word: "Mensaje", otherWord: "mensaje", want: false},
+		{name: "testFoldCase", normalizations: "case", minRatio: 100, word: "Mensaje", otherWord: "mensaje", want: true},
+		{name: "testNFC", normalizations: "nfc", minRatio: 100, word: "acción", otherWord: "acción", want: true},
+		{name: "testPunctuation", normalizations: "punctuation", minRatio: 100, word: "mensaje.", otherWord: "mensaje", want: true},
+		{name: "testFuzzy", normalizations: "", minRatio: 85, word: "mesage", otherWord: "message", want: true},
+		{name: "testFuzzyTooFar", normalizations: "", minRatio: 85, word: "este", otherWord: "otra", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options, err := message.ParseMatchOptions(tt.normalizations, tt.minRatio)
+			if err != nil {
+				t.Fatalf("ParseMatchOptions() error = %v", err)
+			}
+			if got := options.Match(tt.word, tt.otherWord); got != tt.want {
+				t.Errorf("Match('%s', '%s') = %v, want %v", tt.word, tt.otherWord, got, tt.want)
+			}
+		})
+	}
+
+	if _, err := message.ParseMatchOptions("case,accents", 100); err == nil {
+		t.Error("ParseMatchOptions() with unknown normalization, want error")
+	}
+	if _, err := message.ParseMatchOptions("case", 101); err == nil {
+		t.Error("ParseMatchOptions() with ratio greater than 100, want error")
+	}
+}
+
+func TestConsolidateMessageWithOptions(t *testing.T) {
+	options := message.MatchOptions{FoldCase: true, StripPunctuation: true, MinRatio: 85}
+	messages := [][]string{{"este", "es", "un", "message."}, {"Este", "es", "", "message"}, {"este", "", "un", "message"}, {"este", "es", "un", "mesage"}}
+	gotMsg, err := message.ConsolidateMessageWithOptions(messages, options)
+	if err != nil {
+		t.Fatalf("ConsolidateMessageWithOptions() error = %v", err)
+	}
+	// each word takes its most voted spelling
+	if gotMsg.Text("") != "este es un message" {
+		t.Errorf("ConsolidateMessageWithOptions() = '%s', want 'este es un message'", gotMsg.Text(""))
+	}
+
+	// without options the words mismatch
+	if _, err := message.ConsolidateMessageWithOptions(messages, message.MatchOptions{}); err == nil {
+		t.Error("ConsolidateMessageWithOptions() without options, want error")
+	}
+}
+
+func TestConsolidatedMessageGaps(t *testing.T) {
+	tests := []struct {
+		name             string
+		messages         [][]string
+		wantText         string
+		wantGaps         []int
+		wantCompleteness float64
+		wantComplete     bool
+	}{
+		{name: "testEmpty", messages: [][]string{nil, nil}, wantText: "", wantGaps: nil, wantCompleteness: 0, wantComplete: false},
+		{name: "testComplete", messages: [][]string{{"este", "", "un"}, {"", "es", "un"}}, wantText: "este es un", wantGaps: nil, wantCompleteness: 1, wantComplete: true},
+		{name: "testGaps", messages: [][]string{{"", "este", "", "un", "mensaje"}, {"", "este", "", "", "mensaje"}}, wantText: "[?] este [?] un mensaje", wantGaps: []int{0, 2}, wantCompleteness: 0.6, wantComplete: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := message.ConsolidateMessageWithOptions(tt.messages, message.MatchOptions{})
+			if err != nil {
+				t.Fatalf("ConsolidateMessageWithOptions() error = %v", err)
+			}
+			if gotText := got.Text("[?]"); gotText != tt.wantText {
+				t.Errorf("Text() = '%s', want '%s'", gotText, tt.wantText)
+			}
+			if !reflect.DeepEqual(got.Gaps, tt.wantGaps) {
+				t.Errorf("Gaps = %v, want %v", got.Gaps, tt.wantGaps)
+			}
+			if got.Completeness != tt.wantCompleteness {
+				t.Errorf("Completeness = %v, want %v", got.Completeness, tt.wantCompleteness)
+			}
+			if got.IsComplete() != tt.wantComplete {
+				t.Errorf("IsComplete() = %v, want %v", got.IsComplete(), tt.wantComplete)
+			}
+		})
+	}
+}
+
+func TestSuggestGaps(t *testing.T) {
+	phraseModel, err := message.LoadPhraseModel("../_test/phrases.txt")
+	if err != nil {
+		t.Fatalf("LoadPhraseModel() error = %v", err)
+	}
+	tests := []struct {
+		name     string
+		messages [][]string
+		want     []message.GapSuggestions
+	}{
+		{name: "testComplete", messages: [][]string{{"este", "es", "un", "mensaje", "secreto"}}, want: nil},
+		{name: "testCandidates", messages: [][]string{{"este", "es", "un", "", "de", "auxilio"}}, want: []message.GapSuggestions{
+			{Position: 3, Suggestions: []message.Suggestion{{Word: "mensaje", Score: 0.6}, {Word: "pedido", Score: 0.4}}},
+		}},
+		{name: "testWithoutContext", messages: [][]string{{"", "", "nada", "conocido"}}, want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			completeMessage, _ := message.ConsolidateMessageWithOptions(tt.messages, message.MatchOptions{})
+			if got := phraseModel.SuggestGaps(completeMessage, message.MatchOptions{}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SuggestGaps() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := message.LoadPhraseModel("../_test/missing.txt"); err == nil {
+		t.Error("LoadPhraseModel() of a missing file, want error")
+	}
+}
@@ -0,0 +1,107 @@
+package message
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"unicode"
+
+	"github.com/mgironi/operation-fire-quasar/support"
+	fuzzy "github.com/paul-mannino/go-fuzzywuzzy"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Defines the normalization that compares the words in lower case.
+const NORMALIZE_CASE string = "case"
+
+// Defines the normalization that compares the words in Unicode NFC form (composed accents).
+const NORMALIZE_NFC string = "nfc"
+
+// Defines the normalization that compares the words without punctuation.
+const NORMALIZE_PUNCTUATION string = "punctuation"
+
+// Defines the fuzzy ratio of an exact words match.
+const EXACT_MATCH_RATIO int = 100
+
+// Words comparison options, used to align and merge the messages.
+type MatchOptions struct {
+	// compares the words in lower case
+	FoldCase bool
+	// compares the words in Unicode NFC form
+	NFC bool
+	// compares the words without punctuation
+	StripPunctuation bool
+	// min fuzzy ratio (edit distance similarity, from 0 to 100) of two normalized words to consider them the same one.
+	// EXACT_MATCH_RATIO (or not positive) for exact match
+	MinRatio int
+}
+
+// Gets the configured words comparison options (OFQ_MESSAGE_NORMALIZATION and OFQ_MESSAGE_MIN_RATIO).
+// The invalid values are ignored, falling back to exact match.
+func DefaultMatchOptions() (options MatchOptions) {
+	options, err := ParseMatchOptions(support.MessageNormalization(), support.MessageMinRatio())
+	if err != nil {
+		log.Printf("WARN invalid message match options, using exact match. Trace: %s", err.Error())
+		return MatchOptions{MinRatio: EXACT_MATCH_RATIO}
+	}
+	return options
+}
+
+// Parses the words comparison options.
+// input: the normalizations list, comma separated (case, nfc and punctuation), and the min fuzzy ratio.
+// output: the options.
+// error: if some normalization is unknown or the ratio is greater than 100.
+func ParseMatchOptions(normalizations string, minRatio int) (options MatchOptions, err error) {
+	for _, normalization := range strings.Split(normalizations, ",") {
+		switch strings.ToLower(strings.TrimSpace(normalization)) {
+		case "":
+		case NORMALIZE_CASE:
+			options.FoldCase = true
+		case NORMALIZE_NFC:
+			options.NFC = true
+		case NORMALIZE_PUNCTUATION:
+			options.StripPunctuation = true
+		default:
+			return options, fmt.Errorf("unknown normalization '%s', use '%s', '%s' or '%s'", normalization, NORMALIZE_CASE, NORMALIZE_NFC, NORMALIZE_PUNCTUATION)
+		}
+	}
+	if minRatio > EXACT_MATCH_RATIO {
+		return options, fmt.Errorf("invalid min ratio %d, must be up to %d", minRatio, EXACT_MATCH_RATIO)
+	}
+	options.MinRatio = minRatio
+	return options, nil
+}
+
+// Normalizes a word to compare it, see MatchOptions.
+func (options MatchOptions) Normalize(word string) string {
+	if options.NFC {
+		word = norm.NFC.String(word)
+	}
+	if options.FoldCase {
+		word = strings.ToLower(word)
+	}
+	if options.StripPunctuation {
+		word = strings.Map(func(r rune) rune {
+			if unicode.IsPunct(r) {
+				return -1
+			}
+			return r
+		}, word)
+	}
+	return word
+}
+
+// Checks if two words are the same one, comparing them normalized and with the fuzzy ratio tolerance.
+func (options MatchOptions) Match(word string, otherWord string) bool {
+	if word == otherWord {
+		return true
+	}
+	normalized, otherNormalized := options.Normalize(word), options.Normalize(otherWord)
+	if normalized == otherNormalized {
+		return true
+	}
+	if options.MinRatio <= 0 || options.MinRatio >= EXACT_MATCH_RATIO {
+		return false
+	}
+	return fuzzy.Ratio(normalized, otherNormalized) >= options.MinRatio
+}
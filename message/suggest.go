@@ -0,0 +1,173 @@
+package message
+
+import (
+	"bufio"
+	"log"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mgironi/operation-fire-quasar/support"
+)
+
+// Defines the order of the phrases n-gram model, the context of a gap are the NGRAM_ORDER-1 words at each side.
+const NGRAM_ORDER int = 3
+
+// Defines the max count of suggested words of each gap.
+const MAX_GAP_SUGGESTIONS int = 3
+
+// Defines the start of a comment line in the phrases file.
+const PHRASES_COMMENT_PREFIX string = "#"
+
+// A word suggested to fill a gap and its score.
+type Suggestion struct {
+	Word string
+	// the context evidence of the word over the evidence of every suggested word, in the interval (0, 1]
+	Score float64
+}
+
+// The suggested words of a message gap.
+type GapSuggestions struct {
+	// the gap position in the complete message
+	Position int
+	// the suggested words, the best scored first
+	Suggestions []Suggestion
+}
+
+// Phrases dictionary, used as an n-gram model to suggest the words of the message gaps.
+type PhraseModel struct {
+	phrases [][]string
+}
+
+// the configured phrases model, nil if not configured
+var phraseModel *PhraseModel
+
+// Initializes the phrases model from the configured phrases file (OFQ_PHRASES_FILE). Without phrases file the gaps
+// suggestions are disabled.
+func InitializePhraseModel() {
+	path := support.PhrasesFile()
+	if path == "" {
+		phraseModel = nil
+		return
+	}
+	var err error
+	phraseModel, err = LoadPhraseModel(path)
+	if err != nil {
+		log.Printf("WARN Is no possible to load the phrases file '%s', the gaps suggestions are disabled. Trace: %s", path, err.Error())
+		phraseModel = nil
+		return
+	}
+	log.Printf("Loaded %d phrases from '%s'.", len(phraseModel.phrases), path)
+}
+
+// Loads a phrases model from a file, with a phrase per line and its words separated by spaces. The empty lines and
+// the ones starting with PHRASES_COMMENT_PREFIX are ignored. The repeated phrases weight more.
+// error: if the file can't be read.
+func LoadPhraseModel(path string) (model *PhraseModel, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	phrases := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, PHRASES_COMMENT_PREFIX) {
+			continue
+		}
+		phrases = append(phrases, line)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+	return NewPhraseModel(phrases), nil
+}
+
+// Builds a phrases model, see LoadPhraseModel.
+func NewPhraseModel(phrases []string) (model *PhraseModel) {
+	model = &PhraseModel{}
+	for _, phrase := range phrases {
+		if words := strings.Fields(phrase); len(words) > 0 {
+			model.phrases = append(model.phrases, words)
+		}
+	}
+	return model
+}
+
+// Suggests the words of the message gaps with the configured phrases model, see PhraseModel.SuggestGaps.
+// output: the gaps suggestions, nil if the phrases model isn't configured.
+func SuggestGaps(completeMessage ConsolidatedMessage, options MatchOptions) (suggestions []GapSuggestions) {
+	if phraseModel == nil {
+		return nil
+	}
+	return phraseModel.SuggestGaps(completeMessage, options)
+}
+
+// Suggests the words of the message gaps. Each phrase word whose neighbours match the gap neighbours (up to
+// NGRAM_ORDER-1 words at each side, compared with the given options) is a candidate, with as much evidence as
+// matching neighbours. The score of a candidate is its evidence over the evidence of every candidate of the gap.
+// The suggestions are never merged into the message.
+// input: the consolidated message and the words comparison options.
+// output: the suggestions of each gap with candidates, ordered by position.
+func (model *PhraseModel) SuggestGaps(completeMessage ConsolidatedMessage, options MatchOptions) (suggestions []GapSuggestions) {
+	for _, position := range completeMessage.Gaps {
+		evidences := map[string]float64{}
+		spellings := map[string]string{}
+		total := float64(0)
+		for _, phrase := range model.phrases {
+			for i, word := range phrase {
+				evidence := contextMatches(completeMessage.Words, position, phrase, i, options)
+				if evidence == 0 {
+					continue
+				}
+				// the matching words are the same candidate, with the spelling found first
+				key := options.Normalize(word)
+				if _, isPresent := spellings[key]; !isPresent {
+					spellings[key] = word
+				}
+				evidences[key] += float64(evidence)
+				total += float64(evidence)
+			}
+		}
+		if total == 0 {
+			continue
+		}
+
+		gapSuggestions := GapSuggestions{Position: position}
+		for key, evidence := range evidences {
+			gapSuggestions.Suggestions = append(gapSuggestions.Suggestions, Suggestion{Word: spellings[key], Score: evidence / total})
+		}
+		sort.Slice(gapSuggestions.Suggestions, func(a, b int) bool {
+			suggestionA, suggestionB := gapSuggestions.Suggestions[a], gapSuggestions.Suggestions[b]
+			if suggestionA.Score != suggestionB.Score {
+				return suggestionA.Score > suggestionB.Score
+			}
+			return suggestionA.Word < suggestionB.Word
+		})
+		if len(gapSuggestions.Suggestions) > MAX_GAP_SUGGESTIONS {
+			gapSuggestions.Suggestions = gapSuggestions.Suggestions[:MAX_GAP_SUGGESTIONS]
+		}
+		suggestions = append(suggestions, gapSuggestions)
+	}
+	return suggestions
+}
+
+// Counts the consecutive neighbours of a message gap that match the neighbours of a phrase word, at both sides.
+// The context ends at the first gap or mismatch.
+func contextMatches(words []string, position int, phrase []string, wordIdx int, options MatchOptions) (matches int) {
+	for _, direction := range []int{-1, 1} {
+		for distance := 1; distance < NGRAM_ORDER; distance++ {
+			messageIdx, phraseIdx := position+direction*distance, wordIdx+direction*distance
+			if messageIdx < 0 || messageIdx >= len(words) || phraseIdx < 0 || phraseIdx >= len(phrase) {
+				break
+			}
+			if words[messageIdx] == "" || !options.Match(words[messageIdx], phrase[phraseIdx]) {
+				break
+			}
+			matches++
+		}
+	}
+	return matches
+}
@@ -0,0 +1,136 @@
+package message
+
+import (
+	"log"
+	"sort"
+)
+
+// Defines the vote weight of a satellite with unknown reliability.
+const DEFAULT_RELIABILITY float64 = 1
+
+// A word and its votes.
+type WordVotes struct {
+	Word string
+	// the sum of the reliabilities of the satellites that received the word
+	Votes float64
+}
+
+// A message position where the satellites received different words.
+type ContestedWord struct {
+	// the position in the full message
+	Position int
+	// the chosen word, the most voted one
+	Chosen WordVotes
+	// the other words received, the most voted first
+	Alternatives []WordVotes
+}
+
+// Builds consolidation message resolving the conflicting words by majority vote.
+// The messages are aligned first (see AlignMessages), then each position takes the word with most votes, where each
+// satellite votes with its reliability. On ties, the word of the longest message (aligned first) is chosen.
+// The matching words (see MatchOptions) vote together, and its spelling is the most voted one.
+// input: the diferent sources of messages list, the reliability of each one (nil or not positive values for DEFAULT_RELIABILITY)
+// and the words comparison options.
+// output: the complete message, with its gaps, and its contested positions, ordered by position.
+func VoteMessage(messages [][]string, reliabilities []float64, options MatchOptions) (completeMessage ConsolidatedMessage, contested []ContestedWord) {
+	alignment, _ := alignMessages(messages, true, options)
+	words, tallies := tallyVotes(messages, reliabilities, alignment, options)
+	for position, positionTallies := range tallies {
+		if len(positionTallies) > 1 {
+			log.Printf("WARN contested word at position %d, chosen '%s' with %g votes of %d alternatives", position, positionTallies[0].Word, positionTallies[0].Votes, len(positionTallies)-1)
+			contested = append(contested, contestedWordOf(position, positionTallies))
+		}
+	}
+	return consolidatedMessageOf(words), contested
+}
+
+// The votes of a word at a message position, the messages that received it and the votes of each spelling.
+type wordTally struct {
+	WordVotes
+	sources   []int
+	spellings []WordVotes
+}
+
+// Sums the votes of each word by position, see VoteMessage.
+// output: the most voted word of each position (empty if no message received one), and the words tallies of each
+// position, the most voted first. Each word takes its most voted spelling.
+func tallyVotes(messages [][]string, reliabilities []float64, alignment Alignment, options MatchOptions) (words []string, tallies [][]wordTally) {
+	// keeps the alignment order of the words, so the ties are resolved by it
+	tallies = make([][]wordTally, len(alignment.Words))
+	for _, msgIdx := range alignmentOrder(messages) {
+		reliability := reliabilityOf(reliabilities, msgIdx)
+		for j, position := range alignment.Positions[msgIdx] {
+			word := messages[msgIdx][j]
+			if word == "" {
+				continue
+			}
+			tallies[position] = addVotes(tallies[position], word, reliability, msgIdx, options)
+		}
+	}
+
+	words = make([]string, len(alignment.Words))
+	for position, positionTallies := range tallies {
+		if len(positionTallies) == 0 {
+			continue
+		}
+		// stable, so the ties keep the alignment order
+		for i := range positionTallies {
+			spellings := positionTallies[i].spellings
+			sort.SliceStable(spellings, func(a, b int) bool {
+				return spellings[a].Votes > spellings[b].Votes
+			})
+			positionTallies[i].Word = spellings[0].Word
+		}
+		sort.SliceStable(positionTallies, func(a, b int) bool {
+			return positionTallies[a].Votes > positionTallies[b].Votes
+		})
+		words[position] = positionTallies[0].Word
+	}
+	return words, tallies
+}
+
+// Adds the votes of a word received by a message to the position tallies, to the tally of the matching word if any.
+// The word is matched with the first spelling of each tally.
+func addVotes(positionTallies []wordTally, word string, votes float64, msgIdx int, options MatchOptions) []wordTally {
+	for i := range positionTallies {
+		if options.Match(positionTallies[i].spellings[0].Word, word) {
+			positionTallies[i].Votes += votes
+			positionTallies[i].sources = append(positionTallies[i].sources, msgIdx)
+			positionTallies[i].spellings = addSpellingVotes(positionTallies[i].spellings, word, votes)
+			return positionTallies
+		}
+	}
+	return append(positionTallies, wordTally{
+		WordVotes: WordVotes{Word: word, Votes: votes},
+		sources:   []int{msgIdx},
+		spellings: []WordVotes{{Word: word, Votes: votes}},
+	})
+}
+
+// Adds the votes of a spelling.
+func addSpellingVotes(spellings []WordVotes, word string, votes float64) []WordVotes {
+	for i := range spellings {
+		if spellings[i].Word == word {
+			spellings[i].Votes += votes
+			return spellings
+		}
+	}
+	return append(spellings, WordVotes{Word: word, Votes: votes})
+}
+
+// Gets the contested word of a position with more than one word tally.
+func contestedWordOf(position int, positionTallies []wordTally) (contested ContestedWord) {
+	contested = ContestedWord{Position: position, Chosen: positionTallies[0].WordVotes}
+	for _, alternative := range positionTallies[1:] {
+		contested.Alternatives = append(contested.Alternatives, alternative.WordVotes)
+	}
+	return contested
+}
+
+// Gets the reliability of a message, DEFAULT_RELIABILITY if unknown.
+func reliabilityOf(reliabilities []float64, msgIdx int) float64 {
+	if msgIdx < len(reliabilities) && reliabilities[msgIdx] > 0 {
+		return reliabilities[msgIdx]
+	}
+	return DEFAULT_RELIABILITY
+}
@@ -0,0 +1,166 @@
+package model
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Defines the WGS84 ellipsoid semi major axis (equatorial radius) in meters.
+const WGS84_SEMI_MAJOR_AXIS float64 = 6378137.0
+
+// Defines the WGS84 ellipsoid flattening.
+const WGS84_FLATTENING float64 = 1 / 298.257223563
+
+// Defines the WGS84 ellipsoid first eccentricity squared, e^2 = f*(2-f).
+const WGS84_ECCENTRICITY_SQUARED float64 = WGS84_FLATTENING * (2 - WGS84_FLATTENING)
+
+// Defines the max iterations count for the ECEF to geodetic conversion.
+const GEODETIC_MAX_ITERATIONS int = 20
+
+// Defines the latitude change (radians) under which the ECEF to geodetic conversion is considered converged.
+const GEODETIC_CONVERGENCE_TOLERANCE float64 = 1e-12
+
+// Defines the reference frame of the coordinates.
+type Frame string
+
+// Local cartesian frame, the one used by the satellites registry (ENU at the reference origin if configured).
+const FRAME_CARTESIAN Frame = "cartesian"
+
+// Local East-North-Up frame, at a given origin.
+const FRAME_ENU Frame = "enu"
+
+// Geodetic frame, WGS84 latitude, longitude (degrees) and altitude (meters).
+const FRAME_GEODETIC Frame = "geodetic"
+
+// Defines the WGS84 geodetic coordinates. Latitude and longitude in degrees, altitude in meters over the ellipsoid.
+type GeodeticCoordinates struct {
+	Latitude  float64
+	Longitude float64
+	Altitude  float64
+}
+
+// stringfy GeodeticCoordinates value properties.
+func (geo GeodeticCoordinates) String() string {
+	return fmt.Sprintf("(lat: %f, lon: %f, alt: %f)", geo.Latitude, geo.Longitude, geo.Altitude)
+}
+
+// Parses geodetic coordinates from a string.
+// input: the string with format '<latitude>,<longitude>[,<altitude>]', the altitude is optional (0 by default).
+// output: the geodetic coordinates.
+// error: if the format is invalid or the latitude/longitude are out of range.
+func ParseGeodeticCoordinates(geoStr string) (geo GeodeticCoordinates, err error) {
+	values := strings.Split(geoStr, ",")
+	if len(values) < 2 || len(values) > 3 {
+		return geo, fmt.Errorf("can't parse geodetic coordinates '%s', use format '<latitude>,<longitude>[,<altitude>]'", geoStr)
+	}
+
+	parsedValues := make([]float64, len(values))
+	for i, value := range values {
+		var parseErr error
+		parsedValues[i], parseErr = strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if parseErr != nil {
+			return geo, fmt.Errorf("can't parse geodetic coordinates '%s'. %s", geoStr, parseErr.Error())
+		}
+	}
+	geo = GeodeticCoordinates{Latitude: parsedValues[0], Longitude: parsedValues[1]}
+	if len(parsedValues) == 3 {
+		geo.Altitude = parsedValues[2]
+	}
+
+	if math.Abs(geo.Latitude) > 90 || math.Abs(geo.Longitude) > 180 {
+		return geo, fmt.Errorf("geodetic coordinates '%s' out of range. Latitude must be in [-90,90] and longitude in [-180,180]", geoStr)
+	}
+	return geo, nil
+}
+
+// Converts geodetic coordinates to ECEF (Earth-Centered, Earth-Fixed) cartesian coordinates.
+// The conversion formulas are x=(N+h)*cos(lat)*cos(lon), y=(N+h)*cos(lat)*sin(lon) and z=(N*(1-e^2)+h)*sin(lat),
+// where N=a/sqrt(1-e^2*sin(lat)^2) is the prime vertical radius of curvature.
+// input: the geodetic coordinates.
+// output: the ECEF point in meters.
+func GeodeticToECEF(geo GeodeticCoordinates) Point {
+	lat := geo.Latitude * math.Pi / 180
+	lon := geo.Longitude * math.Pi / 180
+	primeVerticalRadius := WGS84_SEMI_MAJOR_AXIS / math.Sqrt(1-WGS84_ECCENTRICITY_SQUARED*math.Pow(math.Sin(lat), 2))
+
+	return Point{
+		X: (primeVerticalRadius + geo.Altitude) * math.Cos(lat) * math.Cos(lon),
+		Y: (primeVerticalRadius + geo.Altitude) * math.Cos(lat) * math.Sin(lon),
+		Z: (primeVerticalRadius*(1-WGS84_ECCENTRICITY_SQUARED) + geo.Altitude) * math.Sin(lat),
+	}
+}
+
+// Converts ECEF (Earth-Centered, Earth-Fixed) cartesian coordinates to geodetic coordinates.
+// The latitude is calculated iteratively, see GeodeticToECEF for the inverse formulas.
+// input: the ECEF point in meters.
+// output: the geodetic coordinates.
+func ECEFToGeodetic(ecef Point) (geo GeodeticCoordinates) {
+	lon := math.Atan2(ecef.Y, ecef.X)
+	distanceToAxis := math.Hypot(ecef.X, ecef.Y)
+
+	// initial latitude, as if the altitude were 0
+	lat := math.Atan2(ecef.Z, distanceToAxis*(1-WGS84_ECCENTRICITY_SQUARED))
+	var alt float64
+	for iteration := 0; iteration < GEODETIC_MAX_ITERATIONS; iteration++ {
+		primeVerticalRadius := WGS84_SEMI_MAJOR_AXIS / math.Sqrt(1-WGS84_ECCENTRICITY_SQUARED*math.Pow(math.Sin(lat), 2))
+
+		// uses the best conditioned formula for the altitude, near the poles cos(lat) tends to 0
+		if math.Abs(lat) < math.Pi/4 {
+			alt = distanceToAxis/math.Cos(lat) - primeVerticalRadius
+		} else {
+			alt = ecef.Z/math.Sin(lat) - primeVerticalRadius*(1-WGS84_ECCENTRICITY_SQUARED)
+		}
+
+		newLat := math.Atan2(ecef.Z, distanceToAxis*(1-WGS84_ECCENTRICITY_SQUARED*primeVerticalRadius/(primeVerticalRadius+alt)))
+		converged := math.Abs(newLat-lat) < GEODETIC_CONVERGENCE_TOLERANCE
+		lat = newLat
+		if converged {
+			break
+		}
+	}
+	return GeodeticCoordinates{Latitude: lat * 180 / math.Pi, Longitude: lon * 180 / math.Pi, Altitude: alt}
+}
+
+// Converts ECEF coordinates to the local East-North-Up frame at the given origin.
+// input: the ECEF point and the ENU frame origin.
+// output: the ENU point (X: east, Y: north, Z: up) in meters.
+func ECEFToENU(ecef Point, origin GeodeticCoordinates) Point {
+	lat := origin.Latitude * math.Pi / 180
+	lon := origin.Longitude * math.Pi / 180
+	delta := ecef.TranslationTo(GeodeticToECEF(origin))
+
+	return Point{
+		X: -math.Sin(lon)*delta.X + math.Cos(lon)*delta.Y,
+		Y: -math.Sin(lat)*math.Cos(lon)*delta.X - math.Sin(lat)*math.Sin(lon)*delta.Y + math.Cos(lat)*delta.Z,
+		Z: math.Cos(lat)*math.Cos(lon)*delta.X + math.Cos(lat)*math.Sin(lon)*delta.Y + math.Sin(lat)*delta.Z,
+	}
+}
+
+// Converts local East-North-Up coordinates at the given origin to ECEF coordinates. Inverse of ECEFToENU.
+// input: the ENU point (X: east, Y: north, Z: up) and the ENU frame origin.
+// output: the ECEF point in meters.
+func ENUToECEF(enu Point, origin GeodeticCoordinates) Point {
+	lat := origin.Latitude * math.Pi / 180
+	lon := origin.Longitude * math.Pi / 180
+	originECEF := GeodeticToECEF(origin)
+
+	return Point{
+		X: originECEF.X - math.Sin(lon)*enu.X - math.Sin(lat)*math.Cos(lon)*enu.Y + math.Cos(lat)*math.Cos(lon)*enu.Z,
+		Y: originECEF.Y + math.Cos(lon)*enu.X - math.Sin(lat)*math.Sin(lon)*enu.Y + math.Cos(lat)*math.Sin(lon)*enu.Z,
+		Z: originECEF.Z + math.Cos(lat)*enu.Y + math.Sin(lat)*enu.Z,
+	}
+}
+
+// Converts geodetic coordinates to the local East-North-Up frame at the given origin.
+// See also GeodeticToECEF and ECEFToENU.
+func GeodeticToENU(geo GeodeticCoordinates, origin GeodeticCoordinates) Point {
+	return ECEFToENU(GeodeticToECEF(geo), origin)
+}
+
+// Converts local East-North-Up coordinates at the given origin to geodetic coordinates.
+// See also ENUToECEF and ECEFToGeodetic.
+func ENUToGeodetic(enu Point, origin GeodeticCoordinates) GeodeticCoordinates {
+	return ECEFToGeodetic(ENUToECEF(enu, origin))
+}
@@ -0,0 +1,86 @@
+package model_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/mgironi/operation-fire-quasar/model"
+)
+
+func TestGeodeticToECEF(t *testing.T) {
+	tests := []struct {
+		name string
+		geo  model.GeodeticCoordinates
+		want model.Point
+	}{
+		{name: "equatorGreenwich", geo: model.GeodeticCoordinates{Latitude: 0, Longitude: 0, Altitude: 0}, want: model.Point{X: model.WGS84_SEMI_MAJOR_AXIS, Y: 0, Z: 0}},
+		{name: "equator90East", geo: model.GeodeticCoordinates{Latitude: 0, Longitude: 90, Altitude: 100}, want: model.Point{X: 0, Y: model.WGS84_SEMI_MAJOR_AXIS + 100, Z: 0}},
+		{name: "northPole", geo: model.GeodeticCoordinates{Latitude: 90, Longitude: 0, Altitude: 0}, want: model.Point{X: 0, Y: 0, Z: 6356752.314245}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := model.GeodeticToECEF(tt.geo)
+			if got.DistanceToPoint(tt.want) > 1e-3 {
+				t.Errorf("GeodeticToECEF() is %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGeodeticRoundTrip(t *testing.T) {
+	origin := model.GeodeticCoordinates{Latitude: -34.6037, Longitude: -58.3816, Altitude: 25}
+	geoPoints := []model.GeodeticCoordinates{
+		origin,
+		{Latitude: -34.5, Longitude: -58.5, Altitude: 1200},
+		{Latitude: 60.1, Longitude: 24.9, Altitude: -15},
+		{Latitude: 89.9, Longitude: 120, Altitude: 300},
+	}
+	for _, geo := range geoPoints {
+		gotGeo := model.ECEFToGeodetic(model.GeodeticToECEF(geo))
+		if math.Abs(gotGeo.Latitude-geo.Latitude) > 1e-9 || math.Abs(gotGeo.Longitude-geo.Longitude) > 1e-9 || math.Abs(gotGeo.Altitude-geo.Altitude) > 1e-4 {
+			t.Errorf("ECEFToGeodetic(GeodeticToECEF()) is %s, want %s", gotGeo, geo)
+		}
+
+		enu := model.GeodeticToENU(geo, origin)
+		gotGeo = model.ENUToGeodetic(enu, origin)
+		if math.Abs(gotGeo.Latitude-geo.Latitude) > 1e-9 || math.Abs(gotGeo.Longitude-geo.Longitude) > 1e-9 || math.Abs(gotGeo.Altitude-geo.Altitude) > 1e-4 {
+			t.Errorf("ENUToGeodetic(GeodeticToENU()) is %s, want %s", gotGeo, geo)
+		}
+	}
+
+	// the origin is the center of the ENU frame, and a point above it is over the up axis
+	if got := model.GeodeticToENU(origin, origin); got.DistanceToPoint(model.Point{}) > 1e-6 {
+		t.Errorf("GeodeticToENU() of origin is %s, want (0, 0)", got)
+	}
+	above := origin
+	above.Altitude += 500
+	if got := model.GeodeticToENU(above, origin); got.DistanceToPoint(model.Point{Z: 500}) > 1e-6 {
+		t.Errorf("GeodeticToENU() of point above origin is %s, want (0, 0, 500)", got)
+	}
+}
+
+func TestParseGeodeticCoordinates(t *testing.T) {
+	tests := []struct {
+		name    string
+		geoStr  string
+		want    model.GeodeticCoordinates
+		wantErr bool
+	}{
+		{name: "withAltitude", geoStr: "-34.6037,-58.3816,25", want: model.GeodeticCoordinates{Latitude: -34.6037, Longitude: -58.3816, Altitude: 25}},
+		{name: "withoutAltitude", geoStr: "10.5, 20.25", want: model.GeodeticCoordinates{Latitude: 10.5, Longitude: 20.25}},
+		{name: "outOfRange", geoStr: "95,20", wantErr: true},
+		{name: "malformed", geoStr: "10,abc", wantErr: true},
+		{name: "insufficient", geoStr: "10", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := model.ParseGeodeticCoordinates(tt.geoStr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseGeodeticCoordinates() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseGeodeticCoordinates() is %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,219 @@
+package model
+
+import (
+	"fmt"
+	"math"
+)
+
+// Defines the kind of a points geometry (layout) problem.
+type GeometryKind string
+
+// Less points than needed to determine a location.
+const GEOMETRY_INSUFFICIENT GeometryKind = "insufficient"
+
+// Two or more points at the same coordinates.
+const GEOMETRY_COINCIDENT GeometryKind = "coincident"
+
+// All the points over the same line.
+const GEOMETRY_COLLINEAR GeometryKind = "collinear"
+
+// The points are almost over the same line, the location calculation is unstable.
+const GEOMETRY_NEAR_SINGULAR GeometryKind = "near-singular"
+
+// All the points over the same plane, the altitude can't be determined.
+const GEOMETRY_COPLANAR GeometryKind = "coplanar"
+
+// Defines the min amount of points needed to determine a location in the plane.
+const GEOMETRY_MIN_POINTS int = 3
+
+// Defines the min amount of points needed to determine a location in the space.
+const GEOMETRY_MIN_SPATIAL_POINTS int = 4
+
+// Defines the spread ratio (narrow/wide axes of the points layout) under which the points are collinear.
+const GEOMETRY_COLLINEAR_TOLERANCE float64 = FLOAT_COMPARISION_TOLERANCE
+
+// Defines the spread ratio (narrow/wide axes of the points layout) under which the points are near singular.
+const GEOMETRY_NEAR_SINGULAR_TOLERANCE float64 = 0.01
+
+// Defines the thickness ratio (thinnest/widest axes of the points layout in the space) under which the points are coplanar.
+const GEOMETRY_COPLANAR_TOLERANCE float64 = 0.01
+
+// Geometry error, describes why a points layout can't be used to determine a location.
+type GeometryError struct {
+	// the kind of geometry problem
+	Kind GeometryKind
+	// the indexes of the points involved
+	Points []int
+	// problem details
+	Detail string
+}
+
+func (geomErr *GeometryError) Error() string {
+	return fmt.Sprintf("invalid points geometry (%s) for points %v. %s", geomErr.Kind, geomErr.Points, geomErr.Detail)
+}
+
+// Validates a points layout to be used as reference to determine a location.
+// The coincident points are detected comparing the distance between each pair of points with the layout size.
+// The collinear and near singular layouts are detected by the spread ratio, the square root of the ratio between
+// the smallest and the biggest eigenvalues of the points scatter matrix (narrow axis length / wide axis length).
+// input: the points coordinates.
+// output: nil if the layout is valid, otherwise a *GeometryError.
+func ValidateGeometry(points []Point) (err error) {
+	allPoints := make([]int, len(points))
+	for i := range points {
+		allPoints[i] = i
+	}
+
+	if len(points) < GEOMETRY_MIN_POINTS {
+		return &GeometryError{Kind: GEOMETRY_INSUFFICIENT, Points: allPoints, Detail: fmt.Sprintf("Points: %d, need %d at least", len(points), GEOMETRY_MIN_POINTS)}
+	}
+
+	// gets the layout size as the biggest distance between points
+	layoutSize := float64(0)
+	for i := range points {
+		for j := i + 1; j < len(points); j++ {
+			layoutSize = math.Max(layoutSize, points[i].DistanceToPoint(points[j]))
+		}
+	}
+
+	// checks for coincident points
+	for i := range points {
+		for j := i + 1; j < len(points); j++ {
+			if points[i].DistanceToPoint(points[j]) <= FLOAT_COMPARISION_TOLERANCE*layoutSize {
+				return &GeometryError{Kind: GEOMETRY_COINCIDENT, Points: []int{i, j}, Detail: fmt.Sprintf("Points %s and %s are at the same coordinates", points[i], points[j])}
+			}
+		}
+	}
+
+	// checks for collinear or near singular layout
+	spreadRatio := SpreadRatio(points)
+	if spreadRatio < GEOMETRY_COLLINEAR_TOLERANCE {
+		return &GeometryError{Kind: GEOMETRY_COLLINEAR, Points: allPoints, Detail: fmt.Sprintf("Spread ratio ~ %.6f", spreadRatio)}
+	}
+	if spreadRatio < GEOMETRY_NEAR_SINGULAR_TOLERANCE {
+		return &GeometryError{Kind: GEOMETRY_NEAR_SINGULAR, Points: allPoints, Detail: fmt.Sprintf("Spread ratio ~ %.6f, need %.4f at least", spreadRatio, GEOMETRY_NEAR_SINGULAR_TOLERANCE)}
+	}
+	return nil
+}
+
+// Calculates the points layout spread ratio, narrow axis length / wide axis length.
+// Uses the eigenvalues of the centered points scatter matrix.
+// input: the points coordinates.
+// output: the ratio in [0,1] interval (0: all points over a line, 1: the points spread evenly in every direction).
+func SpreadRatio(points []Point) float64 {
+	if len(points) == 0 {
+		return 0
+	}
+
+	// calculates centroid
+	var centroid Point
+	for _, pt := range points {
+		centroid.X += pt.X / float64(len(points))
+		centroid.Y += pt.Y / float64(len(points))
+	}
+
+	// calculates scatter matrix
+	var sxx, syy, sxy float64
+	for _, pt := range points {
+		centered := pt.TranslationTo(centroid)
+		sxx += centered.X * centered.X
+		syy += centered.Y * centered.Y
+		sxy += centered.X * centered.Y
+	}
+
+	// calculates eigenvalues of the 2x2 symetric matrix
+	mean := (sxx + syy) / 2
+	radius := math.Hypot((sxx-syy)/2, sxy)
+	biggest := mean + radius
+	smallest := math.Max(mean-radius, 0)
+	if biggest == 0 {
+		return 0
+	}
+	return math.Sqrt(smallest / biggest)
+}
+
+// Validates a points layout to be used as reference to determine a location in the space (X, Y and Z).
+// The points must be 4 at least and not be over the same plane, see ThicknessRatio.
+// input: the points coordinates.
+// output: nil if the layout is valid, otherwise a *GeometryError.
+func ValidateSpatialGeometry(points []Point) (err error) {
+	allPoints := make([]int, len(points))
+	for i := range points {
+		allPoints[i] = i
+	}
+
+	if len(points) < GEOMETRY_MIN_SPATIAL_POINTS {
+		return &GeometryError{Kind: GEOMETRY_INSUFFICIENT, Points: allPoints, Detail: fmt.Sprintf("Points: %d, need %d at least to determine altitude", len(points), GEOMETRY_MIN_SPATIAL_POINTS)}
+	}
+
+	thicknessRatio := ThicknessRatio(points)
+	if thicknessRatio < GEOMETRY_COPLANAR_TOLERANCE {
+		return &GeometryError{Kind: GEOMETRY_COPLANAR, Points: allPoints, Detail: fmt.Sprintf("Thickness ratio ~ %.6f, need %.4f at least", thicknessRatio, GEOMETRY_COPLANAR_TOLERANCE)}
+	}
+	return nil
+}
+
+// Calculates the points layout thickness ratio in the space, thinnest axis length / widest axis length.
+// Uses the eigenvalues of the centered points 3x3 scatter matrix, by the trigonometric solution of the
+// characteristic ecuation of a symetric matrix.
+// input: the points coordinates.
+// output: the ratio in [0,1] interval (0: all points over a plane, 1: the points spread evenly in every direction).
+func ThicknessRatio(points []Point) float64 {
+	if len(points) == 0 {
+		return 0
+	}
+
+	// calculates centroid
+	var centroid Point
+	for _, pt := range points {
+		centroid.X += pt.X / float64(len(points))
+		centroid.Y += pt.Y / float64(len(points))
+		centroid.Z += pt.Z / float64(len(points))
+	}
+
+	// calculates scatter matrix
+	var scatter [3][3]float64
+	for _, pt := range points {
+		centered := pt.TranslationTo(centroid)
+		coords := [3]float64{centered.X, centered.Y, centered.Z}
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				scatter[i][j] += coords[i] * coords[j]
+			}
+		}
+	}
+
+	// calculates eigenvalues of the 3x3 symetric matrix
+	offDiagonal := math.Pow(scatter[0][1], 2) + math.Pow(scatter[0][2], 2) + math.Pow(scatter[1][2], 2)
+	mean := (scatter[0][0] + scatter[1][1] + scatter[2][2]) / 3
+	deviation := math.Pow(scatter[0][0]-mean, 2) + math.Pow(scatter[1][1]-mean, 2) + math.Pow(scatter[2][2]-mean, 2) + 2*offDiagonal
+	if deviation == 0 {
+		// the three eigenvalues are equal
+		if mean == 0 {
+			return 0
+		}
+		return 1
+	}
+	scale := math.Sqrt(deviation / 6)
+
+	// normalized matrix B = (A - mean*I) / scale
+	var normalized [3][3]float64
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			normalized[i][j] = scatter[i][j] / scale
+		}
+		normalized[i][i] = (scatter[i][i] - mean) / scale
+	}
+	determinant := normalized[0][0]*(normalized[1][1]*normalized[2][2]-normalized[1][2]*normalized[2][1]) -
+		normalized[0][1]*(normalized[1][0]*normalized[2][2]-normalized[1][2]*normalized[2][0]) +
+		normalized[0][2]*(normalized[1][0]*normalized[2][1]-normalized[1][1]*normalized[2][0])
+	halfDeterminant := math.Max(-1, math.Min(1, determinant/2))
+	angle := math.Acos(halfDeterminant) / 3
+
+	biggest := mean + 2*scale*math.Cos(angle)
+	smallest := math.Max(mean+2*scale*math.Cos(angle+2*math.Pi/3), 0)
+	if biggest <= 0 {
+		return 0
+	}
+	return math.Sqrt(smallest / biggest)
+}
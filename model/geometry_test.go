@@ -0,0 +1,74 @@
+package model_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mgironi/operation-fire-quasar/model"
+)
+
+func TestValidateGeometry(t *testing.T) {
+	tests := []struct {
+		name     string
+		points   []model.Point
+		wantKind model.GeometryKind
+	}{
+		{name: "valid", points: []model.Point{{X: -500, Y: -200}, {X: 100, Y: -100}, {X: 500, Y: 100}}, wantKind: ""},
+		{name: "validVertical", points: []model.Point{{X: 0, Y: 0}, {X: 0, Y: 100}, {X: 100, Y: 50}}, wantKind: ""},
+		{name: "insufficient", points: []model.Point{{X: 0, Y: 0}, {X: 0, Y: 100}}, wantKind: model.GEOMETRY_INSUFFICIENT},
+		{name: "coincident", points: []model.Point{{X: 100, Y: 100}, {X: 0, Y: 100}, {X: 100, Y: 100}}, wantKind: model.GEOMETRY_COINCIDENT},
+		{name: "collinear", points: []model.Point{{X: 0, Y: 0}, {X: 100, Y: 100}, {X: 300, Y: 300}}, wantKind: model.GEOMETRY_COLLINEAR},
+		{name: "collinearVertical", points: []model.Point{{X: 50, Y: -100}, {X: 50, Y: 0}, {X: 50, Y: 700}}, wantKind: model.GEOMETRY_COLLINEAR},
+		{name: "nearSingular", points: []model.Point{{X: 0, Y: 0}, {X: 500, Y: 1}, {X: 1000, Y: 0}}, wantKind: model.GEOMETRY_NEAR_SINGULAR},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := model.ValidateGeometry(tt.points)
+			if tt.wantKind == "" {
+				if err != nil {
+					t.Errorf("ValidateGeometry() error = %v, want nil", err)
+				}
+				return
+			}
+			var geomErr *model.GeometryError
+			if !errors.As(err, &geomErr) {
+				t.Fatalf("ValidateGeometry() error = %v, want *model.GeometryError", err)
+			}
+			if geomErr.Kind != tt.wantKind {
+				t.Errorf("ValidateGeometry() kind = %s, want %s", geomErr.Kind, tt.wantKind)
+			}
+		})
+	}
+}
+
+func TestValidateSpatialGeometry(t *testing.T) {
+	tests := []struct {
+		name     string
+		points   []model.Point
+		wantKind model.GeometryKind
+	}{
+		{name: "valid", points: []model.Point{{X: -500, Y: -200, Z: 0}, {X: 100, Y: -100, Z: 300}, {X: 500, Y: 100, Z: 0}, {X: 0, Y: 500, Z: 800}}, wantKind: ""},
+		{name: "insufficient", points: []model.Point{{X: -500, Y: -200, Z: 0}, {X: 100, Y: -100, Z: 300}, {X: 500, Y: 100, Z: 0}}, wantKind: model.GEOMETRY_INSUFFICIENT},
+		{name: "planar", points: []model.Point{{X: -500, Y: -200}, {X: 100, Y: -100}, {X: 500, Y: 100}, {X: 0, Y: 500}}, wantKind: model.GEOMETRY_COPLANAR},
+		{name: "sameAltitude", points: []model.Point{{X: -500, Y: -200, Z: 900}, {X: 100, Y: -100, Z: 900}, {X: 500, Y: 100, Z: 900}, {X: 0, Y: 500, Z: 900}}, wantKind: model.GEOMETRY_COPLANAR},
+		{name: "tiltedPlane", points: []model.Point{{X: 0, Y: 0, Z: 0}, {X: 100, Y: 0, Z: 100}, {X: 0, Y: 100, Z: 0}, {X: 100, Y: 100, Z: 100}}, wantKind: model.GEOMETRY_COPLANAR},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := model.ValidateSpatialGeometry(tt.points)
+			if tt.wantKind == "" {
+				if err != nil {
+					t.Errorf("ValidateSpatialGeometry() error = %v, want nil", err)
+				}
+				return
+			}
+			var geomErr *model.GeometryError
+			if !errors.As(err, &geomErr) {
+				t.Fatalf("ValidateSpatialGeometry() error = %v, want *model.GeometryError", err)
+			}
+			if geomErr.Kind != tt.wantKind {
+				t.Errorf("ValidateSpatialGeometry() kind = %s, want %s", geomErr.Kind, tt.wantKind)
+			}
+		})
+	}
+}
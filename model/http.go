@@ -3,35 +3,204 @@ package model
 type CoordinatesResponse struct {
 	X float32 `json:"x"`
 	Y float32 `json:"y"`
+	// only present if the location was calculated in the space (altitude-aware satellites)
+	Z *float32 `json:"z,omitempty" example:"1500.5"`
+	// only present if the geodetic frame was asked for
+	Geodetic *GeodeticResponse `json:"geodetic,omitempty"`
+}
+
+type GeodeticResponse struct {
+	Latitude  float64 `json:"latitude" example:"-34.6037"`
+	Longitude float64 `json:"longitude" example:"-58.3816"`
+	Altitude  float64 `json:"altitude" example:"25.5"`
 }
 
 type TopSecretResponse struct {
 	Position CoordinatesResponse `json:"position"`
 	Message  string              `json:"message"`
+	// the positions of the message words that no satellite received
+	Gaps []int `json:"gaps,omitempty" example:"2"`
+	// the received words over the message words, from 0 to 1. 1 for a complete message
+	Completeness float64           `json:"completeness" example:"1"`
+	Rejected     []string          `json:"rejected,omitempty" example:"sato"`
+	Accuracy     *AccuracyResponse `json:"accuracy,omitempty"`
+	// the name of the solver used to calculate the location
+	Solver string `json:"solver,omitempty" example:"auto"`
+	// the location quality, present only in best effort mode
+	Quality *QualityResponse `json:"quality,omitempty"`
+	// the message positions where the satellites received different words, present only in vote mode
+	Contested []ContestedWordResponse `json:"contested,omitempty"`
+	// the detail of each message word, present only if it was asked for
+	Words []WordDetailResponse `json:"words,omitempty"`
+	// the suggested words of the message gaps, present only if it was asked for. They aren't merged into the message
+	Suggestions []GapSuggestionsResponse `json:"suggestions,omitempty"`
+	// the reference frame of the position, present only if it was asked for
+	Frame string `json:"frame,omitempty" example:"geodetic"`
+	// true when the location can't be determined univocally (only two distances or three timestamps), see candidates
+	Ambiguous bool `json:"ambiguous,omitempty"`
+	// the candidate locations of an ambiguous result
+	Candidates []CoordinatesResponse `json:"candidates,omitempty"`
+	// true when the circles don't intersect and the candidate is the closest approach between them
+	ClosestApproach bool `json:"closestApproach,omitempty"`
+}
+
+type AccuracyResponse struct {
+	Residuals []SatelliteResidualResponse `json:"residuals"`
+	// the position covariance matrix, for example [[0.25,0.01],[0.01,0.16]]. Nested arrays examples aren't supported by swag
+	Covariance   [][]float64          `json:"covariance"`
+	ErrorEllipse ErrorEllipseResponse `json:"errorEllipse"`
+	GDOP         float64              `json:"gdop" example:"1.42"`
+}
+
+type ContestedWordResponse struct {
+	Position     int                 `json:"position" example:"3"`
+	Chosen       WordVotesResponse   `json:"chosen"`
+	Alternatives []WordVotesResponse `json:"alternatives"`
+}
+
+type WordDetailResponse struct {
+	Position int `json:"position" example:"3"`
+	// the word, empty for a gap
+	Word string `json:"word" example:"mensaje"`
+	// the satellites that received the word
+	Satellites []string `json:"satellites" example:"kenobi,sato"`
+	Agreement  int      `json:"agreement" example:"2"`
+	Confidence float64  `json:"confidence" example:"0.67"`
+	// true if no satellite received a word at the position
+	Gap bool `json:"gap,omitempty"`
+}
+
+type GapSuggestionsResponse struct {
+	// the gap position in the message
+	Position int `json:"position" example:"2"`
+	// the suggested words, the best scored first
+	Candidates []SuggestionResponse `json:"candidates"`
+}
+
+type SuggestionResponse struct {
+	Word string `json:"word" example:"auxilio"`
+	// the context evidence of the word over the evidence of every suggested word, from 0 to 1
+	Score float64 `json:"score" example:"0.75"`
+}
+
+type WordVotesResponse struct {
+	Word  string  `json:"word" example:"mensaje"`
+	Votes float64 `json:"votes" example:"2"`
+}
+
+type QualityResponse struct {
+	// exact, good, degraded or rejected
+	Grade       string  `json:"grade" example:"good"`
+	ChiSquare   float64 `json:"chiSquare" example:"1.27"`
+	PValue      float64 `json:"pValue" example:"0.26"`
+	RelativeRMS float64 `json:"relativeRMS" example:"0.0004"`
+}
+
+type SatelliteResidualResponse struct {
+	Name     string  `json:"name" example:"kenobi"`
+	Residual float64 `json:"residual" example:"-0.0032"`
+}
+
+type ErrorEllipseResponse struct {
+	SemiMajorAxis float64 `json:"semiMajorAxis" example:"0.5"`
+	SemiMinorAxis float64 `json:"semiMinorAxis" example:"0.4"`
+	Orientation   float64 `json:"orientation" example:"12.5"`
 }
 
 type SatelliteInfoRequest struct {
 	Name     string   `json:"name" example:"kenobi" redis:"name"`
 	Distance float32  `json:"distance" example:"100.23" redis:"distance"`
 	Message  []string `json:"message" example:",is,a,,message" redis:"message"`
+	// distance standard deviation (coordinates units), optional
+	StdDev float32 `json:"stdDev,omitempty" example:"0.5" redis:"stdDev"`
+	// signal to noise ratio (dB), optional. Used to estimate the distance standard deviation when it's absent
+	SNR *float32 `json:"snr,omitempty" example:"20" redis:"snr"`
+	// satellite reliability, its vote weight when the message words are reconciled by vote. Optional, 1 by default
+	Reliability float32 `json:"reliability,omitempty" example:"0.8" redis:"reliability"`
+	// report time (unix seconds), signed with the report data. Required, unless the unsigned replays are allowed
+	// (OFQ_ALLOW_UNSIGNED_REPLAYS) and the satellite hasn't verification key
+	Timestamp int64 `json:"timestamp,omitempty" example:"1700000000" redis:"timestamp"`
+	// unique report identifier, signed with the report data. Required, unless the unsigned replays are allowed
+	// (OFQ_ALLOW_UNSIGNED_REPLAYS) and the satellite hasn't verification key
+	Nonce string `json:"nonce,omitempty" example:"3f2a9c" redis:"nonce"`
+	// base64 signature of the report, required for the satellites with verification key. See security.SignedPayload
+	Signature string `json:"signature,omitempty" example:"dGhpcyBpcyBhIHNpZ25hdHVyZQ==" redis:"signature"`
 }
 
 type Dataset struct {
 	Satellites []SatelliteInfoRequest
 	Key        string
 	Operation  string
+	// starts at 1 and increases with each update, to detect the concurrent updates
+	Version int64
 }
 
 type TopSecretRequest struct {
 	Satellites []SatelliteInfoRequest `json:"satellites"`
 }
 
+type SatelliteTimingRequest struct {
+	Name string `json:"name" example:"kenobi"`
+	// signal arrival time in seconds, relative to a reference common to all the satellites (and near, to keep the precision)
+	Timestamp float64  `json:"timestamp" example:"0.0000016678"`
+	Message   []string `json:"message" example:",is,a,,message"`
+}
+
+type TopSecretTDOARequest struct {
+	Satellites []SatelliteTimingRequest `json:"satellites"`
+	// signal propagation speed (coordinates units per second), optional. By default the configured one (OFQ_PROPAGATION_SPEED)
+	PropagationSpeed float64 `json:"propagationSpeed,omitempty" example:"299792458"`
+}
+
+type CoordinatesRequest struct {
+	X float32 `json:"x" example:"-100.5"`
+	Y float32 `json:"y" example:"75.2"`
+}
+
+type TrackFixRequest struct {
+	// fix time in seconds, must not be previous to the last track fix
+	Timestamp float64 `json:"timestamp" example:"12.5"`
+	// the fix position in the plane, if absent it's calculated with the satellites distances
+	Position *CoordinatesRequest `json:"position,omitempty"`
+	// the distances to the satellites (raw ranges), used when the position is absent
+	Satellites []SatelliteInfoRequest `json:"satellites,omitempty"`
+}
+
+type TrackRequest struct {
+	Fixes []TrackFixRequest `json:"fixes"`
+}
+
+type VelocityResponse struct {
+	VX float32 `json:"vx" example:"1.5"`
+	VY float32 `json:"vy" example:"-0.25"`
+}
+
+type PredictedPositionResponse struct {
+	Timestamp float64             `json:"timestamp" example:"20"`
+	Position  CoordinatesResponse `json:"position"`
+}
+
+type TrackResponse struct {
+	Track string `json:"track" example:"emitter1"`
+	// timestamp of the last fix
+	Timestamp float64             `json:"timestamp" example:"12.5"`
+	Position  CoordinatesResponse `json:"position"`
+	Velocity  VelocityResponse    `json:"velocity"`
+	// amount of fixes applied to the track
+	Updates int `json:"updates" example:"3"`
+	// only present if a prediction timestamp was asked for
+	Predicted *PredictedPositionResponse `json:"predicted,omitempty"`
+}
+
 type TopSecretSplitRequest struct {
 	*SatelliteInfoRequest
 }
 
 type TopSecretSplitPOSTResponse struct {
 	Operation string `json:"operation"`
+	// the similarity (0 to 100) of the reported message with the one of the dataset it was collected in, present only
+	// if the dataset was found by message (without operation)
+	MatchScore int `json:"matchScore,omitempty" example:"86"`
 }
 
 type ErrorResponse struct {
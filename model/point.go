@@ -8,22 +8,26 @@ import (
 // Defines comparison tolerance between floats values.
 const FLOAT_COMPARISION_TOLERANCE float64 = 0.0001
 
-// Defines Point Struct with X and Y properties.
+// Defines Point Struct with X, Y and the optional Z (altitude, 0 for points in the plane) properties.
 type Point struct {
 	X float64
 	Y float64
+	Z float64
 }
 
-// stringfy Point value properties.
+// stringfy Point value properties. Z is included only if isn't 0.
 func (pt Point) String() string {
-	return fmt.Sprintf("(%f, %f)", pt.X, pt.Y)
+	if pt.Z == 0 {
+		return fmt.Sprintf("(%f, %f)", pt.X, pt.Y)
+	}
+	return fmt.Sprintf("(%f, %f, %f)", pt.X, pt.Y, pt.Z)
 }
 
 // Calculates the distance from Point with ohter Point.
-// Uses the distance between two points formula d=sqrt((Xa-Xb)^2 + (Ya-Yb)^2).
+// Uses the distance between two points formula d=sqrt((Xa-Xb)^2 + (Ya-Yb)^2 + (Za-Zb)^2).
 // Returns the distance.
 func (pt Point) DistanceToPoint(other Point) float64 {
-	return math.Sqrt(math.Pow(pt.X-other.X, 2) + math.Pow(pt.Y-other.Y, 2))
+	return math.Sqrt(math.Pow(pt.X-other.X, 2) + math.Pow(pt.Y-other.Y, 2) + math.Pow(pt.Z-other.Z, 2))
 }
 
 // Calculates the distance from Point with a coordinate x,y given in float32.
@@ -38,10 +42,27 @@ func (pt Point) DistanceToCoordinatesfloat32(x, y float32) float64 {
 // input: reference point to translate to.
 // output: the new Point with traslation.
 func (pt Point) TranslationTo(referencePoint Point) Point {
-	return Point{X: (pt.X - referencePoint.X), Y: pt.Y - referencePoint.Y}
+	return Point{X: (pt.X - referencePoint.X), Y: pt.Y - referencePoint.Y, Z: pt.Z - referencePoint.Z}
 }
 
-// Rotates axes Point coordinates to a given axes rotation angle. Turn anticlockwise.
+// Projects Point to the XY plane (Z coordinate is discarded).
+// output: the new Point with Z equals 0.
+func (pt Point) ProjectionToPlane() Point {
+	return Point{X: pt.X, Y: pt.Y}
+}
+
+// Projects the points list to the XY plane. See also Point.ProjectionToPlane.
+// input: the points list, it's not modified.
+// output: a new points list with the projected points.
+func ProjectPointsToPlane(points []Point) (projected []Point) {
+	projected = make([]Point, len(points))
+	for i, pt := range points {
+		projected[i] = pt.ProjectionToPlane()
+	}
+	return projected
+}
+
+// Rotates axes Point coordinates to a given axes rotation angle. Turn anticlockwise (around Z axis, Z is kept).
 // The coordinates rotation forumala are x'=x*cos(angle)+y*sin(angle) and y'=y*cos(angle)-x*sin(angle).
 // input: axes rotation angle in radians.
 // output: the new Point with rotation.
@@ -52,7 +73,7 @@ func (pt Point) RotateAxesTo(axesRotationAngle float64) Point {
 	// calculate y using coordinates rotation formula. y'=y*cos(angle)-x*sin(angle).
 	y := pt.Y*math.Cos(axesRotationAngle) - pt.X*math.Sin(axesRotationAngle)
 
-	return Point{X: x, Y: y}
+	return Point{X: x, Y: y, Z: pt.Z}
 }
 
 // Rotates axes Point coordinates to a given axes rotation angle with an inverse direction to RotateAxesTo. Turn clockwise.
@@ -66,7 +87,7 @@ func (pt Point) InvertAxesRotationTo(axesRotationAngle float64) Point {
 	// calculates y with y=y'*cos(angle)+x'*sin(angle)
 	y := pt.Y*math.Cos(axesRotationAngle) + pt.X*math.Sin(axesRotationAngle)
 
-	return Point{X: x, Y: y}
+	return Point{X: x, Y: y, Z: pt.Z}
 }
 
 // Checks if Point is equal with other Point, compares property by property using a float comparision tolerance.
@@ -80,5 +101,8 @@ func (pt Point) EqualTo(otherPoint Point) bool {
 	diffY := math.Abs(pt.Y - otherPoint.Y)
 	isEqualY := diffY < FLOAT_COMPARISION_TOLERANCE
 
-	return isEqualX && isEqualY
+	diffZ := math.Abs(pt.Z - otherPoint.Z)
+	isEqualZ := diffZ < FLOAT_COMPARISION_TOLERANCE
+
+	return isEqualX && isEqualY && isEqualZ
 }
@@ -41,6 +41,20 @@ func TestPointDistanceToPoint(t *testing.T) {
 	}
 }
 
+func TestPointDistanceToPointInSpace(t *testing.T) {
+	pointA := model.Point{X: 100, Y: 0, Z: 0}
+	pointB := model.Point{X: 200, Y: 0, Z: 100}
+	distance := pointA.DistanceToPoint(pointB)
+	wanted := math.Sqrt(2) * 100
+	if math.Abs(distance-wanted) > model.FLOAT_COMPARISION_TOLERANCE {
+		t.Errorf(`Distance calc result didn't match. Is %f want %f`, distance, wanted)
+	}
+	projected := pointB.ProjectionToPlane()
+	if projected.Z != 0 || projected.X != pointB.X || projected.Y != pointB.Y {
+		t.Errorf(`Projection result didn't match. Is %s want (%f, %f)`, projected, pointB.X, pointB.Y)
+	}
+}
+
 func TestPointRotateAxesTo(t *testing.T) {
 	point := model.Point{X: 100, Y: 100}
 	rotatedPoint := point.RotateAxesTo(0)
@@ -1,7 +1,13 @@
 package model
 
+import "github.com/mgironi/operation-fire-quasar/security"
+
 // Satelite info struct
 type SateliteInfo struct {
 	Name     string
 	Location Point
+	// standard deviation of the distances measured by the satelite (coordinates units), 0 if unknown
+	NoiseStdDev float64
+	// key to verify the satelite reports signatures, nil if the satelite reports aren't signed
+	VerificationKey *security.VerificationKey
 }
@@ -0,0 +1,28 @@
+package security
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Error of a report without timestamp or nonce, required to detect its replays.
+var ErrMissingNonce = errors.New("the report hasn't timestamp and nonce, required to detect its replays")
+
+// Error of a report already received, with the same nonce.
+var ErrReplayedReport = errors.New("the report was already received, its nonce was used")
+
+// Error of a report whose timestamp is out of the accepted window.
+var ErrStaleReport = errors.New("the report timestamp is out of the accepted window")
+
+// Checks that a report timestamp is in the accepted window around the current time. The window bounds the time the
+// report nonce must be remembered, so older reports can't be replayed once their nonce expires.
+// input: the report timestamp (unix seconds), the current time and the window.
+// error: ErrStaleReport, wrapped with the timestamps, if the report is older or newer than the window.
+func CheckTimestamp(timestamp int64, now time.Time, window time.Duration) (err error) {
+	skew := now.Sub(time.Unix(timestamp, 0))
+	if skew > window || skew < -window {
+		return fmt.Errorf("%w. Timestamp %d, current %d, window %s", ErrStaleReport, timestamp, now.Unix(), window)
+	}
+	return nil
+}
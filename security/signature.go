@@ -0,0 +1,133 @@
+package security
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Defines the HMAC-SHA256 signature algorithm, with a secret shared with the satellite.
+const ALGORITHM_HMAC string = "hmac"
+
+// Defines the Ed25519 signature algorithm, with the satellite public key.
+const ALGORITHM_ED25519 string = "ed25519"
+
+// Defines the separator between the algorithm and the key, see ParseVerificationKey.
+const KEY_ALGORITHM_SEPARATOR string = ":"
+
+// Error of a report without signature.
+var ErrMissingSignature = errors.New("the report isn't signed")
+
+// Error of a report whose signature doesn't match its data, tampered or signed with another key.
+var ErrInvalidSignature = errors.New("the report signature is invalid")
+
+// Key to verify the reports signatures of a satellite.
+type VerificationKey struct {
+	// ALGORITHM_HMAC or ALGORITHM_ED25519
+	Algorithm string
+	// the HMAC secret or the Ed25519 public key
+	Key []byte
+}
+
+// Parses a verification key.
+// input: the key string with format '<algorithm>:<base64 key>'. Example: hmac:c2VjcmV0
+// output: the verification key.
+// error: if the algorithm is unknown, the key isn't base64 encoded or it hasn't the Ed25519 public key size.
+func ParseVerificationKey(keyStr string) (key VerificationKey, err error) {
+	keyParts := strings.SplitN(strings.TrimSpace(keyStr), KEY_ALGORITHM_SEPARATOR, 2)
+	if len(keyParts) != 2 || keyParts[1] == "" {
+		return key, fmt.Errorf("can't parse verification key, use format '<algorithm>%s<base64 key>'", KEY_ALGORITHM_SEPARATOR)
+	}
+	key.Algorithm = strings.ToLower(keyParts[0])
+	key.Key, err = base64.StdEncoding.DecodeString(keyParts[1])
+	if err != nil {
+		return key, fmt.Errorf("can't decode verification key, must be base64 encoded. %s", err.Error())
+	}
+	switch key.Algorithm {
+	case ALGORITHM_HMAC:
+	case ALGORITHM_ED25519:
+		if len(key.Key) != ed25519.PublicKeySize {
+			return key, fmt.Errorf("invalid Ed25519 public key size %d, must be %d bytes", len(key.Key), ed25519.PublicKeySize)
+		}
+	default:
+		return key, fmt.Errorf("unknown signature algorithm '%s', use '%s' or '%s'", key.Algorithm, ALGORITHM_HMAC, ALGORITHM_ED25519)
+	}
+	return key, nil
+}
+
+// Quality data of a satellite report, it affects the location and message results so it's signed too.
+type ReportQuality struct {
+	// distance standard deviation, 0 if absent
+	StdDev float32
+	// signal to noise ratio (dB), nil if absent
+	SNR *float32
+	// satellite reliability, 0 if absent
+	Reliability float32
+}
+
+// Builds the signed payload of a satellite report, the JSON array
+// [name, distance, message, stdDev, snr, reliability, timestamp, nonce], with null snr if absent.
+// Example: ["kenobi",100.5,["este","","un"],0.5,20,0.8,1700000000,"3f2a9c"]
+func SignedPayload(name string, distance float32, message []string, quality ReportQuality, timestamp int64, nonce string) []byte {
+	if message == nil {
+		message = []string{}
+	}
+	// the values are plain, so they are always encoded
+	payload, _ := json.Marshal([]interface{}{name, distance, message, quality.StdDev, quality.SNR, quality.Reliability, timestamp, nonce})
+	return payload
+}
+
+// Signs a payload, see SignedPayload.
+// input: the algorithm, the signing key (the HMAC secret or the Ed25519 private key) and the payload.
+// output: the base64 encoded signature.
+// error: if the algorithm is unknown or the Ed25519 private key is invalid.
+func Sign(algorithm string, signingKey []byte, payload []byte) (signature string, err error) {
+	switch algorithm {
+	case ALGORITHM_HMAC:
+		return base64.StdEncoding.EncodeToString(hmacOf(signingKey, payload)), nil
+	case ALGORITHM_ED25519:
+		if len(signingKey) != ed25519.PrivateKeySize {
+			return "", fmt.Errorf("invalid Ed25519 private key size %d, must be %d bytes", len(signingKey), ed25519.PrivateKeySize)
+		}
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(signingKey, payload)), nil
+	}
+	return "", fmt.Errorf("unknown signature algorithm '%s', use '%s' or '%s'", algorithm, ALGORITHM_HMAC, ALGORITHM_ED25519)
+}
+
+// Verifies the signature of a payload.
+// input: the verification key, the payload and the base64 encoded signature.
+// error: ErrMissingSignature if the signature is empty, ErrInvalidSignature if it doesn't match.
+func Verify(key VerificationKey, payload []byte, signature string) (err error) {
+	if signature == "" {
+		return ErrMissingSignature
+	}
+	signatureBytes, decodeErr := base64.StdEncoding.DecodeString(signature)
+	if decodeErr != nil {
+		return ErrInvalidSignature
+	}
+	switch key.Algorithm {
+	case ALGORITHM_HMAC:
+		if !hmac.Equal(signatureBytes, hmacOf(key.Key, payload)) {
+			return ErrInvalidSignature
+		}
+	case ALGORITHM_ED25519:
+		if len(key.Key) != ed25519.PublicKeySize || !ed25519.Verify(key.Key, payload, signatureBytes) {
+			return ErrInvalidSignature
+		}
+	default:
+		return fmt.Errorf("unknown signature algorithm '%s'", key.Algorithm)
+	}
+	return nil
+}
+
+// Calculates the HMAC-SHA256 of a payload.
+func hmacOf(secret []byte, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,114 @@
+package security_test
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mgironi/operation-fire-quasar/security"
+)
+
+func TestParseVerificationKey(t *testing.T) {
+	publicKey, _, _ := ed25519.GenerateKey(nil)
+	tests := []struct {
+		name    string
+		keyStr  string
+		wantErr bool
+	}{
+		{name: "testHMAC", keyStr: "hmac:" + base64.StdEncoding.EncodeToString([]byte("secret")), wantErr: false},
+		{name: "testEd25519", keyStr: "ed25519:" + base64.StdEncoding.EncodeToString(publicKey), wantErr: false},
+		{name: "testEd25519Size", keyStr: "ed25519:" + base64.StdEncoding.EncodeToString([]byte("short")), wantErr: true},
+		{name: "testUnknownAlgorithm", keyStr: "rsa:" + base64.StdEncoding.EncodeToString([]byte("secret")), wantErr: true},
+		{name: "testNotBase64", keyStr: "hmac:not base64!", wantErr: true},
+		{name: "testWithoutAlgorithm", keyStr: "c2VjcmV0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := security.ParseVerificationKey(tt.keyStr); (err != nil) != tt.wantErr {
+				t.Errorf("ParseVerificationKey() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSignedPayload(t *testing.T) {
+	snr := float32(20)
+	quality := security.ReportQuality{StdDev: 0.5, SNR: &snr, Reliability: 0.8}
+	got := string(security.SignedPayload("kenobi", 100.5, []string{"este", "", "un"}, quality, 1700000000, "3f2a9c"))
+	want := `["kenobi",100.5,["este","","un"],0.5,20,0.8,1700000000,"3f2a9c"]`
+	if got != want {
+		t.Errorf("SignedPayload() = %s, want %s", got, want)
+	}
+	if got := string(security.SignedPayload("kenobi", 100, nil, security.ReportQuality{}, 0, "")); got != `["kenobi",100,[],0,null,0,0,""]` {
+		t.Errorf("SignedPayload() without message nor quality = %s", got)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	payload := security.SignedPayload("kenobi", 100.5, []string{"este", "", "un"}, security.ReportQuality{}, 1700000000, "3f2a9c")
+	tampered := security.SignedPayload("kenobi", 100.5, []string{"este", "", "un"}, security.ReportQuality{}, 1700000000, "3f2a9d")
+	tamperedQuality := security.SignedPayload("kenobi", 100.5, []string{"este", "", "un"}, security.ReportQuality{StdDev: 0.01}, 1700000000, "3f2a9c")
+
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	keys := []struct {
+		name       string
+		key        security.VerificationKey
+		signingKey []byte
+	}{
+		{name: "hmac", key: security.VerificationKey{Algorithm: security.ALGORITHM_HMAC, Key: []byte("secret")}, signingKey: []byte("secret")},
+		{name: "ed25519", key: security.VerificationKey{Algorithm: security.ALGORITHM_ED25519, Key: publicKey}, signingKey: privateKey},
+	}
+	for _, tt := range keys {
+		t.Run(tt.name, func(t *testing.T) {
+			signature, err := security.Sign(tt.key.Algorithm, tt.signingKey, payload)
+			if err != nil {
+				t.Fatalf("Sign() error = %v", err)
+			}
+			if err := security.Verify(tt.key, payload, signature); err != nil {
+				t.Errorf("Verify() error = %v", err)
+			}
+			if err := security.Verify(tt.key, tampered, signature); !errors.Is(err, security.ErrInvalidSignature) {
+				t.Errorf("Verify() of tampered payload error = %v, want %v", err, security.ErrInvalidSignature)
+			}
+			if err := security.Verify(tt.key, tamperedQuality, signature); !errors.Is(err, security.ErrInvalidSignature) {
+				t.Errorf("Verify() of tampered quality error = %v, want %v", err, security.ErrInvalidSignature)
+			}
+			if err := security.Verify(tt.key, payload, ""); !errors.Is(err, security.ErrMissingSignature) {
+				t.Errorf("Verify() without signature error = %v, want %v", err, security.ErrMissingSignature)
+			}
+		})
+	}
+
+	// signed with other secret
+	signature, _ := security.Sign(security.ALGORITHM_HMAC, []byte("other"), payload)
+	if err := security.Verify(keys[0].key, payload, signature); !errors.Is(err, security.ErrInvalidSignature) {
+		t.Errorf("Verify() with other secret error = %v, want %v", err, security.ErrInvalidSignature)
+	}
+}
+
+func TestCheckTimestamp(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name      string
+		timestamp int64
+		wantErr   bool
+	}{
+		{name: "testNow", timestamp: 1700000000, wantErr: false},
+		{name: "testInWindow", timestamp: 1700000000 - 299, wantErr: false},
+		{name: "testStale", timestamp: 1700000000 - 301, wantErr: true},
+		{name: "testFuture", timestamp: 1700000000 + 301, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := security.CheckTimestamp(tt.timestamp, now, 300*time.Second)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckTimestamp() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, security.ErrStaleReport) {
+				t.Errorf("CheckTimestamp() error = %v, want %v", err, security.ErrStaleReport)
+			}
+		})
+	}
+}
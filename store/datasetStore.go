@@ -0,0 +1,327 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mgironi/operation-fire-quasar/model"
+	"github.com/mgironi/operation-fire-quasar/support"
+)
+
+// Defines the Redis datasets store backend.
+const STORE_REDIS string = "redis"
+
+// Defines the in memory datasets store backend, to run without Redis (locally or in tests). The data is lost on exit.
+const STORE_MEMORY string = "memory"
+
+// Store of the datasets collected by the split calls, and of the satellites reports nonces.
+// Each operation has a single dataset, stored by the operation (in Redis the 'dataset:<operation>' hash). The dataset
+// key field is '<operation>:<message>' while incomplete and '<operation>' once complete, it changes on each update and
+// is checked with the version to detect concurrent updates. See SaveNewDataset and UpdateDataset.
+type DatasetStore interface {
+	// Saves the new dataset of an operation with its first satellite data.
+	// output: true if saved.
+	SaveNewDataset(operation string, dataValue model.SatelliteInfoRequest) (saved bool)
+	// Adds the satellite data to the dataset with the previous key and version, keying it again with the consolidated
+	// message. See UpdateDataset.
+	// output: true if saved.
+	// error: ErrDatasetConflict if the dataset isn't the previous version anymore.
+	UpdateDataset(operation string, consolidatedMessage string, previousKey string, previousVersion int64, dataValue model.SatelliteInfoRequest) (saved bool, err error)
+	// Gets the dataset of an operation, or the one that matches the message if the operation is empty, with its
+	// message match score.
+	GetDataset(operation string, message []string) (dataset model.Dataset, matchScore int)
+	// Gets the dataset by its key, empty if not found or if the dataset has another key now.
+	GetDatasetByKey(key string) (dataset model.Dataset)
+	// Gets the dataset of an operation, incomplete or complete, empty if not found.
+	GetDatasetByOperation(operation string) (dataset model.Dataset)
+	// Gets the dataset whose message matches the partial message (the empty words match any word), or the most
+	// similar one by fuzzy match, with the similarity of their messages (0 to 100). Empty if not found.
+	GetDatasetByMessage(message []string) (dataset model.Dataset, matchScore int)
+	// Registers the nonce of a satellite report, only if it wasn't registered before. See RegisterNonce.
+	RegisterNonce(satellite string, nonce string, ttlSeconds int) (isNew bool, err error)
+	// Releases the nonce of a satellite report, so the report can be received again. See ReleaseNonce.
+	ReleaseNonce(satellite string, nonce string) (err error)
+}
+
+// the datasets store in use, nil until initialized
+var datasetStore DatasetStore
+
+// Initialices the datasets store with the configured backend (OFQ_STORE), redis, memory or file.
+// The Redis connection is initialized only for the redis backend.
+// error: if the backend is unknown or the store file can't be opened, the datasets store isn't initialized.
+func InitializeDatasetStore() (err error) {
+	switch backend := support.StoreBackend(); backend {
+	case STORE_MEMORY:
+		log.Print("using in memory datasets store, the data is lost on exit")
+		datasetStore = NewMemoryDatasetStore()
+	case STORE_FILE:
+		path := support.StoreFile()
+		var fileStore *FileDatasetStore
+		fileStore, err = NewFileDatasetStore(path, time.Duration(support.StoreTTL()*float64(time.Second)))
+		if err != nil {
+			return err
+		}
+		log.Printf("using datasets store file '%s'", path)
+		datasetStore = fileStore
+	case STORE_REDIS:
+		InitializeMemorycacheConnection()
+		datasetStore = RedisDatasetStore{}
+	default:
+		err = fmt.Errorf("unknown datasets store '%s', use '%s', '%s' or '%s'", backend, STORE_REDIS, STORE_MEMORY, STORE_FILE)
+	}
+	return err
+}
+
+// Gets the datasets store in use, the Redis one if not initialized.
+func GetDatasetStore() DatasetStore {
+	if datasetStore == nil {
+		return RedisDatasetStore{}
+	}
+	return datasetStore
+}
+
+// Sets the datasets store in use.
+func SetDatasetStore(store DatasetStore) {
+	datasetStore = store
+}
+
+// Datasets store on Redis, see the package functions with the same names.
+type RedisDatasetStore struct{}
+
+func (RedisDatasetStore) SaveNewDataset(operation string, dataValue model.SatelliteInfoRequest) (saved bool) {
+	return SaveNewDataset(operation, dataValue)
+}
+
+func (RedisDatasetStore) UpdateDataset(operation string, consolidatedMessage string, previousKey string, previousVersion int64, dataValue model.SatelliteInfoRequest) (saved bool, err error) {
+	return UpdateDataset(operation, consolidatedMessage, previousKey, previousVersion, dataValue)
+}
+
+func (RedisDatasetStore) GetDataset(operation string, message []string) (dataset model.Dataset, matchScore int) {
+	return GetDataset(operation, message)
+}
+
+func (RedisDatasetStore) GetDatasetByKey(key string) (dataset model.Dataset) {
+	return GetDatasetByKey(key)
+}
+
+func (RedisDatasetStore) GetDatasetByOperation(operation string) (dataset model.Dataset) {
+	return GetDatasetByOperation(operation)
+}
+
+func (RedisDatasetStore) GetDatasetByMessage(message []string) (dataset model.Dataset, matchScore int) {
+	return GetDatasetByMessage(message)
+}
+
+func (RedisDatasetStore) RegisterNonce(satellite string, nonce string, ttlSeconds int) (isNew bool, err error) {
+	return RegisterNonce(satellite, nonce, ttlSeconds)
+}
+
+func (RedisDatasetStore) ReleaseNonce(satellite string, nonce string) (err error) {
+	return ReleaseNonce(satellite, nonce)
+}
+
+// Datasets store in memory, safe for concurrent use. It searches the datasets as the Redis one does.
+type MemoryDatasetStore struct {
+	mutex sync.Mutex
+	// the datasets by operation
+	datasets map[string]model.Dataset
+	// the expiration time of each nonce key
+	nonces map[string]time.Time
+}
+
+// Builds an empty in memory datasets store.
+func NewMemoryDatasetStore() *MemoryDatasetStore {
+	return &MemoryDatasetStore{datasets: map[string]model.Dataset{}, nonces: map[string]time.Time{}}
+}
+
+func (memoryStore *MemoryDatasetStore) SaveNewDataset(operation string, dataValue model.SatelliteInfoRequest) (saved bool) {
+	memoryStore.mutex.Lock()
+	defer memoryStore.mutex.Unlock()
+
+	// as SET NX, doesn't override an existent dataset
+	dataset := newDataset(operation, dataValue)
+	if _, exists := memoryStore.datasets[operation]; exists {
+		log.Printf("Error saving dataset. Operation: %s already has a dataset", operation)
+		return false
+	}
+	memoryStore.datasets[operation] = copyDataset(dataset)
+	return true
+}
+
+func (memoryStore *MemoryDatasetStore) UpdateDataset(operation string, consolidatedMessage string, previousKey string, previousVersion int64, dataValue model.SatelliteInfoRequest) (saved bool, err error) {
+	memoryStore.mutex.Lock()
+	defer memoryStore.mutex.Unlock()
+
+	datasetOperation := operationOfKey(previousKey)
+	previous, exists := memoryStore.datasets[datasetOperation]
+	if !exists || previous.Key != previousKey || previous.Version != previousVersion {
+		return false, ErrDatasetConflict
+	}
+	dataset, isKeyed := addSatelliteData(copyDataset(previous), datasetOperation, consolidatedMessage, dataValue)
+	if !isKeyed {
+		return false, errors.New("the dataset has no operation nor message to build its key")
+	}
+	memoryStore.datasets[datasetOperation] = dataset
+	return true, nil
+}
+
+func (memoryStore *MemoryDatasetStore) GetDataset(operation string, message []string) (dataset model.Dataset, matchScore int) {
+	if operation != "" {
+		return memoryStore.GetDatasetByOperation(operation), 0
+	}
+	if len(message) > 0 {
+		return memoryStore.GetDatasetByMessage(message)
+	}
+	return dataset, 0
+}
+
+func (memoryStore *MemoryDatasetStore) GetDatasetByKey(key string) (dataset model.Dataset) {
+	memoryStore.mutex.Lock()
+	defer memoryStore.mutex.Unlock()
+
+	dataset = memoryStore.datasets[operationOfKey(key)]
+	if dataset.Key != key {
+		return model.Dataset{}
+	}
+	return copyDataset(dataset)
+}
+
+func (memoryStore *MemoryDatasetStore) GetDatasetByOperation(operation string) (dataset model.Dataset) {
+	memoryStore.mutex.Lock()
+	defer memoryStore.mutex.Unlock()
+	return copyDataset(memoryStore.datasets[operation])
+}
+
+func (memoryStore *MemoryDatasetStore) GetDatasetByMessage(message []string) (dataset model.Dataset, matchScore int) {
+	matchFilter := fmt.Sprintf(DATASET_KEY_FORMAT_PATTERN, REDIS_MATCH_PATTERN_WILDCARD, buildMessageMatchPattern(message))
+	dataset = memoryStore.getFirstMatch(matchFilter)
+	if dataset.Key != "" {
+		_, matchScore = bestMatchingKey(message, []string{dataset.Key}, 0)
+		return dataset, matchScore
+	}
+
+	// search by full scan with fuzzywuzzy
+	log.Printf("WARN Key not found, trying filter by fuzzy match process, message: %s", message)
+	memoryStore.mutex.Lock()
+	defer memoryStore.mutex.Unlock()
+	matchKey, matchScore := bestMatchingKey(message, memoryStore.sortedKeys(), FUZZY_PROCESS_MIN_SCORING_ACCEPTED)
+	if matchKey != "" {
+		dataset = copyDataset(memoryStore.datasets[operationOfKey(matchKey)])
+	}
+	return dataset, matchScore
+}
+
+func (memoryStore *MemoryDatasetStore) RegisterNonce(satellite string, nonce string, ttlSeconds int) (isNew bool, err error) {
+	memoryStore.mutex.Lock()
+	defer memoryStore.mutex.Unlock()
+
+	// forgets the expired nonces
+	now := time.Now()
+	for key, expiration := range memoryStore.nonces {
+		if !now.Before(expiration) {
+			delete(memoryStore.nonces, key)
+		}
+	}
+
+	key := NONCE_KEY_PREFIX + satellite + ":" + nonce
+	if _, exists := memoryStore.nonces[key]; exists {
+		return false, nil
+	}
+	memoryStore.nonces[key] = now.Add(time.Duration(ttlSeconds) * time.Second)
+	return true, nil
+}
+
+func (memoryStore *MemoryDatasetStore) ReleaseNonce(satellite string, nonce string) (err error) {
+	memoryStore.mutex.Lock()
+	defer memoryStore.mutex.Unlock()
+
+	delete(memoryStore.nonces, NONCE_KEY_PREFIX+satellite+":"+nonce)
+	return nil
+}
+
+// Gets the dataset of the first key (in alphabetical order) that matches the filter, see matchesPattern.
+func (memoryStore *MemoryDatasetStore) getFirstMatch(matchFilter string) (dataset model.Dataset) {
+	memoryStore.mutex.Lock()
+	defer memoryStore.mutex.Unlock()
+
+	if key := firstMatchingKey(matchFilter, memoryStore.sortedKeys()); key != "" {
+		dataset = copyDataset(memoryStore.datasets[operationOfKey(key)])
+	}
+	return dataset
+}
+
+// Gets the keys of the incomplete datasets (keyed with its message) in alphabetical order.
+func (memoryStore *MemoryDatasetStore) sortedKeys() (keys []string) {
+	keys = make([]string, 0, len(memoryStore.datasets))
+	for operation, dataset := range memoryStore.datasets {
+		if dataset.Key != operation {
+			keys = append(keys, dataset.Key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Gets the first key (of the sorted keys) that matches the filter, see matchesPattern.
+// output: the key, empty if none matches.
+func firstMatchingKey(matchFilter string, sortedKeys []string) (key string) {
+	keys := []string{}
+	for _, key := range sortedKeys {
+		if matchesPattern(matchFilter, key) {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		log.Printf("Key not found, after scanning by: %s", matchFilter)
+		return ""
+	}
+	if len(keys) > 1 {
+		log.Printf("WARN found more than one key, scanning by: %s. Using first key:%s", matchFilter, keys[0])
+	}
+	return keys[0]
+}
+
+// Gets the key whose message is the most similar to the message by fuzzy match.
+// input: the message, the datasets keys and the min scoring accepted.
+// output: the key and its match score (0 to 100), empty if none has the min scoring.
+func bestMatchingKey(message []string, keys []string, processMinScoring int) (matchKey string, matchScore int) {
+	matchChoices := filterKeysByFuzzyProcessScore(strings.Join(message, " "), processMinScoring, keys)
+	sort.SliceStable(matchChoices, func(i, j int) bool {
+		return matchChoices[i].Score > matchChoices[j].Score
+	})
+	if len(matchChoices) > 0 {
+		matchKey, matchScore = matchChoices[0].key, matchChoices[0].Score
+	}
+	return matchKey, matchScore
+}
+
+// Checks if a key matches the pattern, where each REDIS_MATCH_PATTERN_WILDCARD matches any text (as Redis MATCH).
+func matchesPattern(pattern string, key string) bool {
+	parts := strings.Split(pattern, REDIS_MATCH_PATTERN_WILDCARD)
+	if len(parts) == 1 {
+		return pattern == key
+	}
+	if !strings.HasPrefix(key, parts[0]) {
+		return false
+	}
+	key = key[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		partIdx := strings.Index(key, part)
+		if partIdx < 0 {
+			return false
+		}
+		key = key[partIdx+len(part):]
+	}
+	return strings.HasSuffix(key, parts[len(parts)-1])
+}
+
+// Copies a dataset, so the stored one isn't modified by the callers.
+func copyDataset(dataset model.Dataset) model.Dataset {
+	dataset.Satellites = append([]model.SatelliteInfoRequest(nil), dataset.Satellites...)
+	return dataset
+}
@@ -0,0 +1,431 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mgironi/operation-fire-quasar/model"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Defines the file datasets store backend, an embedded key-value file to run without Redis in a single node.
+const STORE_FILE string = "file"
+
+// Defines the max wait to open the store file, locked while another process has it open.
+const STORE_FILE_OPEN_TIMEOUT time.Duration = time.Second
+
+// the buckets of the store file, the datasets are stored by operation
+var datasetsBucket = []byte("datasets")
+var noncesBucket = []byte("nonces")
+
+// the expiration indexes of the buckets, keyed by the expiration time (see expirationIndexKey) with the entry key as value
+var datasetsExpirationsBucket = []byte("datasetsExpirations")
+var noncesExpirationsBucket = []byte("noncesExpirations")
+
+// the message words index of the incomplete datasets, keyed by the word index key and the operation (see wordIndexKey)
+var datasetsWordsBucket = []byte("datasetsWords")
+
+// Defines the separator of the word index key and the operation in the message words index bucket.
+const WORD_INDEX_OPERATION_SEPARATOR byte = 0
+
+// Datasets store on an embedded key-value file (bbolt), safe for concurrent use. Each write is a transaction
+// synced to disk, so the saved datasets survive a crash. The datasets expire after the time to live since their
+// last update, and the nonces after their own time to live. The expired ones are deleted on each write, through an
+// index sorted by expiration time, so only the expired entries are visited. It searches the datasets as the Redis one
+// does, by operation and by the message words index.
+type FileDatasetStore struct {
+	db  *bolt.DB
+	ttl time.Duration
+}
+
+// A dataset saved in the store file, with its expiration.
+type fileDatasetRecord struct {
+	Dataset model.Dataset `json:"dataset"`
+	// the expiration time, unix nanoseconds
+	ExpiresAt int64 `json:"expiresAt"`
+}
+
+// Opens the datasets store file, creating it if not exists.
+// input: the file path and the datasets time to live.
+// output: the datasets store, to close on exit.
+// error: if the file can't be opened, for example if another process has it open.
+func NewFileDatasetStore(path string, ttl time.Duration) (fileStore *FileDatasetStore, err error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: STORE_FILE_OPEN_TIMEOUT})
+	if err != nil {
+		return nil, fmt.Errorf("can't open datasets store file '%s'. %s", path, err.Error())
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{datasetsBucket, noncesBucket} {
+			if _, bucketErr := tx.CreateBucketIfNotExists(bucket); bucketErr != nil {
+				return bucketErr
+			}
+		}
+		// builds the indexes of the files created without them
+		if indexErr := createExpirationIndex(tx, datasetsBucket, datasetsExpirationsBucket, datasetExpiresAt); indexErr != nil {
+			return indexErr
+		}
+		if indexErr := createExpirationIndex(tx, noncesBucket, noncesExpirationsBucket, nonceExpiresAt); indexErr != nil {
+			return indexErr
+		}
+		return createWordIndex(tx)
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("can't initialize datasets store file '%s'. %s", path, err.Error())
+	}
+	return &FileDatasetStore{db: db, ttl: ttl}, nil
+}
+
+// Closes the datasets store file.
+func (fileStore *FileDatasetStore) Close() error {
+	return fileStore.db.Close()
+}
+
+func (fileStore *FileDatasetStore) SaveNewDataset(operation string, dataValue model.SatelliteInfoRequest) (saved bool) {
+	dataset := newDataset(operation, dataValue)
+	err := fileStore.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		purgeExpired(tx, now)
+
+		// as SET NX, doesn't override an existent dataset
+		if _, isPresent := getRecord(tx, operation, now); isPresent {
+			return fmt.Errorf("operation: %s already has a dataset", operation)
+		}
+		return fileStore.putRecord(tx, dataset, now)
+	})
+	if err != nil {
+		log.Printf("Error saving dataset. Trace: %s", err.Error())
+		return false
+	}
+	return true
+}
+
+func (fileStore *FileDatasetStore) UpdateDataset(operation string, consolidatedMessage string, previousKey string, previousVersion int64, dataValue model.SatelliteInfoRequest) (saved bool, err error) {
+	err = fileStore.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		purgeExpired(tx, now)
+
+		datasetOperation := operationOfKey(previousKey)
+		record, isPresent := getRecord(tx, datasetOperation, now)
+		if !isPresent || record.Dataset.Key != previousKey || record.Dataset.Version != previousVersion {
+			return ErrDatasetConflict
+		}
+		dataset, isKeyed := addSatelliteData(record.Dataset, datasetOperation, consolidatedMessage, dataValue)
+		if !isKeyed {
+			return errors.New("the dataset has no operation nor message to build its key")
+		}
+		return fileStore.putRecord(tx, dataset, now)
+	})
+	if err != nil {
+		log.Printf("Error updating dataset. Previous key: %s. Trace: %s", previousKey, err.Error())
+		return false, err
+	}
+	return true, nil
+}
+
+func (fileStore *FileDatasetStore) GetDataset(operation string, message []string) (dataset model.Dataset, matchScore int) {
+	if operation != "" {
+		return fileStore.GetDatasetByOperation(operation), 0
+	}
+	if len(message) > 0 {
+		return fileStore.GetDatasetByMessage(message)
+	}
+	return dataset, 0
+}
+
+func (fileStore *FileDatasetStore) GetDatasetByKey(key string) (dataset model.Dataset) {
+	fileStore.db.View(func(tx *bolt.Tx) error {
+		record, _ := getRecord(tx, operationOfKey(key), time.Now())
+		if record.Dataset.Key == key {
+			dataset = record.Dataset
+		}
+		return nil
+	})
+	return dataset
+}
+
+func (fileStore *FileDatasetStore) GetDatasetByOperation(operation string) (dataset model.Dataset) {
+	fileStore.db.View(func(tx *bolt.Tx) error {
+		record, _ := getRecord(tx, operation, time.Now())
+		dataset = record.Dataset
+		return nil
+	})
+	return dataset
+}
+
+func (fileStore *FileDatasetStore) GetDatasetByMessage(message []string) (dataset model.Dataset, matchScore int) {
+	wordKeys := messageWordKeys(message)
+	if len(wordKeys) == 0 {
+		return dataset, 0
+	}
+	fileStore.db.View(func(tx *bolt.Tx) error {
+		now := time.Now()
+		operations, processMinScoring := candidatesByWordIndex(wordKeys, func(wordKey string) []string {
+			return wordIndexMembers(tx, wordKey)
+		})
+		if processMinScoring > 0 {
+			log.Printf("WARN Key not found, trying filter by fuzzy match process, message: %s", message)
+		}
+
+		// the keys of the incomplete datasets not expired
+		keys := make([]string, 0, len(operations))
+		for _, operation := range operations {
+			if record, isPresent := getRecord(tx, operation, now); isPresent && record.Dataset.Key != operation {
+				keys = append(keys, record.Dataset.Key)
+			}
+		}
+		key, score := bestMatchingKey(message, keys, processMinScoring)
+		if key != "" {
+			record, _ := getRecord(tx, operationOfKey(key), now)
+			dataset, matchScore = record.Dataset, score
+		}
+		return nil
+	})
+	return dataset, matchScore
+}
+
+func (fileStore *FileDatasetStore) RegisterNonce(satellite string, nonce string, ttlSeconds int) (isNew bool, err error) {
+	key := []byte(NONCE_KEY_PREFIX + satellite + ":" + nonce)
+	err = fileStore.db.Update(func(tx *bolt.Tx) error {
+		now := time.Now()
+		purgeExpired(tx, now)
+
+		bucket := tx.Bucket(noncesBucket)
+		if bucket.Get(key) != nil {
+			return nil
+		}
+		isNew = true
+		expiresAt := now.Add(time.Duration(ttlSeconds) * time.Second).UnixNano()
+		return putEntry(tx, noncesBucket, noncesExpirationsBucket, key, []byte(strconv.FormatInt(expiresAt, 10)), expiresAt)
+	})
+	if err != nil {
+		log.Printf("Error registering nonce in datasets store file. Key: %s. Trace: %s", key, err.Error())
+		return false, err
+	}
+	return isNew, nil
+}
+
+func (fileStore *FileDatasetStore) ReleaseNonce(satellite string, nonce string) (err error) {
+	key := []byte(NONCE_KEY_PREFIX + satellite + ":" + nonce)
+	err = fileStore.db.Update(func(tx *bolt.Tx) error {
+		value := tx.Bucket(noncesBucket).Get(key)
+		if value == nil {
+			return nil
+		}
+		expiresAt, _ := nonceExpiresAt(value)
+		return deleteEntry(tx, noncesBucket, noncesExpirationsBucket, key, expiresAt)
+	})
+	if err != nil {
+		log.Printf("Error releasing nonce in datasets store file. Key: %s. Trace: %s", key, err.Error())
+	}
+	return err
+}
+
+// Saves a dataset by its operation, expiring after the time to live, and in the message words index while it's
+// incomplete.
+func (fileStore *FileDatasetStore) putRecord(tx *bolt.Tx, dataset model.Dataset, now time.Time) error {
+	expiresAt := now.Add(fileStore.ttl).UnixNano()
+	serialized, err := json.Marshal(fileDatasetRecord{Dataset: dataset, ExpiresAt: expiresAt})
+	if err != nil {
+		return err
+	}
+	// the previous dataset of the operation is replaced, with its expiration and its words
+	key := []byte(dataset.Operation)
+	if err = deleteRecord(tx, key); err != nil {
+		return err
+	}
+	if err = putEntry(tx, datasetsBucket, datasetsExpirationsBucket, key, serialized, expiresAt); err != nil {
+		return err
+	}
+	return putWordIndex(tx, dataset)
+}
+
+// Deletes the dataset of an operation, with its expiration and its message words, if exists.
+func deleteRecord(tx *bolt.Tx, operation []byte) error {
+	serialized := tx.Bucket(datasetsBucket).Get(operation)
+	if serialized == nil {
+		return nil
+	}
+	// the unreadable datasets aren't in the message words index
+	record := fileDatasetRecord{}
+	json.Unmarshal(serialized, &record)
+	if err := deleteWordIndex(tx, record.Dataset); err != nil {
+		return err
+	}
+	return deleteEntry(tx, datasetsBucket, datasetsExpirationsBucket, operation, record.ExpiresAt)
+}
+
+// Gets the dataset saved by an operation.
+// output: the dataset record, and false if not found or expired.
+func getRecord(tx *bolt.Tx, operation string, now time.Time) (record fileDatasetRecord, isPresent bool) {
+	serialized := tx.Bucket(datasetsBucket).Get([]byte(operation))
+	if serialized == nil {
+		return record, false
+	}
+	if err := json.Unmarshal(serialized, &record); err != nil {
+		log.Printf("Error unmarshaling dataset of the store file. Operation: %s. Trace: %s", operation, err.Error())
+		return fileDatasetRecord{}, false
+	}
+	if record.ExpiresAt <= now.UnixNano() {
+		return fileDatasetRecord{}, false
+	}
+	return record, true
+}
+
+// Adds the operation of an incomplete dataset to the message words index, one entry by each message word.
+func putWordIndex(tx *bolt.Tx, dataset model.Dataset) error {
+	if dataset.Key == dataset.Operation {
+		return nil
+	}
+	words := tx.Bucket(datasetsWordsBucket)
+	for _, wordKey := range messageWordKeys(strings.Split(messageOfKey(dataset.Key), MESSAGE_KEY_SEPARATOR)) {
+		if err := words.Put(wordIndexKey(wordKey, dataset.Operation), []byte{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Removes the operation of a dataset from the message words index, see putWordIndex.
+func deleteWordIndex(tx *bolt.Tx, dataset model.Dataset) error {
+	if dataset.Key == dataset.Operation {
+		return nil
+	}
+	words := tx.Bucket(datasetsWordsBucket)
+	for _, wordKey := range messageWordKeys(strings.Split(messageOfKey(dataset.Key), MESSAGE_KEY_SEPARATOR)) {
+		if err := words.Delete(wordIndexKey(wordKey, dataset.Operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Gets the operations of a message words index key, seeking its entries (they are sorted by the word index key).
+func wordIndexMembers(tx *bolt.Tx, wordKey string) (operations []string) {
+	prefix := wordIndexKey(wordKey, "")
+	cursor := tx.Bucket(datasetsWordsBucket).Cursor()
+	for key, _ := cursor.Seek(prefix); key != nil && bytes.HasPrefix(key, prefix); key, _ = cursor.Next() {
+		operations = append(operations, string(key[len(prefix):]))
+	}
+	return operations
+}
+
+// Builds the key of a message words index entry: the word index key (see messageWordKeys), the separator and the
+// operation.
+func wordIndexKey(wordKey string, operation string) []byte {
+	key := make([]byte, 0, len(wordKey)+1+len(operation))
+	key = append(key, wordKey...)
+	key = append(key, WORD_INDEX_OPERATION_SEPARATOR)
+	return append(key, operation...)
+}
+
+// Creates the message words index with the incomplete datasets, if not exists.
+func createWordIndex(tx *bolt.Tx) error {
+	if tx.Bucket(datasetsWordsBucket) != nil {
+		return nil
+	}
+	if _, err := tx.CreateBucket(datasetsWordsBucket); err != nil {
+		return err
+	}
+	return tx.Bucket(datasetsBucket).ForEach(func(_, serialized []byte) error {
+		record := fileDatasetRecord{}
+		if err := json.Unmarshal(serialized, &record); err != nil {
+			return nil
+		}
+		return putWordIndex(tx, record.Dataset)
+	})
+}
+
+// Deletes the expired datasets and nonces, visiting only the expired entries of the expiration indexes.
+func purgeExpired(tx *bolt.Tx, now time.Time) {
+	for _, names := range [][2][]byte{{datasetsBucket, datasetsExpirationsBucket}, {noncesBucket, noncesExpirationsBucket}} {
+		bucket := tx.Bucket(names[0])
+		index := tx.Bucket(names[1])
+
+		// the keys can't be deleted while iterating
+		indexKeys, keys := expiredEntries(index, now)
+		for i, key := range keys {
+			// the expired datasets are removed from the message words index too
+			if bytes.Equal(names[0], datasetsBucket) {
+				deleteRecord(tx, key)
+			}
+			bucket.Delete(key)
+			index.Delete(indexKeys[i])
+		}
+	}
+}
+
+// Gets the expired entries of an expiration index, the first ones as it's sorted by expiration time.
+// output: the index keys and the entries keys, copied to be used after the iteration.
+func expiredEntries(index *bolt.Bucket, now time.Time) (indexKeys [][]byte, keys [][]byte) {
+	cursor := index.Cursor()
+	for indexKey, key := cursor.First(); indexKey != nil && expirationOf(indexKey) <= now.UnixNano(); indexKey, key = cursor.Next() {
+		indexKeys = append(indexKeys, append([]byte(nil), indexKey...))
+		keys = append(keys, append([]byte(nil), key...))
+	}
+	return indexKeys, keys
+}
+
+// Saves an entry in a bucket and in its expiration index.
+func putEntry(tx *bolt.Tx, bucketName []byte, indexName []byte, key []byte, value []byte, expiresAt int64) error {
+	if err := tx.Bucket(indexName).Put(expirationIndexKey(expiresAt, key), key); err != nil {
+		return err
+	}
+	return tx.Bucket(bucketName).Put(key, value)
+}
+
+// Deletes an entry of a bucket and of its expiration index.
+func deleteEntry(tx *bolt.Tx, bucketName []byte, indexName []byte, key []byte, expiresAt int64) error {
+	if err := tx.Bucket(indexName).Delete(expirationIndexKey(expiresAt, key)); err != nil {
+		return err
+	}
+	return tx.Bucket(bucketName).Delete(key)
+}
+
+// Creates the expiration index of a bucket with its entries, if not exists.
+func createExpirationIndex(tx *bolt.Tx, bucketName []byte, indexName []byte, expiresAtOf func(value []byte) (int64, error)) error {
+	if tx.Bucket(indexName) != nil {
+		return nil
+	}
+	index, err := tx.CreateBucket(indexName)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(bucketName).ForEach(func(key, value []byte) error {
+		// the unreadable entries expire now
+		expiresAt, _ := expiresAtOf(value)
+		return index.Put(expirationIndexKey(expiresAt, key), key)
+	})
+}
+
+// Builds the expiration index key of an entry: the expiration time (unix nanoseconds, big endian to sort by it)
+// followed by the entry key.
+func expirationIndexKey(expiresAt int64, key []byte) []byte {
+	indexKey := make([]byte, 8, 8+len(key))
+	binary.BigEndian.PutUint64(indexKey, uint64(expiresAt))
+	return append(indexKey, key...)
+}
+
+// Gets the expiration time of an expiration index key, see expirationIndexKey.
+func expirationOf(indexKey []byte) int64 {
+	return int64(binary.BigEndian.Uint64(indexKey[:8]))
+}
+
+// Gets the expiration time of a serialized dataset record.
+func datasetExpiresAt(value []byte) (expiresAt int64, err error) {
+	record := fileDatasetRecord{}
+	err = json.Unmarshal(value, &record)
+	return record.ExpiresAt, err
+}
+
+// Gets the expiration time of a nonce entry.
+func nonceExpiresAt(value []byte) (expiresAt int64, err error) {
+	return strconv.ParseInt(string(value), 10, 64)
+}
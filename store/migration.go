@@ -0,0 +1,111 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/mgironi/operation-fire-quasar/model"
+)
+
+// Migrates the datasets saved with the previous keys schema (the dataset JSON in a '<operation>:<message>' key, or
+// '<operation>' once complete) to the operation hashes and the datasets index, removing the previous keys. Each
+// dataset is migrated in a transaction, so the migration can be run again if interrupted. The incomplete datasets
+// already in operation hashes are added to the message words index, if they were saved before it.
+// output: the count of migrated datasets, of the indexed operation hashes, and of the skipped keys (not datasets or
+// of operations already migrated).
+// error: if the store connection isn't available.
+func MigrateDatasets() (migrated int, indexed int, skipped int, err error) {
+	cnn := GetRedisConnection()
+	if cnn == nil {
+		return 0, 0, 0, errors.New("store connection not available")
+	}
+	defer cnn.Close()
+
+	for _, key := range ScanKeys(REDIS_MATCH_PATTERN_WILDCARD) {
+		// the keys of the current schema
+		if strings.HasPrefix(key, DATASET_HASH_KEY_PREFIX) {
+			if indexDataset(cnn, key) {
+				indexed++
+			}
+			continue
+		}
+		if strings.HasPrefix(key, DATASETS_WORD_INDEX_KEY_PREFIX) || strings.HasPrefix(key, NONCE_KEY_PREFIX) {
+			continue
+		}
+		if migrateDataset(cnn, key) {
+			migrated++
+		} else {
+			skipped++
+		}
+	}
+	return migrated, indexed, skipped, nil
+}
+
+// Adds the incomplete dataset of an operation hash to the message words index, in a transaction so a concurrent
+// update doesn't leave its previous words indexed.
+// output: true if indexed, false if the dataset is complete or changed meanwhile.
+func indexDataset(cnn redis.Conn, hashKey string) (indexed bool) {
+	if _, watchErr := cnn.Do("WATCH", hashKey); watchErr != nil {
+		log.Printf("Error in WATCH to redis. Key: %s. Trace: %s", hashKey, watchErr.Error())
+		return false
+	}
+	dataset, readErr := readDataset(cnn, hashKey)
+	if readErr != nil || dataset.Key == "" || dataset.Key == dataset.Operation {
+		cnn.Do("UNWATCH")
+		return false
+	}
+
+	cnn.Send("MULTI")
+	sendWordIndexAdd(cnn, dataset.Key)
+	if _, execErr := redis.Values(cnn.Do("EXEC")); execErr != nil {
+		log.Printf("WARN skipping key: %s, it changed during the indexing. Trace: %s", hashKey, execErr.Error())
+		return false
+	}
+	return true
+}
+
+// Migrates the dataset of a previous schema key, see MigrateDatasets.
+// output: true if migrated.
+func migrateDataset(cnn redis.Conn, key string) (migrated bool) {
+	serialized, getErr := redis.Bytes(cnn.Do("GET", key))
+	if getErr != nil {
+		log.Printf("WARN skipping key: %s, it isn't a dataset. Trace: %s", key, getErr.Error())
+		return false
+	}
+	var dataset model.Dataset
+	if umErr := json.Unmarshal(serialized, &dataset); umErr != nil || dataset.Key != key || dataset.Operation == "" || operationOfKey(key) != dataset.Operation {
+		log.Printf("WARN skipping key: %s, it isn't a dataset. Trace: %v", key, umErr)
+		return false
+	}
+	if dataset.Version == 0 {
+		dataset.Version = 1
+	}
+
+	// the operation hash mustn't exist, the key may be renamed meanwhile
+	hashKey := DATASET_HASH_KEY_PREFIX + dataset.Operation
+	if _, watchErr := cnn.Do("WATCH", hashKey, key); watchErr != nil {
+		log.Printf("Error in WATCH to redis. Key: %s. Trace: %s", hashKey, watchErr.Error())
+		return false
+	}
+	exists, existsErr := redis.Bool(cnn.Do("EXISTS", hashKey))
+	if existsErr != nil || exists {
+		log.Printf("WARN skipping key: %s, the operation is already migrated or can't be checked. Trace: %v", key, existsErr)
+		cnn.Do("UNWATCH")
+		return false
+	}
+
+	cnn.Send("MULTI")
+	if srlErr := sendDatasetWrite(cnn, dataset); srlErr != nil {
+		cnn.Do("DISCARD")
+		return false
+	}
+	cnn.Send("DEL", key)
+	if _, execErr := redis.Values(cnn.Do("EXEC")); execErr != nil {
+		log.Printf("WARN skipping key: %s, it changed during the migration. Trace: %s", key, execErr.Error())
+		return false
+	}
+	return true
+}
@@ -2,6 +2,7 @@ package store
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"strings"
 
 	"github.com/mgironi/operation-fire-quasar/model"
+	"github.com/mgironi/operation-fire-quasar/security"
 	"github.com/mgironi/operation-fire-quasar/support"
 
 	"github.com/google/uuid"
@@ -22,6 +24,9 @@ import (
 // satelites variable
 var satelites map[int]model.SateliteInfo
 
+// reference origin of the local cartesian frame (ENU), nil if not configured
+var referenceOrigin *model.GeodeticCoordinates
+
 // redis connection pool
 var redisPool *redis.Pool
 
@@ -45,12 +50,15 @@ func Initialize() {
 	// the satelites info
 	InitializeSatelitesInfo()
 
-	// loads memory cache connection (Redis)
-	InitializeMemorycacheConnection()
+	// loads the datasets store, memory cache connection (Redis) by default. Only a configured store (OFQ_STORE) can
+	// fail, the server doesn't start instead of running with another store
+	if err := InitializeDatasetStore(); err != nil {
+		log.Fatalf("ERROR\tCan't initialize the datasets store. %s", err.Error())
+	}
 }
 
 // HELP message for passing stalites info by environment variables
-const HELP_PASSING_SATELITES_INFO_ENV = "To load satelites information plese use format '<name>_<xcoord>,<ycoord>'. Example: kenobi_300.25,-340.78"
+const HELP_PASSING_SATELITES_INFO_ENV = "To load satelites information plese use format '<name>_<xcoord>,<ycoord>[,<zcoord>][_<stddev>]'. Example: kenobi_300.25,-340.78 or kenobi_300.25,-340.78,1200_0.5"
 
 // Env key for Kenobi satelite info
 const SATELITE_KENOBI_ENV string = "OFQ_KENOBI"
@@ -61,8 +69,32 @@ const SATELITE_SKYWALKER_ENV string = "OFQ_SKYWALKER"
 // Env key for Sato satelite info
 const SATELITE_SATO_ENV string = "OFQ_SATO"
 
+// Env key for additional satelites info, list of satelite info separated by ';'. Example: rex_0,500;cody_-300,-600
+const SATELITES_EXTRA_ENV string = "OFQ_SATELITES_EXTRA"
+
+// Separator of the satelites info list in SATELITES_EXTRA_ENV
+const SATELITES_EXTRA_SEPARATOR string = ";"
+
+// Env key for the geodetic reference origin of the local cartesian frame, format '<latitude>,<longitude>[,<altitude>]'. Example: -34.6037,-58.3816,25
+const REFERENCE_ORIGIN_ENV string = "OFQ_ORIGIN"
+
+// Env key for the satelites reports verification keys, list of '<name>=<algorithm>:<base64 key>' separated by ';'.
+// The algorithm is hmac (shared secret) or ed25519 (public key). Example: kenobi=hmac:c2VjcmV0;sato=ed25519:<base64 public key>
+const SATELITES_KEYS_ENV string = "OFQ_SATELITES_KEYS"
+
+// Separator between the satelite name and its key in SATELITES_KEYS_ENV
+const SATELITE_KEY_SEPARATOR string = "="
+
+// Prefix of the satelite info location given in geodetic coordinates. Example: kenobi_geo:-34.6037,-58.3816,25
+const GEODETIC_LOCATION_PREFIX string = "geo:"
+
 // Initialices satelites info
-func InitializeSatelitesInfo() {
+// The satelites geometry is validated, if it can't be used to determine a location the default satelites info is loaded.
+// output: the geometry error (*model.GeometryError) found in the configured satelites info, nil if valid.
+func InitializeSatelitesInfo() (err error) {
+	// loads the reference origin, needed to convert geodetic satelites locations
+	InitializeReferenceOrigin()
+
 	// defines environment key to get satelite info
 	satelitesEnvsKeys := []string{SATELITE_KENOBI_ENV, SATELITE_SKYWALKER_ENV, SATELITE_SATO_ENV}
 
@@ -75,6 +107,102 @@ func InitializeSatelitesInfo() {
 		// loads default
 		LoadsDefaultSatelitesInfo()
 	}
+
+	// appends the additional satelites info (if present)
+	for _, extraInfo := range ParseExtraSatelitesInfoFromEnv(SATELITES_EXTRA_ENV) {
+		satelites[len(satelites)] = extraInfo
+	}
+
+	// loads the satelites reports verification keys
+	InitializeSatelitesKeys()
+
+	// checks satelites geometry, valid if can be used in the plane (Z ignored) or in the space
+	err = model.ValidateGeometry(model.ProjectPointsToPlane(GetKnownReferenceCoordinates()))
+	if err != nil && model.ValidateSpatialGeometry(GetKnownReferenceCoordinates()) == nil {
+		log.Printf("WARN satelites info can be used to determine locations only in the space. %s", err.Error())
+		err = nil
+	}
+	if err != nil {
+		log.Printf("ERROR satelites info can't be used to determine locations. %s", err.Error())
+
+		// loads default
+		LoadsDefaultSatelitesInfo()
+		InitializeSatelitesKeys()
+	}
+	return err
+}
+
+// Initialices the satelites reports verification keys from environment variable, see SATELITES_KEYS_ENV.
+// A satelite with an invalid key keeps a key without algorithm, so its reports are always rejected.
+func InitializeSatelitesKeys() {
+	envValue, envPresent := os.LookupEnv(SATELITES_KEYS_ENV)
+	if !envPresent || strings.TrimSpace(envValue) == "" {
+		return
+	}
+	for _, entry := range strings.Split(envValue, SATELITES_EXTRA_SEPARATOR) {
+		entryParts := strings.SplitN(strings.TrimSpace(entry), SATELITE_KEY_SEPARATOR, 2)
+		if len(entryParts) != 2 {
+			log.Printf("WARN: Can't parse '%s' env variable entry, use format '<name>%s<algorithm>:<base64 key>'", SATELITES_KEYS_ENV, SATELITE_KEY_SEPARATOR)
+			continue
+		}
+		satIdx := GetSatelliteInfoIndex(entryParts[0])
+		if satIdx < 0 {
+			log.Printf("WARN: Can't set the verification key of unknown satelite '%s'", entryParts[0])
+			continue
+		}
+		key, parseErr := security.ParseVerificationKey(entryParts[1])
+		if parseErr != nil {
+			log.Printf("ERROR: Can't parse satelite '%s' verification key, its reports will be rejected. %s", entryParts[0], parseErr.Error())
+			key = security.VerificationKey{}
+		}
+		satInfo := satelites[satIdx]
+		satInfo.VerificationKey = &key
+		satelites[satIdx] = satInfo
+	}
+}
+
+// Initialices the geodetic reference origin of the local cartesian frame from environment variable.
+// If it's not present (or invalid) the local frame hasn't geodetic reference.
+func InitializeReferenceOrigin() {
+	referenceOrigin = nil
+	envValue, envPresent := os.LookupEnv(REFERENCE_ORIGIN_ENV)
+	if !envPresent || strings.TrimSpace(envValue) == "" {
+		return
+	}
+	origin, parseErr := model.ParseGeodeticCoordinates(envValue)
+	if parseErr != nil {
+		log.Printf("WARN: Can't parse '%s' env variable value, %s", REFERENCE_ORIGIN_ENV, parseErr)
+		return
+	}
+	referenceOrigin = &origin
+}
+
+// Gets the geodetic reference origin of the local cartesian frame.
+// output: the origin and true if it's configured.
+func GetReferenceOrigin() (origin model.GeodeticCoordinates, present bool) {
+	if referenceOrigin == nil {
+		return origin, false
+	}
+	return *referenceOrigin, true
+}
+
+// Parses the additional satelites info list from environment variable
+// input: environment variable key to parse
+// output: the valid satelites info, in the same order as listed. Those with errors are discarded.
+func ParseExtraSatelitesInfoFromEnv(envKey string) (satelitesInfo []model.SateliteInfo) {
+	envValue, envPresent := os.LookupEnv(envKey)
+	if !envPresent || strings.TrimSpace(envValue) == "" {
+		return satelitesInfo
+	}
+	for _, infoStr := range strings.Split(envValue, SATELITES_EXTRA_SEPARATOR) {
+		info, convErr := ConvertSateliteInfo(strings.TrimSpace(infoStr))
+		if convErr != nil {
+			log.Printf("WARN: Can't parse '%s' env variable value, %s", envKey, convErr)
+			continue
+		}
+		satelitesInfo = append(satelitesInfo, info)
+	}
+	return satelitesInfo
 }
 
 func InitializeMemorycacheConnection() {
@@ -183,17 +311,37 @@ func ParseSatelitesInfoFromEnvs(envKeys []string) (satelitesInfo map[int]model.S
 }
 
 // Converts satelite info string to SateliteInfo
-// input: satelite info string (format '<name>_<xcoord>,<ycoord>')
+// The location can be given in geodetic coordinates (format '<name>_geo:<latitude>,<longitude>[,<altitude>]'),
+// it's converted to the local cartesian frame, so the reference origin must be configured. See REFERENCE_ORIGIN_ENV.
+// The distances noise of the satelite can be given as its standard deviation after the location (optional).
+// input: satelite info string (format '<name>_<xcoord>,<ycoord>[,<zcoord>][_<stddev>]', the z coordinate and the standard deviation are optional)
 // output: the SateliteInfo. See also SateliteInfo struct
 func ConvertSateliteInfo(infoStr string) (info model.SateliteInfo, err error) {
-	const GENERIC_ERROR_MSG string = "Insuficient data. To load satelites information plese use format '<name>_<xcoord>,<ycoord>'. Example: kenobi_300.25,-340.78"
+	const GENERIC_ERROR_MSG string = "Insuficient data. To load satelites information plese use format '<name>_<xcoord>,<ycoord>[,<zcoord>][_<stddev>]'. Example: kenobi_300.25,-340.78"
 
 	infoList := strings.Split(infoStr, "_")
-	if len(infoList) < 2 {
+	if len(infoList) < 2 || len(infoList) > 3 {
 		return info, fmt.Errorf("no posible get satelite info '%s'. %s", infoStr, GENERIC_ERROR_MSG)
 	}
+
+	// parses the distances noise, if present
+	var noiseStdDev float64
+	if len(infoList) == 3 {
+		var parseError error
+		noiseStdDev, parseError = strconv.ParseFloat(infoList[2], 64)
+		if parseError != nil || noiseStdDev <= 0 {
+			return info, fmt.Errorf("can't get satelite info noise standard deviation '%s', must be a positive number. %s", infoStr, GENERIC_ERROR_MSG)
+		}
+	}
+
+	if strings.HasPrefix(infoList[1], GEODETIC_LOCATION_PREFIX) {
+		info, err = convertGeodeticSateliteInfo(infoList[0], strings.TrimPrefix(infoList[1], GEODETIC_LOCATION_PREFIX))
+		info.NoiseStdDev = noiseStdDev
+		return info, err
+	}
+
 	locationCoords := strings.Split(infoList[1], ",")
-	if len(locationCoords) < 2 {
+	if len(locationCoords) < 2 || len(locationCoords) > 3 {
 		return info, fmt.Errorf("no posible get satelite info location coordinates '%s'. %s", infoStr, GENERIC_ERROR_MSG)
 	}
 
@@ -205,10 +353,26 @@ func ConvertSateliteInfo(infoStr string) (info model.SateliteInfo, err error) {
 			return info, fmt.Errorf("can't get satelite info location coordinate '%s'. %s. %s", infoStr, parseError.Error(), GENERIC_ERROR_MSG)
 		}
 	}
-	info = model.SateliteInfo{Name: infoList[0], Location: model.Point{X: parsedCoords[0], Y: parsedCoords[1]}}
+	info = model.SateliteInfo{Name: infoList[0], Location: model.Point{X: parsedCoords[0], Y: parsedCoords[1]}, NoiseStdDev: noiseStdDev}
+	if len(parsedCoords) == 3 {
+		info.Location.Z = parsedCoords[2]
+	}
 	return info, nil
 }
 
+// Converts satelite geodetic location to SateliteInfo in the local cartesian frame.
+func convertGeodeticSateliteInfo(name string, geoStr string) (info model.SateliteInfo, err error) {
+	origin, present := GetReferenceOrigin()
+	if !present {
+		return info, fmt.Errorf("can't convert satelite '%s' geodetic location, the reference origin isn't configured. Please use '%s' env variable", name, REFERENCE_ORIGIN_ENV)
+	}
+	geo, parseErr := model.ParseGeodeticCoordinates(geoStr)
+	if parseErr != nil {
+		return info, fmt.Errorf("can't get satelite '%s' geodetic location. %s", name, parseErr.Error())
+	}
+	return model.SateliteInfo{Name: name, Location: model.GeodeticToENU(geo, origin)}, nil
+}
+
 var GetNewOperationUUID = func() (id string) {
 	id = uuid.New().String()
 	return
@@ -220,23 +384,228 @@ const REDIS_MATCH_PATTERN_WILDCARD = "*"
 
 const MESSAGE_KEY_SEPARATOR = " "
 
+// Defines the prefix of the datasets hashes keys, 'dataset:<operation>'. The hash key doesn't change while the
+// dataset is collected, its fields are DATASET_FIELD_*.
+const DATASET_HASH_KEY_PREFIX string = "dataset:"
+
+// Defines the field of the dataset key, '<operation>:<message>' while incomplete or '<operation>' once complete.
+const DATASET_FIELD_KEY string = "key"
+
+// Defines the field of the dataset operation.
+const DATASET_FIELD_OPERATION string = "operation"
+
+// Defines the field of the dataset version.
+const DATASET_FIELD_VERSION string = "version"
+
+// Defines the field of the dataset satellites data, as JSON array.
+const DATASET_FIELD_SATELLITES string = "satellites"
+
+// Defines the prefix of the message words index keys, '<prefix><position>:<word>' (the word in lower case). Each one is
+// a set of the operations whose incomplete dataset message has the word in the position, so the datasets are searched
+// by message intersecting the sets of its words, without scanning the keys.
+const DATASETS_WORD_INDEX_KEY_PREFIX string = "datasets:word:"
+
+// Saves the new dataset of an operation with its first satellite data, in the operation hash and the message words index.
+// output: true if saved, false if the operation already has a dataset.
 func SaveNewDataset(operation string, dataValue model.SatelliteInfoRequest) (saved bool) {
+	cnn := GetRedisConnection()
+	if cnn == nil {
+		return false
+	}
+	// the connection goes back to the pool, discarding the watched keys
+	defer cnn.Close()
+
+	// as SET NX, doesn't override an existent dataset
+	dataset := newDataset(operation, dataValue)
+	hashKey := DATASET_HASH_KEY_PREFIX + operation
+	if _, err := cnn.Do("WATCH", hashKey); err != nil {
+		log.Printf("Error in WATCH to redis. Key: %s. Trace: %s", hashKey, err.Error())
+		return false
+	}
+	exists, existsErr := redis.Bool(cnn.Do("EXISTS", hashKey))
+	if existsErr != nil || exists {
+		log.Printf("Error saving dataset. Key: %s already exists or can't be checked. Trace: %v", hashKey, existsErr)
+		return false
+	}
+
+	cnn.Send("MULTI")
+	if err := sendDatasetWrite(cnn, dataset); err != nil {
+		cnn.Do("DISCARD")
+		return false
+	}
+	if _, execErr := redis.Values(cnn.Do("EXEC")); execErr != nil {
+		log.Printf("Error in EXEC to redis. Key: %s. Trace: %s", hashKey, execErr.Error())
+		return false
+	}
+	return true
+}
+
+// Builds the new dataset of an operation with its first satellite data, keyed by '<operation>:<message>'.
+func newDataset(operation string, dataValue model.SatelliteInfoRequest) (dataset model.Dataset) {
 	// build key with <operation>:<string_message>
 	stringMsg := strings.Join(dataValue.Message, MESSAGE_KEY_SEPARATOR)
 	dataSetKey := fmt.Sprintf(DATASET_KEY_FORMAT_PATTERN, operation, stringMsg)
-	dataset := model.Dataset{
+	return model.Dataset{
 		Key:        dataSetKey,
 		Operation:  operation,
 		Satellites: []model.SatelliteInfoRequest{dataValue},
+		Version:    1,
 	}
-	saved = SetKeyValuePair(dataSetKey, dataset)
-	return
 }
 
-func UpdateDataset(operation string, consolidatedMessage string, previousKey string, dataValue model.SatelliteInfoRequest) (saved bool) {
-	saved = false
-	dataset := GetDatasetByKey(previousKey)
+// Defines the max attempts of a dataset update, when it conflicts with a concurrent update. Each attempt must read
+// the current dataset again, see UpdateDataset.
+const DATASET_UPDATE_MAX_ATTEMPTS int = 5
+
+// Error of a dataset update over a previous version, the dataset was updated (or removed) concurrently. The
+// update must be done again over the current dataset.
+var ErrDatasetConflict = errors.New("the dataset was updated concurrently")
+
+// Adds the satellite data to the dataset with the previous key and version, keying it again with the consolidated
+// message. The update is atomic (WATCH/MULTI/EXEC): the operation hash and the message words index are updated in the
+// same transaction, which is aborted if the hash changes before it is executed. The caller must read the current
+// dataset and update it again on conflict.
+// input: the operation, the consolidated message (empty if the dataset is complete), the previous key and version
+// of the dataset, and the satellite data. The dataset is the one of the previous key operation.
+// output: true if saved.
+// error: ErrDatasetConflict if the dataset isn't the previous version anymore, or the store error.
+func UpdateDataset(operation string, consolidatedMessage string, previousKey string, previousVersion int64, dataValue model.SatelliteInfoRequest) (saved bool, err error) {
+	cnn := GetRedisConnection()
+	if cnn == nil {
+		return false, errors.New("store connection not available")
+	}
+	// the connection goes back to the pool, discarding the watched keys
+	defer cnn.Close()
+
+	datasetOperation := operationOfKey(previousKey)
+	if operation != "" && operation != datasetOperation {
+		log.Printf("WARN operation in dataset mismatch. Given: '%s'. Existent dataset key: '%s'", operation, previousKey)
+	}
+	hashKey := DATASET_HASH_KEY_PREFIX + datasetOperation
+	if _, err = cnn.Do("WATCH", hashKey); err != nil {
+		log.Printf("Error in WATCH to redis. Key: %s. Trace: %s", hashKey, err.Error())
+		return false, err
+	}
+	previous, readErr := readDataset(cnn, hashKey)
+	if readErr != nil {
+		return false, readErr
+	}
+	if previous.Key != previousKey || previous.Version != previousVersion {
+		return false, ErrDatasetConflict
+	}
+
+	dataset, isKeyed := addSatelliteData(previous, datasetOperation, consolidatedMessage, dataValue)
+	if !isKeyed {
+		return false, errors.New("the dataset has no operation nor message to build its key")
+	}
+
+	// updates the hash and the message words index, only if the watched hash didn't change
+	cnn.Send("MULTI")
+	sendWordIndexRemove(cnn, previousKey)
+	if err = sendDatasetWrite(cnn, dataset); err != nil {
+		cnn.Do("DISCARD")
+		return false, err
+	}
+	_, execErr := redis.Values(cnn.Do("EXEC"))
+	if execErr == redis.ErrNil {
+		log.Printf("WARN dataset key: %s changed during the update", hashKey)
+		return false, ErrDatasetConflict
+	}
+	if execErr != nil {
+		log.Printf("Error in EXEC to redis. Key: %s. Trace: %s", hashKey, execErr.Error())
+		return false, execErr
+	}
+	return true, nil
+}
+
+// Queues (inside MULTI) the commands to save a dataset in its operation hash, and in the message words index while
+// it's incomplete (keyed with its message).
+// error: if the satellites data can't be serialized.
+func sendDatasetWrite(cnn redis.Conn, dataset model.Dataset) (err error) {
+	satellites, err := json.Marshal(dataset.Satellites)
+	if err != nil {
+		log.Printf("Error serializing data. Key: %s, value: %v. Trace: %s", dataset.Key, dataset, err.Error())
+		return err
+	}
+	cnn.Send("HSET", DATASET_HASH_KEY_PREFIX+dataset.Operation,
+		DATASET_FIELD_KEY, dataset.Key,
+		DATASET_FIELD_OPERATION, dataset.Operation,
+		DATASET_FIELD_VERSION, dataset.Version,
+		DATASET_FIELD_SATELLITES, satellites)
+	if dataset.Key != dataset.Operation {
+		sendWordIndexAdd(cnn, dataset.Key)
+	}
+	return nil
+}
 
+// Queues the commands to add the operation of a dataset key to the message words index, one by each message word.
+func sendWordIndexAdd(cnn redis.Conn, key string) {
+	operation := operationOfKey(key)
+	for _, wordKey := range messageWordKeys(strings.Split(messageOfKey(key), MESSAGE_KEY_SEPARATOR)) {
+		cnn.Send("SADD", wordKey, operation)
+	}
+}
+
+// Queues the commands to remove the operation of a dataset key from the message words index, see sendWordIndexAdd.
+func sendWordIndexRemove(cnn redis.Conn, key string) {
+	operation := operationOfKey(key)
+	for _, wordKey := range messageWordKeys(strings.Split(messageOfKey(key), MESSAGE_KEY_SEPARATOR)) {
+		cnn.Send("SREM", wordKey, operation)
+	}
+}
+
+// Gets the message words index keys of a message, one by each non empty word and its position.
+func messageWordKeys(message []string) (wordKeys []string) {
+	for position, word := range message {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word != "" {
+			wordKeys = append(wordKeys, fmt.Sprintf("%s%d:%s", DATASETS_WORD_INDEX_KEY_PREFIX, position, word))
+		}
+	}
+	return wordKeys
+}
+
+// Reads the dataset of an operation hash.
+// output: the dataset, empty if the hash doesn't exist.
+// error: if the hash can't be read or parsed.
+func readDataset(cnn redis.Conn, hashKey string) (dataset model.Dataset, err error) {
+	fields, err := redis.StringMap(cnn.Do("HGETALL", hashKey))
+	if err != nil {
+		log.Printf("Error in HGETALL to redis. Key: %s. Trace: %s", hashKey, err.Error())
+		return dataset, err
+	}
+	if len(fields) == 0 {
+		return dataset, nil
+	}
+	dataset.Key = fields[DATASET_FIELD_KEY]
+	dataset.Operation = fields[DATASET_FIELD_OPERATION]
+	if dataset.Version, err = strconv.ParseInt(fields[DATASET_FIELD_VERSION], 10, 64); err != nil {
+		log.Printf("Error parsing dataset version. Key: %s. Trace: %s", hashKey, err.Error())
+		return model.Dataset{}, err
+	}
+	if err = json.Unmarshal([]byte(fields[DATASET_FIELD_SATELLITES]), &dataset.Satellites); err != nil {
+		log.Printf("Error deserializing dataset satellites. Key: %s. Trace: %s", hashKey, err.Error())
+		return model.Dataset{}, err
+	}
+	return dataset, nil
+}
+
+// Gets the operation of a dataset key, '<operation>:<message>' or '<operation>'.
+func operationOfKey(key string) string {
+	return strings.SplitN(key, ":", 2)[0]
+}
+
+// Gets the message of a dataset key, empty if the key has just the operation (the dataset is complete).
+func messageOfKey(key string) string {
+	if sepIdx := strings.Index(key, ":"); sepIdx != -1 {
+		return key[sepIdx+1:]
+	}
+	return ""
+}
+
+// Adds the satellite data to a dataset, updating its key with the consolidated message.
+// output: the updated dataset, and false if it can't be keyed (no message and no operation).
+func addSatelliteData(dataset model.Dataset, operation string, consolidatedMessage string, dataValue model.SatelliteInfoRequest) (updated model.Dataset, isKeyed bool) {
 	// checks and updates operation in dataset
 	if operation != "" {
 		// set operation value if not defined
@@ -256,94 +625,75 @@ func UpdateDataset(operation string, consolidatedMessage string, previousKey str
 	var newDataSetKey string
 	if consolidatedMessage != "" {
 		// build key with <operation>:<string_message>
-		//stringMsg := strings.Join(dataValue.Message, MESSAGE_KEY_SEPARATOR)
-		//newDataSetKey = fmt.Sprintf(DATASET_KEY_FORMAT_PATTERN, operation, stringMsg)
 		newDataSetKey = fmt.Sprintf(DATASET_KEY_FORMAT_PATTERN, operation, consolidatedMessage)
 	} else if operation != "" {
 		// use key just with operation value
 		newDataSetKey = operation
 	} else {
 		// no message and no operation, impossible to build key
-		return
+		return dataset, false
 	}
 
-	// updates with the new key
+	// updates with the new key, as a new version
 	dataset.Key = newDataSetKey
-
-	// keep safe old dataset
-	oldDataset := GetByKey(previousKey)
-
-	// remove old key,value
-	DeleteKey(previousKey)
-
-	// save new key, value
-	saved = SetKeyValuePair(newDataSetKey, dataset)
-	if !saved {
-		// Restores previous key
-		SetKeyValuePair(newDataSetKey, oldDataset)
-	}
-	return
+	dataset.Version++
+	return dataset, true
 }
 
-func DeleteKey(key string) (success bool) {
-	success = false
+// Defines the prefix of the satellites reports nonces keys, '<prefix><satellite>:<nonce>'.
+const NONCE_KEY_PREFIX string = "nonce:"
 
+// Registers the nonce of a satellite report, only if it wasn't registered before (SET NX). The nonce expires after the
+// ttl, so the store keeps only the recent ones.
+// input: the satellite name, the report nonce and its time to live (seconds).
+// output: true if the nonce is new, false if it was already registered.
+// error: if the store isn't available.
+func RegisterNonce(satellite string, nonce string, ttlSeconds int) (isNew bool, err error) {
 	cnn := GetRedisConnection()
 	if cnn == nil {
-		return
+		return false, errors.New("store connection not available")
 	}
 
-	// apply 'DEL'
-	delCount, setErr := redis.Int(cnn.Do("DEL", key))
-	if setErr != nil {
-		log.Printf("Error in DEL to redis. Key: %s. Trace: %s", key, setErr.Error())
-		return
+	key := NONCE_KEY_PREFIX + satellite + ":" + nonce
+	reply, setErr := redis.String(cnn.Do("SET", key, 1, "NX", "EX", ttlSeconds))
+	if setErr == redis.ErrNil {
+		return false, nil
 	}
-	/*if reply == nil {
-		log.Printf("Error in DEL to redis. Key: %s. Reply is nil", key)
-		return
-	}*/
-
-	// gets deleted count
-	//delCount := redis.Int(reply)
-	success = delCount >= 1
-	if delCount > 1 {
-		// more than one is a problem
-		log.Printf("WARN in DEL to redis. Key: %s. Deleted more than 1 key. total deleted: %d", key, delCount)
+	if setErr != nil {
+		log.Printf("Error in SET to redis. Key: %s. Trace: %s", key, setErr.Error())
+		return false, setErr
 	}
-	return
+	return reply == "OK", nil
 }
 
-func SetKeyValuePair(key string, value interface{}) (success bool) {
-	success = false
+// Releases the nonce of a satellite report (DEL), so the report can be received again. Used when the report was
+// rejected after its nonce was registered.
+// input: the satellite name and the report nonce.
+// error: if the store isn't available.
+func ReleaseNonce(satellite string, nonce string) (err error) {
 	cnn := GetRedisConnection()
 	if cnn == nil {
-		return
+		return errors.New("store connection not available")
 	}
 
-	serialized, srlErr := json.Marshal(value)
-	if srlErr != nil {
-		log.Printf("Error serializing data. Key: %s, value: %v. Trace: %s", key, value, srlErr.Error())
-		return
+	key := NONCE_KEY_PREFIX + satellite + ":" + nonce
+	if _, delErr := cnn.Do("DEL", key); delErr != nil {
+		log.Printf("Error in DEL to redis. Key: %s. Trace: %s", key, delErr.Error())
+		return delErr
 	}
-	// apply 'SET' only if not exists key, using 'NX' arg
-	reply, setErr := cnn.Do("SET", key, serialized, "NX")
-	if reply == nil || setErr != nil {
-		log.Printf("Error in SET to redis. Key: %s, value: %v. Trace: %s", key, value, setErr.Error())
-		return
-	}
-	success = (reply.(string) == "OK")
-	return
+	return nil
 }
 
-func GetDataset(operation string, message []string) (dataset model.Dataset) {
+// Gets the dataset of an operation, or the one that matches the message if the operation is empty.
+// output: the dataset, and its message match score if it was searched by message (see GetDatasetByMessage).
+func GetDataset(operation string, message []string) (dataset model.Dataset, matchScore int) {
 
 	// if operataion is not empty then get key by operation
 	if operation != "" {
 		dataset = GetDatasetByOperation(operation)
 	} else if len(message) > 0 {
 		// if operation is empty should search by matching using partial message (assuming that exits a more complete message)
-		dataset = GetDatasetByMessage(message)
+		dataset, matchScore = GetDatasetByMessage(message)
 	}
 
 	return
@@ -360,51 +710,45 @@ func buildMessageMatchPattern(message []string) (flattedMessage string) {
 	return
 }
 
-func GetDatasetByMessage(message []string) (dataset model.Dataset) {
-	filledWildcardMsg := buildMessageMatchPattern(message)
-	matchFilterOperation := fmt.Sprintf(DATASET_KEY_FORMAT_PATTERN, REDIS_MATCH_PATTERN_WILDCARD, filledWildcardMsg)
-	keys := ScanKeys(matchFilterOperation)
-	countKeys := len(keys)
-	if countKeys == 0 {
-		// search by full scan with fuzzywuzzy
-		log.Printf("WARN Key not found, trying filter by fuzzy match process, message: %s", message)
-		matchKey := ScanWithFuzzy(message)
-		if matchKey != "" {
-			dataset = GetDatasetByKey(matchKey)
-		}
-		return dataset
-	}
-	if countKeys > 1 {
-		log.Printf("WARN found more than one key, scanning by message: %s. Using first key:%s", filledWildcardMsg, keys[0])
+// Gets the dataset whose message has all the words of the partial message (the empty words match any word), or the
+// most similar one by fuzzy match. See MatchByWordIndex.
+// output: the dataset and its message match score (0 to 100), empty if not found.
+func GetDatasetByMessage(message []string) (dataset model.Dataset, matchScore int) {
+	matchKey, matchScore := MatchByWordIndex(message)
+	if matchKey == "" {
+		return dataset, 0
 	}
-	dataset = GetDatasetByKey(keys[0])
-	return dataset
+	return GetDatasetByKey(matchKey), matchScore
 }
 
+// Gets the dataset of an operation, from its hash.
+// output: the dataset, empty if not found.
 func GetDatasetByOperation(operation string) (dataset model.Dataset) {
-	matchFilterOperation := operation + ":*"
-	keys := ScanKeys(matchFilterOperation)
-	countKeys := len(keys)
-	if countKeys == 0 {
-		log.Printf("Key not found, after scanning by operation: %s", operation)
+	cnn := GetRedisConnection()
+	if cnn == nil {
 		return dataset
 	}
-	if countKeys > 1 {
-		log.Printf("WARN found more than one key, scanning by operation: %s. Using first key:%s", operation, keys[0])
+	defer cnn.Close()
 
+	dataset, _ = readDataset(cnn, DATASET_HASH_KEY_PREFIX+operation)
+	if dataset.Key == "" {
+		log.Printf("Key not found, dataset of operation: %s", operation)
 	}
-	dataset = GetDatasetByKey(keys[0])
 	return dataset
 }
 
+// Gets the dataset by its key, from the hash of the key operation.
+// output: the dataset, empty if not found or if the dataset has another key now.
 func GetDatasetByKey(key string) (dataset model.Dataset) {
-	serializedDataset := GetByKey(key)
-	if len(serializedDataset) == 0 {
-		return
+	cnn := GetRedisConnection()
+	if cnn == nil {
+		return dataset
 	}
-	umErr := json.Unmarshal([]byte(serializedDataset), &dataset)
-	if umErr != nil {
-		log.Printf("Error trying to unmarshal value '%s' to '[]model.SatelliteInfoRequest'", serializedDataset)
+	defer cnn.Close()
+
+	dataset, _ = readDataset(cnn, DATASET_HASH_KEY_PREFIX+operationOfKey(key))
+	if dataset.Key != key {
+		return model.Dataset{}
 	}
 	return dataset
 }
@@ -440,58 +784,123 @@ func partialScan(matchFilter string, scanCursor *string, results *[]string) {
 		return
 	}
 
-	// updates results
-	var strErr error
-	*results, strErr = redis.Strings(reply[1], nil)
+	// updates results, appending the keys of this partial scan
+	partialResults, strErr := redis.Strings(reply[1], nil)
 	if strErr != nil {
 		log.Printf("Error in SCAN reply of redis, reply[1] parse error. Trace: %s.", strErr.Error())
 		return
 	}
+	*results = append(*results, partialResults...)
 
 }
 
 const FUZZY_PROCESS_MIN_SCORING_ACCEPTED int = 60
 
-func ScanWithFuzzy(message []string) (matchKey string) {
-	scanCursor := "0"
-	// scan for all keys
-	matchFilter := "*"
-	extractionPhrase := strings.Join(message, " ")
-	minScoring := FUZZY_PROCESS_MIN_SCORING_ACCEPTED
-	keys := []string{}
-	matchChoices := []matchChoiceKey{}
-
-	// do partial scan
-	partialScan(matchFilter, &scanCursor, &keys)
-
-	// apply filter by fuzzy match scoring
-	resultFilter := filterKeysByFuzzyProcessScore(extractionPhrase, minScoring, keys)
+// Defines the max count of datasets scored by fuzzy match when none has all the message words, the ones sharing
+// more words with the message.
+const FUZZY_PROCESS_SHORTLIST_SIZE int = 10
 
-	// collect result filter
-	matchChoices = append(matchChoices, resultFilter...)
+// Searchs the dataset key whose message is the most similar to the message, by the message words index. The
+// candidates are the operations with all the message words (SINTER of the words sets) or, if none, the ones sharing
+// more words with it (FUZZY_PROCESS_SHORTLIST_SIZE at most), and only they are scored by fuzzy match.
+// output: the key and its match score (0 to 100), empty if none has the words or the min scoring.
+func MatchByWordIndex(message []string) (matchKey string, matchScore int) {
+	wordKeys := messageWordKeys(message)
+	if len(wordKeys) == 0 {
+		return "", 0
+	}
+	cnn := GetRedisConnection()
+	if cnn == nil {
+		return "", 0
+	}
+	defer cnn.Close()
 
-	for scanCursor != "0" {
-		// do partial scan
-		partialScan(matchFilter, &scanCursor, &keys)
+	operations, interErr := redis.Strings(cnn.Do("SINTER", redis.Args{}.AddFlat(wordKeys)...))
+	if interErr != nil {
+		log.Printf("Error in SINTER to redis. Keys: %v. Trace: %s", wordKeys, interErr.Error())
+		return "", 0
+	}
+	// all the candidates have the message words, the score just ranks them
+	processMinScoring := 0
+	if len(operations) == 0 {
+		log.Printf("WARN Key not found, trying filter by fuzzy match process, message: %s", message)
+		operations = shortlistByWordIndex(cnn, wordKeys)
+		processMinScoring = FUZZY_PROCESS_MIN_SCORING_ACCEPTED
+	} else if len(operations) > 1 {
+		log.Printf("WARN found more than one dataset with the message words: %s. Using the most similar", message)
+	}
+	return bestMatchingKey(message, datasetKeysOf(cnn, operations), processMinScoring)
+}
 
-		// apply filter by fuzzy match scoring
-		resultFilter = filterKeysByFuzzyProcessScore(extractionPhrase, minScoring, keys)
+// Gets the operations sharing more words with a message, by the message words index.
+// input: the message words index keys, see messageWordKeys.
+// output: FUZZY_PROCESS_SHORTLIST_SIZE operations at most, the ones with more shared words first.
+func shortlistByWordIndex(cnn redis.Conn, wordKeys []string) (operations []string) {
+	operations, _ = operationsBySharedWords(wordKeys, func(wordKey string) []string {
+		members, membersErr := redis.Strings(cnn.Do("SMEMBERS", wordKey))
+		if membersErr != nil {
+			log.Printf("Error in SMEMBERS to redis. Key: %s. Trace: %s", wordKey, membersErr.Error())
+		}
+		return members
+	})
+	if len(operations) > FUZZY_PROCESS_SHORTLIST_SIZE {
+		operations = operations[:FUZZY_PROCESS_SHORTLIST_SIZE]
+	}
+	return operations
+}
 
-		// collect result filter
-		matchChoices = append(matchChoices, resultFilter...)
+// Gets the candidates operations to match a message, by a message words index: the ones with all the message words or,
+// if none, the ones sharing more words with it (FUZZY_PROCESS_SHORTLIST_SIZE at most). See MatchByWordIndex.
+// input: the message words index keys (see messageWordKeys) and the function to get the operations of each one.
+// output: the operations and the min fuzzy match scoring to accept one of them.
+func candidatesByWordIndex(wordKeys []string, membersOf func(wordKey string) []string) (operations []string, processMinScoring int) {
+	operations, sharedWords := operationsBySharedWords(wordKeys, membersOf)
+	withAllWords := 0
+	for withAllWords < len(operations) && sharedWords[operations[withAllWords]] == len(wordKeys) {
+		withAllWords++
+	}
+	if withAllWords > 0 {
+		// all the candidates have the message words, the score just ranks them
+		return operations[:withAllWords], 0
+	}
+	if len(operations) > FUZZY_PROCESS_SHORTLIST_SIZE {
+		operations = operations[:FUZZY_PROCESS_SHORTLIST_SIZE]
 	}
+	return operations, FUZZY_PROCESS_MIN_SCORING_ACCEPTED
+}
 
-	// select result with the best scoring
-	sort.Slice(matchChoices, func(i, j int) bool {
-		return matchChoices[i].Score > matchChoices[j].Score
+// Gets the operations of a message words index sharing words with a message.
+// input: the message words index keys (see messageWordKeys) and the function to get the operations of each one.
+// output: the operations, the ones with more shared words first, and the count of words shared by each one.
+func operationsBySharedWords(wordKeys []string, membersOf func(wordKey string) []string) (operations []string, sharedWords map[string]int) {
+	sharedWords = map[string]int{}
+	for _, wordKey := range wordKeys {
+		for _, operation := range membersOf(wordKey) {
+			if sharedWords[operation] == 0 {
+				operations = append(operations, operation)
+			}
+			sharedWords[operation]++
+		}
+	}
+	sort.SliceStable(operations, func(i, j int) bool {
+		return sharedWords[operations[i]] > sharedWords[operations[j]]
 	})
+	return operations, sharedWords
+}
 
-	if len(matchChoices) > 0 {
-		// selects the first elem of sorted results
-		matchKey = matchChoices[0].key
+// Gets the keys of the incomplete datasets of the operations, from their hashes.
+func datasetKeysOf(cnn redis.Conn, operations []string) (keys []string) {
+	for _, operation := range operations {
+		key, getErr := redis.String(cnn.Do("HGET", DATASET_HASH_KEY_PREFIX+operation, DATASET_FIELD_KEY))
+		if getErr != nil {
+			log.Printf("WARN dataset of operation: %s in the message words index not found. Trace: %s", operation, getErr.Error())
+			continue
+		}
+		if key != operation {
+			keys = append(keys, key)
+		}
 	}
-	return
-
+	return keys
 }
 
 type matchChoiceKey struct {
@@ -502,7 +911,7 @@ type matchChoiceKey struct {
 
 func filterKeysByFuzzyProcessScore(extractionPhrase string, processMinScoring int, keys []string) (matchs []matchChoiceKey) {
 	// clean key from operation segment
-	choices := make([]string, len(keys))
+	choices := make([]string, 0, len(keys))
 	linkedKeys := make(map[string]string, len(keys))
 	for _, key := range keys {
 		sepIdx := strings.IndexAny(key, ":")
@@ -524,7 +933,7 @@ func filterKeysByFuzzyProcessScore(extractionPhrase string, processMinScoring in
 	}
 
 	// build result match list with recovering full key
-	matchs = make([]matchChoiceKey, len(matchPairs))
+	matchs = make([]matchChoiceKey, 0, len(matchPairs))
 	for _, matchPair := range matchPairs {
 		// only accept those who have a higher scoring
 		if matchPair.Score >= processMinScoring {
@@ -547,17 +956,3 @@ func ScanKeys(matchFilter string) (keys []string) {
 	}
 	return
 }
-
-func GetByKey(key string) (value string) {
-	cnn := GetRedisConnection()
-	if cnn == nil {
-		return
-	}
-	var getErr error
-	value, getErr = redis.String(cnn.Do("GET", key))
-	if getErr != nil {
-		log.Printf("Error in GET to redis. Key: %s. Trace: %s", key, getErr.Error())
-		return
-	}
-	return
-}
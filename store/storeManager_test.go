@@ -2,14 +2,18 @@ package store_test
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	test "github.com/mgironi/operation-fire-quasar/_test"
 	"github.com/mgironi/operation-fire-quasar/model"
+	"github.com/mgironi/operation-fire-quasar/security"
 	"github.com/mgironi/operation-fire-quasar/store"
 )
 
@@ -96,6 +100,90 @@ func TestConvertSateliteInfo(t *testing.T) {
 	}
 }
 
+// Tests ConvertSateliteInfo with the optional z coordinate
+func TestConvertSateliteInfoWithAltitude(t *testing.T) {
+	satInfo, err := store.ConvertSateliteInfo("rex_-100.5,200,1500.25")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wanted := model.Point{X: -100.5, Y: 200, Z: 1500.25}
+	if !satInfo.Location.EqualTo(wanted) {
+		t.Errorf("Location mismatch. Is %s, wanted %s", satInfo.Location, wanted)
+	}
+
+	_, err = store.ConvertSateliteInfo("rex_-100.5,200,1500.25,10")
+	if err == nil {
+		t.Errorf("Convertion with 4 coordinates should fail")
+	}
+}
+
+// Tests ConvertSateliteInfo with the distances noise standard deviation
+func TestConvertSateliteInfoWithNoise(t *testing.T) {
+	satInfo, err := store.ConvertSateliteInfo("rex_-100.5,200_2.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if satInfo.NoiseStdDev != 2.5 {
+		t.Errorf("Noise standard deviation mismatch. Is %f, wanted %f", satInfo.NoiseStdDev, 2.5)
+	}
+
+	for _, infoStr := range []string{"rex_-100.5,200_0", "rex_-100.5,200_-1", "rex_-100.5,200_x", "rex_-100.5,200_1_2"} {
+		if _, err = store.ConvertSateliteInfo(infoStr); err == nil {
+			t.Errorf("Convertion of '%s' should fail", infoStr)
+		}
+	}
+}
+
+func TestInitializeSatelitesKeys(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	os.Setenv(store.SATELITES_KEYS_ENV, "kenobi=hmac:c2VjcmV0; sato=rsa:c2VjcmV0;rex=hmac:c2VjcmV0")
+
+	store.InitializeSatelitesInfo()
+	satellites := store.GetSatellitesInfo()
+	wantKenobi := &security.VerificationKey{Algorithm: security.ALGORITHM_HMAC, Key: []byte("secret")}
+	if !reflect.DeepEqual(satellites[0].VerificationKey, wantKenobi) {
+		t.Errorf("Kenobi verification key mismatch. Is %v, wanted %v", satellites[0].VerificationKey, wantKenobi)
+	}
+	if satellites[1].VerificationKey != nil {
+		t.Errorf("Skywalker verification key should be nil, is %v", satellites[1].VerificationKey)
+	}
+	// the invalid key rejects every report
+	if satellites[2].VerificationKey == nil || satellites[2].VerificationKey.Algorithm != "" {
+		t.Errorf("Sato verification key should be without algorithm, is %v", satellites[2].VerificationKey)
+	}
+
+	// cleans the keys
+	test.CleanSatelitesInfoEnvs()
+	store.InitializeSatelitesInfo()
+}
+
+// Tests ConvertSateliteInfo with the location in geodetic coordinates
+func TestConvertSateliteInfoGeodetic(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	defer func() {
+		test.CleanSatelitesInfoEnvs()
+		store.InitializeSatelitesInfo()
+	}()
+
+	// without reference origin can't be converted
+	store.InitializeReferenceOrigin()
+	_, err := store.ConvertSateliteInfo("rex_geo:-34.6037,-58.3816,525")
+	if err == nil {
+		t.Errorf("Convertion of geodetic location without reference origin should fail")
+	}
+
+	os.Setenv(store.REFERENCE_ORIGIN_ENV, "-34.6037,-58.3816,25")
+	store.InitializeReferenceOrigin()
+	satInfo, err := store.ConvertSateliteInfo("rex_geo:-34.6037,-58.3816,525")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wanted := model.Point{X: 0, Y: 0, Z: 500}
+	if !satInfo.Location.EqualTo(wanted) {
+		t.Errorf("Location mismatch. Is %s, wanted %s", satInfo.Location, wanted)
+	}
+}
+
 func TestGetSatelliteInfoIndex(t *testing.T) {
 	type args struct {
 		name string
@@ -128,14 +216,14 @@ func TestGetNewOperationUUID(t *testing.T) {
 
 func TestGetDatasetByKey(t *testing.T) {
 	conn := test.InitRedisMockConnection()
-	key := "thesimplekey"
+	key := "anoperation:the simple key"
 	wantedDataset := model.Dataset{
 		Key:        key,
 		Operation:  "anoperation",
 		Satellites: []model.SatelliteInfoRequest{},
+		Version:    1,
 	}
-	wantedMarshaled, _ := json.Marshal(wantedDataset)
-	cmd := conn.Command("GET", key).Expect(wantedMarshaled)
+	cmd := conn.Command("HGETALL", "dataset:anoperation").Expect(test.DatasetHashReply(wantedDataset))
 	gotDataset := store.GetDatasetByKey(key)
 
 	if conn.Stats(cmd) != 1 {
@@ -148,6 +236,45 @@ func TestGetDatasetByKey(t *testing.T) {
 
 }
 
+func TestRegisterNonce(t *testing.T) {
+	conn := test.InitRedisMockConnection()
+	cmd := conn.Command("SET", "nonce:kenobi:3f2a9c", 1, "NX", "EX", 600).Expect("OK").Expect(nil)
+
+	isNew, err := store.RegisterNonce("kenobi", "3f2a9c", 600)
+	if err != nil || !isNew {
+		t.Errorf("Error TestRegisterNonce(), first registration. Is new: %v, error: %v", isNew, err)
+	}
+	isNew, err = store.RegisterNonce("kenobi", "3f2a9c", 600)
+	if err != nil || isNew {
+		t.Errorf("Error TestRegisterNonce(), replayed registration. Is new: %v, error: %v", isNew, err)
+	}
+	if conn.Stats(cmd) != 2 {
+		t.Errorf("Error TestRegisterNonce(), redis command not used.")
+	}
+
+	conn.Command("SET", "nonce:kenobi:fails", 1, "NX", "EX", 600).ExpectError(errors.New("connection refused"))
+	if _, err = store.RegisterNonce("kenobi", "fails", 600); err == nil {
+		t.Errorf("Error TestRegisterNonce(), store error expected.")
+	}
+}
+
+func TestReleaseNonce(t *testing.T) {
+	conn := test.InitRedisMockConnection()
+	cmd := conn.Command("DEL", "nonce:kenobi:3f2a9c").Expect(int64(1))
+
+	if err := store.ReleaseNonce("kenobi", "3f2a9c"); err != nil {
+		t.Errorf("Error TestReleaseNonce(). Trace: %v", err)
+	}
+	if conn.Stats(cmd) != 1 {
+		t.Errorf("Error TestReleaseNonce(), redis command not used.")
+	}
+
+	conn.Command("DEL", "nonce:kenobi:fails").ExpectError(errors.New("connection refused"))
+	if err := store.ReleaseNonce("kenobi", "fails"); err == nil {
+		t.Errorf("Error TestReleaseNonce(), store error expected.")
+	}
+}
+
 func TestSaveNewDataset(t *testing.T) {
 	conn := test.InitRedisMockConnection()
 	operation := store.GetNewOperationUUID()
@@ -161,19 +288,34 @@ func TestSaveNewDataset(t *testing.T) {
 		Satellites: []model.SatelliteInfoRequest{dataValue},
 		Key:        wantKey,
 		Operation:  operation,
+		Version:    1,
 	}
-	wantValue, _ := json.Marshal(wantValueStruct)
-	cmd := conn.Command("SET", wantKey, wantValue, "NX").Expect("OK")
+	hashKey := store.DATASET_HASH_KEY_PREFIX + operation
+	conn.Command("WATCH", hashKey).Expect("OK")
+	conn.Command("EXISTS", hashKey).Expect(int64(0))
+	conn.Command("MULTI").Expect("OK")
+	cmd := test.MockDatasetWrite(conn, wantValueStruct)
+	cmdSADD := conn.Command("SADD", store.DATASETS_WORD_INDEX_KEY_PREFIX+"3:msg", operation).Expect("QUEUED")
+	conn.Command("EXEC").Expect([]interface{}{int64(4), int64(1)})
 
-	store.SaveNewDataset(operation, dataValue)
+	saved := store.SaveNewDataset(operation, dataValue)
 
-	if operation == "" {
+	if !saved {
 		t.Fatalf("Error TestSaveNewDataset(), dataset not saved. values:%v", dataValue)
 	}
 
-	if conn.Stats(cmd) != 1 {
+	if conn.Stats(cmd) != 1 || conn.Stats(cmdSADD) != 1 {
 		t.Fatalf("Error TestSaveNewDataset(), redis command not used.")
 	}
+
+	// the operation has a dataset
+	conn.Command("EXISTS", hashKey).Expect(int64(1))
+	if store.SaveNewDataset(operation, dataValue) {
+		t.Errorf("Error TestSaveNewDataset(), the existent dataset was overridden.")
+	}
+	if conn.Stats(cmd) != 1 {
+		t.Errorf("Error TestSaveNewDataset(), redis command HSET used with existent dataset.")
+	}
 }
 
 func TestUpdateDataset(t *testing.T) {
@@ -189,31 +331,39 @@ func TestUpdateDataset(t *testing.T) {
 		Satellites: []model.SatelliteInfoRequest{previousValue},
 		Key:        previousKey,
 		Operation:  operation,
+		Version:    1,
 	}
-	previousValueMsl, _ := json.Marshal(previousValueStruct)
-	cmdGET := conn.Command("GET", previousKey).Expect(previousValueMsl)
+	hashKey := store.DATASET_HASH_KEY_PREFIX + operation
+	cmdHGETALL := conn.Command("HGETALL", hashKey).Expect(test.DatasetHashReply(previousValueStruct))
 	consMsg := "is  a msg"
 	wantedKey := fmt.Sprintf("%s:%s", operation, consMsg)
 	dataValue := model.SatelliteInfoRequest{Name: "sato", Distance: 100, Message: []string{"is", "", "a", "msg"}}
-	dataValueStruct := model.Dataset{Key: wantedKey, Operation: operation, Satellites: []model.SatelliteInfoRequest{previousValue, dataValue}}
-	dataValueMsl, _ := json.Marshal(dataValueStruct)
+	dataValueStruct := model.Dataset{Key: wantedKey, Operation: operation, Satellites: []model.SatelliteInfoRequest{previousValue, dataValue}, Version: 2}
 
-	cmdSET := conn.Command("SET", wantedKey, dataValueMsl, "NX").Expect("OK")
-	cmdDEL := conn.Command("DEL", previousKey).Expect(int64(1))
+	conn.Command("WATCH", hashKey).Expect("OK")
+	conn.Command("MULTI").Expect("OK")
+	cmdHSET := test.MockDatasetWrite(conn, dataValueStruct)
+	cmdSREM := conn.GenericCommand("SREM")
+	cmdSADD := conn.Command("SADD", store.DATASETS_WORD_INDEX_KEY_PREFIX+"3:msg", operation).Expect("QUEUED")
+	cmdEXEC := conn.Command("EXEC").Expect([]interface{}{int64(1), int64(0), int64(1)})
 
-	saved := store.UpdateDataset(operation, consMsg, previousKey, dataValue)
+	saved, err := store.UpdateDataset(operation, consMsg, previousKey, 1, dataValue)
 
-	if !saved {
+	if !saved || err != nil {
 		t.Errorf("Error TestUpdateDataset(), dataset not updated. values:%v", dataValue)
 	}
-	if conn.Stats(cmdGET) != 2 {
-		t.Errorf("Error TestUpdateDataset(), redis command GET not used.")
+	if conn.Stats(cmdHGETALL) != 1 {
+		t.Errorf("Error TestUpdateDataset(), redis command HGETALL not used.")
 	}
-	if conn.Stats(cmdSET) != 1 {
-		t.Errorf("Error TestUpdateDataset(), redis command SET not used.")
+	if conn.Stats(cmdHSET) != 1 {
+		t.Errorf("Error TestUpdateDataset(), redis command HSET not used.")
 	}
-	if conn.Stats(cmdDEL) != 1 {
-		t.Errorf("Error TestUpdateDataset(), redis command DEL not used.")
+	// the words of the previous and the consolidated messages, 'is', 'a' and 'msg'
+	if conn.Stats(cmdSREM) != 3 || conn.Stats(cmdSADD) != 1 {
+		t.Errorf("Error TestUpdateDataset(), message words index not updated. SREM: %d, SADD: %d", conn.Stats(cmdSREM), conn.Stats(cmdSADD))
+	}
+	if conn.Stats(cmdEXEC) != 1 {
+		t.Errorf("Error TestUpdateDataset(), redis command EXEC not used.")
 	}
 }
 
@@ -230,33 +380,84 @@ func TestUpdateDatasetByOperation(t *testing.T) {
 		Satellites: []model.SatelliteInfoRequest{previousValue},
 		Key:        previousKey,
 		Operation:  operation,
+		Version:    1,
 	}
-	previousValueMsl, _ := json.Marshal(previousValueStruct)
-	cmdGET := conn.Command("GET", previousKey).Expect(previousValueMsl)
-	//consMsg := "is  a msg"
+	hashKey := store.DATASET_HASH_KEY_PREFIX + operation
+	cmdHGETALL := conn.Command("HGETALL", hashKey).Expect(test.DatasetHashReply(previousValueStruct))
 	wantedKey := operation
 	dataValue := model.SatelliteInfoRequest{Name: "sato", Distance: 100, Message: []string{"is", "", "a", "msg"}}
-	dataValueStruct := model.Dataset{Key: wantedKey, Operation: operation, Satellites: []model.SatelliteInfoRequest{previousValue, dataValue}}
-	dataValueMsl, _ := json.Marshal(dataValueStruct)
+	dataValueStruct := model.Dataset{Key: wantedKey, Operation: operation, Satellites: []model.SatelliteInfoRequest{previousValue, dataValue}, Version: 2}
 
-	cmdSET := conn.Command("SET", wantedKey, dataValueMsl, "NX").Expect("OK")
-	cmdDEL := conn.Command("DEL", previousKey).Expect(int64(1))
+	conn.Command("WATCH", hashKey).Expect("OK")
+	conn.Command("MULTI").Expect("OK")
+	cmdHSET := test.MockDatasetWrite(conn, dataValueStruct)
+	cmdSADD := conn.GenericCommand("SADD")
+	cmdSREM := conn.GenericCommand("SREM")
+	cmdEXEC := conn.Command("EXEC").Expect([]interface{}{int64(1), int64(0), int64(1)})
 
-	saved := store.UpdateDataset(operation, "", previousKey, dataValue)
+	saved, err := store.UpdateDataset(operation, "", previousKey, 1, dataValue)
 
-	if !saved {
+	if !saved || err != nil {
 		t.Errorf("Error TestUpdateDatasetByOperation(), dataset not updated. values:%v", dataValue)
 	}
-	if conn.Stats(cmdGET) != 2 {
-		t.Errorf("Error TestUpdateDatasetByOperation(), redis command GET not used.")
+	if conn.Stats(cmdHGETALL) != 1 {
+		t.Errorf("Error TestUpdateDatasetByOperation(), redis command HGETALL not used.")
+	}
+	if conn.Stats(cmdHSET) != 1 {
+		t.Errorf("Error TestUpdateDatasetByOperation(), redis command HSET not used.")
 	}
-	if conn.Stats(cmdSET) != 1 {
-		t.Errorf("Error TestUpdateDatasetByOperation(), redis command SET not used.")
+	if conn.Stats(cmdSADD) != 0 {
+		t.Errorf("Error TestUpdateDatasetByOperation(), the complete dataset is indexed.")
 	}
-	if conn.Stats(cmdDEL) != 1 {
-		t.Errorf("Error TestUpdateDatasetByOperation(), redis command DEL not used.")
+	if conn.Stats(cmdSREM) != 3 {
+		t.Errorf("Error TestUpdateDatasetByOperation(), the previous message words are indexed yet.")
+	}
+	if conn.Stats(cmdEXEC) != 1 {
+		t.Errorf("Error TestUpdateDatasetByOperation(), redis command EXEC not used.")
 	}
 }
+func TestUpdateDatasetConcurrently(t *testing.T) {
+	conn := test.InitRedisMockConnection()
+	operation := store.GetNewOperationUUID()
+	previousValue := model.SatelliteInfoRequest{Name: "kenobi", Distance: 100, Message: []string{"is", "", "a", "msg"}}
+	previousKey := fmt.Sprintf("%s:%s", operation, strings.Join(previousValue.Message, " "))
+	previous := model.Dataset{Key: previousKey, Operation: operation, Satellites: []model.SatelliteInfoRequest{previousValue}, Version: 1}
+	dataValue := model.SatelliteInfoRequest{Name: "sato", Distance: 100, Message: []string{"is", "", "a", "msg"}}
+	updated := model.Dataset{Key: operation, Operation: operation, Satellites: []model.SatelliteInfoRequest{previousValue, dataValue}, Version: 2}
+
+	hashKey := store.DATASET_HASH_KEY_PREFIX + operation
+	conn.Command("WATCH", hashKey).Expect("OK")
+	conn.Command("MULTI").Expect("OK")
+	test.MockDatasetWrite(conn, updated)
+	cmdHGETALL := conn.Command("HGETALL", hashKey).Expect(test.DatasetHashReply(previous))
+	// the watched hash changes during the first transaction, that is aborted and reported as conflict
+	cmdEXEC := conn.Command("EXEC").Expect(nil).Expect([]interface{}{int64(1), int64(0)})
+
+	saved, err := store.UpdateDataset(operation, "", previousKey, 1, dataValue)
+	if saved || !errors.Is(err, store.ErrDatasetConflict) {
+		t.Errorf("Error TestUpdateDatasetConcurrently(), aborted transaction. got: %t, %v", saved, err)
+	}
+	if conn.Stats(cmdHGETALL) != 1 || conn.Stats(cmdEXEC) != 1 {
+		t.Errorf("Error TestUpdateDatasetConcurrently(), the aborted transaction is retried. HGETALL: %d, EXEC: %d", conn.Stats(cmdHGETALL), conn.Stats(cmdEXEC))
+	}
+
+	// the caller updates it again
+	saved, err = store.UpdateDataset(operation, "", previousKey, 1, dataValue)
+	if !saved || err != nil {
+		t.Errorf("Error TestUpdateDatasetConcurrently(), dataset not updated. Trace: %v", err)
+	}
+
+	// the dataset was updated by other satellite since it was read
+	conn.Command("HGETALL", hashKey).Expect(test.DatasetHashReply(updated))
+	saved, err = store.UpdateDataset(operation, "", previousKey, 1, dataValue)
+	if saved || !errors.Is(err, store.ErrDatasetConflict) {
+		t.Errorf("Error TestUpdateDatasetConcurrently(), previous version updated. got: %t, %v", saved, err)
+	}
+	if conn.Stats(cmdEXEC) != 2 {
+		t.Errorf("Error TestUpdateDatasetConcurrently(), redis command EXEC used with conflict.")
+	}
+}
+
 func TestGetDatasetByMessage(t *testing.T) {
 	conn := test.InitRedisMockConnection()
 	dataValue := model.SatelliteInfoRequest{Name: "kenobi", Distance: 100, Message: []string{"es", "", "msg"}}
@@ -266,42 +467,35 @@ func TestGetDatasetByMessage(t *testing.T) {
 		Key:        key,
 		Operation:  operation,
 		Satellites: []model.SatelliteInfoRequest{dataValue},
+		Version:    1,
 	}
 
-	rslScan1 := make([]interface{}, 2)
-	rslScan1[0] = "210"
-	rslScan1[1] = []interface{}{}
-	cmdSCAN1 := conn.Command("SCAN", "0", "MATCH", "*:es * msg").Expect(rslScan1)
-
-	rslScan2 := make([]interface{}, 2)
-	rslScan2[0] = "0"
-	rslScan2[1] = []interface{}{key}
-	cmdSCAN2 := conn.Command("SCAN", "210", "MATCH", "*:es * msg").Expect(rslScan2)
-
-	wantMsl, _ := json.Marshal(want)
-	cmdGET := conn.Command("GET", key).Expect(wantMsl)
+	// the empty words aren't indexed
+	cmdSINTER := conn.Command("SINTER", "datasets:word:0:es", "datasets:word:2:msg").Expect([]interface{}{[]byte(operation)})
+	cmdHGET := conn.Command("HGET", store.DATASET_HASH_KEY_PREFIX+operation, store.DATASET_FIELD_KEY).Expect([]byte(key))
+	cmdHGETALL := conn.Command("HGETALL", store.DATASET_HASH_KEY_PREFIX+operation).Expect(test.DatasetHashReply(want))
 
 	message := []string{"es", "", "msg"}
-	got := store.GetDatasetByMessage(message)
+	got, gotScore := store.GetDatasetByMessage(message)
 
-	if conn.Stats(cmdSCAN1) != 1 {
-		t.Errorf("Error TestGetDatasetByMessage(), redis command SCAN1 not used.")
+	if conn.Stats(cmdSINTER) != 1 {
+		t.Errorf("Error TestGetDatasetByMessage(), redis command SINTER not used.")
 	}
 
-	if conn.Stats(cmdSCAN2) != 1 {
-		t.Errorf("Error TestGetDatasetByMessage(), redis command SCAN2 not used.")
+	if conn.Stats(cmdHGET) != 1 {
+		t.Errorf("Error TestGetDatasetByMessage(), redis command HGET not used.")
 	}
 
-	if conn.Stats(cmdGET) != 1 {
-		t.Errorf("Error TestGetDatasetByMessage(), redis command GET not used.")
+	if conn.Stats(cmdHGETALL) != 1 {
+		t.Errorf("Error TestGetDatasetByMessage(), redis command HGETALL not used.")
 	}
 
 	if got.Key == "" {
 		t.Errorf("Error TestGetDatasetByMessage(), returns an empty dataset.")
 	}
 
-	if !reflect.DeepEqual(got, want) {
-		t.Errorf("Error TestGetDatasetByMessage(), result mismatch.\n---got:\n%v\n---want:\n%v", got, want)
+	if !reflect.DeepEqual(got, want) || gotScore != 100 {
+		t.Errorf("Error TestGetDatasetByMessage(), result mismatch. score: %d\n---got:\n%v\n---want:\n%v", gotScore, got, want)
 	}
 
 }
@@ -315,75 +509,80 @@ func TestGetDatasetByMessageForcingFuzzy(t *testing.T) {
 		Key:        key,
 		Operation:  operation,
 		Satellites: []model.SatelliteInfoRequest{dataValue},
+		Version:    1,
 	}
 
-	rslScan1 := make([]interface{}, 2)
-	rslScan1[0] = "0"
-	rslScan1[1] = []interface{}{}
-	cmdSCAN1 := conn.Command("SCAN", "0", "MATCH", "*:es un msg").Expect(rslScan1)
-
-	rslScan2 := make([]interface{}, 2)
-	rslScan2[0] = "0"
-	rslScan2[1] = []interface{}{key}
-	cmdSCAN2 := conn.Command("SCAN", "0", "MATCH", "*").Expect(rslScan2)
-
-	wantMsl, _ := json.Marshal(want)
-	cmdGET := conn.Command("GET", key).Expect(wantMsl)
+	// none has all the words, the ones sharing words are scored
+	cmdSINTER := conn.Command("SINTER", "datasets:word:0:es", "datasets:word:1:un", "datasets:word:2:msg").Expect([]interface{}{})
+	cmdSMEMBERS1 := conn.Command("SMEMBERS", "datasets:word:0:es").Expect([]interface{}{[]byte(operation), []byte("456")})
+	cmdSMEMBERS2 := conn.Command("SMEMBERS", "datasets:word:1:un").Expect([]interface{}{})
+	cmdSMEMBERS3 := conn.Command("SMEMBERS", "datasets:word:2:msg").Expect([]interface{}{[]byte(operation)})
+	conn.Command("HGET", store.DATASET_HASH_KEY_PREFIX+operation, store.DATASET_FIELD_KEY).Expect([]byte(key))
+	conn.Command("HGET", store.DATASET_HASH_KEY_PREFIX+"456", store.DATASET_FIELD_KEY).Expect([]byte("456:es otro mensaje diferente"))
+	cmdHGETALL := conn.Command("HGETALL", store.DATASET_HASH_KEY_PREFIX+operation).Expect(test.DatasetHashReply(want))
 
 	message := []string{"es", "un", "msg"}
-	got := store.GetDatasetByMessage(message)
+	got, gotScore := store.GetDatasetByMessage(message)
 
-	if conn.Stats(cmdSCAN1) != 1 {
-		t.Errorf("Error TestGetDatasetByMessageForcingFuzzy(), redis command SCAN1 not used.")
+	if conn.Stats(cmdSINTER) != 1 {
+		t.Errorf("Error TestGetDatasetByMessageForcingFuzzy(), redis command SINTER not used.")
 	}
 
-	if conn.Stats(cmdSCAN2) != 1 {
-		t.Errorf("Error TestGetDatasetByMessageForcingFuzzy(), redis command SCAN2 not used.")
+	if conn.Stats(cmdSMEMBERS1) != 1 || conn.Stats(cmdSMEMBERS2) != 1 || conn.Stats(cmdSMEMBERS3) != 1 {
+		t.Errorf("Error TestGetDatasetByMessageForcingFuzzy(), redis command SMEMBERS not used.")
 	}
 
-	if conn.Stats(cmdGET) != 1 {
-		t.Errorf("Error TestGetDatasetByMessageForcingFuzzy(), redis command GET not used.")
+	if conn.Stats(cmdHGETALL) != 1 {
+		t.Errorf("Error TestGetDatasetByMessageForcingFuzzy(), redis command HGETALL not used.")
 	}
 
 	if got.Key == "" {
 		t.Errorf("Error TestGetDatasetByMessageForcingFuzzy(), returns an empty dataset.")
 	}
 
-	if !reflect.DeepEqual(got, want) {
-		t.Errorf("Error TestGetDatasetByMessageForcingFuzzy(), result mismatch.\n---got:\n%v\n---want:\n%v", got, want)
+	if !reflect.DeepEqual(got, want) || gotScore < store.FUZZY_PROCESS_MIN_SCORING_ACCEPTED || gotScore == 100 {
+		t.Errorf("Error TestGetDatasetByMessageForcingFuzzy(), result mismatch. score: %d\n---got:\n%v\n---want:\n%v", gotScore, got, want)
 	}
 
 }
 
-func TestScanWithFuzzy(t *testing.T) {
+func TestMatchByWordIndex(t *testing.T) {
 	conn := test.InitRedisMockConnection()
 
-	message := []string{"", "is", "message"}
-	want := "123:this  message"
-	similar := "751:this  mess"
+	message := []string{"this", "Is", "", "message"}
+	want := "123:this is a message"
+	similar := "751:this is the message that was lost"
 
-	rslScan1 := make([]interface{}, 2)
-	rslScan1[0] = "37"
-	rslScan1[1] = []interface{}{want, "156:other msg"}
-	cmdSCAN1 := conn.Command("SCAN", "0", "MATCH", "*").Expect(rslScan1)
+	cmdSINTER := conn.Command("SINTER", "datasets:word:0:this", "datasets:word:1:is", "datasets:word:3:message").Expect([]interface{}{[]byte("751"), []byte("123"), []byte("456")})
+	conn.Command("HGET", "dataset:123", store.DATASET_FIELD_KEY).Expect([]byte(want))
+	conn.Command("HGET", "dataset:751", store.DATASET_FIELD_KEY).Expect([]byte(similar))
+	// complete, its words are indexed yet
+	conn.Command("HGET", "dataset:456", store.DATASET_FIELD_KEY).Expect([]byte("456"))
 
-	rslScan2 := make([]interface{}, 2)
-	rslScan2[0] = "0"
-	rslScan2[1] = []interface{}{similar, "1586:other msg"}
-	cmdSCAN2 := conn.Command("SCAN", "37", "MATCH", "*").Expect(rslScan2)
+	got, gotScore := store.MatchByWordIndex(message)
 
-	got := store.ScanWithFuzzy(message)
+	if conn.Stats(cmdSINTER) != 1 {
+		t.Errorf("Error TestMatchByWordIndex(), redis command SINTER not used.")
+	}
 
-	if conn.Stats(cmdSCAN1) != 1 {
-		t.Errorf("Error TestScanWithFuzzy(), redis command SCAN1 not used.")
+	if got != want || gotScore < store.FUZZY_PROCESS_MIN_SCORING_ACCEPTED {
+		t.Errorf("Error TestMatchByWordIndex(), result mismatch. got: '%s' (%d), want:'%s'", got, gotScore, want)
 	}
 
-	if conn.Stats(cmdSCAN2) != 1 {
-		t.Errorf("Error TestScanWithFuzzy(), redis command SCAN2 not used.")
+	// the datasets sharing some words aren't similar enough
+	conn.Command("SINTER", "datasets:word:0:this", "datasets:word:1:is", "datasets:word:3:message").Expect([]interface{}{})
+	conn.Command("SMEMBERS", "datasets:word:0:this").Expect([]interface{}{})
+	conn.Command("SMEMBERS", "datasets:word:1:is").Expect([]interface{}{[]byte("156")})
+	conn.Command("SMEMBERS", "datasets:word:3:message").Expect([]interface{}{})
+	conn.Command("HGET", "dataset:156", store.DATASET_FIELD_KEY).Expect([]byte("156:where is the ship"))
+
+	if got, gotScore := store.MatchByWordIndex(message); got != "" {
+		t.Errorf("Error TestMatchByWordIndex(), got not similar key: '%s' (%d)", got, gotScore)
 	}
 
-	if got != want {
-		t.Errorf("Error TestScanWithFuzzy(), result mismatch. got: '%s', want:'%s'", got, want)
+	// the message without words isn't searched
+	if got, _ := store.MatchByWordIndex([]string{"", " "}); got != "" || conn.Stats(cmdSINTER) != 2 {
+		t.Errorf("Error TestMatchByWordIndex(), searched message without words. got: '%s'", got)
 	}
 }
 
@@ -397,27 +596,338 @@ func TestGetDatasetByOperation(t *testing.T) {
 		Key:        key,
 		Operation:  operation,
 		Satellites: []model.SatelliteInfoRequest{dataValue},
+		Version:    1,
 	}
 
-	rslScan := make([]interface{}, 2)
-	rslScan[0] = "0"
-	rslScan[1] = []interface{}{key}
-	cmdSCAN := conn.Command("SCAN", "0", "MATCH", "123-456:*").Expect(rslScan)
-
-	wantMsl, _ := json.Marshal(want)
-	cmdGET := conn.Command("GET", key).Expect(wantMsl)
+	// the dataset is read directly from the operation hash, without scanning
+	cmdHGETALL := conn.Command("HGETALL", "dataset:123-456").Expect(test.DatasetHashReply(want))
 
 	got := store.GetDatasetByOperation(operation)
 
-	if conn.Stats(cmdSCAN) != 1 {
-		t.Errorf("Error TestGetDatasetByOperation(), redis command SCAN not used.")
+	if conn.Stats(cmdHGETALL) != 1 {
+		t.Errorf("Error TestGetDatasetByOperation(), redis command HGETALL not used.")
 	}
 
-	if conn.Stats(cmdGET) != 1 {
-		t.Errorf("Error TestGetDatasetByOperation(), redis command GET not used.")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Error TestGetDatasetByOperation(), result mismatch.\n---got:\n%v\n---want:\n%v", got, want)
 	}
 
+	// the dataset has another key now
+	if got := store.GetDatasetByKey(key + " other"); got.Key != "" {
+		t.Errorf("Error TestGetDatasetByOperation(), got dataset by previous key: %v", got)
+	}
+	if got := store.GetDatasetByOperation("789"); got.Key != "" {
+		t.Errorf("Error TestGetDatasetByOperation(), got dataset of unknown operation: %v", got)
+	}
+}
+
+func TestMigrateDatasets(t *testing.T) {
+	conn := test.InitRedisMockConnection()
+
+	dataValue := model.SatelliteInfoRequest{Name: "kenobi", Distance: 100, Message: []string{"es", "", "msg"}}
+	incomplete := model.Dataset{Key: "123:es  msg", Operation: "123", Satellites: []model.SatelliteInfoRequest{dataValue}}
+	complete := model.Dataset{Key: "456", Operation: "456", Satellites: []model.SatelliteInfoRequest{dataValue, dataValue, dataValue}}
+	migratedBefore := model.Dataset{Key: "789:es  msg", Operation: "789", Satellites: []model.SatelliteInfoRequest{dataValue}}
+	incompleteMsl, _ := json.Marshal(incomplete)
+	completeMsl, _ := json.Marshal(complete)
+	migratedBeforeMsl, _ := json.Marshal(migratedBefore)
+
+	rslScan := []interface{}{"0", []interface{}{incomplete.Key, complete.Key, migratedBefore.Key, "other", "dataset:789", "datasets:word:0:es", "nonce:kenobi:3f2a9c"}}
+	conn.Command("SCAN", "0", "MATCH", "*").Expect(rslScan)
+	conn.Command("GET", incomplete.Key).Expect(incompleteMsl)
+	conn.Command("GET", complete.Key).Expect(completeMsl)
+	conn.Command("GET", migratedBefore.Key).Expect(migratedBeforeMsl)
+	conn.Command("GET", "other").Expect([]byte("1"))
+	conn.GenericCommand("WATCH").Expect("OK")
+	conn.GenericCommand("UNWATCH").Expect("OK")
+	conn.Command("EXISTS", "dataset:123").Expect(int64(0))
+	conn.Command("EXISTS", "dataset:456").Expect(int64(0))
+	conn.Command("EXISTS", "dataset:789").Expect(int64(1))
+	conn.Command("MULTI").Expect("OK")
+	incomplete.Version, complete.Version = 1, 1
+	cmdHSET1 := test.MockDatasetWrite(conn, incomplete)
+	cmdHSET2 := test.MockDatasetWrite(conn, complete)
+	cmdSADDIncomplete := conn.Command("SADD", "datasets:word:0:es", "123").Expect("QUEUED")
+	cmdDEL1 := conn.Command("DEL", incomplete.Key).Expect("QUEUED")
+	cmdDEL2 := conn.Command("DEL", complete.Key).Expect("QUEUED")
+	cmdDEL3 := conn.Command("DEL", migratedBefore.Key).Expect("QUEUED")
+	// the operation migrated before the message words index
+	migratedBefore.Version = 1
+	conn.Command("HGETALL", "dataset:789").Expect(test.DatasetHashReply(migratedBefore))
+	cmdSADD := conn.Command("SADD", "datasets:word:2:msg", "789").Expect("QUEUED")
+	conn.Command("EXEC").Expect([]interface{}{int64(4), int64(1), int64(1)})
+
+	migrated, indexed, skipped, err := store.MigrateDatasets()
+
+	if err != nil || migrated != 2 || indexed != 1 || skipped != 2 {
+		t.Errorf("Error TestMigrateDatasets(), result mismatch. migrated: %d, indexed: %d, skipped: %d, trace: %v. wanted 2, 1 and 2", migrated, indexed, skipped, err)
+	}
+	if conn.Stats(cmdSADD) != 1 {
+		t.Errorf("Error TestMigrateDatasets(), the operation migrated before isn't indexed by message words.")
+	}
+	if conn.Stats(cmdHSET1) != 1 || conn.Stats(cmdHSET2) != 1 || conn.Stats(cmdDEL1) != 1 || conn.Stats(cmdDEL2) != 1 {
+		t.Errorf("Error TestMigrateDatasets(), datasets not migrated.")
+	}
+	// only the incomplete dataset is indexed by message
+	if conn.Stats(cmdSADDIncomplete) != 1 {
+		t.Errorf("Error TestMigrateDatasets(), the incomplete dataset isn't indexed by message words.")
+	}
+	if conn.Stats(cmdDEL3) != 0 {
+		t.Errorf("Error TestMigrateDatasets(), the dataset of an operation already migrated was removed.")
+	}
+}
+
+// Tests ParseExtraSatelitesInfoFromEnv discarding malformed values
+func TestParseExtraSatelitesInfoFromEnv(t *testing.T) {
+	os.Setenv(store.SATELITES_EXTRA_ENV, "rex_0,500; cody_-300,-600;wrong_value")
+	defer test.CleanSatelitesInfoEnvs()
+
+	want := []model.SateliteInfo{
+		{Name: "rex", Location: model.Point{X: 0, Y: 500}},
+		{Name: "cody", Location: model.Point{X: -300, Y: -600}},
+	}
+	got := store.ParseExtraSatelitesInfoFromEnv(store.SATELITES_EXTRA_ENV)
 	if !reflect.DeepEqual(got, want) {
-		t.Errorf("Error TestGetDatasetByOperation(), result mismatch.\n---got:\n%v\n---want:\n%v", got, want)
+		t.Errorf("ParseExtraSatelitesInfoFromEnv() mismatch.\n---got:\n%v\n---want:\n%v\n", got, want)
+	}
+}
+
+// Tests InitializeSatelitesInfo with a degenerate geometry, default satelites info is expected
+func TestInitializeSatelitesInfoWithCollinearSatelites(t *testing.T) {
+	os.Setenv(store.SATELITE_KENOBI_ENV, "kenobi_0,0")
+	os.Setenv(store.SATELITE_SKYWALKER_ENV, "skywalker_100,100")
+	os.Setenv(store.SATELITE_SATO_ENV, "sato_300,300")
+	defer func() {
+		test.CleanSatelitesInfoEnvs()
+		store.InitializeSatelitesInfo()
+	}()
+
+	err := store.InitializeSatelitesInfo()
+	var geomErr *model.GeometryError
+	if !errors.As(err, &geomErr) || geomErr.Kind != model.GEOMETRY_COLLINEAR {
+		t.Errorf("InitializeSatelitesInfo() error = %v, want collinear geometry error", err)
+	}
+
+	want := []model.Point{{X: -500, Y: -200}, {X: 100, Y: -100}, {X: 500, Y: 100}}
+	got := store.GetKnownReferenceCoordinates()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("InitializeSatelitesInfo() default satelites info not loaded.\n---got:\n%v\n---want:\n%v\n", got, want)
+	}
+}
+
+func TestMemoryDatasetStore(t *testing.T) {
+	datasetStore := store.NewMemoryDatasetStore()
+	kenobi := model.SatelliteInfoRequest{Name: "kenobi", Distance: 500, Message: []string{"este", "", "", "mensaje", ""}}
+	skywalker := model.SatelliteInfoRequest{Name: "skywalker", Distance: 424.26, Message: []string{"", "es", "", "", "secreto"}}
+
+	if !datasetStore.SaveNewDataset("456", kenobi) {
+		t.Fatalf("Error saving new dataset.")
+	}
+	if datasetStore.SaveNewDataset("456", kenobi) {
+		t.Errorf("Error saving new dataset, the existent dataset was overridden.")
+	}
+
+	// search by operation, by partial message and by fuzzy match
+	wantKey := "456:este   mensaje "
+	if got := datasetStore.GetDatasetByOperation("456"); got.Key != wantKey || len(got.Satellites) != 1 {
+		t.Errorf("Error getting dataset by operation. got: %v, wanted key: '%s'.", got, wantKey)
+	}
+	if got, _ := datasetStore.GetDataset("", []string{"este", "", "", "", ""}); got.Key != wantKey {
+		t.Errorf("Error getting dataset by partial message. got: '%s', wanted: '%s'.", got.Key, wantKey)
+	}
+	if got, gotScore := datasetStore.GetDatasetByMessage([]string{"este", "", "", "mensajes", ""}); got.Key != wantKey || gotScore < store.FUZZY_PROCESS_MIN_SCORING_ACCEPTED {
+		t.Errorf("Error getting dataset by fuzzy match. got: '%s' (%d), wanted: '%s'.", got.Key, gotScore, wantKey)
+	}
+	if got := datasetStore.GetDatasetByOperation("789"); got.Key != "" {
+		t.Errorf("Error getting dataset of unknown operation. got: '%s', wanted empty.", got.Key)
+	}
+
+	// the update keys the dataset again, only over the current version
+	if saved, err := datasetStore.UpdateDataset("456", "este es  mensaje secreto", wantKey, 1, skywalker); !saved || err != nil {
+		t.Fatalf("Error updating dataset. Trace: %v", err)
+	}
+	if saved, err := datasetStore.UpdateDataset("456", "", wantKey, 1, skywalker); saved || !errors.Is(err, store.ErrDatasetConflict) {
+		t.Errorf("Error updating removed dataset. got: %t, %v, wanted conflict.", saved, err)
+	}
+	if saved, err := datasetStore.UpdateDataset("456", "", "456:este es  mensaje secreto", 1, skywalker); saved || !errors.Is(err, store.ErrDatasetConflict) {
+		t.Errorf("Error updating previous version of dataset. got: %t, %v, wanted conflict.", saved, err)
+	}
+	if got := datasetStore.GetDatasetByKey(wantKey); got.Key != "" {
+		t.Errorf("Error updating dataset, the previous key '%s' remains.", wantKey)
+	}
+	got := datasetStore.GetDatasetByKey("456:este es  mensaje secreto")
+	want := model.Dataset{Key: "456:este es  mensaje secreto", Operation: "456", Satellites: []model.SatelliteInfoRequest{kenobi, skywalker}, Version: 2}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Error updating dataset.\n---Is:\n%v\n---wanted:\n%v\n", got, want)
+	}
+
+	// the complete dataset is keyed just with the operation, and still found by it (not by message)
+	sato := model.SatelliteInfoRequest{Name: "sato", Distance: 707.1, Message: []string{"este", "", "un", "", ""}}
+	if saved, err := datasetStore.UpdateDataset("456", "", want.Key, 2, sato); !saved || err != nil {
+		t.Fatalf("Error completing dataset. Trace: %v", err)
+	}
+	if got := datasetStore.GetDatasetByOperation("456"); got.Key != "456" || got.Version != 3 || len(got.Satellites) != 3 {
+		t.Errorf("Error getting complete dataset by operation. got: %v, wanted key '456' and version 3.", got)
+	}
+	if got, _ := datasetStore.GetDataset("", []string{"este", "", "", "", ""}); got.Key != "" {
+		t.Errorf("Error getting complete dataset by message. got: '%s', wanted empty.", got.Key)
+	}
+
+	// the nonces are registered once
+	if isNew, err := datasetStore.RegisterNonce("kenobi", "n-kenobi", 600); !isNew || err != nil {
+		t.Errorf("Error registering new nonce. got: %t, %v", isNew, err)
+	}
+	if isNew, err := datasetStore.RegisterNonce("kenobi", "n-kenobi", 600); isNew || err != nil {
+		t.Errorf("Error registering replayed nonce. got: %t, %v", isNew, err)
+	}
+	if isNew, err := datasetStore.RegisterNonce("sato", "n-kenobi", 600); !isNew || err != nil {
+		t.Errorf("Error registering nonce of other satellite. got: %t, %v", isNew, err)
+	}
+
+	// the released nonces can be registered again
+	if err := datasetStore.ReleaseNonce("kenobi", "n-kenobi"); err != nil {
+		t.Errorf("Error releasing nonce. Trace: %v", err)
+	}
+	if isNew, err := datasetStore.RegisterNonce("kenobi", "n-kenobi", 600); !isNew || err != nil {
+		t.Errorf("Error registering released nonce. got: %t, %v", isNew, err)
+	}
+}
+
+func TestFileDatasetStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "datasets.db")
+	fileStore, err := store.NewFileDatasetStore(path, time.Hour)
+	if err != nil {
+		t.Fatalf("Error opening datasets store file. Trace: %s", err.Error())
+	}
+	kenobi := model.SatelliteInfoRequest{Name: "kenobi", Distance: 500, Message: []string{"este", "", "", "mensaje", ""}}
+	skywalker := model.SatelliteInfoRequest{Name: "skywalker", Distance: 424.26, Message: []string{"", "es", "", "", "secreto"}}
+
+	if !fileStore.SaveNewDataset("456", kenobi) {
+		t.Fatalf("Error saving new dataset.")
+	}
+	if fileStore.SaveNewDataset("456", kenobi) {
+		t.Errorf("Error saving new dataset, the existent dataset was overridden.")
+	}
+	wantKey := "456:este   mensaje "
+	if saved, err := fileStore.UpdateDataset("456", "este es  mensaje secreto", wantKey, 1, skywalker); !saved || err != nil {
+		t.Fatalf("Error updating dataset. Trace: %v", err)
+	}
+	if saved, err := fileStore.UpdateDataset("456", "", "456:este es  mensaje secreto", 1, skywalker); saved || !errors.Is(err, store.ErrDatasetConflict) {
+		t.Errorf("Error updating previous version of dataset. got: %t, %v, wanted conflict.", saved, err)
+	}
+	if isNew, err := fileStore.RegisterNonce("kenobi", "n-kenobi", 600); !isNew || err != nil {
+		t.Errorf("Error registering new nonce. got: %t, %v", isNew, err)
+	}
+	fileStore.Close()
+
+	// the datasets and nonces persist after reopening the file
+	fileStore, err = store.NewFileDatasetStore(path, time.Hour)
+	if err != nil {
+		t.Fatalf("Error reopening datasets store file. Trace: %s", err.Error())
+	}
+	defer fileStore.Close()
+	if got := fileStore.GetDatasetByKey(wantKey); got.Key != "" {
+		t.Errorf("Error updating dataset, the previous key '%s' remains.", wantKey)
+	}
+	want := model.Dataset{Key: "456:este es  mensaje secreto", Operation: "456", Satellites: []model.SatelliteInfoRequest{kenobi, skywalker}, Version: 2}
+	if got := fileStore.GetDatasetByOperation("456"); !reflect.DeepEqual(got, want) {
+		t.Errorf("Error getting dataset by operation.\n---Is:\n%v\n---wanted:\n%v\n", got, want)
+	}
+	if got, _ := fileStore.GetDataset("", []string{"", "es", "", "", "secreto"}); got.Key != want.Key {
+		t.Errorf("Error getting dataset by partial message. got: '%s', wanted: '%s'.", got.Key, want.Key)
+	}
+	if got, _ := fileStore.GetDatasetByMessage([]string{"este", "es", "", "mensajes", "secreto"}); got.Key != want.Key {
+		t.Errorf("Error getting dataset by fuzzy match. got: '%s', wanted: '%s'.", got.Key, want.Key)
+	}
+
+	// the complete dataset is keyed just with the operation, and still found by it (not by message)
+	sato := model.SatelliteInfoRequest{Name: "sato", Distance: 707.1, Message: []string{"este", "", "un", "", ""}}
+	if saved, err := fileStore.UpdateDataset("456", "", want.Key, 2, sato); !saved || err != nil {
+		t.Fatalf("Error completing dataset. Trace: %v", err)
+	}
+	if got := fileStore.GetDatasetByOperation("456"); got.Key != "456" || got.Version != 3 || len(got.Satellites) != 3 {
+		t.Errorf("Error getting complete dataset by operation. got: %v, wanted key '456' and version 3.", got)
+	}
+	if got := fileStore.GetDatasetByKey("456"); got.Key != "456" {
+		t.Errorf("Error getting complete dataset by key. got: '%s', wanted '456'.", got.Key)
+	}
+	if got, _ := fileStore.GetDataset("", []string{"", "es", "", "", "secreto"}); got.Key != "" {
+		t.Errorf("Error getting complete dataset by message. got: '%s', wanted empty.", got.Key)
+	}
+	if isNew, err := fileStore.RegisterNonce("kenobi", "n-kenobi", 600); isNew || err != nil {
+		t.Errorf("Error registering replayed nonce. got: %t, %v", isNew, err)
+	}
+	if err := fileStore.ReleaseNonce("kenobi", "n-kenobi"); err != nil {
+		t.Errorf("Error releasing nonce. Trace: %v", err)
+	}
+	if isNew, err := fileStore.RegisterNonce("kenobi", "n-kenobi", 600); !isNew || err != nil {
+		t.Errorf("Error registering released nonce. got: %t, %v", isNew, err)
+	}
+}
+
+func TestFileDatasetStoreExpiration(t *testing.T) {
+	fileStore, err := store.NewFileDatasetStore(filepath.Join(t.TempDir(), "datasets.db"), 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Error opening datasets store file. Trace: %s", err.Error())
+	}
+	defer fileStore.Close()
+
+	kenobi := model.SatelliteInfoRequest{Name: "kenobi", Distance: 500, Message: []string{"este", "", "", "mensaje", ""}}
+	if !fileStore.SaveNewDataset("456", kenobi) {
+		t.Fatalf("Error saving new dataset.")
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	// the update with the same key renews the expiration, the previous one isn't purged
+	wantKey := "456:este   mensaje "
+	if saved, err := fileStore.UpdateDataset("456", "este   mensaje ", wantKey, 1, kenobi); !saved || err != nil {
+		t.Fatalf("Error updating dataset. Trace: %v", err)
+	}
+	time.Sleep(60 * time.Millisecond)
+	if isNew, err := fileStore.RegisterNonce("kenobi", "n-kenobi", 600); !isNew || err != nil {
+		t.Errorf("Error registering new nonce. got: %t, %v", isNew, err)
+	}
+	if got := fileStore.GetDatasetByKey(wantKey); got.Version != 2 {
+		t.Errorf("Error getting renewed dataset. got version: %d, wanted 2.", got.Version)
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	if got := fileStore.GetDatasetByOperation("456"); got.Key != "" {
+		t.Errorf("Error getting expired dataset. got: '%s', wanted empty.", got.Key)
+	}
+	if got, _ := fileStore.GetDatasetByMessage(kenobi.Message); got.Key != "" {
+		t.Errorf("Error getting expired dataset by message. got: '%s', wanted empty.", got.Key)
+	}
+	// the expired key is available again
+	if !fileStore.SaveNewDataset("456", kenobi) {
+		t.Errorf("Error saving new dataset over the expired one.")
+	}
+}
+
+// Tests that the configured datasets store is used, or the error if it can't be
+func TestInitializeDatasetStore(t *testing.T) {
+	defer store.SetDatasetStore(nil)
+
+	t.Setenv("OFQ_STORE", "memory")
+	if err := store.InitializeDatasetStore(); err != nil {
+		t.Fatalf("InitializeDatasetStore() error = %v", err)
+	}
+	if _, isMemory := store.GetDatasetStore().(*store.MemoryDatasetStore); !isMemory {
+		t.Errorf("InitializeDatasetStore() store = %T, want *store.MemoryDatasetStore", store.GetDatasetStore())
+	}
+
+	// the store isn't replaced with another one
+	store.SetDatasetStore(nil)
+	t.Setenv("OFQ_STORE", "unknown")
+	if err := store.InitializeDatasetStore(); err == nil {
+		t.Error("InitializeDatasetStore() with unknown store, want error")
+	}
+	t.Setenv("OFQ_STORE", "file")
+	t.Setenv("OFQ_STORE_FILE", t.TempDir())
+	if err := store.InitializeDatasetStore(); err == nil {
+		t.Error("InitializeDatasetStore() with a directory as store file, want error")
+	}
+	if _, isRedis := store.GetDatasetStore().(store.RedisDatasetStore); !isRedis {
+		t.Errorf("InitializeDatasetStore() store after errors = %T, want the default store.RedisDatasetStore", store.GetDatasetStore())
 	}
 }
@@ -3,6 +3,8 @@ package support
 import (
 	"log"
 	"os"
+	"strconv"
+	"strings"
 )
 
 func WebServerPort() string {
@@ -25,3 +27,155 @@ func getEnv(envkey string, envDefaultValue string) string {
 	}
 	return value
 }
+
+// Defines the default signal propagation speed, the speed of light in vacuum (coordinates units per second).
+const DEFAULT_PROPAGATION_SPEED float64 = 299792458
+
+// Gets the signal propagation speed (coordinates units per second), used to convert arrival times to distances.
+func PropagationSpeed() float64 {
+	return getPositiveFloatEnv("OFQ_PROPAGATION_SPEED", DEFAULT_PROPAGATION_SPEED)
+}
+
+// Defines the default tracking process noise, the spectral density of the emitter acceleration (white noise).
+const DEFAULT_TRACKING_PROCESS_NOISE float64 = 1
+
+// Defines the default tracking measurement noise, the standard deviation of each fix coordinate.
+const DEFAULT_TRACKING_MEASUREMENT_NOISE float64 = 1
+
+// Defines the default tracks time to live (seconds without new fixes).
+const DEFAULT_TRACKING_TTL float64 = 3600
+
+// Gets the tracking process noise (spectral density of the emitter acceleration).
+func TrackingProcessNoise() float64 {
+	return getPositiveFloatEnv("OFQ_TRACKING_PROCESS_NOISE", DEFAULT_TRACKING_PROCESS_NOISE)
+}
+
+// Gets the tracking measurement noise (standard deviation of each fix coordinate).
+func TrackingMeasurementNoise() float64 {
+	return getPositiveFloatEnv("OFQ_TRACKING_MEASUREMENT_NOISE", DEFAULT_TRACKING_MEASUREMENT_NOISE)
+}
+
+// Gets the tracks time to live (seconds), the idle tracks (without new fixes) are deleted after it.
+func TrackingTTL() float64 {
+	return getPositiveFloatEnv("OFQ_TRACKING_TTL", DEFAULT_TRACKING_TTL)
+}
+
+// Gets the name of the location solver used by default.
+func SolverName() string {
+	return getEnv("OFQ_SOLVER", "auto")
+}
+
+// Defines the default significance level of the location residuals chi-square test.
+const DEFAULT_SIGNIFICANCE float64 = 0.001
+
+// Defines the default standard deviation of a distance relative to it, used when the distance noise is unknown.
+const DEFAULT_RELATIVE_STD_DEV float64 = 0.001
+
+// Defines the default max relative RMS of the residuals of a degraded location, in best effort mode.
+const DEFAULT_DEGRADED_RATIO float64 = 0.05
+
+// Gets the significance level of the location residuals chi-square test, the probability of rejecting a right location.
+func Significance() float64 {
+	return getPositiveFloatEnv("OFQ_SIGNIFICANCE", DEFAULT_SIGNIFICANCE)
+}
+
+// Gets the standard deviation of a distance relative to it, used when the distance noise is unknown.
+func RelativeStdDev() float64 {
+	return getPositiveFloatEnv("OFQ_RELATIVE_STD_DEV", DEFAULT_RELATIVE_STD_DEV)
+}
+
+// Gets the max relative RMS of the residuals of a degraded location, in best effort mode.
+func DegradedRatio() float64 {
+	return getPositiveFloatEnv("OFQ_DEGRADED_RATIO", DEFAULT_DEGRADED_RATIO)
+}
+
+// Defines the default min fuzzy ratio of two message words to consider them the same one, exact match.
+const DEFAULT_MESSAGE_MIN_RATIO int = 100
+
+// Gets the message words normalizations, comma separated (case, nfc and punctuation). None by default.
+func MessageNormalization() string {
+	return getEnv("OFQ_MESSAGE_NORMALIZATION", "")
+}
+
+// Gets the min fuzzy ratio (from 0 to 100) of two message words to consider them the same one.
+func MessageMinRatio() int {
+	valueStr := getEnv("OFQ_MESSAGE_MIN_RATIO", strconv.Itoa(DEFAULT_MESSAGE_MIN_RATIO))
+	value, parseErr := strconv.Atoi(valueStr)
+	if parseErr != nil || value < 0 || value > 100 {
+		log.Printf("WARN env variable OFQ_MESSAGE_MIN_RATIO value '%s' is not a number between 0 and 100. Setting default to '%d'", valueStr, DEFAULT_MESSAGE_MIN_RATIO)
+		return DEFAULT_MESSAGE_MIN_RATIO
+	}
+	return value
+}
+
+// Gets the placeholder that renders the message gaps (the words no satellite received). Empty by default.
+func GapPlaceholder() string {
+	return getEnv("OFQ_GAP_PLACEHOLDER", "")
+}
+
+// Gets the path of the phrases file, used to suggest the words of the message gaps. Empty by default (disabled).
+func PhrasesFile() string {
+	return getEnv("OFQ_PHRASES_FILE", "")
+}
+
+// Checks if the satellites reports must be signed, also the ones of the satellites without verification key (rejected).
+// False by default, only the reports of the satellites with verification key are verified.
+func RequireSignatures() bool {
+	return getBoolEnv("OFQ_REQUIRE_SIGNATURES", false)
+}
+
+// Defines the default window (seconds) of the accepted satellites reports timestamps, around the current time.
+const DEFAULT_REPLAY_WINDOW float64 = 300
+
+// Gets the window (seconds) of the accepted satellites reports timestamps, around the current time. The reports
+// nonces are remembered twice this time.
+func ReplayWindow() float64 {
+	return getPositiveFloatEnv("OFQ_REPLAY_WINDOW", DEFAULT_REPLAY_WINDOW)
+}
+
+// Checks if the reports of the satellites without verification key may omit timestamp and nonce, so they can be
+// replayed (legacy clients). False by default, every report must carry both.
+func AllowUnsignedReplays() bool {
+	return getBoolEnv("OFQ_ALLOW_UNSIGNED_REPLAYS", false)
+}
+
+// Gets the datasets store backend, redis (default), memory or file.
+func StoreBackend() string {
+	return strings.ToLower(strings.TrimSpace(getEnv("OFQ_STORE", "redis")))
+}
+
+// Defines the default path of the datasets store file.
+const DEFAULT_STORE_FILE string = "operation-fire-quasar.db"
+
+// Gets the path of the datasets store file, used by the file backend.
+func StoreFile() string {
+	return getEnv("OFQ_STORE_FILE", DEFAULT_STORE_FILE)
+}
+
+// Defines the default time to live (seconds) of the datasets in the store file, one day.
+const DEFAULT_STORE_TTL float64 = 86400
+
+// Gets the time to live (seconds) of the datasets in the store file, since their last update.
+func StoreTTL() float64 {
+	return getPositiveFloatEnv("OFQ_STORE_TTL", DEFAULT_STORE_TTL)
+}
+
+func getBoolEnv(envkey string, envDefaultValue bool) bool {
+	valueStr := getEnv(envkey, strconv.FormatBool(envDefaultValue))
+	value, parseErr := strconv.ParseBool(valueStr)
+	if parseErr != nil {
+		log.Printf("WARN env variable %s value '%s' is not a boolean. Setting default to '%t'", envkey, valueStr, envDefaultValue)
+		return envDefaultValue
+	}
+	return value
+}
+
+func getPositiveFloatEnv(envkey string, envDefaultValue float64) float64 {
+	valueStr := getEnv(envkey, strconv.FormatFloat(envDefaultValue, 'f', -1, 64))
+	value, parseErr := strconv.ParseFloat(valueStr, 64)
+	if parseErr != nil || value <= 0 {
+		log.Printf("WARN env variable %s value '%s' is not a positive number. Setting default to '%f'", envkey, valueStr, envDefaultValue)
+		return envDefaultValue
+	}
+	return value
+}
@@ -0,0 +1,230 @@
+package tracking
+
+import (
+	"errors"
+	"math"
+
+	"github.com/mgironi/operation-fire-quasar/model"
+)
+
+// Defines the initial velocity variance of a new track, big enough to let the first fixes determine the velocity.
+const KALMAN_INITIAL_VELOCITY_VARIANCE float64 = 1e6
+
+// Defines the state size of the constant velocity model [x, y, vx, vy].
+const KALMAN_STATE_SIZE int = 4
+
+// Constant velocity Kalman filter in the plane.
+// The state is [x, y, vx, vy] and the measurements are the fixes positions [x, y], the altitude (Z) isn't filtered.
+type KalmanFilter struct {
+	// state vector [x, y, vx, vy]
+	State []float64
+	// state covariance matrix (4x4)
+	Covariance [][]float64
+	// process noise, spectral density of the acceleration (white noise)
+	ProcessNoise float64
+	// timestamp of the state (seconds)
+	Timestamp float64
+}
+
+// Creates a new Kalman filter initialized with a first fix, with unknown (zero) velocity.
+// input: the fix position, its timestamp, the fix coordinates variance and the process noise.
+// output: the filter.
+func NewKalmanFilter(position model.Point, timestamp float64, measurementVariance float64, processNoise float64) (filter *KalmanFilter) {
+	filter = &KalmanFilter{
+		State:        []float64{position.X, position.Y, 0, 0},
+		Covariance:   newMatrix(KALMAN_STATE_SIZE, KALMAN_STATE_SIZE),
+		ProcessNoise: processNoise,
+		Timestamp:    timestamp,
+	}
+	filter.Covariance[0][0] = measurementVariance
+	filter.Covariance[1][1] = measurementVariance
+	filter.Covariance[2][2] = KALMAN_INITIAL_VELOCITY_VARIANCE
+	filter.Covariance[3][3] = KALMAN_INITIAL_VELOCITY_VARIANCE
+	return filter
+}
+
+// Predicts the filter state to a timestamp (in place).
+//
+// The constant velocity model transition is x' = x + vx*dt, y' = y + vy*dt, with process noise covariance for
+// each axis q * [[dt^3/3, dt^2/2], [dt^2/2, dt]] (position, velocity).
+//
+// input: the timestamp to predict to, can't be previous to the filter timestamp.
+// error: if the timestamp is previous to the filter timestamp.
+func (filter *KalmanFilter) Predict(timestamp float64) (err error) {
+	dt := timestamp - filter.Timestamp
+	if dt < 0 {
+		return errors.New("can't predict to a timestamp previous to the filter state")
+	}
+	filter.State, filter.Covariance = filter.predicted(dt)
+	filter.Timestamp = timestamp
+	return nil
+}
+
+// Gets the predicted state and covariance after dt seconds, without modifying the filter.
+func (filter *KalmanFilter) predicted(dt float64) (state []float64, covariance [][]float64) {
+	transition := identityMatrix(KALMAN_STATE_SIZE)
+	transition[0][2] = dt
+	transition[1][3] = dt
+
+	processCovariance := newMatrix(KALMAN_STATE_SIZE, KALMAN_STATE_SIZE)
+	for axis := 0; axis < 2; axis++ {
+		processCovariance[axis][axis] = filter.ProcessNoise * math.Pow(dt, 3) / 3
+		processCovariance[axis][axis+2] = filter.ProcessNoise * math.Pow(dt, 2) / 2
+		processCovariance[axis+2][axis] = filter.ProcessNoise * math.Pow(dt, 2) / 2
+		processCovariance[axis+2][axis+2] = filter.ProcessNoise * dt
+	}
+
+	state = multiplyMatrixVector(transition, filter.State)
+	covariance = addMatrices(multiplyMatrices(multiplyMatrices(transition, filter.Covariance), transposeMatrix(transition)), processCovariance)
+	return state, covariance
+}
+
+// Updates the filter state with a fix (in place). The filter should be predicted to the fix timestamp before.
+//
+// With the measurement matrix H selecting [x, y], the innovation covariance is S = H*P*Ht + R, the gain is
+// K = P*Ht*S^-1, the state is updated with x = x + K*(z - H*x) and the covariance with P = (I - K*H)*P.
+//
+// input: the fix position and the fix coordinates variance.
+// error: if the innovation covariance is singular.
+func (filter *KalmanFilter) Update(position model.Point, measurementVariance float64) (err error) {
+	// innovation covariance S = H*P*Ht + R, the position block of P plus R
+	innovationCovariance := [][]float64{
+		{filter.Covariance[0][0] + measurementVariance, filter.Covariance[0][1]},
+		{filter.Covariance[1][0], filter.Covariance[1][1] + measurementVariance},
+	}
+	determinant := innovationCovariance[0][0]*innovationCovariance[1][1] - innovationCovariance[0][1]*innovationCovariance[1][0]
+	if determinant == 0 {
+		return errors.New("can't update track, the innovation covariance is singular")
+	}
+	inverseInnovation := [][]float64{
+		{innovationCovariance[1][1] / determinant, -innovationCovariance[0][1] / determinant},
+		{-innovationCovariance[1][0] / determinant, innovationCovariance[0][0] / determinant},
+	}
+
+	// gain K = P*Ht*S^-1, where P*Ht are the first two columns of P
+	gain := newMatrix(KALMAN_STATE_SIZE, 2)
+	for row := 0; row < KALMAN_STATE_SIZE; row++ {
+		for col := 0; col < 2; col++ {
+			gain[row][col] = filter.Covariance[row][0]*inverseInnovation[0][col] + filter.Covariance[row][1]*inverseInnovation[1][col]
+		}
+	}
+
+	// updates state with the innovation
+	innovation := []float64{position.X - filter.State[0], position.Y - filter.State[1]}
+	for row := 0; row < KALMAN_STATE_SIZE; row++ {
+		filter.State[row] += gain[row][0]*innovation[0] + gain[row][1]*innovation[1]
+	}
+
+	// updates covariance P = (I - K*H)*P
+	gainByMeasurement := identityMatrix(KALMAN_STATE_SIZE)
+	for row := 0; row < KALMAN_STATE_SIZE; row++ {
+		gainByMeasurement[row][0] -= gain[row][0]
+		gainByMeasurement[row][1] -= gain[row][1]
+	}
+	filter.Covariance = multiplyMatrices(gainByMeasurement, filter.Covariance)
+	return nil
+}
+
+// Gets the filter position.
+func (filter *KalmanFilter) Position() model.Point {
+	return model.Point{X: filter.State[0], Y: filter.State[1]}
+}
+
+// Gets the filter velocity (coordinates units per second).
+func (filter *KalmanFilter) Velocity() model.Point {
+	return model.Point{X: filter.State[2], Y: filter.State[3]}
+}
+
+// Gets the position covariance matrix (2x2) of the filter state.
+func (filter *KalmanFilter) PositionCovariance() [][]float64 {
+	return [][]float64{
+		{filter.Covariance[0][0], filter.Covariance[0][1]},
+		{filter.Covariance[1][0], filter.Covariance[1][1]},
+	}
+}
+
+// Gets the predicted position at a timestamp, without modifying the filter.
+// input: the timestamp, can't be previous to the filter timestamp.
+// output: the predicted position and its covariance matrix (2x2).
+// error: if the timestamp is previous to the filter timestamp.
+func (filter *KalmanFilter) PredictedPosition(timestamp float64) (position model.Point, covariance [][]float64, err error) {
+	dt := timestamp - filter.Timestamp
+	if dt < 0 {
+		return position, covariance, errors.New("can't predict to a timestamp previous to the filter state")
+	}
+	state, stateCovariance := filter.predicted(dt)
+	covariance = [][]float64{
+		{stateCovariance[0][0], stateCovariance[0][1]},
+		{stateCovariance[1][0], stateCovariance[1][1]},
+	}
+	return model.Point{X: state[0], Y: state[1]}, covariance, nil
+}
+
+// Gets a deep copy of the filter.
+func (filter *KalmanFilter) copy() *KalmanFilter {
+	filterCopy := *filter
+	filterCopy.State = append([]float64{}, filter.State...)
+	filterCopy.Covariance = newMatrix(len(filter.Covariance), len(filter.Covariance))
+	for i := range filter.Covariance {
+		copy(filterCopy.Covariance[i], filter.Covariance[i])
+	}
+	return &filterCopy
+}
+
+func newMatrix(rows, cols int) (matrix [][]float64) {
+	matrix = make([][]float64, rows)
+	for i := range matrix {
+		matrix[i] = make([]float64, cols)
+	}
+	return matrix
+}
+
+func identityMatrix(size int) (matrix [][]float64) {
+	matrix = newMatrix(size, size)
+	for i := 0; i < size; i++ {
+		matrix[i][i] = 1
+	}
+	return matrix
+}
+
+func multiplyMatrices(a, b [][]float64) (result [][]float64) {
+	result = newMatrix(len(a), len(b[0]))
+	for i := range a {
+		for j := range b[0] {
+			for k := range b {
+				result[i][j] += a[i][k] * b[k][j]
+			}
+		}
+	}
+	return result
+}
+
+func multiplyMatrixVector(a [][]float64, v []float64) (result []float64) {
+	result = make([]float64, len(a))
+	for i := range a {
+		for k := range v {
+			result[i] += a[i][k] * v[k]
+		}
+	}
+	return result
+}
+
+func transposeMatrix(a [][]float64) (result [][]float64) {
+	result = newMatrix(len(a[0]), len(a))
+	for i := range a {
+		for j := range a[i] {
+			result[j][i] = a[i][j]
+		}
+	}
+	return result
+}
+
+func addMatrices(a, b [][]float64) (result [][]float64) {
+	result = newMatrix(len(a), len(a[0]))
+	for i := range a {
+		for j := range a[i] {
+			result[i][j] = a[i][j] + b[i][j]
+		}
+	}
+	return result
+}
@@ -0,0 +1,223 @@
+package tracking
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mgironi/operation-fire-quasar/model"
+	"github.com/mgironi/operation-fire-quasar/support"
+)
+
+// Tracked emitter, the fixes are filtered with a constant velocity Kalman filter in the plane (see KalmanFilter).
+type track struct {
+	filter  *KalmanFilter
+	updates int
+	// the time of the last fix, to delete the idle track
+	lastFix time.Time
+}
+
+// Defines the emitter track state.
+type TrackState struct {
+	// the track identifier
+	ID string
+	// timestamp of the last fix (seconds)
+	Timestamp float64
+	// filtered position at the last fix timestamp
+	Position model.Point
+	// filtered velocity (coordinates units per second)
+	Velocity model.Point
+	// position covariance matrix (2x2), the tracks are in the plane
+	PositionCovariance [][]float64
+	// amount of fixes applied to the track
+	Updates int
+}
+
+// in memory tracks registry, by track identifier
+var tracks map[string]*track = map[string]*track{}
+
+// guards the tracks registry
+var tracksMutex sync.Mutex
+
+// the time the idle tracks were deleted for last time, see evictIdleTracks
+var lastEviction time.Time
+
+// Gets the current time, used to expire the idle tracks.
+var GetCurrentTime = func() time.Time {
+	return time.Now()
+}
+
+// Defines a track fix, the position of the emitter at a timestamp (seconds).
+type Fix struct {
+	Timestamp float64
+	Position  model.Point
+}
+
+// Adds a fix to a track, creating the track if it doesn't exists or it was idle (without fixes) longer than the
+// tracks time to live (OFQ_TRACKING_TTL).
+// The fix is filtered with the measurement noise (OFQ_TRACKING_MEASUREMENT_NOISE) and the new tracks are created with
+// the process noise (OFQ_TRACKING_PROCESS_NOISE). The tracks are in the plane, the fix altitude (Z) is ignored.
+// input: the track identifier, the fix timestamp (seconds) and the fix position.
+// output: the track state after the fix.
+// error: if the track identifier is empty, the fix isn't finite or its timestamp is previous to the last track fix.
+func AddFix(trackID string, timestamp float64, position model.Point) (state TrackState, err error) {
+	return AddFixes(trackID, []Fix{{Timestamp: timestamp, Position: position}})
+}
+
+// Adds a series of fixes to a track, as AddFix. The fixes are applied all or none: if one of them can't be applied
+// the track is kept unmodified (or isn't created).
+// input: the track identifier and the fixes, ordered by timestamp.
+// output: the track state after the last fix.
+// error: if the track identifier is empty, there aren't fixes, a fix isn't finite or its timestamp is previous to the
+// previous fix or to the last track fix.
+func AddFixes(trackID string, fixes []Fix) (state TrackState, err error) {
+	if strings.TrimSpace(trackID) == "" {
+		return state, errors.New("track identifier can't be empty")
+	}
+	if len(fixes) == 0 {
+		return state, fmt.Errorf("there aren't fixes to add to track '%s'", trackID)
+	}
+	for _, fix := range fixes {
+		if !isFinite(fix.Timestamp) || !isFinite(fix.Position.X) || !isFinite(fix.Position.Y) {
+			return state, fmt.Errorf("can't add fix %s at timestamp %f to track '%s', values must be finite", fix.Position, fix.Timestamp, trackID)
+		}
+	}
+	measurementVariance := math.Pow(support.TrackingMeasurementNoise(), 2)
+
+	tracksMutex.Lock()
+	defer tracksMutex.Unlock()
+
+	now := GetCurrentTime()
+	evictIdleTracks(now)
+	trk, found := getLiveTrack(trackID, now)
+
+	// works over a copy, to keep the track unmodified if any fix can't be applied
+	var filter *KalmanFilter
+	updates := 0
+	if found {
+		filter = trk.filter.copy()
+		updates = trk.updates
+	} else {
+		planePosition := model.Point{X: fixes[0].Position.X, Y: fixes[0].Position.Y}
+		filter = NewKalmanFilter(planePosition, fixes[0].Timestamp, measurementVariance, support.TrackingProcessNoise())
+		updates = 1
+		fixes = fixes[1:]
+	}
+	for _, fix := range fixes {
+		if fix.Timestamp < filter.Timestamp {
+			return state, fmt.Errorf("fix timestamp %f is previous to the last fix of track '%s' (%f)", fix.Timestamp, trackID, filter.Timestamp)
+		}
+		err = filter.Predict(fix.Timestamp)
+		if err == nil {
+			err = filter.Update(fix.Position, measurementVariance)
+		}
+		if err != nil {
+			return state, err
+		}
+		updates++
+	}
+
+	if !found {
+		trk = &track{}
+		tracks[trackID] = trk
+	}
+	trk.filter = filter
+	trk.updates = updates
+	trk.lastFix = now
+	return trk.state(trackID), nil
+}
+
+// Gets the state of a track.
+// input: the track identifier.
+// output: the track state and true if the track exists and isn't idle.
+func GetTrackState(trackID string) (state TrackState, found bool) {
+	tracksMutex.Lock()
+	defer tracksMutex.Unlock()
+
+	trk, found := getLiveTrack(trackID, GetCurrentTime())
+	if !found {
+		return state, false
+	}
+	return trk.state(trackID), true
+}
+
+// Predicts the position of a track at a timestamp, without modifying the track.
+// input: the track identifier and the timestamp (seconds), can't be previous to the last track fix.
+// output: the predicted position and its covariance matrix (2x2).
+// error: if the track doesn't exists (or is idle) or the timestamp is previous to the last track fix.
+func PredictTrack(trackID string, timestamp float64) (position model.Point, covariance [][]float64, err error) {
+	tracksMutex.Lock()
+	defer tracksMutex.Unlock()
+
+	trk, found := getLiveTrack(trackID, GetCurrentTime())
+	if !found {
+		return position, covariance, fmt.Errorf("track '%s' not found", trackID)
+	}
+	return trk.filter.PredictedPosition(timestamp)
+}
+
+// Deletes a track.
+// input: the track identifier.
+// output: true if the track existed and wasn't idle.
+func DeleteTrack(trackID string) (found bool) {
+	tracksMutex.Lock()
+	defer tracksMutex.Unlock()
+
+	_, found = getLiveTrack(trackID, GetCurrentTime())
+	delete(tracks, trackID)
+	return found
+}
+
+// Gets a track of the registry, deleting it if it's idle. Must be called with the registry guarded.
+// output: the track and true if it exists and isn't idle.
+func getLiveTrack(trackID string, now time.Time) (trk *track, found bool) {
+	trk, found = tracks[trackID]
+	if found && isIdle(trk, now, trackingTTL()) {
+		delete(tracks, trackID)
+		return nil, false
+	}
+	return trk, found
+}
+
+// Deletes the idle tracks of the registry, at most once per tracks time to live, so an idle track is kept up to
+// two periods while new fixes are added. Must be called with the registry guarded.
+func evictIdleTracks(now time.Time) {
+	ttl := trackingTTL()
+	if now.Sub(lastEviction) < ttl {
+		return
+	}
+	lastEviction = now
+	for trackID, trk := range tracks {
+		if isIdle(trk, now, ttl) {
+			delete(tracks, trackID)
+		}
+	}
+}
+
+// Indicates if a track was without fixes longer than the tracks time to live.
+func isIdle(trk *track, now time.Time, ttl time.Duration) bool {
+	return now.Sub(trk.lastFix) >= ttl
+}
+
+// Gets the tracks time to live (OFQ_TRACKING_TTL).
+func trackingTTL() time.Duration {
+	return time.Duration(support.TrackingTTL() * float64(time.Second))
+}
+
+func (trk *track) state(trackID string) TrackState {
+	return TrackState{
+		ID:                 trackID,
+		Timestamp:          trk.filter.Timestamp,
+		Position:           trk.filter.Position(),
+		Velocity:           trk.filter.Velocity(),
+		PositionCovariance: trk.filter.PositionCovariance(),
+		Updates:            trk.updates,
+	}
+}
+
+func isFinite(value float64) bool {
+	return !math.IsNaN(value) && !math.IsInf(value, 0)
+}
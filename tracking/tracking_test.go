@@ -0,0 +1,173 @@
+package tracking_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/mgironi/operation-fire-quasar/model"
+	"github.com/mgironi/operation-fire-quasar/tracking"
+)
+
+// Tests AddFix and PredictTrack with the fixes of an emitter moving at constant velocity
+func TestAddFixConstantVelocity(t *testing.T) {
+	trackID := "constantVelocity"
+	defer tracking.DeleteTrack(trackID)
+
+	// emitter starting at (-100, 50) with velocity (2, -1)
+	var state tracking.TrackState
+	for step := 0; step <= 20; step++ {
+		timestamp := float64(step)
+		var err error
+		state, err = tracking.AddFix(trackID, timestamp, model.Point{X: -100 + 2*timestamp, Y: 50 - timestamp})
+		if err != nil {
+			t.Fatalf("AddFix() error = %v", err)
+		}
+	}
+
+	if state.Updates != 21 {
+		t.Errorf("AddFix() updates = %d, want 21", state.Updates)
+	}
+	if math.Abs(state.Position.X-(-60)) > 0.1 || math.Abs(state.Position.Y-30) > 0.1 {
+		t.Errorf("AddFix() position = %s, want (-60, 30)", state.Position)
+	}
+	if math.Abs(state.Velocity.X-2) > 0.05 || math.Abs(state.Velocity.Y-(-1)) > 0.05 {
+		t.Errorf("AddFix() velocity = %s, want (2, -1)", state.Velocity)
+	}
+
+	predicted, covariance, err := tracking.PredictTrack(trackID, 30)
+	if err != nil {
+		t.Fatalf("PredictTrack() error = %v", err)
+	}
+	if math.Abs(predicted.X-(-40)) > 1 || math.Abs(predicted.Y-20) > 1 {
+		t.Errorf("PredictTrack() position = %s, want (-40, 20)", predicted)
+	}
+	if covariance[0][0] <= state.PositionCovariance[0][0] || covariance[1][1] <= state.PositionCovariance[1][1] {
+		t.Errorf("PredictTrack() covariance = %v, want greater than the track one %v", covariance, state.PositionCovariance)
+	}
+
+	// the prediction doesn't modify the track
+	if got, _ := tracking.GetTrackState(trackID); got.Timestamp != 20 || got.Updates != 21 {
+		t.Errorf("GetTrackState() after predict = %+v, want timestamp 20 and 21 updates", got)
+	}
+}
+
+// Tests AddFix, PredictTrack and DeleteTrack errors
+func TestTrackErrors(t *testing.T) {
+	trackID := "errors"
+	defer tracking.DeleteTrack(trackID)
+
+	if _, err := tracking.AddFix("", 0, model.Point{}); err == nil {
+		t.Error("AddFix() with empty track identifier, want error")
+	}
+	if _, err := tracking.AddFix(trackID, 0, model.Point{X: math.NaN()}); err == nil {
+		t.Error("AddFix() with NaN position, want error")
+	}
+	if _, err := tracking.AddFix(trackID, 10, model.Point{X: 1, Y: 1}); err != nil {
+		t.Fatalf("AddFix() error = %v", err)
+	}
+
+	// out of order fix keeps the track unmodified
+	if _, err := tracking.AddFix(trackID, 5, model.Point{X: 2, Y: 2}); err == nil {
+		t.Error("AddFix() with previous timestamp, want error")
+	}
+	if state, _ := tracking.GetTrackState(trackID); state.Updates != 1 || state.Timestamp != 10 {
+		t.Errorf("GetTrackState() after out of order fix = %+v, want timestamp 10 and 1 update", state)
+	}
+
+	if _, _, err := tracking.PredictTrack(trackID, 5); err == nil {
+		t.Error("PredictTrack() with previous timestamp, want error")
+	}
+	if _, _, err := tracking.PredictTrack("unknown", 20); err == nil {
+		t.Error("PredictTrack() of unknown track, want error")
+	}
+	if _, found := tracking.GetTrackState("unknown"); found {
+		t.Error("GetTrackState() of unknown track found")
+	}
+
+	if !tracking.DeleteTrack(trackID) {
+		t.Error("DeleteTrack() of existing track not found")
+	}
+	if tracking.DeleteTrack(trackID) {
+		t.Error("DeleteTrack() of deleted track found")
+	}
+}
+
+// Tests that AddFixes applies all the fixes or none
+func TestAddFixes(t *testing.T) {
+	trackID := "batch"
+	defer tracking.DeleteTrack(trackID)
+
+	// unordered batch doesn't create the track
+	if _, err := tracking.AddFixes(trackID, []tracking.Fix{{Timestamp: 10}, {Timestamp: 5}}); err == nil {
+		t.Error("AddFixes() with unordered fixes, want error")
+	}
+	if _, found := tracking.GetTrackState(trackID); found {
+		t.Error("GetTrackState() after unordered fixes found")
+	}
+	if _, err := tracking.AddFixes(trackID, nil); err == nil {
+		t.Error("AddFixes() without fixes, want error")
+	}
+
+	state, err := tracking.AddFixes(trackID, []tracking.Fix{{Timestamp: 0, Position: model.Point{X: 0, Y: 0}}, {Timestamp: 10, Position: model.Point{X: 10, Y: 0}}})
+	if err != nil {
+		t.Fatalf("AddFixes() error = %v", err)
+	}
+	if state.Updates != 2 || state.Timestamp != 10 {
+		t.Errorf("AddFixes() = %+v, want timestamp 10 and 2 updates", state)
+	}
+
+	// a batch with a fix previous to the last track fix, or unordered, keeps the track unmodified
+	for _, fixes := range [][]tracking.Fix{
+		{{Timestamp: 20, Position: model.Point{X: 20}}, {Timestamp: 5, Position: model.Point{X: 5}}},
+		{{Timestamp: 5, Position: model.Point{X: 5}}, {Timestamp: 20, Position: model.Point{X: 20}}},
+		{{Timestamp: 20, Position: model.Point{X: 20}}, {Timestamp: 30, Position: model.Point{X: math.Inf(1)}}},
+	} {
+		if _, err := tracking.AddFixes(trackID, fixes); err == nil {
+			t.Errorf("AddFixes(%v) want error", fixes)
+		}
+		if got, _ := tracking.GetTrackState(trackID); got.Updates != 2 || got.Timestamp != 10 {
+			t.Errorf("GetTrackState() after AddFixes(%v) = %+v, want timestamp 10 and 2 updates", fixes, got)
+		}
+	}
+}
+
+// Tests that the tracks without fixes longer than the tracks time to live are deleted
+func TestIdleTracks(t *testing.T) {
+	t.Setenv("OFQ_TRACKING_TTL", "60")
+	now := time.Now()
+	tracking.GetCurrentTime = func() time.Time { return now }
+	defer func() {
+		tracking.GetCurrentTime = time.Now
+		tracking.DeleteTrack("idle")
+		tracking.DeleteTrack("active")
+	}()
+
+	if _, err := tracking.AddFix("idle", 0, model.Point{X: 1, Y: 1}); err != nil {
+		t.Fatalf("AddFix() error = %v", err)
+	}
+	if _, err := tracking.AddFix("active", 0, model.Point{X: 1, Y: 1}); err != nil {
+		t.Fatalf("AddFix() error = %v", err)
+	}
+
+	// the new fixes keep the track alive
+	now = now.Add(40 * time.Second)
+	if _, err := tracking.AddFix("active", 40, model.Point{X: 2, Y: 2}); err != nil {
+		t.Fatalf("AddFix() error = %v", err)
+	}
+	now = now.Add(40 * time.Second)
+	if state, found := tracking.GetTrackState("active"); !found || state.Updates != 2 {
+		t.Errorf("GetTrackState() of active track = %+v, %t, want 2 updates", state, found)
+	}
+	if _, found := tracking.GetTrackState("idle"); found {
+		t.Error("GetTrackState() of idle track found")
+	}
+	if _, _, err := tracking.PredictTrack("idle", 100); err == nil {
+		t.Error("PredictTrack() of idle track, want error")
+	}
+
+	// a fix to an idle track starts a new one
+	if state, err := tracking.AddFix("idle", 80, model.Point{X: 3, Y: 3}); err != nil || state.Updates != 1 {
+		t.Errorf("AddFix() to idle track = %+v, %v, want a new track", state, err)
+	}
+}
@@ -4,13 +4,18 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mgironi/operation-fire-quasar/location"
 	"github.com/mgironi/operation-fire-quasar/message"
 	"github.com/mgironi/operation-fire-quasar/model"
+	"github.com/mgironi/operation-fire-quasar/security"
 	"github.com/mgironi/operation-fire-quasar/store"
+	"github.com/mgironi/operation-fire-quasar/support"
 
 	"net/http"
 )
@@ -33,10 +38,28 @@ func PingHandler(c *gin.Context) {
 // @Summary Obtiene la ubicacion de la nave y el mensaje que emite.
 // @Description Basado en las distancias y mensajes que se reciben de cada satelite, se obtienen la posicion y el mensaje emitido.
 // @Param Body body model.TopSecretRequest true "Las distancias y mensajes recibidos por los satelites"
+// @Param accuracy query bool false "Incluye la estimacion de precision de la ubicacion (residuos, elipse de error y GDOP)"
+// @Param robust query bool false "Calcula la ubicacion en modo robusto, excluyendo los satelites con distancias inconsistentes (equivale a solver=robust)"
+// @Param solver query string false "Algoritmo de calculo de la ubicacion: auto (por defecto, configurable con OFQ_SOLVER), trilateration, leastsquares o robust"
+// @Param detail query bool false "Incluye el detalle de cada palabra del mensaje: satelites que la recibieron, coincidencias, confianza y posiciones sin recibir"
+// @Param normalize query string false "Normalizaciones al comparar las palabras de los mensajes, separadas por coma: case (mayusculas), nfc (Unicode) y punctuation (signos de puntuacion). Configurable con OFQ_MESSAGE_NORMALIZATION"
+// @Param minRatio query int false "Similitud minima (0 a 100, por distancia de edicion) para considerar iguales dos palabras, por defecto 100 (exactas). Configurable con OFQ_MESSAGE_MIN_RATIO"
+// @Param gapPlaceholder query string false "Texto con el que se muestran en el mensaje las palabras que ningun satelite recibio, por ejemplo [?]. Por defecto vacio, configurable con OFQ_GAP_PLACEHOLDER"
+// @Param suggest query bool false "Sugiere palabras para los huecos del mensaje segun el diccionario de frases configurado con OFQ_PHRASES_FILE. Las sugerencias no se agregan al mensaje"
+// @Param vote query bool false "Resuelve las palabras en conflicto entre satelites por mayoria de votos (ponderados por la confiabilidad de cada satelite), informando las posiciones en disputa"
+// @Param bestEffort query bool false "Devuelve siempre la ubicacion que mejor ajusta las distancias, con su calificacion (exact, good, degraded o rejected) en lugar de 404"
+// @Param significance query number false "Nivel de significancia del test chi-cuadrado de los residuos (por defecto 0.001, configurable con OFQ_SIGNIFICANCE)"
+// @Param relativeStdDev query number false "Desvio estandar de las distancias sin ruido conocido, relativo a la distancia (por defecto 0.001, configurable con OFQ_RELATIVE_STD_DEV)"
+// @Param degradedRatio query number false "Error cuadratico medio relativo maximo de una ubicacion degradada en modo best effort (por defecto 0.05, configurable con OFQ_DEGRADED_RATIO)"
+// @Param frame query string false "Sistema de referencia de la ubicacion: cartesian (por defecto), enu o geodetic (latitud, longitud y altitud WGS84)"
+// @Param origin query string false "Origen del sistema enu como '<latitud>,<longitud>[,<altitud>]', por defecto el origen de referencia (OFQ_ORIGIN)"
 // @Accept json
 // @Produce json
 // @Failure 404 {object} model.ErrorResponse
 // @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
 // @Success 200 {object} model.TopSecretRequest
 // @Router /topsecret/ [POST]
 func TopSecretHandler(c *gin.Context) {
@@ -50,27 +73,94 @@ func TopSecretHandler(c *gin.Context) {
 		return
 	}
 
+	// verifies the satellites reports signatures and timestamps, then rejects the replayed ones
+	for _, satelliteData := range requestData.Satellites {
+		if verifyErr := VerifySatelliteReport(satelliteData); verifyErr != nil {
+			log.Printf("TopSecretHandler error with report verification. Trace: %s", verifyErr.Error())
+			c.IndentedJSON(reportRejectionStatus(verifyErr), model.ErrorResponse{Message: verifyErr.Error()})
+			return
+		}
+	}
+	if nonceErr := RegisterSatellitesReportsNonces(requestData.Satellites); nonceErr != nil {
+		log.Printf("TopSecretHandler error with report nonce. Trace: %s", nonceErr.Error())
+		c.IndentedJSON(reportRejectionStatus(nonceErr), model.ErrorResponse{Message: nonceErr.Error()})
+		return
+	}
+
 	// performs calculations, checks and response data
 	DoCalculationsAndResponse("TopSecretHandler", requestData.Satellites, c)
+
+	// the nonces of a failed request aren't used up, so its reports can be sent again
+	if c.Writer.Status() != http.StatusOK {
+		ReleaseSatellitesReportsNonces(requestData.Satellites)
+	}
 }
 
 func DoCalculationsAndResponse(handlerName string, satellitesData []model.SatelliteInfoRequest, c *gin.Context) {
 	// treat request data to lists calculation form
 	distances, messages, treatErr := TreatSatellitesData(satellitesData)
 	if treatErr != nil {
-		c.IndentedJSON(http.StatusNotFound, model.ErrorResponse{Message: treatErr.Error()})
+		c.IndentedJSON(treatRejectionStatus(treatErr), model.ErrorResponse{Message: treatErr.Error()})
+		return
+	}
+
+	// gets the distances standard deviations
+	stdDevs, qualityErr := TreatSatellitesQuality(satellitesData)
+	if qualityErr != nil {
+		c.IndentedJSON(http.StatusBadRequest, model.ErrorResponse{Message: qualityErr.Error()})
+		return
+	}
+
+	// gets the satellites reliabilities
+	reliabilities, reliabilityErr := TreatSatellitesReliability(satellitesData)
+	if reliabilityErr != nil {
+		c.IndentedJSON(http.StatusBadRequest, model.ErrorResponse{Message: reliabilityErr.Error()})
+		return
+	}
+
+	// gets the message words comparison options
+	matchOptions, optionsErr := GetMatchOptionsParams(c)
+	if optionsErr != nil {
+		c.IndentedJSON(http.StatusBadRequest, model.ErrorResponse{Message: optionsErr.Error()})
+		return
+	}
+
+	// gets the solver asked for
+	solverName, solverErr := GetSolverParam(c)
+	if solverErr == nil {
+		_, solverErr = location.GetSolver(solverName)
+	}
+	if solverErr != nil {
+		c.IndentedJSON(http.StatusBadRequest, model.ErrorResponse{Message: solverErr.Error()})
+		return
+	}
+
+	// gets the location acceptance thresholds
+	thresholds, thresholdsErr := GetThresholdsParams(c)
+	if thresholdsErr != nil {
+		c.IndentedJSON(http.StatusBadRequest, model.ErrorResponse{Message: thresholdsErr.Error()})
 		return
 	}
 
 	// calculates location
-	x, y, locErr := location.CalculateLocation(distances)
+	var position model.CoordinatesResponse
+	var rejected []string
+	var accuracyRsp *model.AccuracyResponse
+	var qualityRsp *model.QualityResponse
+	var solverUsed string
+	var locErr error
+	if c.Query("bestEffort") == "true" {
+		position, rejected, accuracyRsp, qualityRsp, solverUsed, locErr = CalculateBestEffortLocation(distances, stdDevs, solverName, thresholds, c.Query("accuracy") == "true")
+	} else {
+		position, rejected, accuracyRsp, solverUsed, locErr = CalculateLocation(distances, stdDevs, solverName, thresholds, c.Query("accuracy") == "true")
+	}
 	if locErr != nil {
 		log.Printf("%s error with calculate location. Trace: %s", handlerName, locErr.Error())
 		c.IndentedJSON(http.StatusNotFound, model.ErrorResponse{Message: "Can't calculate location. Please check distances."})
 		return
 	}
 
-	message, msgsErr := message.ConsolidateMessage(messages)
+	completeMessage, contested, words, msgsErr := ConsolidateMessage(c, messages, reliabilities, satellitesNames(), matchOptions)
 	if msgsErr != nil {
 		log.Printf("%s error with consolidate message. Trace: %s", handlerName, msgsErr.Error())
 		c.IndentedJSON(http.StatusNotFound, model.ErrorResponse{Message: msgsErr.Error()})
@@ -78,12 +168,457 @@ func DoCalculationsAndResponse(handlerName string, satellitesData []model.Satell
 	}
 
 	rspData := model.TopSecretResponse{
-		Position: model.CoordinatesResponse{X: x, Y: y},
-		Message:  message,
+		Position:     position,
+		Message:      completeMessage.Text(GetGapPlaceholderParam(c)),
+		Gaps:         completeMessage.Gaps,
+		Completeness: completeMessage.Completeness,
+		Contested:    contested,
+		Words:        words,
+		Suggestions:  GetSuggestionsParam(c, completeMessage, matchOptions),
+		Rejected:     rejected,
+		Accuracy:     accuracyRsp,
+		Solver:       solverUsed,
+		Quality:      qualityRsp,
+	}
+
+	// converts location to the frame asked for
+	frameErr := ApplyFrame(&rspData, c.Query("frame"), c.Query("origin"))
+	if frameErr != nil {
+		log.Printf("%s error with frame conversion. Trace: %s", handlerName, frameErr.Error())
+		c.IndentedJSON(http.StatusBadRequest, model.ErrorResponse{Message: frameErr.Error()})
+		return
 	}
 	c.IndentedJSON(http.StatusOK, rspData)
 }
 
+// Calculates the ambiguous location with the data of two satellites and responses with the candidates.
+// The response position is the first candidate.
+func DoAmbiguousCalculationsAndResponse(handlerName string, satellitesData []model.SatelliteInfoRequest, c *gin.Context) {
+	satellitesIndexes := make([]int, len(satellitesData))
+	distances := make([]float32, len(satellitesData))
+	messages := make([][]string, len(satellitesData))
+	reliabilities := make([]float64, len(satellitesData))
+	names := make([]string, len(satellitesData))
+	for i, rqSatelliteInfo := range satellitesData {
+		satellitesIndexes[i] = store.GetSatelliteInfoIndex(rqSatelliteInfo.Name)
+		distances[i] = rqSatelliteInfo.Distance
+		messages[i] = rqSatelliteInfo.Message
+		reliabilities[i] = float64(rqSatelliteInfo.Reliability)
+		names[i] = rqSatelliteInfo.Name
+	}
+
+	// gets the message words comparison options
+	matchOptions, optionsErr := GetMatchOptionsParams(c)
+	if optionsErr != nil {
+		c.IndentedJSON(http.StatusBadRequest, model.ErrorResponse{Message: optionsErr.Error()})
+		return
+	}
+
+	ambiguousLocation, locErr := location.CalculateAmbiguousLocationFor(satellitesIndexes, distances)
+	if locErr != nil {
+		log.Printf("%s error with calculate ambiguous location. Trace: %s", handlerName, locErr.Error())
+		c.IndentedJSON(http.StatusNotFound, model.ErrorResponse{Message: "Can't calculate location. Please check distances."})
+		return
+	}
+
+	completeMessage, contested, words, msgsErr := ConsolidateMessage(c, messages, reliabilities, names, matchOptions)
+	if msgsErr != nil {
+		log.Printf("%s error with consolidate message. Trace: %s", handlerName, msgsErr.Error())
+		c.IndentedJSON(http.StatusNotFound, model.ErrorResponse{Message: msgsErr.Error()})
+		return
+	}
+
+	candidates := make([]model.CoordinatesResponse, len(ambiguousLocation.Candidates))
+	for i, candidate := range ambiguousLocation.Candidates {
+		candidates[i] = model.CoordinatesResponse{X: float32(candidate.X), Y: float32(candidate.Y)}
+	}
+	rspData := model.TopSecretResponse{
+		Position:        candidates[0],
+		Message:         completeMessage.Text(GetGapPlaceholderParam(c)),
+		Gaps:            completeMessage.Gaps,
+		Completeness:    completeMessage.Completeness,
+		Contested:       contested,
+		Words:           words,
+		Suggestions:     GetSuggestionsParam(c, completeMessage, matchOptions),
+		Ambiguous:       true,
+		Candidates:      candidates,
+		ClosestApproach: ambiguousLocation.ClosestApproach,
+	}
+
+	// converts location to the frame asked for
+	frameErr := ApplyFrame(&rspData, c.Query("frame"), c.Query("origin"))
+	if frameErr != nil {
+		log.Printf("%s error with frame conversion. Trace: %s", handlerName, frameErr.Error())
+		c.IndentedJSON(http.StatusBadRequest, model.ErrorResponse{Message: frameErr.Error()})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, rspData)
+}
+
+// Gets the solver name asked for by the 'solver' query param. The 'robust=true' query param is an alias of the robust solver.
+// output: the solver name, empty to use the configured one.
+// error: if robust mode is asked for together with another solver.
+func GetSolverParam(c *gin.Context) (solverName string, err error) {
+	solverName = c.Query("solver")
+	if c.Query("robust") == "true" {
+		if solverName != "" && !strings.EqualFold(solverName, location.ROBUST_SOLVER) {
+			return solverName, fmt.Errorf("robust mode can't be used with solver '%s'", solverName)
+		}
+		solverName = location.ROBUST_SOLVER
+	}
+	return solverName, nil
+}
+
+// Gets the location acceptance thresholds, the configured ones overridden by the 'significance', 'relativeStdDev'
+// and 'degradedRatio' query params. See location.DefaultThresholds.
+// error: if some param isn't a number or the thresholds are invalid.
+func GetThresholdsParams(c *gin.Context) (thresholds location.Thresholds, err error) {
+	thresholds = location.DefaultThresholds()
+	params := []struct {
+		name  string
+		value *float64
+	}{
+		{"significance", &thresholds.Significance},
+		{"relativeStdDev", &thresholds.RelativeStdDev},
+		{"degradedRatio", &thresholds.DegradedRatio},
+	}
+	for _, param := range params {
+		valueStr := c.Query(param.name)
+		if valueStr == "" {
+			continue
+		}
+		value, parseErr := strconv.ParseFloat(valueStr, 64)
+		if parseErr != nil {
+			return thresholds, fmt.Errorf("invalid %s '%s', must be a number", param.name, valueStr)
+		}
+		*param.value = value
+	}
+	return thresholds, thresholds.Validate()
+}
+
+// Calculates location with the solver asked for, and its accuracy estimation only if asked for.
+// input: the distances, its standard deviations (nil if unknown), the solver name (the configured one if empty), the thresholds and accuracy flag.
+// output: the location position, the names of the rejected satellites (robust solvers), the accuracy (nil if not asked for) and the solver name used.
+// error: in case the solver is unknown or calculation couldn't be done.
+func CalculateLocation(distances []float32, stdDevs []float64, solverName string, thresholds location.Thresholds, withAccuracy bool) (position model.CoordinatesResponse, rejected []string, accuracyRsp *model.AccuracyResponse, solverUsed string, err error) {
+	solution, solver, err := location.CalculateLocationWithSolver(distances, stdDevs, solverName, thresholds)
+	if err != nil {
+		return position, rejected, accuracyRsp, solverUsed, err
+	}
+	position, rejected, accuracyRsp, err = BuildSolutionResponse(solution, distances, withAccuracy)
+	return position, rejected, accuracyRsp, solver.Name(), err
+}
+
+// Calculates location in best effort mode with the solver asked for, see location.CalculateBestEffortLocation.
+// input: the distances, its standard deviations (nil if unknown), the solver name (the configured one if empty), the thresholds and accuracy flag.
+// output: the location position, the names of the rejected satellites (robust solvers), the accuracy (nil if not asked for),
+// the location quality and the solver name used.
+// error: in case the solver is unknown or even the closest fit location couldn't be calculated.
+func CalculateBestEffortLocation(distances []float32, stdDevs []float64, solverName string, thresholds location.Thresholds, withAccuracy bool) (position model.CoordinatesResponse, rejected []string, accuracyRsp *model.AccuracyResponse, qualityRsp *model.QualityResponse, solverUsed string, err error) {
+	solution, quality, solver, err := location.CalculateBestEffortLocation(distances, stdDevs, solverName, thresholds)
+	if err != nil {
+		return position, rejected, accuracyRsp, qualityRsp, solverUsed, err
+	}
+	qualityRsp = &model.QualityResponse{
+		Grade:       string(quality.Grade),
+		ChiSquare:   quality.ChiSquare,
+		PValue:      quality.PValue,
+		RelativeRMS: quality.RelativeRMS,
+	}
+	position, rejected, accuracyRsp, err = BuildSolutionResponse(solution, distances, withAccuracy)
+	return position, rejected, accuracyRsp, qualityRsp, solver.Name(), err
+}
+
+// Builds the response data of a solver solution, and its accuracy estimation only if asked for.
+// output: the location position, the names of the rejected satellites and the accuracy (nil if not asked for).
+// error: if the accuracy can't be estimated.
+func BuildSolutionResponse(solution location.Solution, distances []float32, withAccuracy bool) (position model.CoordinatesResponse, rejected []string, accuracyRsp *model.AccuracyResponse, err error) {
+	position = BuildCoordinatesResponse(solution.Position, solution.Spatial)
+
+	satellitesInfo := store.GetSatellitesInfo()
+	for _, satIdx := range solution.Rejected {
+		rejected = append(rejected, satellitesInfo[satIdx].Name)
+	}
+
+	if withAccuracy {
+		// estimates accuracy only with the distances that weren't rejected
+		inlierDistances := make([]float32, len(solution.Inliers))
+		inlierPoints := make([]model.Point, len(solution.Inliers))
+		inlierNames := make([]string, len(solution.Inliers))
+		for i, satIdx := range solution.Inliers {
+			inlierDistances[i] = distances[satIdx]
+			inlierPoints[i] = satellitesInfo[satIdx].Location
+			inlierNames[i] = satellitesInfo[satIdx].Name
+		}
+
+		var accuracy location.Accuracy
+		var accErr error
+		if solution.Spatial {
+			accuracy, accErr = location.EstimateSpatialAccuracy(solution.Position, inlierDistances, inlierPoints)
+		} else {
+			accuracy, accErr = location.EstimateAccuracy(float32(solution.Position.X), float32(solution.Position.Y), inlierDistances, inlierPoints)
+		}
+		if accErr != nil {
+			return position, rejected, accuracyRsp, accErr
+		}
+		accuracyRsp = BuildAccuracyResponse(accuracy, inlierNames)
+	}
+	return position, rejected, accuracyRsp, nil
+}
+
+// Converts the response position (and candidates) from the local cartesian frame to the frame asked for.
+// With 'enu' frame the coordinates are replaced by the East-North-Up ones at the origin (the reference origin if not given).
+// With 'geodetic' frame the cartesian coordinates are kept and the geodetic block is added.
+// input: the response data, the frame (empty or 'cartesian' for no conversion) and the ENU origin ('<latitude>,<longitude>[,<altitude>]', optional).
+// error: if the frame or origin are invalid, or the reference origin isn't configured.
+func ApplyFrame(rspData *model.TopSecretResponse, frameStr string, originStr string) (err error) {
+	frame := model.Frame(strings.ToLower(strings.TrimSpace(frameStr)))
+	if frame == "" || frame == model.FRAME_CARTESIAN {
+		return nil
+	}
+
+	var convert func(coordinates *model.CoordinatesResponse) error
+	switch frame {
+	case model.FRAME_ENU:
+		origin, present := store.GetReferenceOrigin()
+		if originStr != "" {
+			var parseErr error
+			origin, parseErr = model.ParseGeodeticCoordinates(originStr)
+			if parseErr != nil {
+				return parseErr
+			}
+		} else if !present {
+			return errors.New("can't convert to ENU frame, the reference origin isn't configured")
+		}
+		convert = func(coordinates *model.CoordinatesResponse) error {
+			enu, enuErr := location.ToENU(pointOfCoordinates(*coordinates), origin)
+			if enuErr != nil {
+				return enuErr
+			}
+			*coordinates = BuildCoordinatesResponse(enu, true)
+			return nil
+		}
+	case model.FRAME_GEODETIC:
+		convert = func(coordinates *model.CoordinatesResponse) error {
+			geo, geoErr := location.ToGeodetic(pointOfCoordinates(*coordinates))
+			if geoErr != nil {
+				return geoErr
+			}
+			coordinates.Geodetic = &model.GeodeticResponse{Latitude: geo.Latitude, Longitude: geo.Longitude, Altitude: geo.Altitude}
+			return nil
+		}
+	default:
+		return fmt.Errorf("unknown frame '%s', use '%s', '%s' or '%s'", frameStr, model.FRAME_CARTESIAN, model.FRAME_ENU, model.FRAME_GEODETIC)
+	}
+
+	if err = convert(&rspData.Position); err != nil {
+		return err
+	}
+	for i := range rspData.Candidates {
+		if err = convert(&rspData.Candidates[i]); err != nil {
+			return err
+		}
+	}
+	rspData.Frame = string(frame)
+	return nil
+}
+
+// Gets the point of a coordinates response, Z is 0 for a location in the plane.
+func pointOfCoordinates(coordinates model.CoordinatesResponse) (pt model.Point) {
+	pt = model.Point{X: float64(coordinates.X), Y: float64(coordinates.Y)}
+	if coordinates.Z != nil {
+		pt.Z = float64(*coordinates.Z)
+	}
+	return pt
+}
+
+// Builds the coordinates response of a calculated position, Z is included only if it was calculated in the space.
+// input: the position and true if was calculated in the space.
+func BuildCoordinatesResponse(position model.Point, spatial bool) (coordinates model.CoordinatesResponse) {
+	coordinates = model.CoordinatesResponse{X: float32(position.X), Y: float32(position.Y)}
+	if spatial {
+		z := float32(position.Z)
+		coordinates.Z = &z
+	}
+	return coordinates
+}
+
+// Builds the accuracy response, linking each residual with its satellite name.
+// input: the accuracy and the satellites names, in the same order as the residuals.
+func BuildAccuracyResponse(accuracy location.Accuracy, names []string) (accuracyRsp *model.AccuracyResponse) {
+	residuals := make([]model.SatelliteResidualResponse, len(accuracy.Residuals))
+	for i, residual := range accuracy.Residuals {
+		residuals[i] = model.SatelliteResidualResponse{Name: names[i], Residual: residual}
+	}
+	accuracyRsp = &model.AccuracyResponse{
+		Residuals:  residuals,
+		Covariance: accuracy.Covariance,
+		ErrorEllipse: model.ErrorEllipseResponse{
+			SemiMajorAxis: accuracy.SemiMajorAxis,
+			SemiMinorAxis: accuracy.SemiMinorAxis,
+			Orientation:   accuracy.Orientation,
+		},
+		GDOP: accuracy.GDOP,
+	}
+	return accuracyRsp
+}
+
+// Gets the distances standard deviations of the request data, ordered as the known satellites.
+// The standard deviation is the given one, or the one estimated with the SNR if absent. See location.StdDevFromSNR.
+// output: the standard deviations, 0 if unknown.
+// error: if some standard deviation is negative.
+func TreatSatellitesQuality(satellitesData []model.SatelliteInfoRequest) (stdDevs []float64, err error) {
+	stdDevs = make([]float64, store.GetSatellitesInfoCount())
+	for _, rqSatelliteInfo := range satellitesData {
+		satIdx := store.GetSatelliteInfoIndex(rqSatelliteInfo.Name)
+		if satIdx < 0 || satIdx >= len(stdDevs) {
+			continue
+		}
+		if rqSatelliteInfo.StdDev < 0 {
+			return stdDevs, fmt.Errorf("invalid standard deviation %f for satellite '%s', must be positive", rqSatelliteInfo.StdDev, rqSatelliteInfo.Name)
+		}
+		if rqSatelliteInfo.StdDev > 0 {
+			stdDevs[satIdx] = float64(rqSatelliteInfo.StdDev)
+		} else if rqSatelliteInfo.SNR != nil {
+			stdDevs[satIdx] = location.StdDevFromSNR(float64(*rqSatelliteInfo.SNR))
+		}
+	}
+	return stdDevs, nil
+}
+
+// Gets the satellites reliabilities of the request data, ordered as the known satellites.
+// output: the reliabilities, 0 if unknown.
+// error: if some reliability is negative.
+func TreatSatellitesReliability(satellitesData []model.SatelliteInfoRequest) (reliabilities []float64, err error) {
+	reliabilities = make([]float64, store.GetSatellitesInfoCount())
+	for _, rqSatelliteInfo := range satellitesData {
+		satIdx := store.GetSatelliteInfoIndex(rqSatelliteInfo.Name)
+		if satIdx < 0 || satIdx >= len(reliabilities) {
+			continue
+		}
+		if rqSatelliteInfo.Reliability < 0 {
+			return reliabilities, fmt.Errorf("invalid reliability %f for satellite '%s', must be positive", rqSatelliteInfo.Reliability, rqSatelliteInfo.Name)
+		}
+		reliabilities[satIdx] = float64(rqSatelliteInfo.Reliability)
+	}
+	return reliabilities, nil
+}
+
+// Consolidates the messages, by majority vote if asked for by the 'vote' query param (see message.VoteMessage),
+// and with the detail of each word if asked for by the 'detail' query param (see message.DetailMessage).
+// input: the messages, the reliability of each one (nil or 0 if unknown), the name of the satellite of each one and
+// the words comparison options (see GetMatchOptionsParams).
+// output: the complete message with its gaps, its contested positions (only in vote mode) and the words detail (nil if
+// not asked for).
+// error: if the messages words mismatch, only if not in vote mode.
+func ConsolidateMessage(c *gin.Context, messages [][]string, reliabilities []float64, names []string, options message.MatchOptions) (completeMessage message.ConsolidatedMessage, contested []model.ContestedWordResponse, words []model.WordDetailResponse, err error) {
+	vote := c.Query("vote") == "true"
+	var contestedWords []message.ContestedWord
+	switch {
+	case c.Query("detail") == "true":
+		var details []message.WordDetail
+		completeMessage, details, contestedWords, err = message.DetailMessage(messages, reliabilities, vote, options)
+		words = make([]model.WordDetailResponse, len(details))
+		for i, detail := range details {
+			satellites := []string{}
+			for _, msgIdx := range detail.Sources {
+				if msgIdx < len(names) {
+					satellites = append(satellites, names[msgIdx])
+				}
+			}
+			words[i] = model.WordDetailResponse{
+				Position:   detail.Position,
+				Word:       detail.Word,
+				Satellites: satellites,
+				Agreement:  detail.Agreement,
+				Confidence: detail.Confidence,
+				Gap:        detail.Gap,
+			}
+		}
+	case vote:
+		completeMessage, contestedWords = message.VoteMessage(messages, reliabilities, options)
+	default:
+		completeMessage, err = message.ConsolidateMessageWithOptions(messages, options)
+	}
+	if err != nil || !vote {
+		return completeMessage, contested, words, err
+	}
+
+	for _, contestedWord := range contestedWords {
+		alternatives := make([]model.WordVotesResponse, len(contestedWord.Alternatives))
+		for i, alternative := range contestedWord.Alternatives {
+			alternatives[i] = model.WordVotesResponse{Word: alternative.Word, Votes: alternative.Votes}
+		}
+		contested = append(contested, model.ContestedWordResponse{
+			Position:     contestedWord.Position,
+			Chosen:       model.WordVotesResponse{Word: contestedWord.Chosen.Word, Votes: contestedWord.Chosen.Votes},
+			Alternatives: alternatives,
+		})
+	}
+	return completeMessage, contested, words, nil
+}
+
+// Gets the message words comparison options, the configured ones overridden by the 'normalize' (comma separated
+// list of case, nfc and punctuation) and 'minRatio' (fuzzy ratio from 0 to 100) query params. See message.MatchOptions.
+// error: if some param is invalid.
+func GetMatchOptionsParams(c *gin.Context) (options message.MatchOptions, err error) {
+	normalizations, isNormalizePresent := c.GetQuery("normalize")
+	minRatioStr, isMinRatioPresent := c.GetQuery("minRatio")
+	if !isNormalizePresent && !isMinRatioPresent {
+		return message.DefaultMatchOptions(), nil
+	}
+	if !isNormalizePresent {
+		normalizations = support.MessageNormalization()
+	}
+	minRatio := support.MessageMinRatio()
+	if isMinRatioPresent {
+		var parseErr error
+		minRatio, parseErr = strconv.Atoi(minRatioStr)
+		if parseErr != nil {
+			return options, fmt.Errorf("invalid minRatio '%s', must be an integer number", minRatioStr)
+		}
+	}
+	return message.ParseMatchOptions(normalizations, minRatio)
+}
+
+// Gets the placeholder that renders the message gaps, the 'gapPlaceholder' query param or the configured one
+// (OFQ_GAP_PLACEHOLDER, empty by default).
+func GetGapPlaceholderParam(c *gin.Context) (placeholder string) {
+	if placeholder, isPresent := c.GetQuery("gapPlaceholder"); isPresent {
+		return placeholder
+	}
+	return support.GapPlaceholder()
+}
+
+// Suggests the words of the message gaps if asked for by the 'suggest' query param, see message.SuggestGaps.
+// output: the gaps suggestions, nil if not asked for or without phrases file (OFQ_PHRASES_FILE).
+func GetSuggestionsParam(c *gin.Context, completeMessage message.ConsolidatedMessage, options message.MatchOptions) (suggestions []model.GapSuggestionsResponse) {
+	if c.Query("suggest") != "true" {
+		return nil
+	}
+	for _, gapSuggestions := range message.SuggestGaps(completeMessage, options) {
+		candidates := make([]model.SuggestionResponse, len(gapSuggestions.Suggestions))
+		for i, suggestion := range gapSuggestions.Suggestions {
+			candidates[i] = model.SuggestionResponse{Word: suggestion.Word, Score: suggestion.Score}
+		}
+		suggestions = append(suggestions, model.GapSuggestionsResponse{Position: gapSuggestions.Position, Candidates: candidates})
+	}
+	return suggestions
+}
+
+// Gets the names of the known satellites, ordered as them.
+func satellitesNames() (names []string) {
+	for _, satelliteInfo := range store.GetSatellitesInfo() {
+		names = append(names, satelliteInfo.Name)
+	}
+	return names
+}
+
+// Error of a report of a satellite without reference data, its name is unknown.
+var ErrUnknownSatellite = errors.New("not exists satellite reference data")
+
+// Sorts the satellites data to lists in the calculation form, by the satellites reference data index.
+// error: if there isn't data of every satellite, or ErrUnknownSatellite if a satellite name is unknown.
 func TreatSatellitesData(satellitesData []model.SatelliteInfoRequest) (distances []float32, messages [][]string, err error) {
 	satellitesCount := store.GetSatellitesInfoCount()
 	if len(satellitesData) < satellitesCount {
@@ -95,6 +630,9 @@ func TreatSatellitesData(satellitesData []model.SatelliteInfoRequest) (distances
 	for _, rqSatelliteInfo := range satellitesData {
 		// gets index synchronized satellite info
 		satIdx := store.GetSatelliteInfoIndex(rqSatelliteInfo.Name)
+		if satIdx < 0 {
+			return distances, messages, fmt.Errorf("%w for '%s'", ErrUnknownSatellite, rqSatelliteInfo.Name)
+		}
 
 		// sets distance to the satellite via index idem like stored satellite info
 		distances[satIdx] = rqSatelliteInfo.Distance
@@ -105,15 +643,145 @@ func TreatSatellitesData(satellitesData []model.SatelliteInfoRequest) (distances
 	return distances, messages, nil
 }
 
+// @BasePath /
+// @Summary Obtiene la ubicacion de la nave y el mensaje que emite, a partir de los tiempos de llegada de la señal.
+// @Description Basado en el instante de recepcion de la señal en cada satelite (diferencia de tiempos de llegada, TDOA) y los mensajes recibidos, se obtienen la posicion y el mensaje emitido Con 5 o mas satelites fuera de un mismo plano la posicion se calcula en el espacio (incluye z). Con 3 satelites la señal puede provenir de dos posiciones: la respuesta es ambigua e informa ambos candidatos. Los reportes de tiempos no se firman ni tienen nonce, por lo que solo se aceptan si se permiten los reportes reenviables sin firma (OFQ_ALLOW_UNSIGNED_REPLAYS), no se requieren firmas (OFQ_REQUIRE_SIGNATURES) y ningun satelite informado tiene clave.
+// @Param Body body model.TopSecretTDOARequest true "Los tiempos de llegada y mensajes recibidos por los satelites, y opcionalmente la velocidad de propagacion"
+// @Param detail query bool false "Incluye el detalle de cada palabra del mensaje: satelites que la recibieron, coincidencias, confianza y posiciones sin recibir"
+// @Param normalize query string false "Normalizaciones al comparar las palabras de los mensajes, separadas por coma: case (mayusculas), nfc (Unicode) y punctuation (signos de puntuacion). Configurable con OFQ_MESSAGE_NORMALIZATION"
+// @Param minRatio query int false "Similitud minima (0 a 100, por distancia de edicion) para considerar iguales dos palabras, por defecto 100 (exactas). Configurable con OFQ_MESSAGE_MIN_RATIO"
+// @Param gapPlaceholder query string false "Texto con el que se muestran en el mensaje las palabras que ningun satelite recibio, por ejemplo [?]. Por defecto vacio, configurable con OFQ_GAP_PLACEHOLDER"
+// @Param suggest query bool false "Sugiere palabras para los huecos del mensaje segun el diccionario de frases configurado con OFQ_PHRASES_FILE. Las sugerencias no se agregan al mensaje"
+// @Param vote query bool false "Resuelve las palabras en conflicto entre satelites por mayoria de votos (ponderados por la confiabilidad de cada satelite), informando las posiciones en disputa"
+// @Param frame query string false "Sistema de referencia de la ubicacion: cartesian (por defecto), enu o geodetic (latitud, longitud y altitud WGS84)"
+// @Param origin query string false "Origen del sistema enu como '<latitud>,<longitud>[,<altitud>]', por defecto el origen de referencia (OFQ_ORIGIN)"
+// @Accept json
+// @Produce json
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Success 200 {object} model.TopSecretResponse
+// @Router /topsecret_tdoa/ [POST]
+func TopSecretTDOAHandler(c *gin.Context) {
+	var requestData model.TopSecretTDOARequest
+
+	// parse json to struct
+	err := c.ShouldBindJSON(&requestData)
+	if err != nil {
+		log.Printf("Error binding json. Trace: %s", err.Error())
+		c.IndentedJSON(http.StatusBadRequest, model.ErrorResponse{Message: "malformed json."})
+		return
+	}
+
+	// the timing reports can't be verified, so they are accepted only if the unverified reports are
+	for _, satelliteData := range requestData.Satellites {
+		if verifyErr := CheckUnverifiableReport(satelliteData.Name); verifyErr != nil {
+			log.Printf("TopSecretTDOAHandler error with report verification. Trace: %s", verifyErr.Error())
+			c.IndentedJSON(http.StatusUnauthorized, model.ErrorResponse{Message: verifyErr.Error()})
+			return
+		}
+	}
+
+	// treat request data to lists calculation form
+	timestamps, messages, treatErr := TreatSatellitesTimingData(requestData.Satellites)
+	if treatErr != nil {
+		c.IndentedJSON(treatRejectionStatus(treatErr), model.ErrorResponse{Message: treatErr.Error()})
+		return
+	}
+
+	// gets the message words comparison options
+	matchOptions, optionsErr := GetMatchOptionsParams(c)
+	if optionsErr != nil {
+		c.IndentedJSON(http.StatusBadRequest, model.ErrorResponse{Message: optionsErr.Error()})
+		return
+	}
+
+	propagationSpeed := requestData.PropagationSpeed
+	if propagationSpeed == 0 {
+		propagationSpeed = support.PropagationSpeed()
+	}
+
+	// calculates location
+	tdoaLocation, locErr := location.CalculatePositionFromTimestamps(timestamps, propagationSpeed)
+	if locErr != nil {
+		log.Printf("TopSecretTDOAHandler error with calculate location. Trace: %s", locErr.Error())
+		c.IndentedJSON(http.StatusNotFound, model.ErrorResponse{Message: "Can't calculate location. Please check timestamps."})
+		return
+	}
+
+	completeMessage, contested, words, msgsErr := ConsolidateMessage(c, messages, nil, satellitesNames(), matchOptions)
+	if msgsErr != nil {
+		log.Printf("TopSecretTDOAHandler error with consolidate message. Trace: %s", msgsErr.Error())
+		c.IndentedJSON(http.StatusNotFound, model.ErrorResponse{Message: msgsErr.Error()})
+		return
+	}
+
+	rspData := model.TopSecretResponse{
+		Position:     BuildCoordinatesResponse(tdoaLocation.Position, tdoaLocation.Spatial),
+		Message:      completeMessage.Text(GetGapPlaceholderParam(c)),
+		Gaps:         completeMessage.Gaps,
+		Completeness: completeMessage.Completeness,
+		Contested:    contested,
+		Words:        words,
+		Suggestions:  GetSuggestionsParam(c, completeMessage, matchOptions),
+	}
+
+	// with 3 timestamps the location can be any of two candidates, the position is the first one
+	if tdoaLocation.Ambiguous() {
+		rspData.Ambiguous = true
+		for _, candidate := range tdoaLocation.Candidates {
+			rspData.Candidates = append(rspData.Candidates, BuildCoordinatesResponse(candidate, tdoaLocation.Spatial))
+		}
+	}
+
+	// converts location to the frame asked for
+	frameErr := ApplyFrame(&rspData, c.Query("frame"), c.Query("origin"))
+	if frameErr != nil {
+		log.Printf("TopSecretTDOAHandler error with frame conversion. Trace: %s", frameErr.Error())
+		c.IndentedJSON(http.StatusBadRequest, model.ErrorResponse{Message: frameErr.Error()})
+		return
+	}
+	c.IndentedJSON(http.StatusOK, rspData)
+}
+
+// Treats the satellites timing data to lists calculation form, ordered as the known satellites.
+// input: the satellites timing data.
+// output: the timestamps and messages lists.
+// error: if there is not data of every satellite or some satellite is unknown.
+func TreatSatellitesTimingData(satellitesData []model.SatelliteTimingRequest) (timestamps []float64, messages [][]string, err error) {
+	satellitesCount := store.GetSatellitesInfoCount()
+	if len(satellitesData) < satellitesCount {
+		log.Printf("Insufficient request data. Satelites timestamps: %d, need at least %d", len(satellitesData), satellitesCount)
+		return timestamps, messages, errors.New("insufficient request data")
+	}
+	timestamps = make([]float64, satellitesCount)
+	messages = make([][]string, satellitesCount)
+	for _, rqSatelliteInfo := range satellitesData {
+		// gets index synchronized satellite info
+		satIdx := store.GetSatelliteInfoIndex(rqSatelliteInfo.Name)
+		if satIdx == -1 {
+			return timestamps, messages, fmt.Errorf("%w for '%s'", ErrUnknownSatellite, rqSatelliteInfo.Name)
+		}
+		timestamps[satIdx] = rqSatelliteInfo.Timestamp
+		messages[satIdx] = rqSatelliteInfo.Message
+	}
+	return timestamps, messages, nil
+}
+
 // @BasePath /
 // @Summary Colecta la distancia de la nave y el mensaje que fue recibido por un satelite.
 // @Description Recibe la distancia y mensaje que recibe un satelite y devuelve el token de operacion para posterior tratamiento.
 // @Param operation path string false "El token de operacion"
 // @Param Body body model.TopSecretSplitRequest true "La distancia y el mensaje recibido por un satelite"
+// @Param normalize query string false "Normalizaciones al comparar las palabras de los mensajes, separadas por coma: case (mayusculas), nfc (Unicode) y punctuation (signos de puntuacion). Configurable con OFQ_MESSAGE_NORMALIZATION"
+// @Param minRatio query int false "Similitud minima (0 a 100, por distancia de edicion) para considerar iguales dos palabras, por defecto 100 (exactas). Configurable con OFQ_MESSAGE_MIN_RATIO"
+// @Param vote query bool false "Resuelve las palabras en conflicto entre satelites por mayoria de votos (ponderados por la confiabilidad de cada satelite), informando las posiciones en disputa"
 // @Accept json
 // @Produce json
 // @Failure 404 {object} model.ErrorResponse
 // @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
 // @Failure 500 {object} model.ErrorResponse
 // @Success 200 {object} model.TopSecretSplitPOSTResponse
 // @Router /topsecret_split/{operation} [POST]
@@ -148,29 +816,64 @@ func TopSecretSplitPOSTHandler(c *gin.Context) {
 		return
 	}
 
-	savedDataset := store.GetDataset(operation, requestData.Message)
+	// verifies the satellite report signature and timestamp, then rejects it if replayed
+	if verifyErr := VerifySatelliteReport(requestData); verifyErr != nil {
+		log.Printf("Error with report verification. Trace: %s", verifyErr.Error())
+		c.IndentedJSON(reportRejectionStatus(verifyErr), model.ErrorResponse{Message: verifyErr.Error()})
+		return
+	}
+	if nonceErr := RegisterSatellitesReportsNonces([]model.SatelliteInfoRequest{requestData}); nonceErr != nil {
+		log.Printf("Error with report nonce. Trace: %s", nonceErr.Error())
+		c.IndentedJSON(reportRejectionStatus(nonceErr), model.ErrorResponse{Message: nonceErr.Error()})
+		return
+	}
+
+	// collects the satellite data, again over the current dataset while it's updated concurrently
+	collected := false
+	for attempt := 1; attempt <= store.DATASET_UPDATE_MAX_ATTEMPTS && !collected; attempt++ {
+		if conflictErr := collectSatelliteData(c, operation, requestData); conflictErr == nil {
+			collected = true
+		} else {
+			log.Printf("WARN dataset updated concurrently, operation: '%s', attempt %d of %d", operation, attempt, store.DATASET_UPDATE_MAX_ATTEMPTS)
+		}
+	}
+	if !collected {
+		c.IndentedJSON(http.StatusConflict, model.ErrorResponse{Message: "Can't update data, the operation is being updated concurrently."})
+	}
+
+	// the nonce of a report that wasn't collected isn't used up, so the report can be sent again
+	if c.Writer.Status() != http.StatusOK {
+		ReleaseSatellitesReportsNonces([]model.SatelliteInfoRequest{requestData})
+	}
+}
+
+// Collects the satellite data in the dataset of the operation (or the one that matches its message), saving a new
+// dataset if not found, and responds.
+// error: store.ErrDatasetConflict, without response, if the dataset was updated concurrently.
+func collectSatelliteData(c *gin.Context, operation string, requestData model.SatelliteInfoRequest) (conflictErr error) {
+	savedDataset, matchScore := store.GetDatasetStore().GetDataset(operation, requestData.Message)
 	if savedDataset.Key == "" {
 		// get operation token
 		operation = store.GetNewOperationUUID()
 
 		// initialize dataset
-		saved := store.SaveNewDataset(operation, requestData)
+		saved := store.GetDatasetStore().SaveNewDataset(operation, requestData)
 		if saved {
 			response := model.TopSecretSplitPOSTResponse{Operation: operation}
 			c.IndentedJSON(http.StatusOK, response)
 		} else {
 			log.Printf("Error in save new dataset. unsaved operacion: %s, request data:%v", operation, requestData)
 			c.IndentedJSON(http.StatusInternalServerError, model.ErrorResponse{Message: "Can't save data."})
-			return
+			return nil
 		}
-		return
+		return nil
 	}
 
 	if satelliteDataAlreadyExists(requestData, savedDataset) {
 		log.Printf("WARN satellite data already exists in datasset: '%s' for operation: '%s'", requestData.Name, savedDataset.Operation)
-		response := model.TopSecretSplitPOSTResponse{Operation: operation}
+		response := model.TopSecretSplitPOSTResponse{Operation: operation, MatchScore: matchScore}
 		c.IndentedJSON(http.StatusOK, response)
-		return
+		return nil
 	}
 
 	countData := len(savedDataset.Satellites) + 1
@@ -181,32 +884,44 @@ func TopSecretSplitPOSTHandler(c *gin.Context) {
 	if countData < store.GetSatellitesInfoCount() {
 		// dataset is incomplete, consolidate partial message with data that have
 		messages := [][]string{}
+		reliabilities := []float64{}
 		for _, satData := range savedDataset.Satellites {
 			messages = append(messages, satData.Message)
+			reliabilities = append(reliabilities, float64(satData.Reliability))
 		}
 		messages = append(messages, requestData.Message)
+		reliabilities = append(reliabilities, float64(requestData.Reliability))
 
 		// gets consolidated message
-		var consErr error
-		consolidatedMessage, consErr = message.ConsolidateMessage(messages)
+		matchOptions, optionsErr := GetMatchOptionsParams(c)
+		if optionsErr != nil {
+			c.IndentedJSON(http.StatusBadRequest, model.ErrorResponse{Message: optionsErr.Error()})
+			return nil
+		}
+		completeMessage, _, _, consErr := ConsolidateMessage(c, messages, reliabilities, nil, matchOptions)
 		if consErr != nil {
 			c.IndentedJSON(http.StatusNotFound, model.ErrorResponse{Message: "Can't consolidate message."})
-			return
+			return nil
 		}
+		consolidatedMessage = completeMessage.Text("")
 	}
 
 	// update dataset
-	updated := store.UpdateDataset(operation, consolidatedMessage, savedDataset.Key, requestData)
+	updated, updateErr := store.GetDatasetStore().UpdateDataset(operation, consolidatedMessage, savedDataset.Key, savedDataset.Version, requestData)
+	if errors.Is(updateErr, store.ErrDatasetConflict) {
+		return updateErr
+	}
 	if !updated {
 		log.Printf("Error in update dataset. operacion: %s, message: %s, previous key: %s, request data:%v", operation, consolidatedMessage, savedDataset.Key, requestData)
 		c.IndentedJSON(http.StatusInternalServerError, model.ErrorResponse{Message: "Can't update data."})
-		return
+		return nil
 	}
 	if operation == "" {
 		operation = savedDataset.Operation
 	}
-	response := model.TopSecretSplitPOSTResponse{Operation: operation}
+	response := model.TopSecretSplitPOSTResponse{Operation: operation, MatchScore: matchScore}
 	c.IndentedJSON(http.StatusOK, response)
+	return nil
 }
 
 func satelliteDataAlreadyExists(requestData model.SatelliteInfoRequest, dataset model.Dataset) (exists bool) {
@@ -220,6 +935,124 @@ func satelliteDataAlreadyExists(requestData model.SatelliteInfoRequest, dataset
 	return
 }
 
+// Gets the current time, used to check the satellites reports timestamps.
+var GetCurrentTime = func() time.Time {
+	return time.Now()
+}
+
+// Error of a report whose nonce can't be registered, so its replay can't be checked.
+var ErrNonceNotRegistered = errors.New("can't register the report nonce")
+
+// Verifies the signature of a satellite report with the satellite verification key (see store.SATELITES_KEYS_ENV),
+// and its timestamp. Every report must carry timestamp and nonce, except the ones of the satellites without
+// verification key if their replays are allowed (OFQ_ALLOW_UNSIGNED_REPLAYS), whose timestamp is checked if present.
+// The reports of the satellites without verification key aren't verified, unless the signatures are required
+// (OFQ_REQUIRE_SIGNATURES).
+// error: if the report is unsigned, its signature doesn't match the report data, it hasn't timestamp or nonce
+// (security.ErrMissingNonce) or its timestamp is out of the accepted window.
+func VerifySatelliteReport(satelliteData model.SatelliteInfoRequest) (err error) {
+	var key *security.VerificationKey
+	if satIdx := store.GetSatelliteInfoIndex(satelliteData.Name); satIdx >= 0 {
+		key = store.GetSatellitesInfo()[satIdx].VerificationKey
+	}
+	if key == nil && support.RequireSignatures() {
+		return fmt.Errorf("the reports of satellite '%s' can't be verified, it hasn't verification key", satelliteData.Name)
+	}
+	if key != nil {
+		quality := security.ReportQuality{StdDev: satelliteData.StdDev, SNR: satelliteData.SNR, Reliability: satelliteData.Reliability}
+		payload := security.SignedPayload(satelliteData.Name, satelliteData.Distance, satelliteData.Message, quality, satelliteData.Timestamp, satelliteData.Nonce)
+		if verifyErr := security.Verify(*key, payload, satelliteData.Signature); verifyErr != nil {
+			return fmt.Errorf("the report of satellite '%s' was rejected. %w", satelliteData.Name, verifyErr)
+		}
+	}
+	if (key != nil || !support.AllowUnsignedReplays()) && (satelliteData.Timestamp == 0 || satelliteData.Nonce == "") {
+		return fmt.Errorf("the report of satellite '%s' was rejected. %w", satelliteData.Name, security.ErrMissingNonce)
+	}
+	if satelliteData.Timestamp != 0 {
+		window := time.Duration(support.ReplayWindow() * float64(time.Second))
+		if staleErr := security.CheckTimestamp(satelliteData.Timestamp, GetCurrentTime(), window); staleErr != nil {
+			return fmt.Errorf("the report of satellite '%s' was rejected. %w", satelliteData.Name, staleErr)
+		}
+	}
+	return nil
+}
+
+// Checks if a satellite report without signature nor nonce (as the timing ones) is accepted. It can't be verified nor
+// its replay rejected, so it's accepted only if the unsigned replays are allowed (OFQ_ALLOW_UNSIGNED_REPLAYS), the
+// signatures aren't required (OFQ_REQUIRE_SIGNATURES) and the satellite hasn't verification key.
+// error: if the report isn't accepted.
+func CheckUnverifiableReport(satellite string) (err error) {
+	if satIdx := store.GetSatelliteInfoIndex(satellite); satIdx >= 0 && store.GetSatellitesInfo()[satIdx].VerificationKey != nil {
+		return fmt.Errorf("the report of satellite '%s' was rejected, it has verification key and the report can't be signed", satellite)
+	}
+	if support.RequireSignatures() {
+		return fmt.Errorf("the report of satellite '%s' was rejected, the signatures are required and the report can't be signed", satellite)
+	}
+	if !support.AllowUnsignedReplays() {
+		return fmt.Errorf("the report of satellite '%s' was rejected. %w", satellite, security.ErrMissingNonce)
+	}
+	return nil
+}
+
+// Registers the nonces of the satellites reports, those present, rejecting the replayed reports. Each nonce is
+// registered only if it wasn't before, so concurrent replays are rejected too. If some nonce can't be registered, the
+// already registered ones are released, so the request doesn't use up any nonce. The nonces are remembered twice the
+// accepted timestamps window (OFQ_REPLAY_WINDOW), so a report can't be replayed while its timestamp is accepted.
+// Must be called after VerifySatelliteReport, and the nonces released if the request fails afterwards
+// (see ReleaseSatellitesReportsNonces).
+// error: security.ErrReplayedReport if some nonce was already registered, ErrNonceNotRegistered if the store fails.
+func RegisterSatellitesReportsNonces(satellitesData []model.SatelliteInfoRequest) (err error) {
+	ttlSeconds := int(math.Ceil(2 * support.ReplayWindow()))
+	for i, satelliteData := range satellitesData {
+		if satelliteData.Nonce == "" {
+			continue
+		}
+		isNew, storeErr := store.GetDatasetStore().RegisterNonce(satelliteData.Name, satelliteData.Nonce, ttlSeconds)
+		if storeErr != nil {
+			err = fmt.Errorf("%w of satellite '%s'. %s", ErrNonceNotRegistered, satelliteData.Name, storeErr.Error())
+		} else if !isNew {
+			err = fmt.Errorf("the report of satellite '%s' was rejected. %w", satelliteData.Name, security.ErrReplayedReport)
+		}
+		if err != nil {
+			ReleaseSatellitesReportsNonces(satellitesData[:i])
+			return err
+		}
+	}
+	return nil
+}
+
+// Releases the nonces of the satellites reports, those present, so the reports can be sent again. Used when the
+// request fails after its nonces were registered, see RegisterSatellitesReportsNonces.
+func ReleaseSatellitesReportsNonces(satellitesData []model.SatelliteInfoRequest) {
+	for _, satelliteData := range satellitesData {
+		if satelliteData.Nonce == "" {
+			continue
+		}
+		if releaseErr := store.GetDatasetStore().ReleaseNonce(satelliteData.Name, satelliteData.Nonce); releaseErr != nil {
+			log.Printf("WARN can't release the report nonce of satellite '%s'. Trace: %s", satelliteData.Name, releaseErr.Error())
+		}
+	}
+}
+
+// Gets the response status of the satellites data that can't be treated, see TreatSatellitesData.
+func treatRejectionStatus(err error) int {
+	if errors.Is(err, ErrUnknownSatellite) {
+		return http.StatusBadRequest
+	}
+	return http.StatusNotFound
+}
+
+// Gets the response status of a rejected satellite report, see VerifySatelliteReport and RegisterSatellitesReportsNonces.
+func reportRejectionStatus(err error) int {
+	switch {
+	case errors.Is(err, security.ErrReplayedReport):
+		return http.StatusConflict
+	case errors.Is(err, ErrNonceNotRegistered):
+		return http.StatusInternalServerError
+	}
+	return http.StatusUnauthorized
+}
+
 func validateSatelliteInfoRequestData(requestData model.SatelliteInfoRequest) (isValid bool, validationErrors string) {
 	isValid = false
 	validationErrors = ""
@@ -234,6 +1067,22 @@ func validateSatelliteInfoRequestData(requestData model.SatelliteInfoRequest) (i
 // @Summary Obtiene la ubicacion de la nave y el mensaje que emite.
 // @Description Recibe el token de operacion y con el set de datos previamente recolectado, basado en las distancias y mensajes que se reciben de cada satelite, se obtienen la posicion y el mensaje emitido.
 // @Param operation path string true "El token de operacion"
+// @Param accuracy query bool false "Incluye la estimacion de precision de la ubicacion (residuos, elipse de error y GDOP)"
+// @Param robust query bool false "Calcula la ubicacion en modo robusto, excluyendo los satelites con distancias inconsistentes (equivale a solver=robust)"
+// @Param solver query string false "Algoritmo de calculo de la ubicacion: auto (por defecto, configurable con OFQ_SOLVER), trilateration, leastsquares o robust"
+// @Param detail query bool false "Incluye el detalle de cada palabra del mensaje: satelites que la recibieron, coincidencias, confianza y posiciones sin recibir"
+// @Param normalize query string false "Normalizaciones al comparar las palabras de los mensajes, separadas por coma: case (mayusculas), nfc (Unicode) y punctuation (signos de puntuacion). Configurable con OFQ_MESSAGE_NORMALIZATION"
+// @Param minRatio query int false "Similitud minima (0 a 100, por distancia de edicion) para considerar iguales dos palabras, por defecto 100 (exactas). Configurable con OFQ_MESSAGE_MIN_RATIO"
+// @Param gapPlaceholder query string false "Texto con el que se muestran en el mensaje las palabras que ningun satelite recibio, por ejemplo [?]. Por defecto vacio, configurable con OFQ_GAP_PLACEHOLDER"
+// @Param suggest query bool false "Sugiere palabras para los huecos del mensaje segun el diccionario de frases configurado con OFQ_PHRASES_FILE. Las sugerencias no se agregan al mensaje"
+// @Param vote query bool false "Resuelve las palabras en conflicto entre satelites por mayoria de votos (ponderados por la confiabilidad de cada satelite), informando las posiciones en disputa"
+// @Param bestEffort query bool false "Devuelve siempre la ubicacion que mejor ajusta las distancias, con su calificacion (exact, good, degraded o rejected) en lugar de 404"
+// @Param significance query number false "Nivel de significancia del test chi-cuadrado de los residuos (por defecto 0.001, configurable con OFQ_SIGNIFICANCE)"
+// @Param relativeStdDev query number false "Desvio estandar de las distancias sin ruido conocido, relativo a la distancia (por defecto 0.001, configurable con OFQ_RELATIVE_STD_DEV)"
+// @Param degradedRatio query number false "Error cuadratico medio relativo maximo de una ubicacion degradada en modo best effort (por defecto 0.05, configurable con OFQ_DEGRADED_RATIO)"
+// @Param frame query string false "Sistema de referencia de la ubicacion: cartesian (por defecto), enu o geodetic (latitud, longitud y altitud WGS84)"
+// @Param origin query string false "Origen del sistema enu como '<latitud>,<longitud>[,<altitud>]', por defecto el origen de referencia (OFQ_ORIGIN)"
+// @Param ambiguous query bool false "Con el set de datos incompleto (solo dos satelites), devuelve las ubicaciones candidatas marcando el resultado como ambiguo"
 // @Produce json
 // @Failure 404 {object} model.ErrorResponse
 // @Success 200 {object} model.TopSecretResponse
@@ -248,7 +1097,15 @@ func TopSecretSplitGETHandler(c *gin.Context) {
 	}
 
 	// get dataset directly by operation
-	dataset := store.GetDatasetByKey(operation)
+	dataset := store.GetDatasetStore().GetDatasetByKey(operation)
+	if (dataset.Key == "" || dataset.Key != operation) && c.Query("ambiguous") == "true" {
+		// get the incomplete dataset, that is saved with the partial message in key
+		dataset = store.GetDatasetStore().GetDatasetByOperation(operation)
+		if len(dataset.Satellites) == 2 {
+			DoAmbiguousCalculationsAndResponse("TopSecretSplitGETHandler", dataset.Satellites, c)
+			return
+		}
+	}
 	if dataset.Key == "" || dataset.Key != operation {
 		resErr := model.ErrorResponse{Message: "Insufficient information"}
 		c.IndentedJSON(http.StatusNotFound, resErr)
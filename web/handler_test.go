@@ -2,21 +2,37 @@ package web_test
 
 import (
 	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
 	"encoding/json"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	test "github.com/mgironi/operation-fire-quasar/_test"
+	"github.com/mgironi/operation-fire-quasar/location"
+	"github.com/mgironi/operation-fire-quasar/message"
 	"github.com/mgironi/operation-fire-quasar/model"
+	"github.com/mgironi/operation-fire-quasar/security"
 	"github.com/mgironi/operation-fire-quasar/store"
 	"github.com/mgironi/operation-fire-quasar/web"
 )
 
+// The requests of the test files don't carry timestamp and nonce, so their replays are allowed (legacy clients).
+// The tests of the replays protection require them, see TestTopSecretHandlerNonces.
+func TestMain(m *testing.M) {
+	os.Setenv("OFQ_ALLOW_UNSIGNED_REPLAYS", "true")
+	os.Exit(m.Run())
+}
+
 func TestPingHandler(t *testing.T) {
 	rPath := "/ping"
 	router := gin.Default()
@@ -127,6 +143,732 @@ func TestTopSecretHandler(t *testing.T) {
 	}
 }
 
+func TestTopSecretHandlerWithAccuracy(t *testing.T) {
+	router := gin.Default()
+	router.POST("/topsecret/", web.TopSecretHandler)
+
+	jsonData := readJSONFile("../_test/topSecret_test1_request.json", t)
+	request, _ := http.NewRequest(http.MethodPost, "/topsecret/?accuracy=true", bytes.NewReader(jsonData))
+	gotRsp := httptest.NewRecorder()
+	router.ServeHTTP(gotRsp, request)
+
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusOK, t)
+
+	var got model.TopSecretResponse
+	unmarshalJSONWithFatal("Got response", gotRsp.Body.Bytes(), &got, t)
+
+	var want model.TopSecretResponse
+	unmarshalJSONWithFatal("Wanted response", readJSONFile("../_test/topSecret_test1_response.json", t), &want, t)
+	compareResponsesByStructure("HTTP response position", got.Position, want.Position, t)
+
+	if got.Accuracy == nil {
+		t.Fatalf("HTTP response accuracy block is missing.\n----got:\n%s", gotRsp.Body.String())
+	}
+	wantNames := []string{"kenobi", "skywalker", "sato"}
+	if len(got.Accuracy.Residuals) != len(wantNames) {
+		t.Fatalf("HTTP response accuracy residuals size is %d, want %d", len(got.Accuracy.Residuals), len(wantNames))
+	}
+	for i, residual := range got.Accuracy.Residuals {
+		if residual.Name != wantNames[i] {
+			t.Errorf("HTTP response accuracy residual %d name is '%s', want '%s'", i, residual.Name, wantNames[i])
+		}
+	}
+	if got.Accuracy.GDOP <= 0 {
+		t.Errorf("HTTP response accuracy GDOP is %f, want a positive value", got.Accuracy.GDOP)
+	}
+}
+
+func TestTopSecretHandlerRobust(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	os.Setenv(store.SATELITES_EXTRA_ENV, "rex_0,500;cody_-300,-600")
+	store.InitializeSatelitesInfo()
+	defer func() {
+		test.CleanSatelitesInfoEnvs()
+		store.InitializeSatelitesInfo()
+	}()
+
+	router := gin.Default()
+	router.POST("/topsecret/", web.TopSecretHandler)
+	jsonData := readJSONFile("../_test/topSecret_test6_request.json", t)
+
+	// without robust mode the faulty distance prevents the location calculation
+	request, _ := http.NewRequest(http.MethodPost, "/topsecret/", bytes.NewReader(jsonData))
+	gotRsp := httptest.NewRecorder()
+	router.ServeHTTP(gotRsp, request)
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusNotFound, t)
+
+	// with robust mode the faulty satellite is rejected
+	request, _ = http.NewRequest(http.MethodPost, "/topsecret/?robust=true&accuracy=true", bytes.NewReader(jsonData))
+	gotRsp = httptest.NewRecorder()
+	router.ServeHTTP(gotRsp, request)
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusOK, t)
+
+	var got model.TopSecretResponse
+	unmarshalJSONWithFatal("Got response", gotRsp.Body.Bytes(), &got, t)
+	compareResponsesByStructure("HTTP response rejected satellites", got.Rejected, []string{"sato"}, t)
+	if !test.AreFloatsEquals(math.Round(float64(got.Position.X)), -200) || !test.AreFloatsEquals(math.Round(float64(got.Position.Y)), 200) {
+		t.Errorf("HTTP response position is (%f, %f), want (-200, 200)", got.Position.X, got.Position.Y)
+	}
+	if got.Accuracy == nil || len(got.Accuracy.Residuals) != 4 {
+		t.Errorf("HTTP response accuracy residuals, want 4 (only not rejected satellites).\n----got:\n%s", gotRsp.Body.String())
+	}
+}
+
+func TestTopSecretSplitGETHandlerAmbiguous(t *testing.T) {
+	conn := test.InitRedisMockConnection()
+
+	operation := "456"
+	key := operation + ":este es  mensaje secreto"
+	dataset := model.Dataset{
+		Key:       key,
+		Operation: operation,
+		Satellites: []model.SatelliteInfoRequest{
+			{Name: "kenobi", Distance: 500, Message: []string{"este", "", "", "mensaje", ""}},
+			{Name: "skywalker", Distance: 424.2641, Message: []string{"", "es", "", "", "secreto"}},
+		},
+	}
+	cmdHGETALL := conn.Command("HGETALL", store.DATASET_HASH_KEY_PREFIX+operation).Expect(test.DatasetHashReply(dataset))
+
+	router := gin.Default()
+	router.GET("/topsecret_split/:operation", web.TopSecretSplitGETHandler)
+
+	// without ambiguous mode the incomplete dataset is insufficient
+	request, _ := http.NewRequest(http.MethodGet, "/topsecret_split/"+operation, strings.NewReader(""))
+	gotRsp := httptest.NewRecorder()
+	router.ServeHTTP(gotRsp, request)
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusNotFound, t)
+
+	// with ambiguous mode responses both candidates
+	request, _ = http.NewRequest(http.MethodGet, "/topsecret_split/"+operation+"?ambiguous=true", strings.NewReader(""))
+	gotRsp = httptest.NewRecorder()
+	router.ServeHTTP(gotRsp, request)
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusOK, t)
+
+	if conn.Stats(cmdHGETALL) != 3 {
+		t.Errorf("Error TestTopSecretSplitGETHandlerAmbiguous(), redis command HGETALL not used.")
+	}
+
+	var got model.TopSecretResponse
+	unmarshalJSONWithFatal("Got response", gotRsp.Body.Bytes(), &got, t)
+	if !got.Ambiguous || len(got.Candidates) != 2 {
+		t.Fatalf("HTTP response isn't ambiguous with 2 candidates.\n----got:\n%s", gotRsp.Body.String())
+	}
+	foundReal := false
+	for _, candidate := range got.Candidates {
+		if test.AreFloatsEquals(math.Round(float64(candidate.X)), -200) && test.AreFloatsEquals(math.Round(float64(candidate.Y)), 200) {
+			foundReal = true
+		}
+	}
+	if !foundReal {
+		t.Errorf("HTTP response candidates %v, want (-200, 200) between them", got.Candidates)
+	}
+	if got.Message != "este es  mensaje secreto" {
+		t.Errorf("HTTP response message is '%s', want '%s'", got.Message, "este es  mensaje secreto")
+	}
+}
+
+func TestTopSecretHandlerFrames(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	store.InitializeSatelitesInfo()
+	defer func() {
+		test.CleanSatelitesInfoEnvs()
+		store.InitializeSatelitesInfo()
+	}()
+
+	router := gin.Default()
+	router.POST("/topsecret/", web.TopSecretHandler)
+	jsonData := readJSONFile("../_test/topSecret_test1_request.json", t)
+	doRequest := func(url string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(jsonData))
+		gotRsp := httptest.NewRecorder()
+		router.ServeHTTP(gotRsp, request)
+		return gotRsp
+	}
+
+	// without reference origin the geodetic frame can't be used
+	compareValuesWithError("HTTP response status code", doRequest("/topsecret/?frame=geodetic").Code, http.StatusBadRequest, t)
+	compareValuesWithError("HTTP response status code", doRequest("/topsecret/?frame=unknown").Code, http.StatusBadRequest, t)
+
+	os.Setenv(store.REFERENCE_ORIGIN_ENV, "-34.6037,-58.3816,25")
+	store.InitializeSatelitesInfo()
+	origin, _ := store.GetReferenceOrigin()
+
+	gotRsp := doRequest("/topsecret/?frame=geodetic")
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusOK, t)
+	var got model.TopSecretResponse
+	unmarshalJSONWithFatal("Got response", gotRsp.Body.Bytes(), &got, t)
+	if got.Position.Geodetic == nil || got.Frame != "geodetic" {
+		t.Fatalf("HTTP response without geodetic position.\n----got:\n%s", gotRsp.Body.String())
+	}
+	want := model.ENUToGeodetic(model.Point{X: float64(got.Position.X), Y: float64(got.Position.Y)}, origin)
+	if math.Abs(got.Position.Geodetic.Latitude-want.Latitude) > 1e-9 || math.Abs(got.Position.Geodetic.Longitude-want.Longitude) > 1e-9 {
+		t.Errorf("HTTP response geodetic position is %v, want %s", *got.Position.Geodetic, want)
+	}
+
+	// ENU frame at the reference origin is the local cartesian frame
+	gotRsp = doRequest("/topsecret/?frame=enu")
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusOK, t)
+	got = model.TopSecretResponse{}
+	unmarshalJSONWithFatal("Got response", gotRsp.Body.Bytes(), &got, t)
+	if !test.AreFloatsEquals(math.Round(float64(got.Position.X)), -200) || !test.AreFloatsEquals(math.Round(float64(got.Position.Y)), 200) || got.Position.Z == nil {
+		t.Errorf("HTTP response ENU position is (%f, %f), want (-200, 200) with z", got.Position.X, got.Position.Y)
+	}
+}
+
+func TestTopSecretTDOAHandler(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	store.InitializeSatelitesInfo()
+
+	router := gin.Default()
+	router.POST("/topsecret_tdoa/", web.TopSecretTDOAHandler)
+	jsonData := readJSONFile("../_test/topSecretTDOA_test1_request.json", t)
+
+	request, _ := http.NewRequest(http.MethodPost, "/topsecret_tdoa/", bytes.NewReader(jsonData))
+	gotRsp := httptest.NewRecorder()
+	router.ServeHTTP(gotRsp, request)
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusOK, t)
+
+	// with 3 satellites the timestamps fit two locations
+	var got model.TopSecretResponse
+	unmarshalJSONWithFatal("Got response", gotRsp.Body.Bytes(), &got, t)
+	if !got.Ambiguous || len(got.Candidates) != 2 {
+		t.Fatalf("HTTP response ambiguous is %t with candidates %v, want 2 candidates", got.Ambiguous, got.Candidates)
+	}
+	found := false
+	for _, candidate := range got.Candidates {
+		found = found || (test.AreFloatsEquals(math.Round(float64(candidate.X)), -200) && test.AreFloatsEquals(math.Round(float64(candidate.Y)), 200))
+	}
+	if !found || got.Position != got.Candidates[0] {
+		t.Errorf("HTTP response position is (%f, %f) with candidates %v, want (-200, 200) as candidate", got.Position.X, got.Position.Y, got.Candidates)
+	}
+	if got.Message != "este es un mensaje secreto" {
+		t.Errorf("HTTP response message is '%s', want '%s'", got.Message, "este es un mensaje secreto")
+	}
+
+	// unknown satellite
+	request, _ = http.NewRequest(http.MethodPost, "/topsecret_tdoa/", strings.NewReader(`{"satellites":[{"name":"kenobi","timestamp":1},{"name":"skywalker","timestamp":1},{"name":"other","timestamp":1}]}`))
+	gotRsp = httptest.NewRecorder()
+	router.ServeHTTP(gotRsp, request)
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusBadRequest, t)
+
+	// the timing reports can't be signed nor carry nonce, they are rejected if the signatures or the nonces are required
+	defer func() {
+		os.Unsetenv("OFQ_REQUIRE_SIGNATURES")
+		os.Setenv("OFQ_ALLOW_UNSIGNED_REPLAYS", "true")
+	}()
+	os.Setenv("OFQ_REQUIRE_SIGNATURES", "true")
+	gotRsp = httptest.NewRecorder()
+	router.ServeHTTP(gotRsp, httptest.NewRequest(http.MethodPost, "/topsecret_tdoa/", bytes.NewReader(jsonData)))
+	compareValuesWithError("HTTP response status code signatures required", gotRsp.Code, http.StatusUnauthorized, t)
+	os.Unsetenv("OFQ_REQUIRE_SIGNATURES")
+	os.Unsetenv("OFQ_ALLOW_UNSIGNED_REPLAYS")
+	gotRsp = httptest.NewRecorder()
+	router.ServeHTTP(gotRsp, httptest.NewRequest(http.MethodPost, "/topsecret_tdoa/", bytes.NewReader(jsonData)))
+	compareValuesWithError("HTTP response status code nonces required", gotRsp.Code, http.StatusUnauthorized, t)
+}
+
+// Tests the TDOA location in the space, with satellites at different altitudes
+func TestTopSecretTDOAHandlerSpatial(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	defer func() {
+		test.CleanSatelitesInfoEnvs()
+		store.InitializeSatelitesInfo()
+	}()
+	os.Setenv(store.SATELITES_EXTRA_ENV, "rex_0,500,1200;cody_-300,-600,800;echo_600,-500,400")
+	store.InitializeSatelitesInfo()
+
+	want := model.Point{X: -200, Y: 200, Z: 300}
+	requestData := model.TopSecretTDOARequest{PropagationSpeed: 1000}
+	for i, pt := range store.GetKnownReferenceCoordinates() {
+		requestData.Satellites = append(requestData.Satellites, model.SatelliteTimingRequest{Name: store.GetSatellitesInfo()[i].Name, Timestamp: 1 + pt.DistanceToPoint(want)/1000})
+	}
+	jsonData, _ := json.Marshal(requestData)
+
+	router := gin.Default()
+	router.POST("/topsecret_tdoa/", web.TopSecretTDOAHandler)
+	request, _ := http.NewRequest(http.MethodPost, "/topsecret_tdoa/", bytes.NewReader(jsonData))
+	gotRsp := httptest.NewRecorder()
+	router.ServeHTTP(gotRsp, request)
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusOK, t)
+
+	var got model.TopSecretResponse
+	unmarshalJSONWithFatal("Got response", gotRsp.Body.Bytes(), &got, t)
+	if got.Ambiguous || got.Position.Z == nil {
+		t.Fatalf("HTTP response ambiguous is %t and z is %v, want a location in the space", got.Ambiguous, got.Position.Z)
+	}
+	if !test.AreFloatsEquals(math.Round(float64(got.Position.X)), -200) || !test.AreFloatsEquals(math.Round(float64(got.Position.Y)), 200) || !test.AreFloatsEquals(math.Round(float64(*got.Position.Z)), 300) {
+		t.Errorf("HTTP response position is (%f, %f, %f), want (-200, 200, 300)", got.Position.X, got.Position.Y, *got.Position.Z)
+	}
+}
+
+func TestTopSecretHandlerSolver(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	store.InitializeSatelitesInfo()
+
+	router := gin.Default()
+	router.POST("/topsecret/", web.TopSecretHandler)
+	jsonData := readJSONFile("../_test/topSecret_test1_request.json", t)
+	doRequest := func(url string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest(http.MethodPost, url, bytes.NewReader(jsonData))
+		gotRsp := httptest.NewRecorder()
+		router.ServeHTTP(gotRsp, request)
+		return gotRsp
+	}
+
+	for _, solverName := range []string{"trilateration", "leastsquares", "robust"} {
+		gotRsp := doRequest("/topsecret/?solver=" + solverName)
+		compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusOK, t)
+
+		var got model.TopSecretResponse
+		unmarshalJSONWithFatal("Got response", gotRsp.Body.Bytes(), &got, t)
+		if got.Solver != solverName {
+			t.Errorf("HTTP response solver is '%s', want '%s'", got.Solver, solverName)
+		}
+		if !test.AreFloatsEquals(math.Round(float64(got.Position.X)), -200) || !test.AreFloatsEquals(math.Round(float64(got.Position.Y)), 200) {
+			t.Errorf("HTTP response position with solver %s is (%f, %f), want (-200, 200)", solverName, got.Position.X, got.Position.Y)
+		}
+	}
+
+	// unknown solver and robust mode with another solver
+	compareValuesWithError("HTTP response status code", doRequest("/topsecret/?solver=unknown").Code, http.StatusBadRequest, t)
+	compareValuesWithError("HTTP response status code", doRequest("/topsecret/?solver=trilateration&robust=true").Code, http.StatusBadRequest, t)
+}
+
+func TestTopSecretHandlerWeighted(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	os.Setenv(store.SATELITES_EXTRA_ENV, "rex_0,500;cody_-300,-600")
+	store.InitializeSatelitesInfo()
+	defer func() {
+		test.CleanSatelitesInfoEnvs()
+		store.InitializeSatelitesInfo()
+	}()
+
+	router := gin.Default()
+	router.POST("/topsecret/", web.TopSecretHandler)
+	doRequest := func(satoQuality string) *httptest.ResponseRecorder {
+		body := strings.Replace(string(readJSONFile("../_test/topSecret_test6_request.json", t)), `"distance": 850,`, `"distance": 850, `+satoQuality, 1)
+		request, _ := http.NewRequest(http.MethodPost, "/topsecret/", strings.NewReader(body))
+		gotRsp := httptest.NewRecorder()
+		router.ServeHTTP(gotRsp, request)
+		return gotRsp
+	}
+
+	// with the noise of the faulty distance the location is accepted, and the faulty distance counts less
+	for _, satoQuality := range []string{`"stdDev": 200,`, `"snr": -26,`} {
+		gotRsp := doRequest(satoQuality)
+		compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusOK, t)
+
+		var got model.TopSecretResponse
+		unmarshalJSONWithFatal("Got response", gotRsp.Body.Bytes(), &got, t)
+		if math.Abs(float64(got.Position.X)-(-200)) > 1 || math.Abs(float64(got.Position.Y)-200) > 1 {
+			t.Errorf("HTTP response position with %s is (%f, %f), want (-200, 200) +/- 1", satoQuality, got.Position.X, got.Position.Y)
+		}
+	}
+
+	// invalid standard deviation
+	compareValuesWithError("HTTP response status code", doRequest(`"stdDev": -1,`).Code, http.StatusBadRequest, t)
+}
+
+func TestTopSecretHandlerBestEffort(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	os.Setenv(store.SATELITES_EXTRA_ENV, "rex_0,500;cody_-300,-600")
+	store.InitializeSatelitesInfo()
+	defer func() {
+		test.CleanSatelitesInfoEnvs()
+		store.InitializeSatelitesInfo()
+	}()
+
+	router := gin.Default()
+	router.POST("/topsecret/", web.TopSecretHandler)
+	doRequest := func(query string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest(http.MethodPost, "/topsecret/"+query, bytes.NewBuffer(readJSONFile("../_test/topSecret_test6_request.json", t)))
+		gotRsp := httptest.NewRecorder()
+		router.ServeHTTP(gotRsp, request)
+		return gotRsp
+	}
+
+	// the faulty distance fails the location
+	compareValuesWithError("HTTP response status code", doRequest("").Code, http.StatusNotFound, t)
+
+	// in best effort mode the closest fit location is responded, even if rejected
+	gotRsp := doRequest("?bestEffort=true")
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusOK, t)
+	var got model.TopSecretResponse
+	unmarshalJSONWithFatal("Got response", gotRsp.Body.Bytes(), &got, t)
+	if got.Quality == nil || got.Quality.Grade != string(location.GRADE_REJECTED) {
+		t.Errorf("HTTP response quality is %+v, want grade %s", got.Quality, location.GRADE_REJECTED)
+	}
+
+	// with a bigger degraded ratio the closest fit location is degraded
+	gotRsp = doRequest("?bestEffort=true&degradedRatio=0.1")
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusOK, t)
+	got = model.TopSecretResponse{}
+	unmarshalJSONWithFatal("Got response", gotRsp.Body.Bytes(), &got, t)
+	if got.Quality == nil || got.Quality.Grade != string(location.GRADE_DEGRADED) {
+		t.Errorf("HTTP response quality is %+v, want grade %s", got.Quality, location.GRADE_DEGRADED)
+	}
+
+	// with a looser noise the location is accepted
+	compareValuesWithError("HTTP response status code", doRequest("?relativeStdDev=0.2").Code, http.StatusOK, t)
+
+	// invalid thresholds
+	compareValuesWithError("HTTP response status code", doRequest("?significance=2").Code, http.StatusBadRequest, t)
+	compareValuesWithError("HTTP response status code", doRequest("?relativeStdDev=abc").Code, http.StatusBadRequest, t)
+}
+
+func TestTopSecretHandlerVote(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	store.InitializeSatelitesInfo()
+
+	router := gin.Default()
+	router.POST("/topsecret/", web.TopSecretHandler)
+	doRequest := func(body string) (gotRsp *httptest.ResponseRecorder, got model.TopSecretResponse) {
+		request, _ := http.NewRequest(http.MethodPost, "/topsecret/?vote=true", strings.NewReader(body))
+		gotRsp = httptest.NewRecorder()
+		router.ServeHTTP(gotRsp, request)
+		unmarshalJSONWithFatal("Got response", gotRsp.Body.Bytes(), &got, t)
+		return gotRsp, got
+	}
+
+	// the mismatching words of test3 are resolved by vote, on ties wins the first satellite
+	body := string(readJSONFile("../_test/topSecret_test3_request.json", t))
+	gotRsp, got := doRequest(body)
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusOK, t)
+	compareResponsesByStructure("HTTP response message", got.Message, "este es un  secreto", t)
+	wantContested := []model.ContestedWordResponse{{Position: 1, Chosen: model.WordVotesResponse{Word: "es", Votes: 1}, Alternatives: []model.WordVotesResponse{{Word: "otra", Votes: 1}}}}
+	compareResponsesByStructure("HTTP response contested", got.Contested, wantContested, t)
+
+	// the more reliable satellite wins
+	gotRsp, got = doRequest(strings.Replace(body, `"distance": 424.26,`, `"distance": 424.26, "reliability": 2,`, 1))
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusOK, t)
+	compareResponsesByStructure("HTTP response message", got.Message, "este otra un  secreto", t)
+}
+
+func TestTopSecretHandlerDetail(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	store.InitializeSatelitesInfo()
+
+	router := gin.Default()
+	router.POST("/topsecret/", web.TopSecretHandler)
+	request, _ := http.NewRequest(http.MethodPost, "/topsecret/?detail=true", bytes.NewBuffer(readJSONFile("../_test/topSecret_test1_request.json", t)))
+	gotRsp := httptest.NewRecorder()
+	router.ServeHTTP(gotRsp, request)
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusOK, t)
+
+	var got model.TopSecretResponse
+	unmarshalJSONWithFatal("Got response", gotRsp.Body.Bytes(), &got, t)
+	compareValuesWithError("HTTP response words count", len(got.Words), 5, t)
+	compareResponsesByStructure("HTTP response first word", got.Words[0], model.WordDetailResponse{Position: 0, Word: "este", Satellites: []string{"kenobi", "sato"}, Agreement: 2, Confidence: 2.0 / 3}, t)
+	compareResponsesByStructure("HTTP response last word", got.Words[4], model.WordDetailResponse{Position: 4, Word: "secreto", Satellites: []string{"skywalker"}, Agreement: 1, Confidence: 1.0 / 3}, t)
+}
+
+func TestTopSecretHandlerMatchOptions(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	store.InitializeSatelitesInfo()
+
+	router := gin.Default()
+	router.POST("/topsecret/", web.TopSecretHandler)
+	doRequest := func(query string) (gotRsp *httptest.ResponseRecorder, got model.TopSecretResponse) {
+		body := string(readJSONFile("../_test/topSecret_test1_request.json", t))
+		body = strings.Replace(body, `["este","","un","",""]`, `["Este","","un","mensaje.",""]`, 1)
+		request, _ := http.NewRequest(http.MethodPost, "/topsecret/"+query, strings.NewReader(body))
+		gotRsp = httptest.NewRecorder()
+		router.ServeHTTP(gotRsp, request)
+		unmarshalJSONWithFatal("Got response", gotRsp.Body.Bytes(), &got, t)
+		return gotRsp, got
+	}
+
+	// exact match by default
+	gotRsp, _ := doRequest("")
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusNotFound, t)
+
+	// the normalized words match, on ties wins the spelling of the first satellite
+	gotRsp, got := doRequest("?normalize=case,punctuation")
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusOK, t)
+	compareResponsesByStructure("HTTP response message", got.Message, "este es un mensaje secreto", t)
+
+	// invalid options
+	gotRsp, _ = doRequest("?normalize=accents")
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusBadRequest, t)
+	gotRsp, _ = doRequest("?minRatio=high")
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusBadRequest, t)
+}
+
+func TestTopSecretHandlerGaps(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	store.InitializeSatelitesInfo()
+
+	router := gin.Default()
+	router.POST("/topsecret/", web.TopSecretHandler)
+	body := string(readJSONFile("../_test/topSecret_test1_request.json", t))
+	body = strings.Replace(body, `["este","","un","",""]`, `["este","","","",""]`, 1)
+	request, _ := http.NewRequest(http.MethodPost, "/topsecret/?gapPlaceholder=[?]", strings.NewReader(body))
+	gotRsp := httptest.NewRecorder()
+	router.ServeHTTP(gotRsp, request)
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusOK, t)
+
+	var got model.TopSecretResponse
+	unmarshalJSONWithFatal("Got response", gotRsp.Body.Bytes(), &got, t)
+	compareResponsesByStructure("HTTP response message", got.Message, "este es [?] mensaje secreto", t)
+	compareResponsesByStructure("HTTP response gaps", got.Gaps, []int{2}, t)
+	compareResponsesByStructure("HTTP response completeness", got.Completeness, 0.8, t)
+}
+
+func TestTopSecretHandlerSuggest(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	store.InitializeSatelitesInfo()
+	os.Setenv("OFQ_PHRASES_FILE", "../_test/phrases.txt")
+	defer os.Unsetenv("OFQ_PHRASES_FILE")
+	message.InitializePhraseModel()
+	defer message.InitializePhraseModel()
+
+	router := gin.Default()
+	router.POST("/topsecret/", web.TopSecretHandler)
+	body := string(readJSONFile("../_test/topSecret_test1_request.json", t))
+	body = strings.Replace(body, `["este","","un","",""]`, `["este","","","",""]`, 1)
+	request, _ := http.NewRequest(http.MethodPost, "/topsecret/?suggest=true", strings.NewReader(body))
+	gotRsp := httptest.NewRecorder()
+	router.ServeHTTP(gotRsp, request)
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusOK, t)
+
+	// the suggestions aren't merged into the message
+	var got model.TopSecretResponse
+	unmarshalJSONWithFatal("Got response", gotRsp.Body.Bytes(), &got, t)
+	compareResponsesByStructure("HTTP response message", got.Message, "este es  mensaje secreto", t)
+	wantSuggestions := []model.GapSuggestionsResponse{{Position: 2, Candidates: []model.SuggestionResponse{{Word: "un", Score: 1}}}}
+	compareResponsesByStructure("HTTP response suggestions", got.Suggestions, wantSuggestions, t)
+}
+
+func TestTopSecretHandlerSignatures(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	publicKey, privateKey, _ := ed25519.GenerateKey(nil)
+	os.Setenv(store.SATELITES_KEYS_ENV, "kenobi=hmac:"+base64.StdEncoding.EncodeToString([]byte("secret"))+";sato=ed25519:"+base64.StdEncoding.EncodeToString(publicKey))
+	store.InitializeSatelitesInfo()
+	now := time.Unix(1700000000, 0)
+	web.GetCurrentTime = func() time.Time { return now }
+	defer func() {
+		test.CleanSatelitesInfoEnvs()
+		os.Unsetenv("OFQ_REQUIRE_SIGNATURES")
+		store.InitializeSatelitesInfo()
+		web.GetCurrentTime = time.Now
+	}()
+
+	// the nonces are new the first time, then they are replays
+	conn := test.InitRedisMockConnection()
+	conn.Command("SET", "nonce:kenobi:n-kenobi", 1, "NX", "EX", 600).Expect("OK").Expect(nil)
+	conn.Command("SET", "nonce:sato:n-sato", 1, "NX", "EX", 600).Expect("OK").Expect(nil)
+
+	router := gin.Default()
+	router.POST("/topsecret/", web.TopSecretHandler)
+	router.POST("/topsecret_split/:operation", web.TopSecretSplitPOSTHandler)
+	doRequest := func(url string, requestData interface{}) (status int) {
+		body, _ := json.Marshal(requestData)
+		request, _ := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(body))
+		gotRsp := httptest.NewRecorder()
+		router.ServeHTTP(gotRsp, request)
+		return gotRsp.Code
+	}
+	sign := func(satelliteData *model.SatelliteInfoRequest, algorithm string, signingKey []byte) {
+		satelliteData.Timestamp = now.Unix()
+		satelliteData.Nonce = "n-" + satelliteData.Name
+		quality := security.ReportQuality{StdDev: satelliteData.StdDev, SNR: satelliteData.SNR, Reliability: satelliteData.Reliability}
+		payload := security.SignedPayload(satelliteData.Name, satelliteData.Distance, satelliteData.Message, quality, satelliteData.Timestamp, satelliteData.Nonce)
+		satelliteData.Signature, _ = security.Sign(algorithm, signingKey, payload)
+	}
+
+	var requestData model.TopSecretRequest
+	unmarshalJSONWithFatal("Request", readJSONFile("../_test/topSecret_test1_request.json", t), &requestData, t)
+
+	// unsigned reports of satellites with verification key
+	compareValuesWithError("HTTP response status code unsigned", doRequest("/topsecret/", requestData), http.StatusUnauthorized, t)
+	compareValuesWithError("HTTP split response status code unsigned", doRequest("/topsecret_split/123", requestData.Satellites[0]), http.StatusUnauthorized, t)
+
+	// signed reports, skywalker hasn't verification key
+	sign(&requestData.Satellites[0], security.ALGORITHM_HMAC, []byte("secret"))
+	sign(&requestData.Satellites[2], security.ALGORITHM_ED25519, privateKey)
+	compareValuesWithError("HTTP response status code signed", doRequest("/topsecret/", requestData), http.StatusOK, t)
+
+	// replayed reports
+	compareValuesWithError("HTTP response status code replayed", doRequest("/topsecret/", requestData), http.StatusConflict, t)
+
+	// tampered report
+	requestData.Satellites[2].Distance = 100
+	compareValuesWithError("HTTP response status code tampered", doRequest("/topsecret/", requestData), http.StatusUnauthorized, t)
+	requestData.Satellites[2].Distance = 707.10
+
+	// tampered report quality, it's signed too
+	snr := float32(40)
+	for _, tamper := range []func(satelliteData *model.SatelliteInfoRequest){
+		func(satelliteData *model.SatelliteInfoRequest) { satelliteData.StdDev = 0.01 },
+		func(satelliteData *model.SatelliteInfoRequest) { satelliteData.SNR = &snr },
+		func(satelliteData *model.SatelliteInfoRequest) { satelliteData.Reliability = 5 },
+	} {
+		tamperedData := model.TopSecretRequest{Satellites: append([]model.SatelliteInfoRequest{}, requestData.Satellites...)}
+		tamper(&tamperedData.Satellites[0])
+		compareValuesWithError("HTTP response status code tampered quality", doRequest("/topsecret/", tamperedData), http.StatusUnauthorized, t)
+	}
+
+	// stale reports, out of the default window
+	now = now.Add(301 * time.Second)
+	compareValuesWithError("HTTP response status code stale", doRequest("/topsecret/", requestData), http.StatusUnauthorized, t)
+	now = now.Add(-301 * time.Second)
+
+	// required signatures, skywalker can't be verified
+	os.Setenv("OFQ_REQUIRE_SIGNATURES", "true")
+	compareValuesWithError("HTTP response status code required", doRequest("/topsecret/", requestData), http.StatusUnauthorized, t)
+}
+
+// Tests the nonces of every report are required when the replay window is configured, and they aren't used up by
+// the failed requests
+func TestTopSecretHandlerNonces(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	store.InitializeSatelitesInfo()
+	store.SetDatasetStore(store.NewMemoryDatasetStore())
+	// every report must carry timestamp and nonce by default
+	os.Unsetenv("OFQ_ALLOW_UNSIGNED_REPLAYS")
+	now := time.Unix(1700000000, 0)
+	web.GetCurrentTime = func() time.Time { return now }
+	store.GetNewOperationUUID = func() string {
+		return "456"
+	}
+	defer func() {
+		store.SetDatasetStore(nil)
+		os.Setenv("OFQ_ALLOW_UNSIGNED_REPLAYS", "true")
+		web.GetCurrentTime = time.Now
+	}()
+
+	router := gin.Default()
+	router.POST("/topsecret/", web.TopSecretHandler)
+	router.POST("/topsecret_split/:operation", web.TopSecretSplitPOSTHandler)
+	doRequest := func(url string, requestData interface{}) (status int) {
+		body, _ := json.Marshal(requestData)
+		request, _ := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(body))
+		gotRsp := httptest.NewRecorder()
+		router.ServeHTTP(gotRsp, request)
+		return gotRsp.Code
+	}
+	var requestData model.TopSecretRequest
+	unmarshalJSONWithFatal("Request", readJSONFile("../_test/topSecret_test1_request.json", t), &requestData, t)
+
+	// unsigned reports without timestamp and nonce
+	compareValuesWithError("HTTP response status code without nonces", doRequest("/topsecret/", requestData), http.StatusUnauthorized, t)
+
+	// unknown satellite
+	unknownData := model.TopSecretRequest{Satellites: append([]model.SatelliteInfoRequest(nil), requestData.Satellites...)}
+	unknownData.Satellites[2].Name = "other"
+	os.Setenv("OFQ_ALLOW_UNSIGNED_REPLAYS", "true")
+	compareValuesWithError("HTTP response status code unknown satellite", doRequest("/topsecret/", unknownData), http.StatusBadRequest, t)
+	os.Unsetenv("OFQ_ALLOW_UNSIGNED_REPLAYS")
+
+	for i := range requestData.Satellites {
+		requestData.Satellites[i].Timestamp = now.Unix()
+		requestData.Satellites[i].Nonce = "n-" + requestData.Satellites[i].Name
+	}
+
+	// the location can't be calculated, the nonces are released
+	requestData.Satellites[2].Distance = 5000
+	compareValuesWithError("HTTP response status code failed", doRequest("/topsecret/", requestData), http.StatusNotFound, t)
+	requestData.Satellites[2].Distance = 707.10
+
+	// a replayed report releases the nonces of the other ones
+	sato := requestData.Satellites[2]
+	compareValuesWithError("HTTP split response status code", doRequest("/topsecret_split/456", sato), http.StatusOK, t)
+	compareValuesWithError("HTTP response status code replayed sato", doRequest("/topsecret/", requestData), http.StatusConflict, t)
+	requestData.Satellites[2].Nonce = "n-sato-2"
+	compareValuesWithError("HTTP response status code", doRequest("/topsecret/", requestData), http.StatusOK, t)
+	compareValuesWithError("HTTP response status code replayed", doRequest("/topsecret/", requestData), http.StatusConflict, t)
+
+	// the split report that isn't collected doesn't use up its nonce
+	kenobi := requestData.Satellites[0]
+	kenobi.Nonce = "n-kenobi-2"
+	compareValuesWithError("HTTP split response status code invalid options", doRequest("/topsecret_split/456?normalize=accents", kenobi), http.StatusBadRequest, t)
+	compareValuesWithError("HTTP split response status code collected", doRequest("/topsecret_split/456", kenobi), http.StatusOK, t)
+	compareValuesWithError("HTTP split response status code replayed", doRequest("/topsecret_split/456", kenobi), http.StatusConflict, t)
+}
+
+func TestTopSecretSplitHandlerMemoryStore(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	store.InitializeSatelitesInfo()
+	store.SetDatasetStore(store.NewMemoryDatasetStore())
+	defer store.SetDatasetStore(nil)
+	store.GetNewOperationUUID = func() string {
+		return "456"
+	}
+
+	router := gin.Default()
+	router.POST("/topsecret_split/:operation", web.TopSecretSplitPOSTHandler)
+	router.GET("/topsecret_split/:operation", web.TopSecretSplitGETHandler)
+	doRequest := func(method string, url string, body []byte) (gotRsp *httptest.ResponseRecorder) {
+		request, _ := http.NewRequest(method, url, bytes.NewBuffer(body))
+		gotRsp = httptest.NewRecorder()
+		router.ServeHTTP(gotRsp, request)
+		return gotRsp
+	}
+
+	// the datasets of the operation are collected without Redis
+	for i, rqFilename := range []string{"POST1", "POST2", "POST3"} {
+		gotRsp := doRequest(http.MethodPost, "/topsecret_split/456", readJSONFile("../_test/topSecretSplit_test1-"+rqFilename+"_request.json", t))
+		compareValuesWithError("HTTP POST response status code", gotRsp.Code, http.StatusOK, t)
+		var got model.TopSecretSplitPOSTResponse
+		unmarshalJSONWithFatal("Got response", gotRsp.Body.Bytes(), &got, t)
+		compareResponsesByStructure("HTTP POST response operation", got.Operation, "456", t)
+
+		// the dataset is complete with the last satellite data
+		wantStatusCode := http.StatusNotFound
+		if i == 2 {
+			wantStatusCode = http.StatusOK
+		}
+		compareValuesWithError("HTTP GET response status code", doRequest(http.MethodGet, "/topsecret_split/456", nil).Code, wantStatusCode, t)
+	}
+
+	var got, want model.TopSecretResponse
+	unmarshalJSONWithFatal("Got response", doRequest(http.MethodGet, "/topsecret_split/456", nil).Body.Bytes(), &got, t)
+	unmarshalJSONWithFatal("Want response", readJSONFile("../_test/topSecretSplit_test1-GET3_response.json", t), &want, t)
+	compareResponsesByStructure("HTTP GET response", got, want, t)
+}
+
+func TestTopSecretSplitHandlerConcurrentReports(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	store.InitializeSatelitesInfo()
+	store.SetDatasetStore(store.NewMemoryDatasetStore())
+	defer store.SetDatasetStore(nil)
+	store.GetNewOperationUUID = func() string {
+		return "456"
+	}
+
+	router := gin.Default()
+	router.POST("/topsecret_split/:operation", web.TopSecretSplitPOSTHandler)
+	router.GET("/topsecret_split/:operation", web.TopSecretSplitGETHandler)
+	doRequest := func(method string, url string, body []byte) (gotRsp *httptest.ResponseRecorder) {
+		request, _ := http.NewRequest(method, url, bytes.NewBuffer(body))
+		gotRsp = httptest.NewRecorder()
+		router.ServeHTTP(gotRsp, request)
+		return gotRsp
+	}
+	gotRsp := doRequest(http.MethodPost, "/topsecret_split/456", readJSONFile("../_test/topSecretSplit_test1-POST1_request.json", t))
+	compareValuesWithError("HTTP POST response status code", gotRsp.Code, http.StatusOK, t)
+
+	// the other satellites report at the same time, none of them is lost
+	var wg sync.WaitGroup
+	statusCodes := make([]int, 2)
+	for i, rqFilename := range []string{"POST2", "POST3"} {
+		wg.Add(1)
+		go func(i int, body []byte) {
+			defer wg.Done()
+			statusCodes[i] = doRequest(http.MethodPost, "/topsecret_split/456", body).Code
+		}(i, readJSONFile("../_test/topSecretSplit_test1-"+rqFilename+"_request.json", t))
+	}
+	wg.Wait()
+	for _, statusCode := range statusCodes {
+		compareValuesWithError("HTTP POST response status code", statusCode, http.StatusOK, t)
+	}
+
+	var got, want model.TopSecretResponse
+	unmarshalJSONWithFatal("Got response", doRequest(http.MethodGet, "/topsecret_split/456", nil).Body.Bytes(), &got, t)
+	unmarshalJSONWithFatal("Want response", readJSONFile("../_test/topSecretSplit_test1-GET3_response.json", t), &want, t)
+	compareResponsesByStructure("HTTP GET response", got, want, t)
+}
+
 type tssArgs struct {
 	routerPath string
 	url        string
@@ -194,16 +936,19 @@ func TestTopSecretSplitHandler(t *testing.T) {
 		Key:        wantKey,
 		Operation:  operation,
 		Satellites: []model.SatelliteInfoRequest{dataValue},
+		Version:    1,
 	}
 
-	rslScan := make([]interface{}, 2)
-	rslScan[0] = "0"
-	rslScan[1] = []interface{}{}
-	cmdSCAN1 := conn.Command("SCAN", "0", "MATCH", "*:este * * mensaje *").Expect(rslScan)
-	cmdSCAN2 := conn.Command("SCAN", "0", "MATCH", "*").Expect(rslScan)
+	hashKey := store.DATASET_HASH_KEY_PREFIX + operation
+	// no dataset shares words with the message
+	cmdSINTER := conn.Command("SINTER", "datasets:word:0:este", "datasets:word:3:mensaje").Expect([]interface{}{})
+	cmdSMEMBERS := conn.GenericCommand("SMEMBERS").Expect([]interface{}{})
 
-	wantMsl, _ := json.Marshal(want)
-	cmdSET := conn.Command("SET", wantKey, wantMsl, "NX").Expect("OK")
+	conn.Command("WATCH", hashKey).Expect("OK")
+	conn.Command("EXISTS", hashKey).Expect(int64(0))
+	conn.Command("MULTI").Expect("OK")
+	cmdSET := test.MockDatasetWrite(conn, want)
+	conn.Command("EXEC").Expect([]interface{}{int64(4), int64(1)})
 
 	tPOST.name = baseTestName + "-POST1"
 	tPOST.args.rqFilename = "../_test/topSecretSplit_test1-POST1_request.json"
@@ -214,15 +959,20 @@ func TestTopSecretSplitHandler(t *testing.T) {
 		t.Fatalf("Error in test %s. Operation token is empty.", tPOST.name)
 	}
 
-	if conn.Stats(cmdSCAN1) != 1 && conn.Stats(cmdSCAN2) != 1 {
-		t.Errorf("Error TestTopSecretSplitHandler(), redis command SCAN not used.")
+	if conn.Stats(cmdSINTER) != 1 || conn.Stats(cmdSMEMBERS) != 2 {
+		t.Errorf("Error TestTopSecretSplitHandler(), redis commands SINTER and SMEMBERS not used.")
+	}
+
+	if gotP1.MatchScore != 0 {
+		t.Errorf("Error TestTopSecretSplitHandler(), match score of a new operation: %d", gotP1.MatchScore)
 	}
 
 	if conn.Stats(cmdSET) != 1 {
-		t.Errorf("Error TestTopSecretSplitHandler(), redis command SET not used.")
+		t.Errorf("Error TestTopSecretSplitHandler(), redis command HSET not used.")
 	}
 
-	cmdGET1GET := conn.Command("GET", operation).Expect("")
+	// the operation hash has the incomplete dataset
+	cmdHGETALL := conn.Command("HGETALL", hashKey).Expect(test.DatasetHashReply(want))
 
 	tGET.name = baseTestName + "-GET1"
 	tGET.args.routerPath = basepathGET + ":operation"
@@ -232,29 +982,20 @@ func TestTopSecretSplitHandler(t *testing.T) {
 	wantG1 := model.ErrorResponse{}
 	runAsIt(tGET, &gotG1, &wantG1, t)
 
-	if conn.Stats(cmdGET1GET) != 1 {
-		t.Errorf("Error TestTopSecretSplitHandler(), redis command GET not used.")
+	if conn.Stats(cmdHGETALL) != 1 {
+		t.Errorf("Error TestTopSecretSplitHandler(), redis command HGETALL not used.")
 	}
 
-	rslScanPOST2SCAN1 := make([]interface{}, 2)
-	rslScanPOST2SCAN1[0] = "36"
-	rslScanPOST2SCAN1[1] = []interface{}{}
-	cmdPOST2SCAN1 := conn.Command("SCAN", "0", "MATCH", "*:* es * * secreto").Expect(rslScanPOST2SCAN1)
-
-	rslScanPOST2SCAN2 := make([]interface{}, 2)
-	rslScanPOST2SCAN2[0] = "0"
-	rslScanPOST2SCAN2[1] = []interface{}{wantKey}
-	cmdPOST2SCAN2 := conn.Command("SCAN", "36", "MATCH", "*:* es * * secreto").Expect(rslScanPOST2SCAN2)
+	cmdPOST2SINTER := conn.Command("SINTER", "datasets:word:1:es", "datasets:word:4:secreto").Expect([]interface{}{[]byte(operation)})
+	cmdHGET := conn.Command("HGET", hashKey, store.DATASET_FIELD_KEY).Expect([]byte(wantKey))
 
-	cmdPOST2GET := conn.Command("GET", wantKey).Expect(wantMsl)
 	dtValuePOST2 := model.SatelliteInfoRequest{Name: "skywalker", Distance: 424.26, Message: []string{"", "es", "", "", "secreto"}}
 	wantkeyPOST2 := operation + ":este es  mensaje secreto"
 	want.Key = wantkeyPOST2
 	want.Satellites = append(want.Satellites, dtValuePOST2)
-	wantMsl, _ = json.Marshal(want)
-	cmdPOST2SET := conn.Command("SET", wantkeyPOST2, wantMsl, "NX").Expect("OK")
-
-	cmdPOST2DEL := conn.Command("DEL", wantKey).Expect(1)
+	want.Version++
+	cmdPOST2SET := test.MockDatasetWrite(conn, want)
+	conn.Command("EXEC").Expect([]interface{}{int64(1), int64(0), int64(1)})
 
 	tPOST.name = baseTestName + "-POST2"
 	tPOST.args.rqFilename = "../_test/topSecretSplit_test1-POST2_request.json"
@@ -265,23 +1006,20 @@ func TestTopSecretSplitHandler(t *testing.T) {
 		t.Fatalf("Error in test %s. Operation token is empty.", tPOST.name)
 	}
 
-	if conn.Stats(cmdPOST2SCAN1) != 1 && conn.Stats(cmdPOST2SCAN2) != 1 {
-		t.Errorf("Error TestTopSecretSplitHandler(), redis command SCAN not used.")
+	if conn.Stats(cmdPOST2SINTER) != 1 || conn.Stats(cmdHGET) != 1 {
+		t.Errorf("Error TestTopSecretSplitHandler(), redis commands SINTER and HGET not used.")
 	}
 
-	if conn.Stats(cmdPOST2GET) != 3 {
-		t.Errorf("Error TestTopSecretSplitHandler(), redis command GET not used.")
+	// the dataset is read to find it and to update it
+	if conn.Stats(cmdHGETALL) != 3 {
+		t.Errorf("Error TestTopSecretSplitHandler(), redis command HGETALL not used.")
 	}
 
 	if conn.Stats(cmdPOST2SET) != 1 {
-		t.Errorf("Error TestTopSecretSplitHandler(), redis command SET not used.")
+		t.Errorf("Error TestTopSecretSplitHandler(), redis command HSET not used.")
 	}
 
-	if conn.Stats(cmdPOST2DEL) != 1 {
-		t.Errorf("Error TestTopSecretSplitHandler(), redis command DEL not used.")
-	}
-
-	//cmdGET2GET := conn.Command("GET", operation).Expect("")
+	cmdHGETALL = conn.Command("HGETALL", hashKey).Expect(test.DatasetHashReply(want))
 
 	tGET.name = baseTestName + "-GET2"
 	tGET.args.routerPath = basepathGET + ":operation"
@@ -291,29 +1029,21 @@ func TestTopSecretSplitHandler(t *testing.T) {
 	wantG2 := model.ErrorResponse{}
 	runAsIt(tGET, &gotG2, &wantG2, t)
 
-	if conn.Stats(cmdGET1GET) != 2 {
-		t.Errorf("Error TestTopSecretSplitHandler(), redis command GET not used.")
+	if conn.Stats(cmdHGETALL) != 4 {
+		t.Errorf("Error TestTopSecretSplitHandler(), redis command HGETALL not used.")
 	}
 
-	rslScanPOST3SCAN1 := make([]interface{}, 2)
-	rslScanPOST3SCAN1[0] = "0"
-	rslScanPOST3SCAN1[1] = []interface{}{}
-	cmdPOST3SCAN1 := conn.Command("SCAN", "0", "MATCH", "*:este * un * *").Expect(rslScanPOST3SCAN1)
-
-	rslScanPOST3SCAN2 := make([]interface{}, 2)
-	rslScanPOST3SCAN2[0] = "0"
-	rslScanPOST3SCAN2[1] = []interface{}{wantkeyPOST2}
-	cmdPOST3SCAN2 := conn.Command("SCAN", "0", "MATCH", "*:este * un * *").Expect(rslScanPOST3SCAN2)
+	cmdPOST3SINTER := conn.Command("SINTER", "datasets:word:0:este", "datasets:word:2:un").Expect([]interface{}{[]byte(operation)})
+	cmdHGET = conn.Command("HGET", hashKey, store.DATASET_FIELD_KEY).Expect([]byte(wantkeyPOST2))
 
-	cmdPOST3GET := conn.Command("GET", wantkeyPOST2).Expect(wantMsl)
 	dtValuePOST3 := model.SatelliteInfoRequest{Name: "sato", Distance: 707.10, Message: []string{"este", "", "un", "", ""}}
 	wantkeyPOST3 := operation
 	want.Key = wantkeyPOST3
 	want.Satellites = append(want.Satellites, dtValuePOST3)
-	wantMsl, _ = json.Marshal(want)
-	cmdPOST3SET := conn.Command("SET", wantkeyPOST3, wantMsl, "NX").Expect("OK")
-
-	cmdPOST3DEL := conn.Command("DEL", wantkeyPOST2).Expect(1)
+	want.Version++
+	cmdPOST3SET := test.MockDatasetWrite(conn, want)
+	cmdPOST3SADD := conn.GenericCommand("SADD")
+	saddBefore := conn.Stats(cmdPOST3SADD)
 
 	tPOST.name = baseTestName + "-POST3"
 	tPOST.args.rqFilename = "../_test/topSecretSplit_test1-POST3_request.json"
@@ -324,23 +1054,29 @@ func TestTopSecretSplitHandler(t *testing.T) {
 		t.Fatalf("Error in test %s. Operation token is empty.", tPOST.name)
 	}
 
-	if conn.Stats(cmdPOST3SCAN1) != 1 && conn.Stats(cmdPOST3SCAN2) != 1 {
-		t.Errorf("Error TestTopSecretSplitHandler(), redis command SCAN not used.")
+	if conn.Stats(cmdPOST3SINTER) != 1 || conn.Stats(cmdHGET) != 2 {
+		t.Errorf("Error TestTopSecretSplitHandler(), redis commands SINTER and HGET not used.")
+	}
+
+	// the dataset was found by message
+	if gotP3.MatchScore == 0 {
+		t.Errorf("Error TestTopSecretSplitHandler(), match score not present.")
 	}
 
-	if conn.Stats(cmdPOST3GET) != 3 {
-		t.Errorf("Error TestTopSecretSplitHandler(), redis command GET not used.")
+	if conn.Stats(cmdHGETALL) != 6 {
+		t.Errorf("Error TestTopSecretSplitHandler(), redis command HGETALL not used.")
 	}
 
 	if conn.Stats(cmdPOST3SET) != 1 {
-		t.Errorf("Error TestTopSecretSplitHandler(), redis command SET not used.")
+		t.Errorf("Error TestTopSecretSplitHandler(), redis command HSET not used.")
 	}
 
-	if conn.Stats(cmdPOST3DEL) != 1 {
-		t.Errorf("Error TestTopSecretSplitHandler(), redis command DEL not used.")
+	// the complete dataset is removed from the index
+	if conn.Stats(cmdPOST3SADD) != saddBefore {
+		t.Errorf("Error TestTopSecretSplitHandler(), the complete dataset is indexed.")
 	}
 
-	cmdGET1GET = conn.Command("GET", wantkeyPOST3).Expect(wantMsl)
+	cmdHGETALL = conn.Command("HGETALL", hashKey).Expect(test.DatasetHashReply(want))
 
 	tGET.name = baseTestName + "-GET3"
 	tGET.args.routerPath = basepathGET + ":operation"
@@ -354,8 +1090,8 @@ func TestTopSecretSplitHandler(t *testing.T) {
 	wantG3 := model.TopSecretResponse{}
 	runAsIt(tGET, &gotG3, &wantG3, t)
 
-	if conn.Stats(cmdGET1GET) != 3 {
-		t.Errorf("Error TestTopSecretSplitHandler(), redis command GET not used.")
+	if conn.Stats(cmdHGETALL) != 7 {
+		t.Errorf("Error TestTopSecretSplitHandler(), redis command HGETALL not used.")
 	}
 }
 
@@ -18,8 +18,12 @@ func InitializeServer() {
 	router.SetTrustedProxies(nil)
 	router.GET("/ping", PingHandler)
 	router.POST("/topsecret/", TopSecretHandler)
+	router.POST("/topsecret_tdoa/", TopSecretTDOAHandler)
 	router.POST("/topsecret_split/:operation", TopSecretSplitPOSTHandler)
 	router.GET("/topsecret_split/:operation", TopSecretSplitGETHandler)
+	router.POST("/tracks/:track", TrackPOSTHandler)
+	router.GET("/tracks/:track", TrackGETHandler)
+	router.DELETE("/tracks/:track", TrackDELETEHandler)
 
 	// swagger index
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerfiles.Handler))
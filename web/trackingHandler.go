@@ -0,0 +1,202 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mgironi/operation-fire-quasar/location"
+	"github.com/mgironi/operation-fire-quasar/model"
+	"github.com/mgironi/operation-fire-quasar/tracking"
+)
+
+// @BasePath /
+// @Summary Agrega fixes de posicion al seguimiento de un emisor.
+// @Description Agrega una serie temporal de fixes (posiciones o distancias a los satelites) al seguimiento del emisor, filtrados con un filtro de Kalman de velocidad constante en el plano (la altura Z de los fixes se ignora). Responde la posicion y velocidad estimadas. Los fixes se aplican todos o ninguno, si alguno es invalido o el instante de prediccion es invalido o anterior al ultimo fix el seguimiento no se modifica. Los seguimientos sin fixes durante OFQ_TRACKING_TTL segundos se eliminan.
+// @Param track path string true "Identificador del seguimiento (emisor)"
+// @Param Body body model.TrackRequest true "Los fixes ordenados por tiempo, cada uno con la posicion o las distancias a los satelites"
+// @Param at query number false "Instante (segundos) para el cual se predice la posicion"
+// @Accept json
+// @Produce json
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Failure 401 {object} model.ErrorResponse
+// @Failure 409 {object} model.ErrorResponse
+// @Failure 500 {object} model.ErrorResponse
+// @Success 200 {object} model.TrackResponse
+// @Router /tracks/{track} [POST]
+func TrackPOSTHandler(c *gin.Context) {
+	var requestData model.TrackRequest
+
+	// parse json to struct
+	err := c.ShouldBindJSON(&requestData)
+	if err != nil || len(requestData.Fixes) == 0 {
+		log.Printf("Error binding json or without fixes. Trace: %v", err)
+		c.IndentedJSON(http.StatusBadRequest, model.ErrorResponse{Message: "malformed json."})
+		return
+	}
+
+	// validates the prediction timestamp before updating the track, it can't be previous to the last fix
+	at, atErr := GetPredictionTimestamp(c)
+	if atErr == nil && at != nil && *at < requestData.Fixes[len(requestData.Fixes)-1].Timestamp {
+		atErr = fmt.Errorf("prediction timestamp %f is previous to the last fix (%f)", *at, requestData.Fixes[len(requestData.Fixes)-1].Timestamp)
+	}
+	if atErr != nil {
+		log.Printf("TrackPOSTHandler error with prediction timestamp. Trace: %s", atErr.Error())
+		c.IndentedJSON(http.StatusBadRequest, model.ErrorResponse{Message: atErr.Error()})
+		return
+	}
+
+	// verifies the satellites reports signatures and timestamps, as the ones of POST /topsecret/
+	var satellitesData []model.SatelliteInfoRequest
+	for i, fix := range requestData.Fixes {
+		for _, satelliteData := range fix.Satellites {
+			if verifyErr := VerifySatelliteReport(satelliteData); verifyErr != nil {
+				log.Printf("TrackPOSTHandler error with fix %d report verification. Trace: %s", i, verifyErr.Error())
+				c.IndentedJSON(reportRejectionStatus(verifyErr), model.ErrorResponse{Message: verifyErr.Error()})
+				return
+			}
+		}
+		satellitesData = append(satellitesData, fix.Satellites...)
+	}
+
+	// solves the fixes positions before updating the track, to keep it unmodified with invalid fixes
+	fixes := make([]tracking.Fix, len(requestData.Fixes))
+	for i, fix := range requestData.Fixes {
+		position, fixErr := GetFixPosition(fix)
+		if errors.Is(fixErr, ErrUnknownSatellite) {
+			log.Printf("TrackPOSTHandler error with fix %d satellites. Trace: %s", i, fixErr.Error())
+			c.IndentedJSON(http.StatusBadRequest, model.ErrorResponse{Message: fixErr.Error()})
+			return
+		}
+		if fixErr != nil {
+			log.Printf("TrackPOSTHandler error with fix %d position. Trace: %s", i, fixErr.Error())
+			c.IndentedJSON(http.StatusNotFound, model.ErrorResponse{Message: fmt.Sprintf("Can't calculate fix %d location. Please check distances.", i)})
+			return
+		}
+		fixes[i] = tracking.Fix{Timestamp: fix.Timestamp, Position: position}
+	}
+
+	// rejects the replayed reports, the nonces of a failed request aren't used up
+	if nonceErr := RegisterSatellitesReportsNonces(satellitesData); nonceErr != nil {
+		log.Printf("TrackPOSTHandler error with report nonce. Trace: %s", nonceErr.Error())
+		c.IndentedJSON(reportRejectionStatus(nonceErr), model.ErrorResponse{Message: nonceErr.Error()})
+		return
+	}
+	defer func() {
+		if c.Writer.Status() != http.StatusOK {
+			ReleaseSatellitesReportsNonces(satellitesData)
+		}
+	}()
+
+	// the fixes are applied all or none, an unordered batch keeps the track unmodified
+	state, fixesErr := tracking.AddFixes(c.Param("track"), fixes)
+	if fixesErr != nil {
+		log.Printf("TrackPOSTHandler error adding fixes. Trace: %s", fixesErr.Error())
+		c.IndentedJSON(http.StatusBadRequest, model.ErrorResponse{Message: fixesErr.Error()})
+		return
+	}
+
+	DoTrackResponse(state, at, c)
+}
+
+// @BasePath /
+// @Summary Obtiene el estado del seguimiento de un emisor.
+// @Description Obtiene la posicion y velocidad estimadas (en el plano) del emisor al ultimo fix, y opcionalmente la posicion predicha para un instante posterior. Responde 404 si el seguimiento no existe o fue eliminado por inactividad.
+// @Param track path string true "Identificador del seguimiento (emisor)"
+// @Param at query number false "Instante (segundos) para el cual se predice la posicion"
+// @Produce json
+// @Failure 404 {object} model.ErrorResponse
+// @Failure 400 {object} model.ErrorResponse
+// @Success 200 {object} model.TrackResponse
+// @Router /tracks/{track} [GET]
+func TrackGETHandler(c *gin.Context) {
+	state, found := tracking.GetTrackState(c.Param("track"))
+	if !found {
+		c.IndentedJSON(http.StatusNotFound, model.ErrorResponse{Message: "track not found."})
+		return
+	}
+	at, atErr := GetPredictionTimestamp(c)
+	if atErr != nil {
+		c.IndentedJSON(http.StatusBadRequest, model.ErrorResponse{Message: atErr.Error()})
+		return
+	}
+	DoTrackResponse(state, at, c)
+}
+
+// @BasePath /
+// @Summary Elimina el seguimiento de un emisor.
+// @Param track path string true "Identificador del seguimiento (emisor)"
+// @Produce json
+// @Failure 404 {object} model.ErrorResponse
+// @Success 204
+// @Router /tracks/{track} [DELETE]
+func TrackDELETEHandler(c *gin.Context) {
+	if !tracking.DeleteTrack(c.Param("track")) {
+		c.IndentedJSON(http.StatusNotFound, model.ErrorResponse{Message: "track not found."})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// Gets the timestamp (seconds) of the 'at' query param, for which the track position is predicted.
+// output: the timestamp, nil if the param isn't present.
+// error: if the param isn't a number.
+func GetPredictionTimestamp(c *gin.Context) (at *float64, err error) {
+	atStr := c.Query("at")
+	if atStr == "" {
+		return nil, nil
+	}
+	value, parseErr := strconv.ParseFloat(atStr, 64)
+	if parseErr != nil {
+		return nil, fmt.Errorf("invalid prediction timestamp '%s'", atStr)
+	}
+	return &value, nil
+}
+
+// Responses the track state, with the predicted position at the given timestamp if it isn't nil.
+func DoTrackResponse(state tracking.TrackState, at *float64, c *gin.Context) {
+	rspData := model.TrackResponse{
+		Track:     state.ID,
+		Timestamp: state.Timestamp,
+		Position:  BuildCoordinatesResponse(state.Position, false),
+		Velocity:  model.VelocityResponse{VX: float32(state.Velocity.X), VY: float32(state.Velocity.Y)},
+		Updates:   state.Updates,
+	}
+
+	if at != nil {
+		predicted, _, predictErr := tracking.PredictTrack(state.ID, *at)
+		if predictErr != nil {
+			c.IndentedJSON(http.StatusBadRequest, model.ErrorResponse{Message: predictErr.Error()})
+			return
+		}
+		rspData.Predicted = &model.PredictedPositionResponse{Timestamp: *at, Position: BuildCoordinatesResponse(predicted, false)}
+	}
+	c.IndentedJSON(http.StatusOK, rspData)
+}
+
+// Gets the position of a track fix, the given one or calculated with the satellites distances, in the plane.
+// The satellites reports must be verified before, see VerifySatelliteReport.
+// error: if the fix hasn't position nor satellites, ErrUnknownSatellite if a satellite name is unknown, or the
+// location can't be calculated.
+func GetFixPosition(fix model.TrackFixRequest) (position model.Point, err error) {
+	if fix.Position != nil {
+		return model.Point{X: float64(fix.Position.X), Y: float64(fix.Position.Y)}, nil
+	}
+	if len(fix.Satellites) == 0 {
+		return position, fmt.Errorf("fix at timestamp %f hasn't position nor satellites distances", fix.Timestamp)
+	}
+
+	distances, _, treatErr := TreatSatellitesData(fix.Satellites)
+	if treatErr != nil {
+		return position, treatErr
+	}
+	x, y, locErr := location.CalculateLocation(distances)
+	if locErr != nil {
+		return position, locErr
+	}
+	return model.Point{X: float64(x), Y: float64(y)}, nil
+}
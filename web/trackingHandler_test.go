@@ -0,0 +1,124 @@
+package web_test
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	test "github.com/mgironi/operation-fire-quasar/_test"
+	"github.com/mgironi/operation-fire-quasar/model"
+	"github.com/mgironi/operation-fire-quasar/store"
+	"github.com/mgironi/operation-fire-quasar/tracking"
+	"github.com/mgironi/operation-fire-quasar/web"
+)
+
+// Tests the tracks handlers, adding fixes by position and by satellites distances, getting and deleting the track
+func TestTrackHandlers(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	store.InitializeSatelitesInfo()
+
+	router := gin.Default()
+	router.POST("/tracks/:track", web.TrackPOSTHandler)
+	router.GET("/tracks/:track", web.TrackGETHandler)
+	router.DELETE("/tracks/:track", web.TrackDELETEHandler)
+	doRequest := func(method string, url string, body string) *httptest.ResponseRecorder {
+		request, _ := http.NewRequest(method, url, strings.NewReader(body))
+		gotRsp := httptest.NewRecorder()
+		router.ServeHTTP(gotRsp, request)
+		return gotRsp
+	}
+
+	// the last fix by distances is located at (-200, 200)
+	gotRsp := doRequest(http.MethodPost, "/tracks/emitter1?at=3", `{"fixes":[
+		{"timestamp":0,"position":{"x":-200,"y":200}},
+		{"timestamp":1,"position":{"x":-200,"y":200}},
+		{"timestamp":2,"satellites":[{"name":"kenobi","distance":500},{"name":"skywalker","distance":424.26},{"name":"sato","distance":707.10}]}]}`)
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusOK, t)
+
+	var got model.TrackResponse
+	unmarshalJSONWithFatal("Got response", gotRsp.Body.Bytes(), &got, t)
+	if got.Track != "emitter1" || got.Updates != 3 || got.Timestamp != 2 {
+		t.Errorf("HTTP response track = %+v, want track emitter1, 3 updates at timestamp 2", got)
+	}
+	if math.Abs(float64(got.Position.X)-(-200)) > 0.1 || math.Abs(float64(got.Position.Y)-200) > 0.1 {
+		t.Errorf("HTTP response position is (%f, %f), want (-200, 200)", got.Position.X, got.Position.Y)
+	}
+	if got.Predicted == nil || got.Predicted.Timestamp != 3 {
+		t.Fatalf("HTTP response predicted = %+v, want prediction at timestamp 3", got.Predicted)
+	}
+
+	// gets track state
+	gotRsp = doRequest(http.MethodGet, "/tracks/emitter1", "")
+	compareValuesWithError("HTTP response status code", gotRsp.Code, http.StatusOK, t)
+	got = model.TrackResponse{}
+	unmarshalJSONWithFatal("Got response", gotRsp.Body.Bytes(), &got, t)
+	if got.Updates != 3 || got.Predicted != nil {
+		t.Errorf("HTTP response track = %+v, want 3 updates without prediction", got)
+	}
+
+	// invalid requests
+	compareValuesWithError("HTTP response status code", doRequest(http.MethodGet, "/tracks/emitter1?at=1", "").Code, http.StatusBadRequest, t)
+	compareValuesWithError("HTTP response status code", doRequest(http.MethodGet, "/tracks/emitter1?at=x", "").Code, http.StatusBadRequest, t)
+	compareValuesWithError("HTTP response status code", doRequest(http.MethodPost, "/tracks/emitter1", `{"fixes":[{"timestamp":1,"position":{"x":0,"y":0}}]}`).Code, http.StatusBadRequest, t)
+	compareValuesWithError("HTTP response status code", doRequest(http.MethodPost, "/tracks/emitter1", `{"fixes":[{"timestamp":5}]}`).Code, http.StatusNotFound, t)
+	compareValuesWithError("HTTP response status code", doRequest(http.MethodPost, "/tracks/emitter1", `{"fixes":[]}`).Code, http.StatusBadRequest, t)
+	compareValuesWithError("HTTP response status code", doRequest(http.MethodPost, "/tracks/emitter1", `{"fixes":[
+		{"timestamp":5,"satellites":[{"name":"kenobi","distance":500},{"name":"skywalker","distance":424.26},{"name":"other","distance":707.10}]}]}`).Code, http.StatusBadRequest, t)
+
+	// the invalid batches or prediction timestamps keep the track unmodified
+	compareValuesWithError("HTTP response status code", doRequest(http.MethodPost, "/tracks/emitter1", `{"fixes":[
+		{"timestamp":3,"position":{"x":0,"y":0}},{"timestamp":2.5,"position":{"x":0,"y":0}}]}`).Code, http.StatusBadRequest, t)
+	compareValuesWithError("HTTP response status code", doRequest(http.MethodPost, "/tracks/emitter1?at=x", `{"fixes":[{"timestamp":3,"position":{"x":0,"y":0}}]}`).Code, http.StatusBadRequest, t)
+	compareValuesWithError("HTTP response status code", doRequest(http.MethodPost, "/tracks/emitter1?at=2", `{"fixes":[{"timestamp":3,"position":{"x":0,"y":0}}]}`).Code, http.StatusBadRequest, t)
+	if state, _ := tracking.GetTrackState("emitter1"); state.Updates != 3 || state.Timestamp != 2 {
+		t.Errorf("Track state after invalid requests = %+v, want 3 updates at timestamp 2", state)
+	}
+
+	// deletes track
+	compareValuesWithError("HTTP response status code", doRequest(http.MethodDelete, "/tracks/emitter1", "").Code, http.StatusNoContent, t)
+	compareValuesWithError("HTTP response status code", doRequest(http.MethodGet, "/tracks/emitter1", "").Code, http.StatusNotFound, t)
+}
+
+// Tests that the satellites reports of the fixes are verified and their nonces registered, as the POST /topsecret/ ones
+func TestTrackHandlerReports(t *testing.T) {
+	test.CleanSatelitesInfoEnvs()
+	store.InitializeSatelitesInfo()
+	store.SetDatasetStore(store.NewMemoryDatasetStore())
+	os.Unsetenv("OFQ_ALLOW_UNSIGNED_REPLAYS")
+	now := time.Unix(1700000000, 0)
+	web.GetCurrentTime = func() time.Time { return now }
+	defer func() {
+		store.SetDatasetStore(nil)
+		os.Setenv("OFQ_ALLOW_UNSIGNED_REPLAYS", "true")
+		os.Unsetenv("OFQ_REQUIRE_SIGNATURES")
+		web.GetCurrentTime = time.Now
+		tracking.DeleteTrack("emitter2")
+	}()
+
+	router := gin.Default()
+	router.POST("/tracks/:track", web.TrackPOSTHandler)
+	doRequest := func(body string) int {
+		request, _ := http.NewRequest(http.MethodPost, "/tracks/emitter2", strings.NewReader(body))
+		gotRsp := httptest.NewRecorder()
+		router.ServeHTTP(gotRsp, request)
+		return gotRsp.Code
+	}
+	withoutNonces := `{"fixes":[{"timestamp":0,"satellites":[{"name":"kenobi","distance":500},{"name":"skywalker","distance":424.26},{"name":"sato","distance":707.10}]}]}`
+	withNonces := `{"fixes":[{"timestamp":0,"satellites":[
+		{"name":"kenobi","distance":500,"timestamp":1700000000,"nonce":"t-kenobi"},
+		{"name":"skywalker","distance":424.26,"timestamp":1700000000,"nonce":"t-skywalker"},
+		{"name":"sato","distance":707.10,"timestamp":1700000000,"nonce":"t-sato"}]}]}`
+
+	compareValuesWithError("HTTP response status code without nonces", doRequest(withoutNonces), http.StatusUnauthorized, t)
+	compareValuesWithError("HTTP response status code", doRequest(withNonces), http.StatusOK, t)
+	compareValuesWithError("HTTP response status code replayed", doRequest(withNonces), http.StatusConflict, t)
+
+	// the reports of the satellites without verification key are rejected if the signatures are required
+	os.Setenv("OFQ_REQUIRE_SIGNATURES", "true")
+	compareValuesWithError("HTTP response status code signatures required", doRequest(strings.ReplaceAll(withNonces, `"t-`, `"t2-`)), http.StatusUnauthorized, t)
+}